@@ -0,0 +1,216 @@
+// Package agents loads subagent definitions and runs them. A subagent is a
+// Markdown file whose frontmatter names it and picks its model and tools and
+// whose body is its system prompt; the task tool hands it a job, it works
+// through its own agent loop, and only its final report comes back.
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/frontmatter"
+	"github.com/fbettag/pfui/internal/tools"
+)
+
+// Scope records where a definition came from.
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"
+	ScopeProject Scope = "project"
+)
+
+// DefaultMaxTurns bounds a subagent's tool rounds when its definition does
+// not say otherwise.
+const DefaultMaxTurns = 20
+
+// Definition is one subagent.
+type Definition struct {
+	Name        string
+	Description string
+	// Model is a routing selector (model, provider/model, or tag:key=value);
+	// empty runs the subagent on the caller's model.
+	Model string
+	// Tools lists the tools the subagent may call; empty allows every tool
+	// a subagent can have.
+	Tools    []string
+	Prompt   string
+	MaxTurns int
+	Path     string
+	Scope    Scope
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// UserDir returns ~/.pfui/agents, honoring PFUI_HOME.
+func UserDir() (string, error) {
+	if custom := os.Getenv("PFUI_HOME"); custom != "" {
+		return filepath.Join(custom, "agents"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".pfui", "agents"), nil
+}
+
+// ProjectDir returns the project's .pfui/agents directory.
+func ProjectDir(projectRoot string) string {
+	return filepath.Join(projectRoot, ".pfui", "agents")
+}
+
+// Load reads the user definitions and then the project ones; a project
+// definition replaces a user definition of the same name. Files that fail
+// to parse are reported in the error list and skipped.
+func Load(projectRoot string) ([]Definition, []error) {
+	byName := make(map[string]Definition)
+	var errs []error
+	if dir, err := UserDir(); err != nil {
+		errs = append(errs, err)
+	} else {
+		errs = append(errs, loadDir(dir, ScopeUser, byName)...)
+	}
+	if projectRoot != "" {
+		errs = append(errs, loadDir(ProjectDir(projectRoot), ScopeProject, byName)...)
+	}
+	defs := make([]Definition, 0, len(byName))
+	for _, def := range byName {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, errs
+}
+
+func loadDir(dir string, scope Scope, into map[string]Definition) []error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return []error{fmt.Errorf("listing %s: %w", dir, err)}
+	}
+	sort.Strings(paths)
+	var errs []error
+	for _, path := range paths {
+		def, err := LoadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		def.Scope = scope
+		into[def.Name] = def
+	}
+	return errs
+}
+
+// LoadFile parses one definition. The name defaults to the file name and
+// the system prompt to the Markdown body.
+func LoadFile(path string) (Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Definition{}, fmt.Errorf("reading subagent: %w", err)
+	}
+	doc, err := frontmatter.Parse(data)
+	if err != nil {
+		return Definition{}, fmt.Errorf("%s: %w", path, err)
+	}
+	def := Definition{
+		Name:        strings.ToLower(strings.TrimSpace(doc.Get("name"))),
+		Description: strings.TrimSpace(doc.Get("description")),
+		Model:       strings.TrimSpace(doc.Get("model")),
+		Prompt:      strings.TrimSpace(firstField(doc, "system_prompt", "system-prompt", "prompt")),
+		MaxTurns:    DefaultMaxTurns,
+		Path:        path,
+	}
+	if def.Name == "" {
+		def.Name = strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".md"))
+	}
+	if !validName.MatchString(def.Name) {
+		return Definition{}, fmt.Errorf("%s: name %q must be lowercase letters, digits, - or _", path, def.Name)
+	}
+	if def.Description == "" {
+		return Definition{}, fmt.Errorf("%s: description is required so the model knows when to use %s", path, def.Name)
+	}
+	if def.Prompt == "" {
+		def.Prompt = strings.TrimSpace(doc.Body)
+	}
+	if def.Model == "inherit" {
+		def.Model = ""
+	}
+	if raw := strings.TrimSpace(firstField(doc, "max_turns", "max-turns")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return Definition{}, fmt.Errorf("%s: max_turns must be a positive number", path)
+		}
+		def.MaxTurns = n
+	}
+	for _, key := range []string{"tools", "allowed_tools", "allowed-tools"} {
+		if list := doc.List(key); list != nil {
+			def.Tools = list
+			break
+		}
+	}
+	for _, name := range def.Tools {
+		if err := checkTool(name); err != nil {
+			return Definition{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return def, nil
+}
+
+func firstField(doc frontmatter.Document, keys ...string) string {
+	for _, key := range keys {
+		if value := doc.Get(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// excluded tools never reach a subagent: it cannot start subagents of its
+// own, interrupt the operator, or rewrite the caller's plan.
+var excluded = map[string]bool{
+	tools.TaskName:       true,
+	tools.AskUserName:    true,
+	tools.UpdatePlanName: true,
+}
+
+func checkTool(name string) error {
+	if excluded[name] {
+		return fmt.Errorf("subagents cannot use %s", name)
+	}
+	for _, spec := range tools.Specs() {
+		if spec.Name == name {
+			return nil
+		}
+	}
+	return errors.New("unknown tool " + strconv.Quote(name))
+}
+
+// Allows reports whether the subagent may call the named tool.
+func (d Definition) Allows(name string) bool {
+	if excluded[name] {
+		return false
+	}
+	if len(d.Tools) == 0 {
+		return true
+	}
+	for _, allowed := range d.Tools {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Info returns what the task tool tells the model about each definition.
+func Info(defs []Definition) []tools.AgentInfo {
+	out := make([]tools.AgentInfo, 0, len(defs))
+	for _, def := range defs {
+		out = append(out, tools.AgentInfo{Name: def.Name, Description: def.Description})
+	}
+	return out
+}
@@ -0,0 +1,200 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/modelcatalog"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/tools"
+	"github.com/fbettag/pfui/internal/workspace"
+)
+
+func TestLoadMergesUserAndProjectDefinitions(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("PFUI_HOME", home)
+	for path, content := range map[string]string{
+		filepath.Join(home, "agents", "reviewer.md"):             "---\ndescription: user reviewer\n---\nReview carefully.\n",
+		filepath.Join(home, "agents", "explorer.md"):             "---\nname: explorer\ndescription: Finds code\nmodel: Claude/claude-haiku\ntools: [read_file, grep, glob]\nmax_turns: 5\nsystem_prompt: |\n  Search, do not edit.\n  Cite paths.\n---\nignored body\n",
+		filepath.Join(project, ".pfui", "agents", "reviewer.md"): "---\ndescription: project reviewer\nallowed-tools:\n  - read_file\n  - exec\n---\nProject rules.\n",
+		filepath.Join(project, ".pfui", "agents", "broken.md"):   "---\ndescription: spawns more\ntools: task\n---\n",
+		filepath.Join(project, ".pfui", "agents", "quiet.md"):    "No header, so no description.\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defs, errs := Load(project)
+	if len(errs) != 2 {
+		t.Fatalf("expected two load errors, got %v", errs)
+	}
+	if len(defs) != 2 || defs[0].Name != "explorer" || defs[1].Name != "reviewer" {
+		t.Fatalf("unexpected definitions %+v", defs)
+	}
+	explorer, reviewer := defs[0], defs[1]
+	if explorer.Scope != ScopeUser || explorer.Model != "Claude/claude-haiku" || explorer.MaxTurns != 5 || explorer.Prompt != "Search, do not edit.\nCite paths." {
+		t.Fatalf("unexpected explorer %+v", explorer)
+	}
+	if !explorer.Allows(tools.GrepName) || explorer.Allows(tools.ExecName) {
+		t.Fatalf("explorer tools %v", explorer.Tools)
+	}
+	if reviewer.Scope != ScopeProject || reviewer.Description != "project reviewer" || reviewer.Prompt != "Project rules." || reviewer.MaxTurns != DefaultMaxTurns {
+		t.Fatalf("project definition should win: %+v", reviewer)
+	}
+	if (Definition{}).Allows(tools.TaskName) || (Definition{}).Allows(tools.AskUserName) {
+		t.Fatal("subagents must never get task or ask_user")
+	}
+}
+
+func TestResolvePicksProviderAndModel(t *testing.T) {
+	active, other := &scripted{name: "OpenAI"}, &scripted{name: "Claude"}
+	target := Target{
+		Provider:  active,
+		Model:     "gpt-5",
+		Providers: []provider.Provider{active, other},
+		Catalog:   []modelcatalog.Model{{Name: "claude-haiku", Provider: "Claude", Tags: map[string]string{"speed": "fast"}}},
+	}
+	cases := []struct {
+		selector string
+		provider provider.Provider
+		model    string
+	}{
+		{"", active, "gpt-5"},
+		{"gpt-5-mini", active, "gpt-5-mini"},
+		{"claude/claude-sonnet", other, "claude-sonnet"},
+		{"tag:speed=fast", other, "claude-haiku"},
+	}
+	for _, tc := range cases {
+		p, model, err := Resolve(tc.selector, target)
+		if err != nil || p != tc.provider || model != tc.model {
+			t.Fatalf("Resolve(%q) = %v, %q, %v", tc.selector, p, model, err)
+		}
+	}
+	if _, _, err := Resolve("tag:speed=slow", target); err == nil {
+		t.Fatal("expected an error for an unmatched tag")
+	}
+}
+
+func TestDelegatorRunsAnIsolatedLoop(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("PFUI_HOME", home)
+	for path, content := range map[string]string{
+		filepath.Join(project, "notes.txt"):                    "the answer is 42\n",
+		filepath.Join(project, ".pfui", "agents", "reader.md"): "---\ndescription: Reads files\ntools: read_file\nmax_turns: 2\n---\nYou read files.\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &scripted{name: "Test", replies: []provider.StreamChunk{
+		{ToolCalls: []provider.ToolCall{
+			{ID: "1", Name: tools.ReadFileName, Arguments: `{"path":"notes.txt"}`},
+			{ID: "2", Name: tools.ExecName, Arguments: `{"command":"rm"}`},
+		}},
+		{Content: "notes.txt says the answer is 42."},
+	}}
+	m := NewManager(project)
+	delegate := m.Delegator(func(selector string) (provider.Provider, string, error) { return p, "test-model", nil })
+	parent := tools.Runner{ProjectRoot: project, Workspace: workspace.New(project, nil)}
+	report, err := delegate(context.Background(), parent, tools.TaskRequest{Agent: "reader", Prompt: "What does notes.txt say?"})
+	if err != nil {
+		t.Fatalf("delegate: %v", err)
+	}
+	if report.Report != "notes.txt says the answer is 42." || report.Rounds != 1 || report.ToolCalls != 2 || report.RunID != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	first, second := p.requests[0], p.requests[1]
+	if len(first.Tools) != 1 || first.Tools[0].Name != tools.ReadFileName {
+		t.Fatalf("subagent should only see its tools, got %+v", first.Tools)
+	}
+	if !strings.HasPrefix(first.Messages[0].Content, "You read files.") || first.Messages[1].Content != "What does notes.txt say?" {
+		t.Fatalf("unexpected opening messages %+v", first.Messages)
+	}
+	results := second.Messages[len(second.Messages)-2:]
+	if !strings.Contains(results[0].Content, "the answer is 42") {
+		t.Fatalf("read_file result missing: %+v", results[0])
+	}
+	var denied tools.Error
+	if err := json.Unmarshal([]byte(results[1].Content), &denied); err != nil || denied.Error != "tool_not_allowed" {
+		t.Fatalf("exec should be refused, got %q", results[1].Content)
+	}
+	runs := m.Runs()
+	if len(runs) != 1 || runs[0].Status != StatusDone || runs[0].Report != report.Report {
+		t.Fatalf("unexpected runs %+v", runs)
+	}
+
+	_, err = delegate(context.Background(), parent, tools.TaskRequest{Agent: "writer", Prompt: "x"})
+	var unknown *tools.UnknownAgentError
+	if !errors.As(err, &unknown) || len(m.Runs()) != 1 {
+		t.Fatalf("expected an unknown agent error, got %v", err)
+	}
+}
+
+func TestDelegatorAsksForAReportAtTheTurnLimit(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("PFUI_HOME", home)
+	for path, content := range map[string]string{
+		filepath.Join(project, ".pfui", "agents", "looper.md"): "---\ndescription: Never stops\nmax_turns: 1\n---\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	call := provider.StreamChunk{ToolCalls: []provider.ToolCall{{ID: "1", Name: tools.GlobName, Arguments: `{"pattern":"*"}`}}}
+	p := &scripted{name: "Test", replies: []provider.StreamChunk{call, {Content: "Stopped early: nothing found."}}}
+	delegate := NewManager(project).Delegator(func(string) (provider.Provider, string, error) { return p, "", nil })
+	report, err := delegate(context.Background(), tools.Runner{ProjectRoot: project, Workspace: workspace.New(project, nil)}, tools.TaskRequest{Agent: "looper", Prompt: "loop"})
+	if err != nil || report.Report != "Stopped early: nothing found." {
+		t.Fatalf("report %+v, err %v", report, err)
+	}
+	last := p.requests[1]
+	if len(last.Tools) != 0 || !strings.Contains(last.Messages[len(last.Messages)-1].Content, "final report") {
+		t.Fatalf("the last request should drop tools and ask for the report: %+v", last)
+	}
+}
+
+// scripted replays one chunk per StreamChat call and records the requests.
+type scripted struct {
+	name     string
+	replies  []provider.StreamChunk
+	requests []provider.ChatCompletionRequest
+}
+
+func (s *scripted) Name() string        { return s.name }
+func (s *scripted) Kind() provider.Kind { return provider.KindCustom }
+func (s *scripted) ListModels(context.Context) ([]provider.Model, error) {
+	return nil, nil
+}
+func (s *scripted) StartChat(context.Context, provider.StartChatOptions) (provider.Session, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *scripted) StreamChat(ctx context.Context, req provider.ChatCompletionRequest) (<-chan provider.StreamChunk, error) {
+	s.requests = append(s.requests, req)
+	if len(s.replies) == 0 {
+		return nil, errors.New("no more replies")
+	}
+	chunk := s.replies[0]
+	s.replies = s.replies[1:]
+	chunk.Done = true
+	ch := make(chan provider.StreamChunk, 1)
+	ch <- chunk
+	close(ch)
+	return ch, nil
+}
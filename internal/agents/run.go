@@ -0,0 +1,318 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fbettag/pfui/internal/modelcatalog"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/routing"
+	"github.com/fbettag/pfui/internal/tools"
+)
+
+// Status is where a subagent run stands.
+type Status string
+
+const (
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// Run records one task handed to a subagent.
+type Run struct {
+	ID        int
+	Agent     string
+	Task      string
+	Model     string
+	Status    Status
+	Rounds    int
+	ToolCalls int
+	StartedAt time.Time
+	EndedAt   time.Time
+	Report    string
+	Error     string
+}
+
+// Target is what a definition's model selector resolves against: the
+// caller's provider and model, the other providers, and their models.
+type Target struct {
+	Provider  provider.Provider
+	Model     string
+	Providers []provider.Provider
+	Catalog   []modelcatalog.Model
+}
+
+// Resolve picks the provider and model for selector. An empty selector
+// keeps the caller's; "provider/model" names a provider, a plain name runs
+// on the caller's provider, and tag selectors need the catalog.
+func Resolve(selector string, t Target) (provider.Provider, string, error) {
+	if strings.TrimSpace(selector) == "" {
+		if t.Provider == nil {
+			return nil, "", errors.New("no active provider")
+		}
+		return t.Provider, t.Model, nil
+	}
+	sel, err := routing.ParseSelector(selector)
+	if err != nil {
+		return nil, "", err
+	}
+	if sel.TagKey != "" {
+		var match *modelcatalog.Model
+		for i := range t.Catalog {
+			if !sel.Matches(t.Catalog[i]) {
+				continue
+			}
+			if t.Provider != nil && strings.EqualFold(t.Catalog[i].Provider, t.Provider.Name()) {
+				match = &t.Catalog[i]
+				break
+			}
+			if match == nil {
+				match = &t.Catalog[i]
+			}
+		}
+		if match == nil {
+			return nil, "", fmt.Errorf("no model matches %q", selector)
+		}
+		p := findProvider(t.Providers, match.Provider)
+		if p == nil {
+			return nil, "", fmt.Errorf("provider %s is not available", match.Provider)
+		}
+		return p, match.Name, nil
+	}
+	if name, model, ok := strings.Cut(sel.Name, "/"); ok {
+		if p := findProvider(t.Providers, name); p != nil {
+			return p, model, nil
+		}
+	}
+	if t.Provider == nil {
+		return nil, "", errors.New("no active provider")
+	}
+	return t.Provider, sel.Name, nil
+}
+
+func findProvider(providers []provider.Provider, name string) provider.Provider {
+	for _, p := range providers {
+		if strings.EqualFold(p.Name(), name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Manager holds the loaded definitions and the session's runs. It is safe
+// for concurrent use: runs happen on tool goroutines while the TUI lists them.
+type Manager struct {
+	mu   sync.Mutex
+	defs []Definition
+	errs []error
+	runs []Run
+}
+
+// NewManager loads the definitions for projectRoot.
+func NewManager(projectRoot string) *Manager {
+	m := &Manager{}
+	m.Reload(projectRoot)
+	return m
+}
+
+// Reload rereads the definitions; runs are kept.
+func (m *Manager) Reload(projectRoot string) {
+	defs, errs := Load(projectRoot)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defs, m.errs = defs, errs
+}
+
+// Definitions returns the loaded subagents by name.
+func (m *Manager) Definitions() []Definition {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Definition(nil), m.defs...)
+}
+
+// Errors returns the problems the last load ran into.
+func (m *Manager) Errors() []error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]error(nil), m.errs...)
+}
+
+// Runs returns the session's runs, oldest first.
+func (m *Manager) Runs() []Run {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Run(nil), m.runs...)
+}
+
+// Run returns the run with id.
+func (m *Manager) Run(id int) (Run, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.runs) {
+		return Run{}, false
+	}
+	return m.runs[id-1], true
+}
+
+func (m *Manager) lookup(name string) (Definition, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var known []string
+	for _, def := range m.defs {
+		if def.Name == name {
+			return def, nil
+		}
+		known = append(known, def.Name)
+	}
+	return Definition{}, &tools.UnknownAgentError{Name: name, Known: known}
+}
+
+func (m *Manager) start(def Definition, task, model string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs = append(m.runs, Run{ID: len(m.runs) + 1, Agent: def.Name, Task: task, Model: model, Status: StatusRunning, StartedAt: time.Now()})
+	return len(m.runs)
+}
+
+func (m *Manager) update(id int, fn func(*Run)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(&m.runs[id-1])
+}
+
+// Delegator returns the tools.Delegator that runs tasks on these subagents.
+// resolve turns a definition's model selector into a provider and model.
+func (m *Manager) Delegator(resolve func(selector string) (provider.Provider, string, error)) tools.Delegator {
+	return func(ctx context.Context, parent tools.Runner, req tools.TaskRequest) (tools.TaskReport, error) {
+		def, err := m.lookup(req.Agent)
+		if err != nil {
+			return tools.TaskReport{}, err
+		}
+		p, model, err := resolve(def.Model)
+		if err != nil {
+			return tools.TaskReport{}, fmt.Errorf("subagent %s model: %w", def.Name, err)
+		}
+		id := m.start(def, req.Prompt, model)
+		report := tools.TaskReport{RunID: id, Agent: def.Name}
+		err = m.loop(ctx, def, parent, p, model, req.Prompt, &report)
+		m.update(id, func(r *Run) {
+			r.EndedAt = time.Now()
+			r.Report = report.Report
+			r.Status = StatusDone
+			if err != nil {
+				r.Status, r.Error = StatusFailed, err.Error()
+			}
+		})
+		return report, err
+	}
+}
+
+// loop is the subagent's own agent loop: it shares the caller's executor,
+// workspace and checkpoints but not its conversation, plan or operator.
+func (m *Manager) loop(ctx context.Context, def Definition, parent tools.Runner, p provider.Provider, model, task string, report *tools.TaskReport) error {
+	runner := parent
+	runner.Plan, runner.Ask, runner.Delegate = nil, nil, nil
+	specs := toolSpecs(def)
+	conversation := []provider.ChatMessage{
+		{Role: "system", Content: systemPrompt(def, parent.ProjectRoot)},
+		{Role: "user", Content: task},
+	}
+	for {
+		final := report.Rounds >= def.MaxTurns
+		req := provider.ChatCompletionRequest{Model: model, Messages: conversation}
+		if !final {
+			req.Tools = specs
+		}
+		text, calls, err := complete(ctx, p, req)
+		if err != nil {
+			return err
+		}
+		conversation = append(conversation, provider.ChatMessage{Role: "assistant", Content: text, ToolCalls: calls})
+		if len(calls) == 0 || final {
+			report.Report = strings.TrimSpace(text)
+			if report.Report == "" {
+				return errors.New("the subagent finished without a report")
+			}
+			return nil
+		}
+		report.Rounds++
+		for _, call := range calls {
+			report.ToolCalls++
+			msg := provider.ChatMessage{Role: "tool", ToolCallID: call.ID, Name: call.Name}
+			if !def.Allows(call.Name) {
+				msg.Content = tools.ErrorResult("tool_not_allowed", fmt.Sprintf("%s is not among this subagent's tools", call.Name), "")
+			} else {
+				out := runner.Run(ctx, call)
+				msg.Content, msg.Images = out.Content, out.Images
+				if out.Checkpoint > 0 {
+					report.Checkpoints = append(report.Checkpoints, out.Checkpoint)
+				}
+			}
+			conversation = append(conversation, msg)
+		}
+		m.update(report.RunID, func(r *Run) { r.Rounds, r.ToolCalls = report.Rounds, report.ToolCalls })
+		if report.Rounds >= def.MaxTurns {
+			conversation = append(conversation, provider.ChatMessage{Role: "user", Content: fmt.Sprintf("You have used all %d tool rounds. Reply now with your final report.", def.MaxTurns)})
+		}
+	}
+}
+
+// complete drains one streamed completion.
+func complete(ctx context.Context, p provider.Provider, req provider.ChatCompletionRequest) (string, []provider.ToolCall, error) {
+	stream, err := p.StreamChat(ctx, req)
+	if err != nil {
+		return "", nil, err
+	}
+	var text strings.Builder
+	var calls []provider.ToolCall
+	for chunk := range stream {
+		if chunk.Err != nil {
+			return "", nil, chunk.Err
+		}
+		text.WriteString(chunk.Content)
+		calls = append(calls, chunk.ToolCalls...)
+		if chunk.Done {
+			break
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	return text.String(), calls, nil
+}
+
+func toolSpecs(def Definition) []provider.ToolSpec {
+	var specs []provider.ToolSpec
+	for _, spec := range tools.Specs() {
+		if def.Allows(spec.Name) {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+func systemPrompt(def Definition, projectRoot string) string {
+	var b strings.Builder
+	if def.Prompt != "" {
+		b.WriteString(def.Prompt)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(fmt.Sprintf("You are the pfui subagent %q, working in %s on a task handed to you by another agent. ", def.Name, projectRoot))
+	b.WriteString("Nobody sees your intermediate messages and you cannot ask questions: work the task through with your tools, then reply with a final report without tool calls. ")
+	b.WriteString("That report is all the other agent receives, so make it self-contained: the findings, the files and lines that matter, what you changed, and anything left open.")
+	return b.String()
+}
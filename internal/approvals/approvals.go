@@ -0,0 +1,489 @@
+// Package approvals decides whether a tool request may run. Rules come from
+// the user (~/.pfui/approvals.toml), the project (.pfui/approvals.toml), and
+// answers remembered during the session. Project allow rules only count once
+// the operator has trusted the project file, so a cloned repository cannot
+// approve its own commands.
+package approvals
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/fbettag/pfui/internal/trust"
+)
+
+// FileName is the approvals file under ~/.pfui and <project>/.pfui.
+const FileName = "approvals.toml"
+
+// Decision is the outcome of evaluating a request.
+type Decision string
+
+const (
+	Allow Decision = "allow"
+	Deny  Decision = "deny"
+	Ask   Decision = "ask"
+)
+
+// ParseDecision validates a decision string.
+func ParseDecision(raw string) (Decision, error) {
+	switch Decision(strings.ToLower(strings.TrimSpace(raw))) {
+	case Allow:
+		return Allow, nil
+	case Deny:
+		return Deny, nil
+	case Ask:
+		return Ask, nil
+	default:
+		return "", fmt.Errorf("unknown decision %q (use allow, deny, or ask)", raw)
+	}
+}
+
+// Scope records where a rule came from. Later scopes take precedence.
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"
+	ScopeProject Scope = "project"
+	ScopeSession Scope = "session"
+)
+
+var scopeRank = map[Scope]int{ScopeUser: 0, ScopeProject: 1, ScopeSession: 2}
+
+// Mode mirrors the PLAN/AUTO/OFF badge.
+type Mode string
+
+const (
+	ModePlan Mode = "plan"
+	ModeAuto Mode = "auto"
+	ModeOff  Mode = "off"
+)
+
+// Rule matches requests by tool, command, arguments, and workdir. Empty fields
+// match anything; patterns use * (any run of characters) and ? (one character).
+type Rule struct {
+	Tool     string   `toml:"tool,omitempty"`
+	Command  string   `toml:"command,omitempty"`
+	Args     string   `toml:"args,omitempty"`
+	Workdir  string   `toml:"workdir,omitempty"`
+	Decision Decision `toml:"decision"`
+	Reason   string   `toml:"reason,omitempty"`
+	Scope    Scope    `toml:"-"`
+}
+
+// Matches reports whether the rule applies to req.
+func (r Rule) Matches(req Request) bool {
+	if r.Tool != "" && !matchGlob(r.Tool, req.Tool) {
+		return false
+	}
+	if r.Command != "" && !matchGlob(r.Command, req.Command) && !matchGlob(r.Command, filepath.Base(req.Command)) {
+		return false
+	}
+	if r.Args != "" && !matchGlob(r.Args, strings.Join(req.Args, " ")) {
+		return false
+	}
+	if r.Workdir != "" && !matchGlob(expandHome(r.Workdir), req.Workdir) {
+		return false
+	}
+	return true
+}
+
+// String renders the rule for /approvals listings.
+func (r Rule) String() string {
+	var parts []string
+	if r.Tool != "" {
+		parts = append(parts, "tool="+r.Tool)
+	}
+	if r.Command != "" {
+		parts = append(parts, "command="+r.Command)
+	}
+	if r.Args != "" {
+		parts = append(parts, fmt.Sprintf("args=%q", r.Args))
+	}
+	if r.Workdir != "" {
+		parts = append(parts, "workdir="+r.Workdir)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "everything")
+	}
+	out := fmt.Sprintf("%s %s", r.Decision, strings.Join(parts, " "))
+	if r.Reason != "" {
+		out += " — " + r.Reason
+	}
+	return out
+}
+
+// Request is what the engine evaluates.
+type Request struct {
+	Tool    string
+	Command string
+	Args    []string
+	Workdir string
+	Mode    Mode
+	// Root is the project root; recursive deletes outside it are risky.
+	Root string
+}
+
+// Verdict explains a decision.
+type Verdict struct {
+	Decision Decision
+	// Rule is the rule that decided, nil when the mode default applied.
+	Rule   *Rule
+	Reason string
+	// Risks lists why the command was flagged as risky; such commands always
+	// need explicit confirmation.
+	Risks []string
+}
+
+type ruleFile struct {
+	Rules []Rule     `toml:"rule"`
+	Risks []RiskRule `toml:"risk,omitempty"`
+}
+
+// Engine holds rules from every scope.
+type Engine struct {
+	mu          sync.Mutex
+	rules       []Rule
+	risks       []RiskRule
+	userPath    string
+	projectPath string
+	trust       *trust.Store
+	// projectTrusted is set when the project file, as loaded, is trusted.
+	projectTrusted bool
+}
+
+// DefaultPaths returns ~/.pfui/approvals.toml and <projectRoot>/.pfui/approvals.toml.
+func DefaultPaths(projectRoot string) (string, string) {
+	user := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		user = filepath.Join(home, ".pfui", FileName)
+	}
+	project := ""
+	if projectRoot != "" {
+		project = filepath.Join(projectRoot, ".pfui", FileName)
+	}
+	return user, project
+}
+
+// Load reads both rule files; missing files are fine. Session rules start
+// empty. Without a trust store the project's allow rules are ignored.
+func Load(userPath, projectPath string) (*Engine, error) {
+	return LoadTrusted(userPath, projectPath, nil)
+}
+
+// LoadTrusted is Load with a trust store deciding whether the project file's
+// allow rules apply.
+func LoadTrusted(userPath, projectPath string, store *trust.Store) (*Engine, error) {
+	e := &Engine{userPath: userPath, projectPath: projectPath, trust: store}
+	return e, e.Reload()
+}
+
+// Reload rereads the rule files, keeping session rules.
+func (e *Engine) Reload() error {
+	user, userRisks, userErr := readRules(e.userPath, ScopeUser)
+	project, projectRisks, projectErr := readRules(e.projectPath, ScopeProject)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.risks = append(userRisks, projectRisks...)
+	var session []Rule
+	for _, rule := range e.rules {
+		if rule.Scope == ScopeSession {
+			session = append(session, rule)
+		}
+	}
+	e.rules = append(append(user, project...), session...)
+	e.projectTrusted = e.trust.Trusted(e.projectPath)
+	return errors.Join(userErr, projectErr)
+}
+
+// ProjectTrusted reports whether the project file's allow rules apply.
+func (e *Engine) ProjectTrusted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.projectTrusted
+}
+
+// HasProjectAllows reports whether the project file holds allow rules, which
+// are ignored until it is trusted.
+func (e *Engine) HasProjectAllows() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, rule := range e.rules {
+		if rule.Scope == ScopeProject && rule.Decision == Allow {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustProject accepts the project file as it is now and rereads it.
+func (e *Engine) TrustProject() error {
+	if e.projectPath == "" {
+		return errors.New("no project approvals file")
+	}
+	if err := e.trust.Trust(e.projectPath); err != nil {
+		return err
+	}
+	return e.Reload()
+}
+
+// writeProject rewrites the project file with rules. A file the operator
+// trusted (or that did not exist yet) stays trusted, since the change is
+// theirs; an untrusted one stays untrusted.
+func (e *Engine) writeProject(write func() error) error {
+	e.mu.Lock()
+	keep := e.projectTrusted
+	e.mu.Unlock()
+	if _, err := os.Stat(e.projectPath); errors.Is(err, os.ErrNotExist) {
+		keep = e.trust != nil
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	if !keep {
+		return nil
+	}
+	if err := e.trust.Trust(e.projectPath); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.projectTrusted = true
+	e.mu.Unlock()
+	return nil
+}
+
+func readRules(path string, scope Scope) ([]Rule, []RiskRule, error) {
+	file, err := readRuleFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules := make([]Rule, 0, len(file.Rules))
+	for i, rule := range file.Rules {
+		decision, err := ParseDecision(string(rule.Decision))
+		if err != nil {
+			return rules, nil, fmt.Errorf("%s rule %d: %w", path, i+1, err)
+		}
+		rule.Decision = decision
+		rule.Scope = scope
+		rules = append(rules, rule)
+	}
+	risks := make([]RiskRule, 0, len(file.Risks))
+	for i, risk := range file.Risks {
+		if risk.Command == "" {
+			return rules, risks, fmt.Errorf("%s risk %d: command is required", path, i+1)
+		}
+		risk.Scope = scope
+		risks = append(risks, risk)
+	}
+	return rules, risks, nil
+}
+
+func readRuleFile(path string) (ruleFile, error) {
+	var file ruleFile
+	if path == "" {
+		return file, nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return file, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := toml.Unmarshal(raw, &file); err != nil {
+		return file, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// RiskRules returns the user and project risk rules.
+func (e *Engine) RiskRules() []RiskRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]RiskRule(nil), e.risks...)
+}
+
+// Rules returns every active rule, user scope first.
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// Add installs a rule. ScopeSession keeps it in memory; ScopeUser and
+// ScopeProject also append it to the matching file.
+func (e *Engine) Add(rule Rule, scope Scope) error {
+	if _, err := ParseDecision(string(rule.Decision)); err != nil {
+		return err
+	}
+	rule.Scope = scope
+	switch scope {
+	case ScopeUser:
+		if err := appendRule(e.userPath, rule); err != nil {
+			return err
+		}
+	case ScopeProject:
+		if err := e.writeProject(func() error { return appendRule(e.projectPath, rule) }); err != nil {
+			return err
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = append(e.rules, rule)
+	return nil
+}
+
+// Remove deletes the rule at index (as listed by Rules), rewriting its file
+// when it was persisted.
+func (e *Engine) Remove(index int) (Rule, error) {
+	e.mu.Lock()
+	if index < 0 || index >= len(e.rules) {
+		e.mu.Unlock()
+		return Rule{}, fmt.Errorf("no rule %d", index+1)
+	}
+	removed := e.rules[index]
+	e.rules = append(e.rules[:index:index], e.rules[index+1:]...)
+	var remaining []Rule
+	for _, rule := range e.rules {
+		if rule.Scope == removed.Scope {
+			remaining = append(remaining, rule)
+		}
+	}
+	e.mu.Unlock()
+	switch removed.Scope {
+	case ScopeUser:
+		return removed, writeRules(e.userPath, remaining)
+	case ScopeProject:
+		return removed, e.writeProject(func() error { return writeRules(e.projectPath, remaining) })
+	}
+	return removed, nil
+}
+
+func appendRule(path string, rule Rule) error {
+	existing, _, err := readRules(path, rule.Scope)
+	if err != nil {
+		return err
+	}
+	return writeRules(path, append(existing, rule))
+}
+
+func writeRules(path string, rules []Rule) error {
+	if path == "" {
+		return errors.New("no approvals file configured for this scope")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(path), err)
+	}
+	// Keep the file's risk rules; only the allow/deny/ask rules are rewritten.
+	existing, err := readRuleFile(path)
+	if err != nil {
+		return err
+	}
+	data, err := toml.Marshal(ruleFile{Rules: rules, Risks: existing.Risks})
+	if err != nil {
+		return fmt.Errorf("encoding approvals: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Evaluate decides req. A matching deny from any scope wins; otherwise the
+// highest-precedence scope (session, then project, then user) with a matching
+// rule decides, and the last matching rule within that scope wins. Project
+// allow rules are skipped while the project file is untrusted. Without a
+// match the mode default applies. Risky commands (see Classify) always ask,
+// in every mode and whatever allow rules say, and in PLAN mode mutating
+// commands never run without confirmation.
+func (e *Engine) Evaluate(req Request) Verdict {
+	e.mu.Lock()
+	var match *Rule
+	for i := range e.rules {
+		rule := e.rules[i]
+		if !rule.Matches(req) {
+			continue
+		}
+		if rule.Decision == Deny {
+			e.mu.Unlock()
+			return Verdict{Decision: Deny, Rule: &rule, Reason: ruleReason(rule)}
+		}
+		if rule.Scope == ScopeProject && rule.Decision == Allow && !e.projectTrusted {
+			continue
+		}
+		if match == nil || scopeRank[rule.Scope] >= scopeRank[match.Scope] {
+			match = &rule
+		}
+	}
+	e.mu.Unlock()
+
+	if risks := e.Classify(req); len(risks) > 0 {
+		return Verdict{Decision: Ask, Rule: match, Reason: "risky: " + strings.Join(risks, "; "), Risks: risks}
+	}
+	mutating := IsMutating(req.Command, req.Args)
+	if req.Mode == ModePlan && mutating {
+		return Verdict{Decision: Ask, Rule: match, Reason: "PLAN mode requires confirmation for commands that may change files"}
+	}
+	if match != nil {
+		return Verdict{Decision: match.Decision, Rule: match, Reason: ruleReason(*match)}
+	}
+	switch {
+	case req.Mode == ModeAuto:
+		return Verdict{Decision: Allow, Reason: "AUTO mode"}
+	case mutating:
+		return Verdict{Decision: Ask, Reason: "command may change files"}
+	default:
+		return Verdict{Decision: Allow, Reason: "read-only command"}
+	}
+}
+
+func ruleReason(rule Rule) string {
+	if rule.Reason != "" {
+		return rule.Reason
+	}
+	return fmt.Sprintf("%s rule: %s", rule.Scope, rule.String())
+}
+
+// matchGlob matches s against pattern where * spans any characters
+// (including /) and ? matches exactly one.
+func matchGlob(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
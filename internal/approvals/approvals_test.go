@@ -0,0 +1,279 @@
+package approvals
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/trust"
+)
+
+func TestEvaluatePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "user.toml")
+	projectPath := filepath.Join(dir, "project", ".pfui", FileName)
+	if err := os.MkdirAll(filepath.Dir(projectPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(userPath, []byte(`
+[[rule]]
+command = "make"
+decision = "ask"
+
+[[rule]]
+command = "rm"
+args = "-rf /*"
+decision = "deny"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(projectPath, []byte(`
+[[rule]]
+command = "make"
+args = "test*"
+decision = "allow"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := trust.Open(filepath.Join(dir, trust.FileName))
+	e, err := LoadTrusted(userPath, projectPath, store)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if v := e.Evaluate(Request{Command: "make", Args: []string{"test", "-j4"}, Mode: ModeOff}); v.Decision != Ask {
+		t.Fatalf("an untrusted project allow must not beat user ask, got %s (%s)", v.Decision, v.Reason)
+	}
+	if err := e.TrustProject(); err != nil {
+		t.Fatalf("TrustProject: %v", err)
+	}
+	if v := e.Evaluate(Request{Command: "make", Args: []string{"test", "-j4"}, Mode: ModeOff}); v.Decision != Allow {
+		t.Fatalf("trusted project allow should beat user ask, got %s (%s)", v.Decision, v.Reason)
+	}
+	if err := e.Add(Rule{Command: "go", Decision: Allow}, ScopeProject); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if !store.Trusted(projectPath) {
+		t.Fatal("the operator's own project rule should keep the file trusted")
+	}
+	err = os.WriteFile(projectPath, []byte(`
+[[rule]]
+command = "*"
+decision = "allow"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if v := e.Evaluate(Request{Command: "make", Args: []string{"test"}, Mode: ModeOff}); v.Decision != Ask {
+		t.Fatalf("an edited project file must lose trust, got %s (%s)", v.Decision, v.Reason)
+	}
+	if v := e.Evaluate(Request{Command: "make", Args: []string{"install"}, Mode: ModeOff}); v.Decision != Ask {
+		t.Fatalf("expected user ask for make install, got %s", v.Decision)
+	}
+	if err := e.Add(Rule{Command: "rm", Decision: Allow}, ScopeSession); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if v := e.Evaluate(Request{Command: "/bin/rm", Args: []string{"-rf", "/etc"}, Mode: ModeAuto}); v.Decision != Deny {
+		t.Fatalf("deny must win over a session allow, got %s", v.Decision)
+	}
+}
+
+func TestPlanModeAsksForMutatingCommands(t *testing.T) {
+	e, err := Load("", "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := e.Add(Rule{Command: "touch", Decision: Allow}, ScopeSession); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if v := e.Evaluate(Request{Command: "touch", Args: []string{"x"}, Mode: ModePlan}); v.Decision != Ask {
+		t.Fatalf("PLAN mode must confirm mutating commands, got %s", v.Decision)
+	}
+	if v := e.Evaluate(Request{Command: "git", Args: []string{"status"}, Mode: ModePlan}); v.Decision != Allow {
+		t.Fatalf("read-only git status should run in PLAN, got %s", v.Decision)
+	}
+	if v := e.Evaluate(Request{Command: "touch", Args: []string{"x"}, Mode: ModeAuto}); v.Decision != Allow {
+		t.Fatalf("AUTO should allow, got %s", v.Decision)
+	}
+}
+
+func TestFileEditsFollowModeAndRules(t *testing.T) {
+	e, err := Load("", "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	edit := func(path string, mode Mode) Decision {
+		return e.Evaluate(Request{Tool: "edit_file", Command: "edit_file", Args: []string{path}, Mode: mode}).Decision
+	}
+	if d := edit("main.go", ModeAuto); d != Allow {
+		t.Fatalf("AUTO should apply edits, got %s", d)
+	}
+	if d := edit("main.go", ModeOff); d != Ask {
+		t.Fatalf("OFF should confirm edits, got %s", d)
+	}
+	if d := edit("main.go", ModePlan); d != Ask {
+		t.Fatalf("PLAN should confirm edits, got %s", d)
+	}
+	if err := e.Add(Rule{Tool: "edit_file", Args: "go.mod", Decision: Ask}, ScopeSession); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if d := edit("go.mod", ModeAuto); d != Ask {
+		t.Fatalf("an ask rule should hold back an AUTO edit, got %s", d)
+	}
+}
+
+func TestAddPersistsAndRemoveRewrites(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, ".pfui", FileName)
+	e, err := Load(userPath, "")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := e.Add(Rule{Command: "npm", Args: "test", Decision: Allow}, ScopeUser); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	reloaded, err := Load(userPath, "")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	rules := reloaded.Rules()
+	if len(rules) != 1 || rules[0].Command != "npm" || rules[0].Scope != ScopeUser {
+		t.Fatalf("unexpected persisted rules %#v", rules)
+	}
+	if _, err := reloaded.Remove(0); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	again, _ := Load(userPath, "")
+	if len(again.Rules()) != 0 {
+		t.Fatalf("expected rule file to be emptied, got %#v", again.Rules())
+	}
+}
+
+func TestGateRemembersSessionAnswers(t *testing.T) {
+	e, _ := Load("", "")
+	gate := NewGate(e)
+	gate.SetMode(ModeOff)
+	prompts := 0
+	gate.SetPrompter(func(ctx context.Context, req toolexec.Request, v Verdict) (Answer, error) {
+		prompts++
+		return Answer{Decision: Allow, Remember: ScopeSession, Rule: Rule{Command: req.Command}}, nil
+	})
+	req := toolexec.Request{Tool: "exec", Command: "make", Args: []string{"build"}}
+	for i := 0; i < 2; i++ {
+		if _, err := gate.Approve(context.Background(), req); err != nil {
+			t.Fatalf("Approve: %v", err)
+		}
+	}
+	if prompts != 1 {
+		t.Fatalf("expected one prompt, got %d", prompts)
+	}
+
+	gate.SetPrompter(nil)
+	_, err := gate.Approve(context.Background(), toolexec.Request{Command: "touch", Args: []string{"x"}})
+	var denied *DeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected DeniedError without a prompter, got %v", err)
+	}
+}
+
+func TestGateRecordsWhoDecided(t *testing.T) {
+	e, _ := Load("", "")
+	gate := NewGate(e)
+	gate.SetMode(ModeOff)
+	var got []string
+	gate.SetRecorder(func(r Record) {
+		got = append(got, string(r.Decision)+"/"+r.DecidedBy)
+	})
+	gate.SetPrompter(func(ctx context.Context, req toolexec.Request, v Verdict) (Answer, error) {
+		return Answer{Decision: Deny, Feedback: "no"}, nil
+	})
+	if err := e.Add(Rule{Command: "make", Decision: Allow}, ScopeSession); err != nil {
+		t.Fatal(err)
+	}
+	gate.Approve(context.Background(), toolexec.Request{Command: "ls"})
+	gate.Approve(context.Background(), toolexec.Request{Command: "make"})
+	gate.Approve(context.Background(), toolexec.Request{Command: "rm", Args: []string{"x"}})
+	want := []string{"allow/mode", "allow/rule", "deny/operator"}
+	if len(got) != len(want) {
+		t.Fatalf("recorded %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("recorded %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMatchGlobAndMutating(t *testing.T) {
+	if !matchGlob("git push*", "git push --force origin") || matchGlob("git push", "git pull") {
+		t.Fatal("glob mismatch")
+	}
+	if !matchGlob("/home/*/src", "/home/me/deep/src") {
+		t.Fatal("* should span path separators")
+	}
+	if IsMutating("git", []string{"log", "-n", "3"}) || !IsMutating("git", []string{"commit", "-m", "x"}) {
+		t.Fatal("git classification wrong")
+	}
+	if IsMutating("find", []string{".", "-name", "*.go"}) || !IsMutating("find", []string{".", "-delete"}) {
+		t.Fatal("find classification wrong")
+	}
+	if !IsMutating("sh", []string{"-c", "ls"}) {
+		t.Fatal("shells are treated as mutating")
+	}
+	cases := []struct {
+		command  string
+		args     []string
+		mutating bool
+	}{
+		{"git", []string{"stash"}, true},
+		{"git", []string{"stash", "-u"}, true},
+		{"git", []string{"stash", "push", "-m", "wip"}, true},
+		{"git", []string{"stash", "pop"}, true},
+		{"git", []string{"stash", "list"}, false},
+		{"git", []string{"stash", "show", "-p"}, false},
+		{"sed", []string{"-i", "s/a/b/", "f"}, true},
+		{"sed", []string{"-Ei", "s/a/b/", "f"}, true},
+		{"sed", []string{"-ni.bak", "p", "f"}, true},
+		{"sed", []string{"--in-place=.bak", "s/a/b/", "f"}, true},
+		{"sed", []string{"-n", "s/i/x/p", "f"}, false},
+		{"sed", []string{"-es/i/x/", "f"}, false},
+		{"go", []string{"env", "GOPATH"}, false},
+		{"go", []string{"env", "-w", "GOFLAGS=-mod=mod"}, true},
+		{"go", []string{"env", "-u", "GOFLAGS"}, true},
+		{"sed", []string{"-n", "w out.txt", "f"}, true},
+		{"sed", []string{"s/a/b/w out.txt", "f"}, true},
+		{"sed", []string{"-e", "1e date", "f"}, true},
+		{"sed", []string{"-ne", "$W out", "f"}, true},
+		{"sed", []string{"/x/s/a/b/ge", "f"}, true},
+		{"sed", []string{"-f", "edit.sed", "f"}, true},
+		{"sed", []string{"-n", "/error/p", "f"}, false},
+		{"sed", []string{"s/we/ew/g", "f"}, false},
+		{"sed", []string{"/a/,/b/d;s|x|y|gI", "f"}, false},
+		{"sed", []string{"-e", "s/e/x/", "-e", "a\\nwritten", "f"}, false},
+		{"rg", []string{"needle", "src"}, false},
+		{"rg", []string{"--pre", "./decode", "needle"}, true},
+		{"rg", []string{"--pre-glob=*.pdf", "--pre=pdftotext", "needle"}, true},
+		{"ast-grep", []string{"-p", "foo($A)", "src"}, false},
+		{"ast-grep", []string{"run", "-p", "foo($A)", "-r", "bar($A)", "-U"}, true},
+		{"ast-grep", []string{"run", "-p", "foo($A)", "-r", "bar($A)", "--update-all"}, true},
+		{"git", []string{"diff", "--output=changes.patch"}, true},
+		{"git", []string{"log", "-p", "--output", "log.txt"}, true},
+		{"git", []string{"diff", "--output-indicator-new=>"}, false},
+		{"git", []string{"-C", "status", "push"}, true},
+		{"git", []string{"-c", "color.ui=never", "-C", "sub", "status"}, false},
+		{"git", []string{"-C", "branch", "branch", "-D", "old"}, true},
+		{"find", []string{".", "-name", "*.go", "-fprint0", "list"}, true},
+	}
+	for _, tc := range cases {
+		if got := IsMutating(tc.command, tc.args); got != tc.mutating {
+			t.Errorf("IsMutating(%s %v) = %t, want %t", tc.command, tc.args, got, tc.mutating)
+		}
+	}
+}
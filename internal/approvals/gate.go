@@ -0,0 +1,193 @@
+package approvals
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+// Answer is the operator's reply to an ask verdict.
+type Answer struct {
+	Decision Decision
+	// Remember stores Rule in the given scope; empty means answer once.
+	Remember Scope
+	Rule     Rule
+	// Feedback is passed back to the model on denial.
+	Feedback string
+	// Edited, when set, replaces the request the operator approved.
+	Edited *toolexec.Request
+}
+
+// Prompter asks the operator about a request and blocks until they answer.
+type Prompter func(ctx context.Context, req toolexec.Request, verdict Verdict) (Answer, error)
+
+// Who made a decision, as reported to a Recorder.
+const (
+	DecidedByRule     = "rule"
+	DecidedByMode     = "mode"
+	DecidedByOperator = "operator"
+)
+
+// Record describes one decision for a Recorder.
+type Record struct {
+	// Request is the request as it will run (after any operator edit).
+	Request  toolexec.Request
+	Decision Decision
+	// DecidedBy is one of the DecidedBy constants.
+	DecidedBy string
+	Reason    string
+	// Risks says why the command was flagged as risky, if it was.
+	Risks []string
+}
+
+// Recorder is told about every decision the gate makes, e.g. for the audit log.
+type Recorder func(Record)
+
+// DeniedError is returned to the caller (and on to the model) when a request
+// is refused.
+type DeniedError struct {
+	Command  string
+	Reason   string
+	Feedback string
+}
+
+func (e *DeniedError) Error() string {
+	msg := fmt.Sprintf("%s denied: %s", e.Command, e.Reason)
+	if e.Feedback != "" {
+		msg += " (operator: " + e.Feedback + ")"
+	}
+	return msg
+}
+
+// Gate implements toolexec.Approver on top of an Engine.
+type Gate struct {
+	engine *Engine
+
+	mu     sync.Mutex
+	mode   Mode
+	root   string
+	prompt Prompter
+	record Recorder
+}
+
+// NewGate wraps engine; mode starts at PLAN like a fresh session.
+func NewGate(engine *Engine) *Gate {
+	return &Gate{engine: engine, mode: ModePlan}
+}
+
+// Engine exposes the underlying rules.
+func (g *Gate) Engine() *Engine {
+	return g.engine
+}
+
+// SetMode tracks the PLAN/AUTO/OFF badge.
+func (g *Gate) SetMode(mode Mode) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.mode = mode
+}
+
+// SetProjectRoot tells the risk classifier where the project lives.
+func (g *Gate) SetProjectRoot(root string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.root = root
+}
+
+// SetPrompter installs the operator prompt used for ask verdicts. Without one,
+// ask verdicts are denied.
+func (g *Gate) SetPrompter(p Prompter) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prompt = p
+}
+
+// SetRecorder installs a hook that sees every decision (nil disables it).
+func (g *Gate) SetRecorder(r Recorder) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.record = r
+}
+
+func (g *Gate) recordDecision(req toolexec.Request, verdict Verdict, decision Decision, decidedBy, reason string) {
+	g.mu.Lock()
+	record := g.record
+	g.mu.Unlock()
+	if record != nil {
+		record(Record{Request: req, Decision: decision, DecidedBy: decidedBy, Reason: reason, Risks: verdict.Risks})
+	}
+}
+
+// Evaluate returns the verdict for req under the current mode.
+func (g *Gate) Evaluate(req toolexec.Request) Verdict {
+	g.mu.Lock()
+	mode, root := g.mode, g.root
+	g.mu.Unlock()
+	return g.engine.Evaluate(Request{
+		Tool:    req.Tool,
+		Command: req.Command,
+		Args:    req.Args,
+		Workdir: req.Workdir,
+		Mode:    mode,
+		Root:    root,
+	})
+}
+
+// Approve is consulted by the executor before every request. An edited
+// command is re-evaluated so deny rules still apply to it.
+func (g *Gate) Approve(ctx context.Context, req toolexec.Request) (toolexec.Request, error) {
+	verdict := g.Evaluate(req)
+	by := DecidedByMode
+	if verdict.Rule != nil && verdict.Decision != Ask {
+		by = DecidedByRule
+	}
+	switch verdict.Decision {
+	case Allow:
+		g.recordDecision(req, verdict, Allow, by, verdict.Reason)
+		return req, nil
+	case Deny:
+		g.recordDecision(req, verdict, Deny, by, verdict.Reason)
+		return req, &DeniedError{Command: req.Command, Reason: verdict.Reason}
+	}
+	g.mu.Lock()
+	prompt := g.prompt
+	g.mu.Unlock()
+	if prompt == nil {
+		reason := verdict.Reason + "; approval required (add a rule with /approvals allow)"
+		g.recordDecision(req, verdict, Deny, by, reason)
+		return req, &DeniedError{Command: req.Command, Reason: reason}
+	}
+	answer, err := prompt(ctx, req, verdict)
+	if err != nil {
+		g.recordDecision(req, verdict, Deny, DecidedByOperator, err.Error())
+		return req, fmt.Errorf("approval prompt: %w", err)
+	}
+	if answer.Remember != "" {
+		rule := answer.Rule
+		rule.Decision = answer.Decision
+		if err := g.engine.Add(rule, answer.Remember); err != nil {
+			return req, fmt.Errorf("remembering approval: %w", err)
+		}
+	}
+	if answer.Decision != Allow {
+		reason := "operator declined"
+		if answer.Feedback != "" {
+			reason += ": " + answer.Feedback
+		}
+		g.recordDecision(req, verdict, Deny, DecidedByOperator, reason)
+		return req, &DeniedError{Command: req.Command, Reason: "operator declined", Feedback: answer.Feedback}
+	}
+	if answer.Edited != nil {
+		edited := *answer.Edited
+		if v := g.Evaluate(edited); v.Decision == Deny {
+			g.recordDecision(edited, v, Deny, DecidedByRule, v.Reason)
+			return edited, &DeniedError{Command: edited.Command, Reason: v.Reason}
+		}
+		g.recordDecision(edited, verdict, Allow, DecidedByOperator, "operator edited and approved")
+		return edited, nil
+	}
+	g.recordDecision(req, verdict, Allow, DecidedByOperator, "operator approved")
+	return req, nil
+}
@@ -0,0 +1,293 @@
+package approvals
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// readOnlyCommands never modify the filesystem on their own. Anything not
+// listed here (including shells, whose scripts we do not inspect) is treated
+// as mutating.
+var readOnlyCommands = map[string]bool{
+	"cat": true, "head": true, "tail": true, "less": true, "more": true,
+	"ls": true, "tree": true, "pwd": true, "echo": true, "printf": true,
+	"wc": true, "stat": true, "file": true, "du": true, "df": true,
+	"grep": true, "egrep": true, "fgrep": true, "rg": true, "ag": true, "ast-grep": true,
+	"which": true, "whereis": true, "type": true, "whoami": true, "id": true,
+	"uname": true, "date": true, "printenv": true,
+	"diff": true, "cmp": true, "sha256sum": true, "md5sum": true, "shasum": true,
+	"jq": true, "basename": true, "dirname": true, "realpath": true,
+	"ps": true, "uptime": true, "true": true, "false": true, "test": true,
+}
+
+// readOnlyGit lists git subcommands that only inspect the repository.
+var readOnlyGit = map[string]bool{
+	"status": true, "log": true, "diff": true, "show": true, "blame": true,
+	"grep": true, "ls-files": true, "ls-tree": true, "rev-parse": true,
+	"describe": true, "shortlog": true, "reflog": true, "cat-file": true,
+	"merge-base": true, "whatchanged": true,
+}
+
+// readOnlyGo lists go subcommands that do not write into the project.
+var readOnlyGo = map[string]bool{
+	"version": true, "env": true, "list": true, "doc": true, "vet": true,
+}
+
+// IsMutating reports whether command may change files. It errs on the side of
+// yes: unknown programs, shells, and anything with output redirection flags
+// count as mutating.
+func IsMutating(command string, args []string) bool {
+	name := filepath.Base(command)
+	switch name {
+	case "git":
+		// Global options such as -C dir and -c key=value take values that
+		// are not the subcommand.
+		sub, rest := gitSubcommand(args)
+		if sub == "branch" || sub == "remote" || sub == "tag" {
+			// Listing forms only: `git branch`, `git branch -a`, `git remote -v`.
+			return len(operands(rest)) > 0
+		}
+		if sub == "stash" {
+			// A bare `git stash` is `git stash push`; only list and show look.
+			rest := operands(rest)
+			return len(rest) == 0 || (rest[0] != "list" && rest[0] != "show")
+		}
+		// --output (not --output-indicator-*) sends diff, log, or show
+		// output to a file.
+		for _, arg := range args {
+			if arg == "--output" || strings.HasPrefix(arg, "--output=") {
+				return true
+			}
+		}
+		return !readOnlyGit[sub]
+	case "go":
+		sub := firstOperand(args)
+		if sub == "env" {
+			// `go env -w` and `-u` rewrite the go env file.
+			for _, arg := range args {
+				if arg == "-w" || arg == "-u" {
+					return true
+				}
+			}
+		}
+		return !readOnlyGo[sub]
+	case "find":
+		for _, arg := range args {
+			switch arg {
+			case "-delete", "-exec", "-execdir", "-ok", "-okdir", "-fprint", "-fprint0", "-fprintf", "-fls":
+				return true
+			}
+		}
+		return false
+	case "sed":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--in-place") || sedShortInPlace(arg) {
+				return true
+			}
+		}
+		scripts, fromFile := sedScripts(args)
+		if fromFile {
+			// A -f script is not read here, so it may write or execute.
+			return true
+		}
+		for _, script := range scripts {
+			if sedScriptWrites(script) {
+				return true
+			}
+		}
+		return false
+	case "rg":
+		// --pre runs a program on every searched file.
+		for _, arg := range args {
+			if arg == "--pre" || strings.HasPrefix(arg, "--pre=") || strings.HasPrefix(arg, "--pre-glob") {
+				return true
+			}
+		}
+		return false
+	case "ast-grep":
+		// -U applies every rewrite without asking.
+		return hasFlag(args, "U", "update-all")
+	}
+	return !readOnlyCommands[name]
+}
+
+// sedShortInPlace reports whether a short-flag bundle such as -i, -Ei, or
+// -ni.bak asks for in-place editing. -e and -f take the rest of the bundle
+// as their argument, so an i after them is not a flag.
+func sedShortInPlace(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' || arg[1] == '-' {
+		return false
+	}
+	for _, c := range arg[1:] {
+		switch c {
+		case 'i':
+			return true
+		case 'e', 'f', 'l':
+			return false
+		}
+	}
+	return false
+}
+
+// sedScripts collects the scripts given with -e/--expression, or the first
+// operand when there are none. fromFile reports a -f/--file script.
+func sedScripts(args []string) (scripts []string, fromFile bool) {
+	explicit := false
+	var operands []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		next := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch {
+		case arg == "--":
+			operands = append(operands, args[i+1:]...)
+			i = len(args)
+		case arg == "--expression":
+			scripts, explicit = append(scripts, next()), true
+		case strings.HasPrefix(arg, "--expression="):
+			scripts, explicit = append(scripts, strings.TrimPrefix(arg, "--expression=")), true
+		case arg == "--file" || strings.HasPrefix(arg, "--file="):
+			fromFile = true
+		case arg == "--line-length":
+			next()
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") && arg != "-":
+		bundle:
+			for j := 1; j < len(arg); j++ {
+				switch arg[j] {
+				case 'e':
+					value := arg[j+1:]
+					if value == "" {
+						value = next()
+					}
+					scripts, explicit = append(scripts, value), true
+					break bundle
+				case 'f':
+					fromFile = true
+					if j+1 == len(arg) {
+						next()
+					}
+					break bundle
+				case 'l':
+					if j+1 == len(arg) {
+						next()
+					}
+					break bundle
+				case 'i':
+					// -i takes the rest of the bundle as its backup suffix.
+					break bundle
+				}
+			}
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	if !explicit && len(operands) > 0 {
+		scripts = append(scripts, operands[0])
+	}
+	return scripts, fromFile
+}
+
+// sedScriptWrites reports whether a sed script uses the w, W, or e commands,
+// or an s command with the w or e flag: the first two write files, the
+// others run shell commands.
+func sedScriptWrites(script string) bool {
+	i := 0
+	for i < len(script) {
+		if strings.IndexByte(" \t\n;{}!", script[i]) >= 0 {
+			i++
+			continue
+		}
+		i = skipSedAddress(script, i)
+		if i >= len(script) {
+			return false
+		}
+		cmd := script[i]
+		i++
+		switch cmd {
+		case 'w', 'W', 'e':
+			return true
+		case 's':
+			if i >= len(script) {
+				return false
+			}
+			delim := script[i]
+			i = skipSedDelimited(script, i+1, delim)
+			i = skipSedDelimited(script, i, delim)
+			for i < len(script) && strings.IndexByte(";\n}", script[i]) < 0 {
+				if script[i] == 'w' || script[i] == 'e' {
+					return true
+				}
+				i++
+			}
+		case 'y':
+			if i >= len(script) {
+				return false
+			}
+			delim := script[i]
+			i = skipSedDelimited(script, i+1, delim)
+			i = skipSedDelimited(script, i, delim)
+		case 'a', 'i', 'c', 'r', 'R', '#', ':':
+			// Text, file names, labels, and comments run to the end of the line.
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+		case 'b', 't', 'T':
+			for i < len(script) && strings.IndexByte(";\n}", script[i]) < 0 {
+				i++
+			}
+		}
+	}
+	return false
+}
+
+// skipSedAddress steps over an address or range (1, $, /re/, \cREc, 1,+3,
+// first~step) and returns the index of the command that follows.
+func skipSedAddress(script string, i int) int {
+	for i < len(script) {
+		c := script[i]
+		switch {
+		case c >= '0' && c <= '9' || c == '$' || c == ',' || c == '~' || c == '+' || c == ' ' || c == '\t' || c == '!':
+			i++
+		case c == '/':
+			i = skipSedDelimited(script, i+1, '/')
+			for i < len(script) && (script[i] == 'I' || script[i] == 'M') {
+				i++
+			}
+		case c == '\\' && i+1 < len(script):
+			i = skipSedDelimited(script, i+2, script[i+1])
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipSedDelimited returns the index just past the next unescaped delim.
+func skipSedDelimited(script string, i int, delim byte) int {
+	for i < len(script) {
+		switch script[i] {
+		case '\\':
+			i += 2
+			continue
+		case delim:
+			return i + 1
+		}
+		i++
+	}
+	return i
+}
+
+func firstOperand(args []string) string {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
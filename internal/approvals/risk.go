@@ -0,0 +1,645 @@
+package approvals
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RiskRule flags matching commands as risky. Risky commands always need an
+// explicit confirmation, whatever the mode or allow rules say; only a deny
+// rule overrides them. Command matches the program's base name and Args the
+// space-joined arguments, with the same patterns as Rule.
+type RiskRule struct {
+	Command string `toml:"command"`
+	Args    string `toml:"args,omitempty"`
+	Reason  string `toml:"reason,omitempty"`
+	Scope   Scope  `toml:"-"`
+}
+
+// shells are interpreters whose -c script is parsed and which count as the
+// receiving end of a download pipe.
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+}
+
+// pipeInterpreters run whatever arrives on stdin.
+var pipeInterpreters = map[string]bool{
+	"python": true, "python3": true, "perl": true, "ruby": true, "node": true, "php": true,
+}
+
+// substitutionRunners execute their arguments as code, so a command or
+// process substitution among them runs whatever that command printed.
+var substitutionRunners = map[string]bool{"eval": true, "source": true, ".": true}
+
+// commandWrappers run the command named in their arguments.
+var commandWrappers = map[string]bool{
+	"sudo": true, "doas": true, "env": true, "nice": true, "timeout": true, "nohup": true, "time": true,
+	"exec": true, "command": true, "builtin": true, "stdbuf": true, "ionice": true, "xargs": true,
+}
+
+// RunsCode reports whether command runs code or another command taken from
+// its arguments or input (a shell, an interpreter, eval, or a wrapper such
+// as sudo or env), so no argument prefix safely bounds what it does.
+func RunsCode(command string) bool {
+	name := filepath.Base(command)
+	return shells[name] || pipeInterpreters[name] || substitutionRunners[name] || commandWrappers[name]
+}
+
+// publishCommands maps a program onto the subcommands that publish a package.
+var publishCommands = map[string][]string{
+	"npm": {"publish"}, "yarn": {"publish", "npm publish"}, "pnpm": {"publish"},
+	"cargo": {"publish"}, "gem": {"push"}, "twine": {"upload"}, "poetry": {"publish"},
+	"flit": {"publish"}, "hatch": {"publish"}, "mvn": {"deploy"}, "gradle": {"publish"},
+	"dotnet": {"nuget push"}, "mix": {"hex.publish"}, "docker": {"push"}, "podman": {"push"},
+}
+
+// Classify returns why req is risky, or nil. The command line is unwrapped
+// first: `sh -c` scripts are parsed into pipelines, and sudo, env, xargs,
+// and similar prefixes are looked through. A recursive rm is only risky
+// outside req.Root (or when its targets are unknown); cd within a script
+// moves the directory its relative targets resolve against.
+func (e *Engine) Classify(req Request) []string {
+	e.mu.Lock()
+	rules := append([]RiskRule(nil), e.risks...)
+	e.mu.Unlock()
+	var reasons []string
+	seen := map[string]bool{}
+	add := func(reason string) {
+		if !seen[reason] {
+			seen[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+	argv := append([]string{req.Command}, req.Args...)
+	start := workdir{path: req.Workdir}
+	if start.path == "" {
+		start.path = req.Root
+	}
+	for _, pipeline := range expandCommand(argv, 0, start) {
+		for i, cmd := range pipeline {
+			if reason := builtinRisk(cmd, req); reason != "" {
+				add(reason)
+			}
+			for _, rule := range rules {
+				if rule.matches(cmd.argv) {
+					add(rule.reason())
+				}
+			}
+			if i == 0 {
+				continue
+			}
+			name := filepath.Base(cmd.argv[0])
+			if !shells[name] && !pipeInterpreters[name] {
+				continue
+			}
+			for _, prev := range pipeline[:i] {
+				if src := filepath.Base(prev.argv[0]); src == "curl" || src == "wget" {
+					add(fmt.Sprintf("pipes a %s download into %s", src, name))
+				}
+			}
+		}
+	}
+	return reasons
+}
+
+func (r RiskRule) matches(argv []string) bool {
+	if r.Command != "" && !matchGlob(r.Command, argv[0]) && !matchGlob(r.Command, filepath.Base(argv[0])) {
+		return false
+	}
+	return r.Args == "" || matchGlob(r.Args, strings.Join(argv[1:], " "))
+}
+
+func (r RiskRule) reason() string {
+	if r.Reason != "" {
+		return r.Reason
+	}
+	desc := r.Command
+	if r.Args != "" {
+		desc += " " + r.Args
+	}
+	return fmt.Sprintf("%s risk rule: %s", r.Scope, desc)
+}
+
+// simpleCommand is one program invocation after unwrapping prefixes.
+type simpleCommand struct {
+	argv []string
+	sudo bool
+	// xargs marks commands whose trailing arguments come from stdin.
+	xargs bool
+	// dir is where the command runs after any cd earlier in its script.
+	dir workdir
+}
+
+// workdir is a directory a parsed script has moved to. It is unknown after a
+// cd the classifier cannot follow: to a variable, ~, -, or a relative path
+// from an unknown directory.
+type workdir struct {
+	path    string
+	unknown bool
+}
+
+func (w workdir) cd(target string) workdir {
+	switch {
+	case target == "" || target == "-" || strings.ContainsAny(target, "$`~*?["):
+		return workdir{unknown: true}
+	case filepath.IsAbs(target):
+		return workdir{path: filepath.Clean(target)}
+	case w.unknown || w.path == "":
+		return workdir{unknown: true}
+	}
+	return workdir{path: filepath.Join(w.path, target)}
+}
+
+// cdTarget reports whether stage changes directory and where to; a bare cd
+// goes home, which counts as unknown.
+func cdTarget(stage []string) (string, bool) {
+	cmd, ok := unwrap(stage)
+	if !ok {
+		return "", false
+	}
+	if name := cmd.argv[0]; name != "cd" && name != "pushd" {
+		return "", false
+	}
+	if targets := operands(cmd.argv[1:]); len(targets) > 0 {
+		return targets[0], true
+	}
+	return "", true
+}
+
+// maxShellDepth bounds nested `sh -c` parsing.
+const maxShellDepth = 4
+
+// expandCommand turns argv, run in dir, into pipelines of simple commands,
+// descending into shell scripts passed with -c. A cd in a script moves the
+// directory of the commands after it; a nested script starts where its
+// parent was and its own cds stay inside it.
+func expandCommand(argv []string, depth int, dir workdir) [][]simpleCommand {
+	cmd, ok := unwrap(argv)
+	if !ok {
+		return nil
+	}
+	cmd.dir = dir
+	name := filepath.Base(cmd.argv[0])
+	if shells[name] && depth < maxShellDepth {
+		if script, ok := shellScript(cmd.argv[1:]); ok {
+			var out [][]simpleCommand
+			for _, pipeline := range parseScript(script) {
+				var expanded []simpleCommand
+				for _, stage := range pipeline {
+					if target, ok := cdTarget(stage); ok {
+						dir = dir.cd(target)
+						continue
+					}
+					inner := expandCommand(stage, depth+1, dir)
+					// A nested script keeps its own pipelines; a simple stage
+					// stays in this one so download pipes are still seen.
+					if len(inner) == 1 {
+						for _, c := range inner[0] {
+							c.sudo = c.sudo || cmd.sudo
+							expanded = append(expanded, c)
+						}
+						continue
+					}
+					out = append(out, inner...)
+				}
+				if len(expanded) > 0 {
+					out = append(out, expanded)
+				}
+			}
+			return out
+		}
+	}
+	return [][]simpleCommand{{cmd}}
+}
+
+// shellScript returns the -c argument of a shell invocation.
+func shellScript(args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-o" || arg == "+o":
+			i++
+		case strings.HasPrefix(arg, "--") || strings.HasPrefix(arg, "+"):
+		case !strings.HasPrefix(arg, "-") || arg == "-":
+			return "", false
+		case strings.Contains(arg[1:], "c") && i+1 < len(args):
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// unwrap strips variable assignments and wrappers such as sudo, env, nice,
+// timeout, and xargs, returning the command that actually runs.
+func unwrap(argv []string) (simpleCommand, bool) {
+	var cmd simpleCommand
+	for len(argv) > 0 {
+		if isAssignment(argv[0]) {
+			argv = argv[1:]
+			continue
+		}
+		switch filepath.Base(argv[0]) {
+		case "sudo", "doas":
+			cmd.sudo = true
+			argv = skipFlags(argv[1:], "ugCpgrtUDh")
+		case "env":
+			argv = skipFlags(argv[1:], "uCS")
+		case "nice":
+			argv = skipFlags(argv[1:], "n")
+		case "timeout":
+			argv = skipFlags(argv[1:], "sk")
+			if len(argv) > 0 {
+				argv = argv[1:] // duration
+			}
+		case "nohup", "time", "exec", "command", "builtin", "stdbuf", "ionice":
+			argv = skipFlags(argv[1:], "")
+		case "xargs":
+			cmd.xargs = true
+			argv = skipFlags(argv[1:], "IiLlnPdEsa")
+		default:
+			cmd.argv = argv
+			return cmd, true
+		}
+	}
+	return cmd, false
+}
+
+// skipFlags drops leading flags; letters in withValue take the next argument
+// unless the value is attached (-uroot).
+func skipFlags(args []string, withValue string) []string {
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return args[1:]
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return args
+		}
+		args = args[1:]
+		if strings.HasPrefix(arg, "--") {
+			continue
+		}
+		if letter := arg[len(arg)-1:]; len(arg) == 2 && strings.Contains(withValue, letter) && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	return args
+}
+
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// builtinRisk applies the rules every pfui session gets.
+func builtinRisk(cmd simpleCommand, req Request) string {
+	argv := cmd.argv
+	name := filepath.Base(argv[0])
+	args := argv[1:]
+	via := ""
+	if cmd.sudo {
+		via = " (via sudo)"
+	}
+	if hasSubstitution(argv[0]) {
+		return "runs a command named by a command substitution" + via
+	}
+	if shells[name] || pipeInterpreters[name] || substitutionRunners[name] {
+		for _, arg := range args {
+			if hasSubstitution(arg) {
+				return fmt.Sprintf("%s runs code from a command or process substitution%s", name, via)
+			}
+		}
+	}
+	switch {
+	case name == "rm":
+		if !hasFlag(args, "r", "recursive") && !hasFlag(args, "R", "") {
+			return ""
+		}
+		if cmd.xargs {
+			return "rm -r of paths read by xargs" + via
+		}
+		for _, target := range operands(args) {
+			if !insideRoot(target, cmd.dir, req) {
+				return fmt.Sprintf("rm -r of %s outside the project%s", target, via)
+			}
+		}
+		if cmd.sudo {
+			return "recursive rm via sudo"
+		}
+	case name == "git":
+		sub, rest := gitSubcommand(args)
+		switch sub {
+		case "push":
+			// Short flags bundle, as in -fu.
+			if hasFlag(rest, "f", "force") {
+				return "git push --force rewrites remote history"
+			}
+			if hasFlag(rest, "d", "delete") {
+				return "git push --delete deletes remote branches"
+			}
+			for _, arg := range rest {
+				if strings.HasPrefix(arg, "--force-with-lease") ||
+					(strings.HasPrefix(arg, "+") && len(arg) > 1) || arg == "--mirror" {
+					return "git push " + arg + " rewrites or deletes remote history"
+				}
+				if strings.HasPrefix(arg, ":") && len(arg) > 1 {
+					return "git push " + arg + " deletes a remote branch"
+				}
+			}
+		case "reset":
+			if hasLong(rest, "hard") {
+				return "git reset --hard discards uncommitted changes"
+			}
+		case "clean":
+			if hasFlag(rest, "f", "force") {
+				return "git clean -f deletes untracked files"
+			}
+		}
+	case name == "dd":
+		return "dd writes raw data" + via
+	case name == "mkfs" || strings.HasPrefix(name, "mkfs.") || name == "wipefs" || name == "mkswap":
+		return name + " formats a device" + via
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		if hasFlag(args, "R", "recursive") {
+			return name + " -R changes permissions recursively" + via
+		}
+	}
+	if subs, ok := publishCommands[name]; ok {
+		for _, words := range subcommandCandidates(args) {
+			joined := strings.Join(words, " ")
+			for _, sub := range subs {
+				if joined == sub || strings.HasPrefix(joined, sub+" ") {
+					return fmt.Sprintf("%s %s publishes a package", name, sub)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// subcommandCandidates returns the operand lists a subcommand could start
+// from. Global flags come first, and a flag may take the word after it as
+// its value (npm --tag beta publish), so every operand that follows only
+// flags and possible flag values is a candidate.
+func subcommandCandidates(args []string) [][]string {
+	var out [][]string
+	afterFlag := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return append(out, args[i+1:])
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			afterFlag = !strings.Contains(arg, "=")
+			continue
+		}
+		out = append(out, operands(args[i:]))
+		if !afterFlag {
+			return out
+		}
+		afterFlag = false
+	}
+	return out
+}
+
+// hasSubstitution reports a $(...), backtick, or <(...) substitution in word.
+func hasSubstitution(word string) bool {
+	return strings.Contains(word, "$(") || strings.Contains(word, "`") || strings.Contains(word, "<(")
+}
+
+// hasFlag reports a short flag (also inside bundles like -rf) or a long one.
+func hasFlag(args []string, short, long string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if long != "" && (arg == "--"+long || strings.HasPrefix(arg, "--"+long+"=")) {
+			return true
+		}
+		if short != "" && strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg[1:], short) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLong(args []string, long string) bool {
+	return hasFlag(args, "", long)
+}
+
+// operands returns the non-flag arguments (everything after --).
+func operands(args []string) []string {
+	var out []string
+	flags := true
+	for _, arg := range args {
+		if flags && arg == "--" {
+			flags = false
+			continue
+		}
+		if flags && strings.HasPrefix(arg, "-") && arg != "-" {
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// gitSubcommand skips global options such as -C dir and -c key=value.
+func gitSubcommand(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-C" || arg == "-c" || arg == "--git-dir" || arg == "--work-tree":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			return arg, args[i+1:]
+		}
+	}
+	return "", nil
+}
+
+// insideRoot reports whether target, run from dir, resolves beneath the
+// project root. Unexpanded variables, home paths, globs reaching above the
+// root, and relative paths from an unknown directory count as outside.
+func insideRoot(target string, dir workdir, req Request) bool {
+	if req.Root == "" || strings.ContainsAny(target, "$`~") {
+		return false
+	}
+	path := target
+	if !filepath.IsAbs(path) {
+		if dir.unknown || dir.path == "" {
+			return false
+		}
+		path = filepath.Join(dir.path, path)
+	}
+	rel, err := filepath.Rel(filepath.Clean(req.Root), filepath.Clean(path))
+	if err != nil || rel == "." {
+		// Deleting the project itself is as bad as deleting outside it.
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// parseScript splits a shell script into pipelines of argv words. It handles
+// quoting, escapes, the control operators ; & && || | and newlines, and
+// treats $(...), backtick, and <(...) or >(...) substitutions as scripts of
+// their own, keeping their text in the word they appear in. It is a
+// classifier's view of the script, not an interpreter: anything it cannot
+// follow is left as plain words.
+func parseScript(script string) [][][]string {
+	var (
+		pipelines [][][]string
+		pipeline  [][]string
+		words     []string
+		cur       strings.Builder
+		inWord    bool
+		quote     rune
+	)
+	endWord := func() {
+		if inWord {
+			words = append(words, cur.String())
+			cur.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			pipeline = append(pipeline, words)
+			words = nil
+		}
+	}
+	endPipeline := func() {
+		endCommand()
+		if len(pipeline) > 0 {
+			pipelines = append(pipelines, pipeline)
+			pipeline = nil
+		}
+	}
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\' && i+1 < len(runes):
+			i++
+			if runes[i] != '\n' {
+				cur.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '(' && quote != '\'':
+			end := matchParen(runes, i+1)
+			pipelines = append(pipelines, parseScript(string(runes[i+2:end]))...)
+			cur.WriteString(string(runes[i:min(end+1, len(runes))]))
+			inWord = true
+			i = end
+		case r == '`':
+			end := i + 1
+			for end < len(runes) && runes[end] != '`' {
+				end++
+			}
+			pipelines = append(pipelines, parseScript(string(runes[i+1:end]))...)
+			cur.WriteString(string(runes[i:min(end+1, len(runes))]))
+			inWord = true
+			i = end
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == '#' && !inWord:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			endPipeline()
+		case r == '|':
+			if i+1 < len(runes) && runes[i+1] == '|' {
+				i++
+				endPipeline()
+			} else {
+				endCommand()
+			}
+		case r == ';' || r == '\n' || r == '&' || r == '(' || r == ')' || r == '{' && !inWord || r == '}' && !inWord:
+			if r == '&' && i+1 < len(runes) && runes[i+1] == '&' {
+				i++
+			}
+			endPipeline()
+		case (r == '<' || r == '>') && i+1 < len(runes) && runes[i+1] == '(' && quote == 0:
+			end := matchParen(runes, i+1)
+			pipelines = append(pipelines, parseScript(string(runes[i+2:end]))...)
+			cur.WriteString(string(runes[i:min(end+1, len(runes))]))
+			inWord = true
+			i = end
+		case r == '>' || r == '<':
+			// Drop redirections and their target (2>&1, > file, <<EOF).
+			endWord()
+			if len(words) > 0 && isDigits(words[len(words)-1]) {
+				words = words[:len(words)-1]
+			}
+			for i+1 < len(runes) && strings.ContainsRune("<>&", runes[i+1]) {
+				i++
+			}
+			for i+1 < len(runes) && (runes[i+1] == ' ' || runes[i+1] == '\t') {
+				i++
+			}
+			for i+1 < len(runes) && !strings.ContainsRune(" \t\n;&|()", runes[i+1]) {
+				i++
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	endPipeline()
+	return pipelines
+}
+
+// matchParen returns the index of the ) closing the ( at open.
+func matchParen(runes []rune, open int) int {
+	depth := 0
+	for i := open; i < len(runes); i++ {
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(runes)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,291 @@
+// Package audit keeps a tamper-evident, append-only log of what the agent
+// did: every tool request, approval decision, command start and end, and
+// provider request. Each JSONL entry carries the hash of the one before it,
+// so editing or dropping a line breaks the chain and Verify reports where.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the log's name inside the pfui home directory.
+const FileName = "audit.jsonl"
+
+// maxEntryBytes bounds a single line when reading the log back.
+const maxEntryBytes = 16 << 20
+
+// Kind identifies what an entry records.
+type Kind string
+
+const (
+	KindToolRequest     Kind = "tool_request"
+	KindApproval        Kind = "approval"
+	KindToolStart       Kind = "tool_start"
+	KindToolEnd         Kind = "tool_end"
+	KindProviderRequest Kind = "provider_request"
+)
+
+// FileChange is a file a command or file edit created, modified, deleted,
+// or moved.
+type FileChange struct {
+	Path string `json:"path"`
+	Op   string `json:"op"`
+	// From is where a moved file came from.
+	From string `json:"from,omitempty"`
+}
+
+// Entry is one line of the log. Prev and Hash chain it to its predecessor:
+// Hash is the SHA-256 of the entry encoded with Hash empty.
+type Entry struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id,omitempty"`
+	Kind      Kind      `json:"kind"`
+
+	// Tool calls.
+	CallID  string   `json:"call_id,omitempty"`
+	Tool    string   `json:"tool,omitempty"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	Workdir string   `json:"workdir,omitempty"`
+	JobID   string   `json:"job_id,omitempty"`
+	// Reason is the model's stated reason on requests and the decision's
+	// reason on approvals.
+	Reason    string `json:"reason,omitempty"`
+	Decision  string `json:"decision,omitempty"`
+	DecidedBy string `json:"decided_by,omitempty"`
+	// Risks says why a command was flagged as risky.
+	Risks  []string `json:"risks,omitempty"`
+	Status string   `json:"status,omitempty"`
+	// ExitCode is a pointer so a clean exit still shows up as 0.
+	ExitCode     *int         `json:"exit_code,omitempty"`
+	OutputSHA256 string       `json:"output_sha256,omitempty"`
+	OutputBytes  int          `json:"output_bytes,omitempty"`
+	Files        []FileChange `json:"files,omitempty"`
+
+	// Provider requests.
+	Provider     string `json:"provider,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Messages     int    `json:"messages,omitempty"`
+	InputTokens  int    `json:"input_tokens,omitempty"`
+	OutputTokens int    `json:"output_tokens,omitempty"`
+
+	Error string `json:"error,omitempty"`
+	Prev  string `json:"prev"`
+	Hash  string `json:"hash"`
+}
+
+// computeHash returns the chain hash of e (ignoring its current Hash).
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends entries to a JSONL file. Appends are serialized within the
+// process and, on unix, across processes with an advisory lock, so several
+// pfui sessions can share one chain.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultPath resolves $PFUI_HOME/audit.jsonl or ~/.pfui/audit.jsonl.
+func DefaultPath() (string, error) {
+	if custom := os.Getenv("PFUI_HOME"); custom != "" {
+		return filepath.Join(custom, FileName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".pfui", FileName), nil
+}
+
+// Open returns a log writing to path, creating its directory if needed.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("ensuring audit dir: %w", err)
+	}
+	return &Log{path: path}, nil
+}
+
+// Path returns the log file.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append chains e onto the log and returns it as written.
+func (l *Log) Append(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return e, fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return e, fmt.Errorf("locking audit log: %w", err)
+	}
+	defer unlockFile(f)
+
+	last, err := lastEntry(f)
+	if err != nil {
+		return e, err
+	}
+	e.Seq = last.Seq + 1
+	e.Prev = last.Hash
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	if e.Hash, err = e.computeHash(); err != nil {
+		return e, fmt.Errorf("encoding audit entry: %w", err)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e, fmt.Errorf("encoding audit entry: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return e, fmt.Errorf("writing audit log: %w", err)
+	}
+	return e, nil
+}
+
+// lastEntry reads the final line of f by scanning backwards from the end.
+// An empty file yields the zero entry (Seq 0, no hash).
+func lastEntry(f *os.File) (Entry, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return Entry{}, fmt.Errorf("reading audit log: %w", err)
+	}
+	end := info.Size()
+	var tail []byte
+	const block = 64 * 1024
+	for pos := end; pos > 0; {
+		start := max(pos-block, 0)
+		buf := make([]byte, pos-start)
+		if _, err := f.ReadAt(buf, start); err != nil && !errors.Is(err, io.EOF) {
+			return Entry{}, fmt.Errorf("reading audit log: %w", err)
+		}
+		tail = append(buf, tail...)
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 || start == 0 {
+			line := trimmed[i+1:]
+			if len(line) == 0 {
+				return Entry{}, nil
+			}
+			var e Entry
+			if err := json.Unmarshal(line, &e); err != nil {
+				return Entry{}, fmt.Errorf("audit log tail is corrupt (run pfui audit verify): %w", err)
+			}
+			return e, nil
+		}
+		if len(tail) > maxEntryBytes {
+			return Entry{}, errors.New("audit log tail is corrupt (run pfui audit verify): last line too long")
+		}
+		pos = start
+	}
+	return Entry{}, nil
+}
+
+// ChainError reports the first entry that does not verify.
+type ChainError struct {
+	Line   int
+	Seq    int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit log broken at line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// Verify walks the log at path and checks every sequence number, back link,
+// and hash. It returns the number of entries checked; a broken chain is
+// reported as a *ChainError. A missing log verifies as empty.
+func Verify(path string) (int, error) {
+	count := 0
+	var prev Entry
+	err := scan(path, func(line int, raw []byte) error {
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return &ChainError{Line: line, Seq: prev.Seq + 1, Reason: "not valid JSON: " + err.Error()}
+		}
+		switch {
+		case e.Seq != prev.Seq+1:
+			return &ChainError{Line: line, Seq: e.Seq, Reason: fmt.Sprintf("expected seq %d", prev.Seq+1)}
+		case e.Prev != prev.Hash:
+			return &ChainError{Line: line, Seq: e.Seq, Reason: "previous hash does not match (entry missing or reordered)"}
+		}
+		want, err := e.computeHash()
+		if err != nil {
+			return err
+		}
+		if want != e.Hash {
+			return &ChainError{Line: line, Seq: e.Seq, Reason: "hash mismatch (entry was modified)"}
+		}
+		prev = e
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// Read returns the entries for which keep returns true (all when keep is nil).
+func Read(path string, keep func(Entry) bool) ([]Entry, error) {
+	var out []Entry
+	err := scan(path, func(line int, raw []byte) error {
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return fmt.Errorf("audit log line %d: %w", line, err)
+		}
+		if keep == nil || keep(e) {
+			out = append(out, e)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func scan(path string, fn func(line int, raw []byte) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxEntryBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		if err := fn(line, raw); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+func TestAppendChainsAndVerifies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	log, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := log.Append(Entry{Kind: KindToolRequest, Command: "ls"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := log.Append(Entry{Kind: KindApproval, Command: "ls", Decision: "allow", DecidedBy: "mode"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Seq != 1 || second.Seq != 2 || second.Prev != first.Hash || first.Prev != "" {
+		t.Fatalf("entries not chained: %+v %+v", first, second)
+	}
+	n, err := Verify(path)
+	if err != nil || n != 2 {
+		t.Fatalf("Verify = %d, %v", n, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	log, _ := Open(path)
+	for _, cmd := range []string{"ls", "rm", "cat"} {
+		if _, err := log.Append(Entry{Kind: KindToolRequest, Command: cmd}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	edited := strings.Replace(string(data), `"command":"rm"`, `"command":"rn"`, 1)
+	if err := os.WriteFile(path, []byte(edited), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var chain *ChainError
+	if _, err := Verify(path); !errors.As(err, &chain) || chain.Line != 2 {
+		t.Fatalf("edit not detected at line 2: %v", err)
+	}
+
+	dropped := lines[0] + lines[2]
+	if err := os.WriteFile(path, []byte(dropped), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(path); !errors.As(err, &chain) || chain.Line != 2 {
+		t.Fatalf("dropped entry not detected: %v", err)
+	}
+}
+
+func TestRecorderLogsToolEndWithFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(work, "gone.txt"), []byte("x"), 0o644)
+	log, _ := Open(filepath.Join(dir, FileName))
+	rec := log.Session("sess-1")
+
+	job := toolexec.Job{ID: "job-1", CallID: "call_1", Command: "sh", Workdir: work}
+	rec.AuditStart(job)
+	os.Remove(filepath.Join(work, "gone.txt"))
+	os.WriteFile(filepath.Join(work, "new.txt"), []byte("y"), 0o644)
+	job.Status, job.ExitCode, job.Output = toolexec.JobSuccess, 0, "done"
+	rec.AuditEnd(job)
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(log.Path(), func(e Entry) bool { return e.Kind == KindToolEnd })
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Read = %v, %v", entries, err)
+	}
+	end := entries[0]
+	if end.SessionID != "sess-1" || end.CallID != "call_1" || end.ExitCode == nil || *end.ExitCode != 0 || end.OutputBytes != 4 {
+		t.Fatalf("unexpected end entry %+v", end)
+	}
+	want := []FileChange{{Path: "gone.txt", Op: "deleted"}, {Path: "new.txt", Op: "created"}}
+	if len(end.Files) != 2 || end.Files[0] != want[0] || end.Files[1] != want[1] {
+		t.Fatalf("files = %+v, want %+v", end.Files, want)
+	}
+}
+
+func TestRecorderLogsFileEditEnds(t *testing.T) {
+	dir := t.TempDir()
+	log, _ := Open(filepath.Join(dir, FileName))
+	rec := log.Session("sess-1")
+
+	req := toolexec.Request{Tool: "apply_patch", Command: "apply_patch", Args: []string{"a.go", "b.go"}, CallID: "call_2"}
+	rec.AuditFiles(req, []toolexec.FileChange{{Path: "a.go", Op: "modified"}, {Path: "b.go", Op: "moved", From: "old.go"}}, nil)
+	rec.AuditFiles(req, nil, errors.New("writing a.go: permission denied"))
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(log.Path(), func(e Entry) bool { return e.Kind == KindToolEnd })
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Read = %v, %v", entries, err)
+	}
+	done, failed := entries[0], entries[1]
+	if done.Tool != "apply_patch" || done.CallID != "call_2" || done.Status != "success" || len(done.Files) != 2 || done.Files[1] != (FileChange{Path: "b.go", Op: "moved", From: "old.go"}) {
+		t.Fatalf("unexpected end entry %+v", done)
+	}
+	if failed.Status != "failed" || failed.Error != "writing a.go: permission denied" || len(failed.Files) != 0 {
+		t.Fatalf("unexpected failed entry %+v", failed)
+	}
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxSnapshotFiles bounds how many files a working-directory snapshot
+// tracks; past it, changes to further files go unrecorded.
+const maxSnapshotFiles = 20000
+
+// skipDirs are not walked when looking for modified files.
+var skipDirs = map[string]bool{".git": true, "node_modules": true, ".hg": true, ".svn": true}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// tree maps paths relative to a root onto their size and mtime.
+type tree map[string]fileStamp
+
+func snapshotTree(root string) tree {
+	snap := make(tree)
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(snap) >= maxSnapshotFiles {
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		snap[rel] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return snap
+}
+
+// diff lists files created, modified, or deleted between t and after.
+func (t tree) diff(after tree) []FileChange {
+	var out []FileChange
+	for path, stamp := range after {
+		old, ok := t[path]
+		switch {
+		case !ok:
+			out = append(out, FileChange{Path: path, Op: "created"})
+		case old.size != stamp.size || !old.modTime.Equal(stamp.modTime):
+			out = append(out, FileChange{Path: path, Op: "modified"})
+		}
+	}
+	for path := range t {
+		if _, ok := after[path]; !ok {
+			out = append(out, FileChange{Path: path, Op: "deleted"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
@@ -0,0 +1,202 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+// Recorder writes entries for one chat session. It implements
+// toolexec.Auditor, records approval decisions, and wraps providers so every
+// completion is logged with its token counts. Append failures are kept in
+// Err rather than failing the tool call or stream that triggered them.
+type Recorder struct {
+	log     *Log
+	session string
+
+	mu        sync.Mutex
+	snapshots map[string]tree
+	err       error
+}
+
+// Session returns a recorder that tags entries with sessionID.
+func (l *Log) Session(sessionID string) *Recorder {
+	return &Recorder{log: l, session: sessionID, snapshots: make(map[string]tree)}
+}
+
+// Err returns the most recent append failure, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) append(e Entry) {
+	e.SessionID = r.session
+	if _, err := r.log.Append(e); err != nil {
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+	}
+}
+
+// AuditRequest records a tool request before it is approved.
+func (r *Recorder) AuditRequest(req toolexec.Request) {
+	r.append(Entry{
+		Kind:    KindToolRequest,
+		CallID:  req.CallID,
+		Tool:    req.Tool,
+		Command: req.Command,
+		Args:    req.Args,
+		Workdir: req.Workdir,
+		Reason:  req.Reason,
+	})
+}
+
+// Approval records a decision, who made it (rule, mode, or operator), and
+// why the command was flagged as risky, if it was.
+func (r *Recorder) Approval(req toolexec.Request, decision, decidedBy, reason string, risks []string) {
+	r.append(Entry{
+		Kind:      KindApproval,
+		CallID:    req.CallID,
+		Tool:      req.Tool,
+		Command:   req.Command,
+		Args:      req.Args,
+		Workdir:   req.Workdir,
+		Decision:  decision,
+		DecidedBy: decidedBy,
+		Reason:    reason,
+		Risks:     risks,
+	})
+}
+
+// AuditStart records a command starting and snapshots its working directory
+// so AuditEnd can list the files it touched.
+func (r *Recorder) AuditStart(job toolexec.Job) {
+	if job.Workdir != "" {
+		snap := snapshotTree(job.Workdir)
+		r.mu.Lock()
+		r.snapshots[job.ID] = snap
+		r.mu.Unlock()
+	}
+	r.append(Entry{
+		Kind:    KindToolStart,
+		CallID:  job.CallID,
+		Command: job.Command,
+		Args:    job.Args,
+		Workdir: job.Workdir,
+		JobID:   job.ID,
+	})
+}
+
+// AuditEnd records how a command ended, a hash of its captured output, and
+// the files that changed under its working directory while it ran.
+func (r *Recorder) AuditEnd(job toolexec.Job) {
+	r.mu.Lock()
+	before, ok := r.snapshots[job.ID]
+	delete(r.snapshots, job.ID)
+	r.mu.Unlock()
+	var files []FileChange
+	if ok {
+		files = before.diff(snapshotTree(job.Workdir))
+	}
+	sum := sha256.Sum256([]byte(job.Output))
+	exit := job.ExitCode
+	r.append(Entry{
+		Kind:         KindToolEnd,
+		CallID:       job.CallID,
+		Command:      job.Command,
+		Args:         job.Args,
+		Workdir:      job.Workdir,
+		JobID:        job.ID,
+		Status:       string(job.Status),
+		ExitCode:     &exit,
+		OutputSHA256: hex.EncodeToString(sum[:]),
+		OutputBytes:  len(job.Output),
+		Files:        files,
+		Error:        job.Error,
+	})
+}
+
+// AuditFiles records the end of a file edit: the files it created,
+// modified, deleted, or moved, and the error that stopped it, if any.
+func (r *Recorder) AuditFiles(req toolexec.Request, files []toolexec.FileChange, err error) {
+	entry := Entry{
+		Kind:    KindToolEnd,
+		CallID:  req.CallID,
+		Tool:    req.Tool,
+		Command: req.Command,
+		Args:    req.Args,
+		Workdir: req.Workdir,
+		Status:  string(toolexec.JobSuccess),
+	}
+	for _, file := range files {
+		entry.Files = append(entry.Files, FileChange{Path: file.Path, Op: file.Op, From: file.From})
+	}
+	if err != nil {
+		entry.Status = string(toolexec.JobFailed)
+		entry.Error = err.Error()
+	}
+	r.append(entry)
+}
+
+// WrapProvider returns p with StreamChat logged: one provider_request entry
+// per completion, written when the stream finishes.
+func (r *Recorder) WrapProvider(p provider.Provider) provider.Provider {
+	if p == nil {
+		return nil
+	}
+	return &auditedProvider{Provider: p, rec: r}
+}
+
+type auditedProvider struct {
+	provider.Provider
+	rec *Recorder
+}
+
+func (a *auditedProvider) StreamChat(ctx context.Context, req provider.ChatCompletionRequest) (<-chan provider.StreamChunk, error) {
+	entry := Entry{
+		Kind:     KindProviderRequest,
+		Provider: a.Name(),
+		Model:    req.Model,
+		Messages: len(req.Messages),
+	}
+	stream, err := a.Provider.StreamChat(ctx, req)
+	if err != nil {
+		entry.Error = err.Error()
+		a.rec.append(entry)
+		return nil, err
+	}
+	out := make(chan provider.StreamChunk)
+	go func() {
+		defer close(out)
+		recorded := false
+		record := func() {
+			if !recorded {
+				recorded = true
+				a.rec.append(entry)
+			}
+		}
+		// Record before forwarding the final chunk: callers often stop
+		// reading once they see Done.
+		for chunk := range stream {
+			if chunk.Usage != nil {
+				entry.InputTokens = chunk.Usage.InputTokens
+				entry.OutputTokens = chunk.Usage.OutputTokens
+			}
+			if chunk.Err != nil && entry.Error == "" {
+				entry.Error = chunk.Err.Error()
+			}
+			if chunk.Done || chunk.Err != nil {
+				record()
+			}
+			out <- chunk
+		}
+		record()
+	}()
+	return out, nil
+}
@@ -0,0 +1,740 @@
+// Package checkpoint snapshots files before the agent changes them so the
+// operator can roll a session back without relying on git.
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fbettag/pfui/internal/search"
+)
+
+const (
+	objectsDir   = "objects"
+	manifestsDir = "manifests"
+)
+
+const (
+	// MaxTreeFiles caps how many files a tree snapshot records; larger trees
+	// are snapshotted partially and never have files deleted on restore.
+	MaxTreeFiles = 20000
+	// MaxFileSize is the largest file a tree snapshot copies. Bigger files
+	// are listed as skipped and left alone on restore.
+	MaxFileSize = 8 << 20
+)
+
+// ErrNoCheckpoints is returned by Undo when there is nothing to revert.
+var ErrNoCheckpoints = errors.New("no checkpoints")
+
+// ConflictError is returned by Restore and Undo when reverting would
+// overwrite something the checkpointed calls did not do: a file changed
+// again after the call, or a checkpoint that was never sealed, so what its
+// call changed is unknown. Restoring with force reverts anyway.
+type ConflictError struct {
+	// Changed are paths whose contents differ from what the call left.
+	Changed []string
+	// Unsealed are checkpoints whose call did not finish recording.
+	Unsealed []int
+}
+
+func (e *ConflictError) Error() string {
+	var parts []string
+	if len(e.Changed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d files changed since the tool call (%s)", len(e.Changed), strings.Join(e.Changed, ", ")))
+	}
+	if len(e.Unsealed) > 0 {
+		ids := make([]string, 0, len(e.Unsealed))
+		for _, id := range e.Unsealed {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		parts = append(parts, fmt.Sprintf("checkpoints %s never recorded what their call changed", strings.Join(ids, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// File is one path a checkpoint covers.
+type File struct {
+	// Path is absolute.
+	Path string `json:"path"`
+	// Missing means the file did not exist; restoring deletes it.
+	Missing bool `json:"missing,omitempty"`
+	// Hash names the stored copy of the contents (SHA-256, hex).
+	Hash string      `json:"hash,omitempty"`
+	Mode fs.FileMode `json:"mode,omitempty"`
+	// Skipped files were too large or unreadable to copy; restoring leaves
+	// them as they are.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Change is a path a tool call changed, as recorded by Seal.
+type Change struct {
+	Path string `json:"path"`
+	// After hashes the contents the call left ("" when it deleted the file).
+	After string `json:"after,omitempty"`
+}
+
+// Checkpoint is the state of some files right before one tool call.
+type Checkpoint struct {
+	ID int `json:"id"`
+	// Turn is the operator prompt the tool call belonged to; /undo reverts
+	// every checkpoint of the latest turn.
+	Turn    int    `json:"turn"`
+	Tool    string `json:"tool"`
+	CallID  string `json:"call_id,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	// Root is set for tree snapshots: every file under it that no ignore
+	// file excludes was recorded, so Seal can tell which files the call
+	// created (unless Partial).
+	Root string `json:"root,omitempty"`
+	// Partial marks a tree snapshot that hit MaxTreeFiles.
+	Partial   bool      `json:"partial,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+	// Sealed is set once the call finished and Changes lists what it
+	// changed; restoring reverts only those paths.
+	Sealed  bool     `json:"sealed,omitempty"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Tree reports whether the checkpoint covers a whole directory tree.
+func (c Checkpoint) Tree() bool {
+	return c.Root != ""
+}
+
+// Result lists what a restore changed on disk.
+type Result struct {
+	// Reverted are the checkpoints that were undone, newest first.
+	Reverted []Checkpoint
+	// Restored files were written back; Removed files were deleted.
+	Restored []string
+	Removed  []string
+}
+
+// Store keeps the checkpoints of one session in a directory (normally
+// ~/.pfui/checkpoints/<session>): contents are stored once under objects/,
+// named by SHA-256, and each checkpoint is a JSON manifest under manifests/.
+// A Store is safe for concurrent use.
+type Store struct {
+	dir string
+
+	mu   sync.Mutex
+	next int
+	// hashes remembers file hashes by size and mtime so tree snapshots
+	// only read files that changed.
+	hashes map[string]cachedHash
+}
+
+type cachedHash struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// DefaultDir resolves $PFUI_HOME/checkpoints/<session> or
+// ~/.pfui/checkpoints/<session>.
+func DefaultDir(session string) (string, error) {
+	if strings.TrimSpace(session) == "" {
+		return "", errors.New("checkpoints need a session")
+	}
+	if custom := os.Getenv("PFUI_HOME"); custom != "" {
+		return filepath.Join(custom, "checkpoints", session), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".pfui", "checkpoints", session), nil
+}
+
+// Open returns the store in dir. Nothing is created until the first
+// snapshot.
+func Open(dir string) *Store {
+	return &Store{dir: dir, hashes: make(map[string]cachedHash)}
+}
+
+// Dir returns the store's directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Snapshot records paths (absolute) as they are now. Paths that do not
+// exist are recorded as missing, so restoring deletes them. meta supplies
+// the turn, tool, and summary; the stored checkpoint is returned.
+func (s *Store) Snapshot(meta Checkpoint, paths []string) (Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	meta.Root, meta.Partial = "", false
+	meta.Files = make([]File, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		file, err := s.captureFile(path, 0)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		meta.Files = append(meta.Files, file)
+	}
+	return s.save(meta)
+}
+
+// SnapshotTree records every file under root that .gitignore and
+// .pfuiignore files do not exclude, for tool calls (such as shell
+// commands) whose effects are unknown up front.
+func (s *Store) SnapshotTree(meta Checkpoint, root string) (Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	meta.Root, meta.Partial = root, false
+	meta.Files = nil
+	err = walkTree(root, func(path string) error {
+		if len(meta.Files) >= MaxTreeFiles {
+			meta.Partial = true
+			return filepath.SkipAll
+		}
+		file, err := s.captureFile(path, MaxFileSize)
+		if err != nil {
+			return err
+		}
+		meta.Files = append(meta.Files, file)
+		return nil
+	})
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return s.save(meta)
+}
+
+// walkTree calls fn for every regular file under root that no ignore file
+// excludes. Unreadable directories are skipped.
+func walkTree(root string, fn func(path string) error) error {
+	matcher := search.NewMatcher(root)
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if matcher.Ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// captureFile copies path into the object store. Files over limit (when
+// limit > 0) and files that cannot be read are marked skipped rather than
+// failing the snapshot; a limit of zero means the file must be captured.
+func (s *Store) captureFile(path string, limit int64) (File, error) {
+	file := File{Path: path}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		file.Missing = true
+		return file, nil
+	}
+	if err != nil {
+		if limit > 0 {
+			file.Skipped = true
+			return file, nil
+		}
+		return file, fmt.Errorf("checkpointing %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return file, fmt.Errorf("checkpointing %s: is a directory", path)
+	}
+	file.Mode = info.Mode().Perm()
+	if limit > 0 && info.Size() > limit {
+		file.Skipped = true
+		return file, nil
+	}
+	if cached, ok := s.hashes[path]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		if _, err := os.Stat(s.objectPath(cached.hash)); err == nil {
+			file.Hash = cached.hash
+			return file, nil
+		}
+	}
+	hash, err := s.storeObject(path)
+	if err != nil {
+		if limit > 0 && !errors.Is(err, errStore) {
+			file.Skipped = true
+			return file, nil
+		}
+		return file, fmt.Errorf("checkpointing %s: %w", path, err)
+	}
+	s.hashes[path] = cachedHash{size: info.Size(), modTime: info.ModTime(), hash: hash}
+	file.Hash = hash
+	return file, nil
+}
+
+// errStore marks failures writing the store itself, which always fail a
+// snapshot (unlike an unreadable project file).
+var errStore = errors.New("writing checkpoint store")
+
+// storeObject copies path into objects/ and returns its hash.
+func (s *Store) storeObject(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	dir := filepath.Join(s.dir, objectsDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("%w: %v", errStore, err)
+	}
+	tmp, err := os.CreateTemp(dir, ".object-*")
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errStore, err)
+	}
+	defer os.Remove(tmp.Name())
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, sum), src); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("%w: %v", errStore, err)
+	}
+	hash := hex.EncodeToString(sum.Sum(nil))
+	dest := s.objectPath(hash)
+	if _, err := os.Stat(dest); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return "", fmt.Errorf("%w: %v", errStore, err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", fmt.Errorf("%w: %v", errStore, err)
+	}
+	return hash, nil
+}
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.dir, objectsDir, hash[:2], hash)
+}
+
+func (s *Store) manifestPath(id int) string {
+	return filepath.Join(s.dir, manifestsDir, strconv.Itoa(id)+".json")
+}
+
+// save numbers cp and writes its manifest.
+func (s *Store) save(cp Checkpoint) (Checkpoint, error) {
+	if s.next == 0 {
+		existing, err := s.listLocked()
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		s.next = 1
+		if len(existing) > 0 {
+			s.next = existing[len(existing)-1].ID + 1
+		}
+	}
+	cp.ID = s.next
+	if cp.CreatedAt.IsZero() {
+		cp.CreatedAt = time.Now().UTC()
+	}
+	if err := s.writeManifest(cp); err != nil {
+		return Checkpoint{}, err
+	}
+	s.next++
+	return cp, nil
+}
+
+func (s *Store) writeManifest(cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	path := s.manifestPath(cp.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating checkpoint dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// List returns the session's checkpoints, oldest first.
+func (s *Store) List() ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked()
+}
+
+func (s *Store) listLocked() ([]Checkpoint, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, manifestsDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoints: %w", err)
+	}
+	var list []Checkpoint
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		cp, err := s.load(id)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, cp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (s *Store) load(id int) (Checkpoint, error) {
+	data, err := os.ReadFile(s.manifestPath(id))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("reading checkpoint %d: %w", id, err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return Checkpoint{}, fmt.Errorf("parsing checkpoint %d: %w", id, err)
+	}
+	return cp, nil
+}
+
+// LastTurn returns the turn of the newest checkpoint, or zero.
+func (s *Store) LastTurn() int {
+	list, err := s.List()
+	if err != nil || len(list) == 0 {
+		return 0
+	}
+	return list[len(list)-1].Turn
+}
+
+// Discard forgets a checkpoint without touching any files, e.g. when the
+// tool call it was taken for was denied.
+func (s *Store) Discard(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.manifestPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing checkpoint %d: %w", id, err)
+	}
+	return nil
+}
+
+// Seal records what the tool call behind checkpoint id changed, once the
+// call has finished. Restore only reverts those paths, and refuses when one
+// of them changed again afterwards.
+func (s *Store) Seal(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, err := s.load(id)
+	if err != nil {
+		return err
+	}
+	cp.Changes = nil
+	recorded := make(map[string]bool, len(cp.Files))
+	for _, file := range cp.Files {
+		recorded[file.Path] = true
+		if file.Skipped {
+			continue
+		}
+		now := s.currentHash(file.Path)
+		if now != file.Hash {
+			cp.Changes = append(cp.Changes, Change{Path: file.Path, After: now})
+		}
+	}
+	if cp.Tree() && !cp.Partial {
+		// Files the call created were not in the snapshot.
+		err := walkTree(cp.Root, func(path string) error {
+			if !recorded[path] {
+				cp.Changes = append(cp.Changes, Change{Path: path, After: s.currentHash(path)})
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("sealing checkpoint %d: %w", id, err)
+		}
+	}
+	cp.Sealed = true
+	return s.writeManifest(cp)
+}
+
+// currentHash hashes path ("" when absent), reusing the hash cache when
+// the size and mtime are unchanged.
+func (s *Store) currentHash(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if cached, ok := s.hashes[path]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.hash
+	}
+	hash, err := fileHash(path)
+	if err != nil {
+		return ""
+	}
+	s.hashes[path] = cachedHash{size: info.Size(), modTime: info.ModTime(), hash: hash}
+	return hash
+}
+
+// Restore puts the files back the way they were at checkpoint id, undoing
+// it and every later checkpoint (newest first), and then forgets those
+// checkpoints. Only the paths each call changed are reverted. When one of
+// them changed again since, or a checkpoint was never sealed, Restore
+// returns a *ConflictError and touches nothing unless force is set. Copies
+// stay in the store, so an interrupted restore can be repeated.
+func (s *Store) Restore(id int, force bool) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.listLocked()
+	if err != nil {
+		return Result{}, err
+	}
+	var revert []Checkpoint
+	for i := len(list) - 1; i >= 0 && list[i].ID >= id; i-- {
+		revert = append(revert, list[i])
+	}
+	if len(revert) == 0 || revert[len(revert)-1].ID != id {
+		return Result{}, fmt.Errorf("checkpoint %d not found", id)
+	}
+	if conflict := s.conflicts(revert); conflict != nil && !force {
+		return Result{}, conflict
+	}
+	before := make(map[string]string)
+	for _, cp := range revert {
+		if err := s.apply(cp, before); err != nil {
+			return Result{}, fmt.Errorf("restoring checkpoint %d: %w", cp.ID, err)
+		}
+	}
+	for _, cp := range revert {
+		if err := os.Remove(s.manifestPath(cp.ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return Result{}, fmt.Errorf("removing checkpoint %d: %w", cp.ID, err)
+		}
+	}
+	// Numbering continues from the restored checkpoint, as it would after
+	// reopening the store.
+	s.next = id
+	res := Result{Reverted: revert}
+	for path, old := range before {
+		now, _ := fileHash(path)
+		switch {
+		case now == old:
+		case now == "":
+			res.Removed = append(res.Removed, path)
+		default:
+			res.Restored = append(res.Restored, path)
+		}
+	}
+	sort.Strings(res.Restored)
+	sort.Strings(res.Removed)
+	return res, nil
+}
+
+// Undo restores the first checkpoint of the latest turn, reverting
+// everything that turn changed.
+func (s *Store) Undo(force bool) (Result, error) {
+	list, err := s.List()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(list) == 0 {
+		return Result{}, ErrNoCheckpoints
+	}
+	turn := list[len(list)-1].Turn
+	first := list[len(list)-1].ID
+	for i := len(list) - 1; i >= 0 && list[i].Turn == turn; i-- {
+		first = list[i].ID
+	}
+	return s.Restore(first, force)
+}
+
+// conflicts checks, newest checkpoint first, that every path a call
+// changed still holds what the call left, following the reverts of later
+// checkpoints in the same restore.
+func (s *Store) conflicts(revert []Checkpoint) *ConflictError {
+	conflict := &ConflictError{}
+	seen := make(map[string]bool)
+	expect := make(map[string]string)
+	for _, cp := range revert {
+		if !cp.Sealed {
+			conflict.Unsealed = append(conflict.Unsealed, cp.ID)
+			continue
+		}
+		for _, change := range cp.Changes {
+			now, ok := expect[change.Path]
+			if !ok {
+				now, _ = fileHash(change.Path)
+			}
+			if now != change.After && !seen[change.Path] {
+				seen[change.Path] = true
+				conflict.Changed = append(conflict.Changed, change.Path)
+			}
+			expect[change.Path] = cp.before(change.Path).Hash
+		}
+	}
+	if len(conflict.Changed) == 0 && len(conflict.Unsealed) == 0 {
+		return nil
+	}
+	sort.Strings(conflict.Changed)
+	return conflict
+}
+
+// before returns the recorded state of path; paths the snapshot did not
+// record were created by the call and count as missing.
+func (c Checkpoint) before(path string) File {
+	for _, file := range c.Files {
+		if file.Path == path {
+			return file
+		}
+	}
+	return File{Path: path, Missing: true}
+}
+
+// apply writes cp's files back: the paths its call changed when sealed,
+// everything it recorded otherwise. before collects each touched path's
+// hash ("" when absent) the first time it is touched, so the caller can
+// report what actually changed.
+func (s *Store) apply(cp Checkpoint, before map[string]string) error {
+	touch := func(path string) {
+		if _, ok := before[path]; !ok {
+			before[path], _ = fileHash(path)
+		}
+	}
+	if cp.Sealed {
+		for _, change := range cp.Changes {
+			touch(change.Path)
+			if err := s.revertFile(cp.before(change.Path)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	recorded := make(map[string]bool, len(cp.Files))
+	for _, file := range cp.Files {
+		recorded[file.Path] = true
+		if file.Skipped {
+			continue
+		}
+		touch(file.Path)
+		if err := s.revertFile(file); err != nil {
+			return err
+		}
+	}
+	if !cp.Tree() || cp.Partial {
+		return nil
+	}
+	// Files that appeared after a full tree snapshot were created by the
+	// tool call (or later) and go away.
+	var extra []string
+	err := walkTree(cp.Root, func(path string) error {
+		if !recorded[path] {
+			extra = append(extra, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range extra {
+		touch(path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// revertFile puts one recorded file back: deleted when it was missing,
+// rewritten when its contents differ, and chmodded when only the mode does.
+func (s *Store) revertFile(file File) error {
+	if file.Skipped {
+		return nil
+	}
+	if file.Missing {
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if current, _ := fileHash(file.Path); current == file.Hash {
+		if info, err := os.Stat(file.Path); err == nil && info.Mode().Perm() != file.Mode {
+			return os.Chmod(file.Path, file.Mode)
+		}
+		return nil
+	}
+	return s.writeFile(file)
+}
+
+// writeFile replaces file.Path with its stored copy via a temporary file.
+func (s *Store) writeFile(file File) error {
+	src, err := os.Open(s.objectPath(file.Hash))
+	if err != nil {
+		return fmt.Errorf("copy of %s is gone: %w", file.Path, err)
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file.Path), "."+filepath.Base(file.Path)+".pfui-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(file.Mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file.Path)
+}
+
+// fileHash hashes a file's contents; it returns "" when the file is absent.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
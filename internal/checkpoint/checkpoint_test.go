@@ -0,0 +1,300 @@
+package checkpoint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRestoresEditedCreatedAndDeletedFiles(t *testing.T) {
+	root := t.TempDir()
+	store := Open(filepath.Join(t.TempDir(), "session"))
+	edited := filepath.Join(root, "main.go")
+	created := filepath.Join(root, "new", "file.go")
+	deleted := filepath.Join(root, "old.go")
+	for path, content := range map[string]string{
+		edited:  "package main\n",
+		deleted: "package old\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := store.Snapshot(Checkpoint{Turn: 1, Tool: "edit_file"}, []string{edited, created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != 1 || len(first.Files) != 2 || !first.Files[1].Missing {
+		t.Fatalf("unexpected checkpoint: %+v", first)
+	}
+	for path, content := range map[string]string{
+		edited:  "package main\n\nfunc main() {}\n",
+		created: "package new\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Seal(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Snapshot(Checkpoint{Turn: 2, Tool: "apply_patch"}, []string{deleted, edited})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != 2 {
+		t.Fatalf("expected sequential IDs, got %d", second.ID)
+	}
+	os.Remove(deleted)
+	if err := os.WriteFile(edited, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Seal(second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := store.Restore(first.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(edited); string(got) != "package main\n" {
+		t.Fatalf("edited file not restored: %q", got)
+	}
+	if _, err := os.Stat(created); err == nil {
+		t.Fatal("file created after the checkpoint should be removed")
+	}
+	if got, _ := os.ReadFile(deleted); string(got) != "package old\n" {
+		t.Fatalf("deleted file not restored: %q", got)
+	}
+	if !reflect.DeepEqual(res.Restored, []string{edited, deleted}) && !reflect.DeepEqual(res.Restored, []string{deleted, edited}) {
+		t.Fatalf("unexpected restored list: %v", res.Restored)
+	}
+	if !reflect.DeepEqual(res.Removed, []string{created}) {
+		t.Fatalf("unexpected removed list: %v", res.Removed)
+	}
+	if len(res.Reverted) != 2 || res.Reverted[0].ID != 2 {
+		t.Fatalf("expected both checkpoints reverted newest first: %+v", res.Reverted)
+	}
+	if list, _ := store.List(); len(list) != 0 {
+		t.Fatalf("restored checkpoints should be dropped, got %d", len(list))
+	}
+	if _, err := store.Restore(first.ID, false); err == nil {
+		t.Fatal("restoring a dropped checkpoint should fail")
+	}
+
+	// A reopened store (as after --resume) continues numbering.
+	if _, err := store.Snapshot(Checkpoint{Turn: 3}, []string{edited}); err != nil {
+		t.Fatal(err)
+	}
+	reopened := Open(store.Dir())
+	cp, err := reopened.Snapshot(Checkpoint{Turn: 3}, []string{edited})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.ID != 2 {
+		t.Fatalf("expected ID 2 after reopening, got %d", cp.ID)
+	}
+	if reopened.LastTurn() != 3 {
+		t.Fatalf("expected last turn 3, got %d", reopened.LastTurn())
+	}
+}
+
+func TestTreeRestoreRemovesNewFilesButKeepsIgnoredOnes(t *testing.T) {
+	root := t.TempDir()
+	store := Open(filepath.Join(t.TempDir(), "session"))
+	for path, content := range map[string]string{
+		filepath.Join(root, ".gitignore"):       "build/\n",
+		filepath.Join(root, "src", "a.txt"):     "a\n",
+		filepath.Join(root, "build", "out.bin"): "old build\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cp, err := store.SnapshotTree(Checkpoint{Turn: 1, Tool: "exec"}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Tree() || cp.Partial || len(cp.Files) != 2 {
+		t.Fatalf("expected .gitignore and src/a.txt only: %+v", cp.Files)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(root, "src", "a.txt"):     "changed\n",
+		filepath.Join(root, "src", "b.txt"):     "generated\n",
+		filepath.Join(root, "build", "out.bin"): "new build\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Seal(cp.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Restore(cp.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "src", "a.txt")); string(got) != "a\n" {
+		t.Fatalf("a.txt not restored: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src", "b.txt")); err == nil {
+		t.Fatal("file created by the command should be removed")
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "build", "out.bin")); string(got) != "new build\n" {
+		t.Fatalf("ignored files must be left alone, got %q", got)
+	}
+}
+
+func TestUndoRevertsTheLatestTurn(t *testing.T) {
+	root := t.TempDir()
+	store := Open(filepath.Join(t.TempDir(), "session"))
+	path := filepath.Join(root, "notes.md")
+	if _, err := store.Undo(false); !errors.Is(err, ErrNoCheckpoints) {
+		t.Fatalf("expected ErrNoCheckpoints, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for turn, next := range []string{"v2", "v3", "v4"} {
+		cp, err := store.Snapshot(Checkpoint{Turn: 1 + turn/2}, []string{path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(next), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Seal(cp.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Turn 1 wrote v2 and v3, turn 2 wrote v4.
+	res, err := store.Undo(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "v3" || len(res.Reverted) != 1 {
+		t.Fatalf("undo should revert only turn 2, got %q after %d checkpoints", got, len(res.Reverted))
+	}
+	res, err = store.Undo(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "v1" || len(res.Reverted) != 2 {
+		t.Fatalf("undo should revert both checkpoints of turn 1, got %q after %d", got, len(res.Reverted))
+	}
+}
+
+func TestRestoreKeepsChangesMadeOutsideTheCall(t *testing.T) {
+	root := t.TempDir()
+	store := Open(filepath.Join(t.TempDir(), "session"))
+	for path, content := range map[string]string{
+		filepath.Join(root, "agent.txt"): "before\n",
+		filepath.Join(root, "mine.txt"):  "mine\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cp, err := store.SnapshotTree(Checkpoint{Turn: 1, Tool: "exec"}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "agent.txt"), []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Seal(cp.ID); err != nil {
+		t.Fatal(err)
+	}
+	// The operator keeps working after the call.
+	for path, content := range map[string]string{
+		filepath.Join(root, "mine.txt"):  "edited by hand\n",
+		filepath.Join(root, "notes.txt"): "new by hand\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.Restore(cp.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "agent.txt")); string(got) != "before\n" {
+		t.Fatalf("call's change not reverted: %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "mine.txt")); string(got) != "edited by hand\n" {
+		t.Fatalf("operator edit was overwritten: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "notes.txt")); err != nil {
+		t.Fatal("file the operator created was deleted")
+	}
+}
+
+func TestRestoreRefusesWhenTheCallsFilesChangedAgain(t *testing.T) {
+	root := t.TempDir()
+	store := Open(filepath.Join(t.TempDir(), "session"))
+	path := filepath.Join(root, "main.go")
+	if err := os.WriteFile(path, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := store.Snapshot(Checkpoint{Turn: 1, Tool: "edit_file"}, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Seal(cp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("v3 by hand"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.Undo(false)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !reflect.DeepEqual(conflict.Changed, []string{path}) {
+		t.Fatalf("expected a conflict on %s, got %v", path, err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "v3 by hand" {
+		t.Fatalf("refused restore changed the file: %q", got)
+	}
+	if _, err := store.Undo(true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "v1" {
+		t.Fatalf("forced undo did not restore: %q", got)
+	}
+
+	// Unsealed checkpoints (background jobs) also need force.
+	unsealed, err := store.SnapshotTree(Checkpoint{Turn: 2, Tool: "exec"}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Restore(unsealed.ID, false)
+	if !errors.As(err, &conflict) || !reflect.DeepEqual(conflict.Unsealed, []int{unsealed.ID}) {
+		t.Fatalf("expected an unsealed conflict, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fbettag/pfui/internal/audit"
+)
+
+func newAuditCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the tamper-evident log of agent actions",
+	}
+	cmd.AddCommand(newAuditVerifyCommand(), newAuditShowCommand())
+	return cmd
+}
+
+func newAuditVerifyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify",
+		Short: "Check that no audit entry was modified, dropped, or reordered",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := audit.DefaultPath()
+			if err != nil {
+				return err
+			}
+			n, err := audit.Verify(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %d entries, chain intact\n", path, n)
+			return nil
+		},
+	}
+}
+
+func newAuditShowCommand() *cobra.Command {
+	var (
+		session string
+		asJSON  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print audit entries, optionally for one session",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := audit.DefaultPath()
+			if err != nil {
+				return err
+			}
+			entries, err := audit.Read(path, func(e audit.Entry) bool {
+				return session == "" || strings.HasPrefix(e.SessionID, session)
+			})
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				for _, e := range entries {
+					if err := enc.Encode(e); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			if len(entries) == 0 {
+				fmt.Fprintln(out, "No audit entries.")
+				return nil
+			}
+			w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "SEQ\tTIME\tSESSION\tKIND\tDETAIL")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.Seq, e.Time.Local().Format("2006-01-02 15:04:05"),
+					shortID(e.SessionID), e.Kind, auditDetail(e))
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&session, "session", "", "Only show entries from this session (ID or prefix)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print raw JSONL entries")
+	return cmd
+}
+
+// auditDetail summarizes an entry on one line.
+func auditDetail(e audit.Entry) string {
+	command := strings.TrimSpace(e.Command + " " + strings.Join(e.Args, " "))
+	var detail string
+	switch e.Kind {
+	case audit.KindToolRequest:
+		detail = fmt.Sprintf("%s: %s", e.Tool, command)
+	case audit.KindApproval:
+		detail = fmt.Sprintf("%s by %s: %s (%s)", e.Decision, e.DecidedBy, command, e.Reason)
+		if len(e.Risks) > 0 {
+			detail += " risky: " + strings.Join(e.Risks, "; ")
+		}
+	case audit.KindToolStart:
+		detail = fmt.Sprintf("job %s: %s", shortID(e.JobID), command)
+	case audit.KindToolEnd:
+		exit := "-"
+		if e.ExitCode != nil {
+			exit = fmt.Sprint(*e.ExitCode)
+		}
+		detail = fmt.Sprintf("job %s %s exit=%s output=%dB sha256=%.12s", shortID(e.JobID), e.Status, exit, e.OutputBytes, e.OutputSHA256)
+		if e.JobID == "" {
+			// File edits end without a job or process.
+			detail = fmt.Sprintf("%s %s", e.Tool, e.Status)
+		}
+		if len(e.Files) > 0 {
+			files := make([]string, 0, len(e.Files))
+			for _, f := range e.Files {
+				if f.From != "" {
+					files = append(files, f.Op+" "+f.From+" → "+f.Path)
+					continue
+				}
+				files = append(files, f.Op+" "+f.Path)
+			}
+			detail += " files: " + strings.Join(files, ", ")
+		}
+	case audit.KindProviderRequest:
+		detail = fmt.Sprintf("%s %s messages=%d tokens in=%d out=%d", e.Provider, e.Model, e.Messages, e.InputTokens, e.OutputTokens)
+	}
+	if e.Error != "" {
+		detail += " error: " + e.Error
+	}
+	return detail
+}
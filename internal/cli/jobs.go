@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fbettag/pfui/internal/config"
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+func newJobsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "Inspect background jobs started by pfui",
+	}
+	cmd.AddCommand(newJobsListCommand(), newJobsLogsCommand(), newJobsKillCommand())
+	return cmd
+}
+
+func openJobStore() (*toolexec.Store, error) {
+	dir, err := toolexec.DefaultJobsDir()
+	if err != nil {
+		return nil, err
+	}
+	return toolexec.NewStore(dir)
+}
+
+func newJobsListCommand() *cobra.Command {
+	var session string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List persisted jobs (running ones first)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openJobStore()
+			if err != nil {
+				return err
+			}
+			jobs, err := store.Reconcile()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tSTATUS\tEXIT\tSTARTED\tSESSION\tCOMMAND")
+			shown := 0
+			for _, running := range []bool{true, false} {
+				for _, job := range jobs {
+					if (job.Status == toolexec.JobRunning) != running {
+						continue
+					}
+					if session != "" && !strings.HasPrefix(job.SessionID, session) {
+						continue
+					}
+					exit := "-"
+					if !running {
+						exit = fmt.Sprint(job.ExitCode)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", shortID(job.ID), job.Status, exit,
+						job.StartedAt.Local().Format("2006-01-02 15:04"), shortID(job.SessionID),
+						strings.TrimSpace(job.Command+" "+strings.Join(job.Args, " ")))
+					shown++
+				}
+			}
+			if shown == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No jobs.")
+				return nil
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringVar(&session, "session", "", "Only show jobs from this session (ID or prefix)")
+	return cmd
+}
+
+func newJobsLogsCommand() *cobra.Command {
+	var follow, stderr bool
+	cmd := &cobra.Command{
+		Use:   "logs ID",
+		Short: "Print a job's stdout (or stderr) log",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openJobStore()
+			if err != nil {
+				return err
+			}
+			job, err := store.Resolve(args[0])
+			if err != nil {
+				return err
+			}
+			stream := toolexec.StreamStdout
+			if stderr {
+				stream = toolexec.StreamStderr
+			}
+			f, err := os.Open(store.LogPath(job.ID, stream))
+			if err != nil {
+				return fmt.Errorf("opening log: %w", err)
+			}
+			defer f.Close()
+			out := cmd.OutOrStdout()
+			for {
+				if _, err := io.Copy(out, f); err != nil {
+					return err
+				}
+				if !follow {
+					return nil
+				}
+				if job, err = store.Load(job.ID); err != nil || job.Status != toolexec.JobRunning {
+					_, err := io.Copy(out, f)
+					return err
+				}
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-time.After(200 * time.Millisecond):
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep printing output until the job ends")
+	cmd.Flags().BoolVar(&stderr, "stderr", false, "Print the stderr log instead of stdout")
+	return cmd
+}
+
+func newJobsKillCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "kill ID",
+		Short: "Stop a running job (SIGINT, then SIGTERM, then SIGKILL)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cfgFile)
+			if err != nil {
+				return err
+			}
+			grace, _ := cfg.Exec.KillGraceDuration()
+			if grace <= 0 {
+				grace = toolexec.DefaultKillGrace
+			}
+			store, err := openJobStore()
+			if err != nil {
+				return err
+			}
+			job, err := store.Kill(args[0], grace)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Stopped job %s (%s)\n", shortID(job.ID), job.Command)
+			return nil
+		},
+	}
+}
+
+func shortID(id string) string {
+	if len(id) <= 8 {
+		return id
+	}
+	return id[:8]
+}
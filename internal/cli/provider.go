@@ -21,6 +21,12 @@ func newProviderInitCommand() *cobra.Command {
 	var adapter string
 	var host string
 	var token string
+	var endpoint string
+	var apiVersion string
+	var auth string
+	var deployments map[string]string
+	var region string
+	var profile string
 	cmd := &cobra.Command{
 		Use:   "init NAME",
 		Short: "Create a provider manifest skeleton",
@@ -32,6 +38,13 @@ func newProviderInitCommand() *cobra.Command {
 				Adapter: provider.AdapterKind(adapter),
 				Host:    host,
 				Token:   token,
+
+				Endpoint:    endpoint,
+				APIVersion:  apiVersion,
+				Auth:        auth,
+				Deployments: deployments,
+				Region:      region,
+				Profile:     profile,
 			})
 			if err != nil {
 				return err
@@ -40,8 +53,14 @@ func newProviderInitCommand() *cobra.Command {
 			return nil
 		},
 	}
-	cmd.Flags().StringVar(&adapter, "adapter", string(provider.AdapterOpenAIChat), "Adapter kind (openai-chat|openai-responses|anthropic-messages)")
+	cmd.Flags().StringVar(&adapter, "adapter", string(provider.AdapterOpenAIChat), "Adapter kind (openai-chat|openai-responses|anthropic-messages|azure-openai|gemini|bedrock)")
 	cmd.Flags().StringVar(&host, "host", "", "Provider hostname/base URL")
 	cmd.Flags().StringVar(&token, "token", "", "Bearer/API token (stored locally)")
+	cmd.Flags().StringVar(&endpoint, "endpoint", "", "Azure OpenAI resource endpoint (azure-openai)")
+	cmd.Flags().StringVar(&apiVersion, "api-version", "", "Azure OpenAI api-version (azure-openai)")
+	cmd.Flags().StringVar(&auth, "auth", "", "Azure auth mode: api-key or entra (azure-openai)")
+	cmd.Flags().StringVar(&region, "region", "", "AWS region (bedrock)")
+	cmd.Flags().StringVar(&profile, "profile", "", "AWS shared config profile (bedrock)")
+	cmd.Flags().StringToStringVar(&deployments, "deployment", nil, "Azure deployment=model mapping, repeatable (azure-openai)")
 	return cmd
 }
@@ -21,6 +21,7 @@ var (
 	cfgFile       string
 	runConfigMode bool
 	resumeID      string
+	sandboxLevel  string
 )
 
 // Execute boots the CLI.
@@ -46,12 +47,16 @@ func newRootCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&runConfigMode, "configuration", false, "Launch configuration wizard (clears scrollback)")
 	cmd.Flags().StringVar(&resumeID, "resume", "", "Resume a previous chat by UUID (omit to pick from history)")
 	cmd.Flags().Lookup("resume").NoOptDefVal = resumePickerSentinel
+	cmd.Flags().StringVar(&sandboxLevel, "sandbox", "", "Exec sandbox level for this session: read-only, workspace-write, or full")
 
 	cmd.AddCommand(
 		newExecCommand(),
 		newProviderCommand(),
 		newMCPCommand(),
 		newAuthCommand(),
+		newJobsCommand(),
+		newAuditCommand(),
+		newSandboxHelperCommand(),
 	)
 
 	return cmd
@@ -99,6 +104,7 @@ func runRoot(ctx context.Context) error {
 		ProjectPath: projectPath,
 		Providers:   providers,
 		LaunchArgs:  launchArgs,
+		Sandbox:     sandboxLevel,
 	})
 }
 
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fbettag/pfui/internal/sandbox"
+)
+
+// newSandboxHelperCommand is the hidden re-exec target the exec tool uses to
+// confine commands. It never returns on success because it execs the program.
+func newSandboxHelperCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                sandbox.HelperCommand,
+		Hidden:             true,
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := sandbox.RunHelper(args)
+			fmt.Fprintf(os.Stderr, "pfui: %v\n", err)
+			os.Exit(126)
+		},
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -17,9 +19,14 @@ const (
 
 // Config captures persisted user preferences.
 type Config struct {
-	Models    ModelConfig     `toml:"models"`
-	Providers ProvidersConfig `toml:"providers"`
-	Plan      PlanConfig      `toml:"plan"`
+	Models      ModelConfig       `toml:"models"`
+	Providers   ProvidersConfig   `toml:"providers"`
+	Plan        PlanConfig        `toml:"plan"`
+	Routing     RoutingConfig     `toml:"routing"`
+	Exec        ExecConfig        `toml:"exec"`
+	Sandbox     SandboxConfig     `toml:"sandbox"`
+	Files       FilesConfig       `toml:"files"`
+	Checkpoints CheckpointsConfig `toml:"checkpoints"`
 }
 
 // ModelConfig governs model discovery/rendering.
@@ -46,6 +53,10 @@ func Default() Config {
 			FilePath:  "PLAN.md",
 			AutoWrite: false,
 		},
+		Checkpoints: CheckpointsConfig{
+			Enabled: true,
+			Exec:    true,
+		},
 	}
 }
 
@@ -70,6 +81,184 @@ type PlanConfig struct {
 	AutoWrite bool `toml:"auto_write"`
 }
 
+// RoutingConfig maps session phases to model selectors. Each selector is either
+// a model name ("claude-4.5-sonnet"), a provider-qualified name
+// ("Claude/claude-4.1-opus"), or a catalog tag ("tag:mode=plan"). Empty values
+// leave the phase on whatever model the operator picked via /model.
+type RoutingConfig struct {
+	// Plan selects the model used while in PLAN mode.
+	Plan string `toml:"plan"`
+	// Execution selects the model used while in AUTO mode.
+	Execution string `toml:"execution"`
+	// Utility selects the cheap model used for titles, summaries, and compaction.
+	Utility string `toml:"utility"`
+}
+
+// ExecConfig tunes how the exec tool stops commands. Durations use Go syntax
+// ("3s", "10m").
+type ExecConfig struct {
+	// KillGrace is the wait between SIGINT, SIGTERM, and SIGKILL when a command is canceled.
+	KillGrace string `toml:"kill_grace"`
+	// DefaultTimeout bounds commands that do not request their own timeout; empty means none.
+	DefaultTimeout string `toml:"default_timeout"`
+	// Env controls which environment variables commands inherit.
+	Env EnvConfig `toml:"env"`
+	// MaxJobs caps concurrently running background jobs; more wait in a
+	// queue. Zero means the default (4), negative means no limit.
+	MaxJobs int `toml:"max_jobs"`
+	// KeepJobs is how many finished jobs are kept (zero means 100).
+	KeepJobs int `toml:"keep_jobs"`
+	// KeepFor is how long finished jobs are kept; empty means a week.
+	KeepFor string `toml:"keep_for"`
+	// Limits caps the resources of each command.
+	Limits LimitsConfig `toml:"limits"`
+	// AutoFollowUp starts a new model turn in AUTO mode when a background
+	// job the model started finishes, instead of waiting for the next prompt.
+	AutoFollowUp bool `toml:"auto_follow_up"`
+}
+
+// LimitsConfig caps each exec command. Empty values mean no limit.
+type LimitsConfig struct {
+	// CPUs caps CPU bandwidth in cores (needs cgroups v2 with systemd).
+	CPUs float64 `toml:"cpus"`
+	// Memory caps memory, e.g. "512M" or "4G".
+	Memory string `toml:"memory"`
+	// CPUTime caps the CPU time of each process, e.g. "30m".
+	CPUTime string `toml:"cpu_time"`
+}
+
+// EnvConfig filters the environment passed to exec commands. Patterns are
+// shell globs over variable names.
+type EnvConfig struct {
+	// Inherit lists variables passed through; empty inherits everything not denied.
+	Inherit []string `toml:"inherit"`
+	// Deny adds patterns to the built-in denylist (*_TOKEN, *_KEY, PFUI_*, ...).
+	Deny []string `toml:"deny"`
+	// Allow exempts variables from the denylist.
+	Allow []string `toml:"allow"`
+	// Set injects variables into every command.
+	Set map[string]string `toml:"set"`
+}
+
+// ProjectEnvFile holds per-project injected variables, relative to the project root.
+const ProjectEnvFile = ".pfui/env.toml"
+
+// ProjectEnv is the per-project env file: a [set] table of injected
+// variables plus optional extra deny patterns.
+type ProjectEnv struct {
+	Deny []string          `toml:"deny"`
+	Set  map[string]string `toml:"set"`
+}
+
+// LoadProjectEnv reads projectRoot/.pfui/env.toml; a missing file yields an empty ProjectEnv.
+func LoadProjectEnv(projectRoot string) (ProjectEnv, error) {
+	var env ProjectEnv
+	if projectRoot == "" {
+		return env, nil
+	}
+	path := filepath.Join(projectRoot, ProjectEnvFile)
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return env, nil
+	}
+	if err != nil {
+		return env, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := toml.Unmarshal(raw, &env); err != nil {
+		return env, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return env, nil
+}
+
+// SandboxConfig confines exec tool commands (Linux only).
+type SandboxConfig struct {
+	// Level is read-only, workspace-write, or full; empty picks workspace-write where supported.
+	Level string `toml:"level"`
+	// IsolateNetwork blocks internet access for sandboxed commands.
+	IsolateNetwork bool `toml:"isolate_network"`
+	// WritablePaths lists extra directories writable under workspace-write (~ expands).
+	WritablePaths []string `toml:"writable_paths"`
+}
+
+// FilesConfig scopes the built-in file tools.
+type FilesConfig struct {
+	// AllowedDirs lists directories outside the project the file tools may
+	// use (~ expands).
+	AllowedDirs []string `toml:"allowed_dirs"`
+}
+
+// CheckpointsConfig governs the file snapshots behind /undo and /restore.
+type CheckpointsConfig struct {
+	// Enabled snapshots touched files before every file edit (default true).
+	Enabled bool `toml:"enabled"`
+	// Exec also snapshots the project tree (minus ignored files) before
+	// exec commands that may change files (default true).
+	Exec bool `toml:"exec"`
+}
+
+// KillGraceDuration parses KillGrace, returning zero when unset.
+func (c ExecConfig) KillGraceDuration() (time.Duration, error) {
+	return parseDuration("exec.kill_grace", c.KillGrace)
+}
+
+// DefaultTimeoutDuration parses DefaultTimeout, returning zero when unset.
+func (c ExecConfig) DefaultTimeoutDuration() (time.Duration, error) {
+	return parseDuration("exec.default_timeout", c.DefaultTimeout)
+}
+
+// KeepForDuration parses KeepFor, returning zero when unset.
+func (c ExecConfig) KeepForDuration() (time.Duration, error) {
+	return parseDuration("exec.keep_for", c.KeepFor)
+}
+
+// MemoryBytes parses Memory ("512M", "4G", or plain bytes), returning zero when unset.
+func (c LimitsConfig) MemoryBytes() (int64, error) {
+	raw := strings.ToUpper(strings.TrimSpace(c.Memory))
+	if raw == "" {
+		return 0, nil
+	}
+	raw = strings.TrimSuffix(strings.TrimSuffix(raw, "B"), "I")
+	shift := 0
+	switch {
+	case strings.HasSuffix(raw, "K"):
+		shift = 10
+	case strings.HasSuffix(raw, "M"):
+		shift = 20
+	case strings.HasSuffix(raw, "G"):
+		shift = 30
+	case strings.HasSuffix(raw, "T"):
+		shift = 40
+	}
+	if shift > 0 {
+		raw = raw[:len(raw)-1]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("parsing exec.limits.memory: %q is not a size like 512M or 4G", c.Memory)
+	}
+	return int64(n * float64(int64(1)<<shift)), nil
+}
+
+// CPUTimeDuration parses CPUTime, returning zero when unset.
+func (c LimitsConfig) CPUTimeDuration() (time.Duration, error) {
+	return parseDuration("exec.limits.cpu_time", c.CPUTime)
+}
+
+func parseDuration(key, raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", key, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("parsing %s: duration must not be negative", key)
+	}
+	return d, nil
+}
+
 // DefaultPath resolves ~/.pfui/config.toml (creating the directory if necessary).
 func DefaultPath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -110,6 +299,24 @@ func Load(path string) (Config, error) {
 		cfg.Models.ProviderWhitelist = map[string][]string{}
 	}
 	cfg.Plan = normalizePlanConfig(cfg.Plan)
+	if _, err := cfg.Exec.KillGraceDuration(); err != nil {
+		return cfg, err
+	}
+	if _, err := cfg.Exec.DefaultTimeoutDuration(); err != nil {
+		return cfg, err
+	}
+	if _, err := cfg.Exec.KeepForDuration(); err != nil {
+		return cfg, err
+	}
+	if _, err := cfg.Exec.Limits.MemoryBytes(); err != nil {
+		return cfg, err
+	}
+	if _, err := cfg.Exec.Limits.CPUTimeDuration(); err != nil {
+		return cfg, err
+	}
+	if cfg.Exec.Limits.CPUs < 0 {
+		return cfg, errors.New("parsing exec.limits.cpus: must not be negative")
+	}
 	return cfg, nil
 }
 
@@ -195,4 +402,44 @@ const exampleConfig = `# pfui configuration
 # storage = "memory"
 # file_path = "PLAN.md"
 # auto_write = false
+
+# Route models per phase: PLAN mode, AUTO mode, and cheap utility calls
+# (titles, summaries, compaction). Selectors accept a model name, a
+# provider-qualified name, or a catalog tag. Use /route to inspect or override.
+#
+# [routing]
+# plan = "tag:tier=opus"
+# execution = "tag:mode=execution"
+# utility = "claude-4.5-haiku"
+
+# [exec] controls how the exec tool stops commands. Canceled or timed-out
+# commands receive SIGINT, then SIGTERM, then SIGKILL across their whole process
+# group, waiting kill_grace between each step. default_timeout applies to
+# commands that do not set their own timeout.
+#
+# [exec]
+# kill_grace = "2s"
+# default_timeout = "10m"
+#
+# [exec.env] filters the environment exec commands inherit. Built-in deny
+# patterns (*_TOKEN, *_KEY, *_SECRET, *_PASSWORD, *_CREDENTIALS, PFUI_*, ...)
+# always apply; deny adds more, allow exempts names, inherit switches to an
+# allowlist, and set injects values. Projects can add a [set] table (and deny
+# patterns) in .pfui/env.toml.
+#
+# [exec.env]
+# inherit = ["PATH", "HOME", "LANG", "TERM", "GO*"]
+# deny = ["DATABASE_URL"]
+# allow = ["GITHUB_TOKEN"]
+# set = { CI = "1" }
+
+# [sandbox] confines exec commands on Linux with Landlock, seccomp, and
+# namespaces. Levels: "read-only" (no writes), "workspace-write" (project root,
+# temp dirs, and writable_paths), or "full" (unconfined). Switch per session
+# with /sandbox or --sandbox.
+#
+# [sandbox]
+# level = "workspace-write"
+# isolate_network = true
+# writable_paths = ["~/.cache/go-build", "~/go/pkg/mod"]
 `
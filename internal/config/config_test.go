@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadMissingFileReturnsDefaults(t *testing.T) {
@@ -44,6 +45,103 @@ enabled = false
 	}
 }
 
+func TestLoadParsesRouting(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+	err := os.WriteFile(path, []byte(`[routing]
+plan = "tag:tier=opus"
+utility = "claude-4.5-haiku"
+`), 0o644)
+	if err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Routing.Plan != "tag:tier=opus" || cfg.Routing.Utility != "claude-4.5-haiku" {
+		t.Fatalf("unexpected routing config: %#v", cfg.Routing)
+	}
+	if cfg.Routing.Execution != "" {
+		t.Fatalf("expected empty execution route, got %q", cfg.Routing.Execution)
+	}
+}
+
+func TestLoadParsesExecDurations(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+	if err := os.WriteFile(path, []byte("[exec]\nkill_grace = \"500ms\"\ndefault_timeout = \"5m\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if d, _ := cfg.Exec.KillGraceDuration(); d != 500*time.Millisecond {
+		t.Fatalf("unexpected kill grace %s", d)
+	}
+	if d, _ := cfg.Exec.DefaultTimeoutDuration(); d != 5*time.Minute {
+		t.Fatalf("unexpected default timeout %s", d)
+	}
+
+	if err := os.WriteFile(path, []byte("[exec]\nkill_grace = \"soon\"\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatalf("expected invalid duration to fail")
+	}
+}
+
+func TestLoadParsesJobLimits(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.toml")
+	body := "[exec]\nmax_jobs = 2\nkeep_for = \"12h\"\n[exec.limits]\ncpus = 1.5\nmemory = \"512M\"\ncpu_time = \"10m\"\n"
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.Exec.MaxJobs != 2 || cfg.Exec.Limits.CPUs != 1.5 {
+		t.Fatalf("unexpected exec config %+v", cfg.Exec)
+	}
+	if n, _ := cfg.Exec.Limits.MemoryBytes(); n != 512<<20 {
+		t.Fatalf("unexpected memory limit %d", n)
+	}
+	if d, _ := cfg.Exec.KeepForDuration(); d != 12*time.Hour {
+		t.Fatalf("unexpected keep_for %s", d)
+	}
+	if n, _ := (LimitsConfig{Memory: "4GiB"}).MemoryBytes(); n != 4<<30 {
+		t.Fatalf("unexpected 4GiB = %d", n)
+	}
+	if _, err := (LimitsConfig{Memory: "lots"}).MemoryBytes(); err == nil {
+		t.Fatalf("expected invalid size to fail")
+	}
+}
+
+func TestLoadProjectEnv(t *testing.T) {
+	root := t.TempDir()
+	env, err := LoadProjectEnv(root)
+	if err != nil || len(env.Set) != 0 {
+		t.Fatalf("expected empty env for missing file, got %+v (%v)", env, err)
+	}
+	path := filepath.Join(root, ProjectEnvFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("deny = [\"DATABASE_URL\"]\n[set]\nGOFLAGS = \"-mod=mod\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env, err = LoadProjectEnv(root)
+	if err != nil {
+		t.Fatalf("LoadProjectEnv: %v", err)
+	}
+	if env.Set["GOFLAGS"] != "-mod=mod" || len(env.Deny) != 1 {
+		t.Fatalf("unexpected project env %+v", env)
+	}
+}
+
 func TestSaveExampleWritesTemplate(t *testing.T) {
 	tmp := t.TempDir()
 	path := filepath.Join(tmp, "config.toml")
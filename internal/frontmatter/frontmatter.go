@@ -0,0 +1,172 @@
+// Package frontmatter reads the "---" header of Markdown definition files
+// (subagents, skills). It understands the small YAML subset those headers
+// use: "key: value" scalars (optionally quoted), "[a, b]" and "- item"
+// lists, and "|" or ">" block scalars.
+package frontmatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const delimiter = "---"
+
+// Document is a parsed file: its header fields and the Markdown after it.
+type Document struct {
+	Fields map[string]string
+	Lists  map[string][]string
+	Body   string
+}
+
+// Get returns a scalar field, or "" when it is missing.
+func (d Document) Get(key string) string {
+	return d.Fields[key]
+}
+
+// List returns a list field. A scalar is split on commas, so "tools: a, b"
+// and "tools: [a, b]" mean the same.
+func (d Document) List(key string) []string {
+	if list, ok := d.Lists[key]; ok {
+		return list
+	}
+	raw := d.Fields[key]
+	if raw == "" {
+		return nil
+	}
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Parse splits data into header and body. A file without a header is all
+// body. Keys are lowercased.
+func Parse(data []byte) (Document, error) {
+	doc := Document{Fields: make(map[string]string), Lists: make(map[string][]string)}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	if strings.TrimRight(lines[0], " \t") != delimiter {
+		doc.Body = text
+		return doc, nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], " \t") != delimiter {
+			continue
+		}
+		if err := parseHeader(lines[1:i], &doc); err != nil {
+			return doc, err
+		}
+		doc.Body = strings.TrimLeft(strings.Join(lines[i+1:], "\n"), "\n")
+		return doc, nil
+	}
+	return doc, fmt.Errorf("frontmatter is not closed with %s", delimiter)
+}
+
+// parseHeader reads the lines between the delimiters; error line numbers
+// count from the top of the file.
+func parseHeader(lines []string, doc *Document) error {
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return fmt.Errorf("line %d: unexpected indentation", i+2)
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("line %d: expected key: value", i+2)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "|" || value == ">" || value == "|-" || value == ">-":
+			var block []string
+			for i+1 < len(lines) && (strings.TrimSpace(lines[i+1]) == "" || isIndented(lines[i+1])) {
+				i++
+				block = append(block, lines[i])
+			}
+			doc.Fields[key] = joinBlock(block, value[0] == '>')
+		case value == "":
+			var items []string
+			for i+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i+1]), "- ") && isIndentedOrDash(lines[i+1]) {
+				i++
+				items = append(items, unquote(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "- "))))
+			}
+			if items != nil {
+				doc.Lists[key] = items
+			} else {
+				doc.Fields[key] = ""
+			}
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			var items []string
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(strings.TrimSpace(item)); item != "" {
+					items = append(items, item)
+				}
+			}
+			doc.Lists[key] = items
+		default:
+			doc.Fields[key] = unquote(value)
+		}
+	}
+	return nil
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+func isIndentedOrDash(line string) bool {
+	return isIndented(line) || strings.HasPrefix(line, "- ")
+}
+
+// joinBlock strips the common indentation of a block scalar; folded (">")
+// blocks join lines with spaces and keep blank lines as breaks.
+func joinBlock(lines []string, folded bool) string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		out[i] = strings.TrimRight(line, " \t")
+	}
+	text := strings.Trim(strings.Join(out, "\n"), "\n")
+	if !folded {
+		return text
+	}
+	paragraphs := strings.Split(text, "\n\n")
+	for i, p := range paragraphs {
+		paragraphs[i] = strings.Join(strings.Fields(p), " ")
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '"' && value[len(value)-1] == '"':
+			if s, err := strconv.Unquote(value); err == nil {
+				return s
+			}
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		}
+	}
+	return value
+}
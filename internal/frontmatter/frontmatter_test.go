@@ -0,0 +1,41 @@
+package frontmatter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseReadsScalarsListsAndBlocks(t *testing.T) {
+	doc, err := Parse([]byte("---\r\nname: reviewer\r\ndescription: \"Reviews diffs: style, bugs\"\r\ntools: [read_file, grep]\r\nmodels:\r\n  - a\r\n  - 'b'\r\nsystem_prompt: |\r\n  Line one.\r\n\r\n  Line two.\r\nsummary: >\r\n  folded\r\n  text\r\n---\r\n\r\n# Body\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Get("name") != "reviewer" || doc.Get("description") != "Reviews diffs: style, bugs" {
+		t.Fatalf("unexpected scalars %v", doc.Fields)
+	}
+	if got := doc.List("tools"); !reflect.DeepEqual(got, []string{"read_file", "grep"}) {
+		t.Fatalf("unexpected tools %v", got)
+	}
+	if got := doc.List("models"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("unexpected models %v", got)
+	}
+	if doc.Get("system_prompt") != "Line one.\n\nLine two." || doc.Get("summary") != "folded text" {
+		t.Fatalf("unexpected blocks %q %q", doc.Get("system_prompt"), doc.Get("summary"))
+	}
+	if doc.Body != "# Body\n" {
+		t.Fatalf("unexpected body %q", doc.Body)
+	}
+
+	if doc, err := Parse([]byte("just markdown\n")); err != nil || doc.Body != "just markdown\n" || len(doc.Fields) != 0 {
+		t.Fatalf("a file without frontmatter is all body: %+v %v", doc, err)
+	}
+	if got := (Document{Fields: map[string]string{"tools": "exec, grep"}}).List("tools"); !reflect.DeepEqual(got, []string{"exec", "grep"}) {
+		t.Fatalf("comma lists: %v", got)
+	}
+	if _, err := Parse([]byte("---\nname: x\n")); err == nil {
+		t.Fatal("expected an unclosed header to fail")
+	}
+	if _, err := Parse([]byte("---\nnot a field\n---\n")); err == nil {
+		t.Fatal("expected a malformed line to fail")
+	}
+}
@@ -20,6 +20,11 @@ type Session struct {
 	Summary   string    `json:"summary"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Checkpoints lists the IDs of the session's file checkpoints (see
+	// /checkpoints), so a resumed session can still undo them.
+	Checkpoints []int `json:"checkpoints,omitempty"`
+	// DisabledSkills lists the skills switched off with /skill.
+	DisabledSkills []string `json:"disabled_skills,omitempty"`
 }
 
 const historyFile = "history.json"
@@ -0,0 +1,285 @@
+// Package patch computes unified diffs for file edits and applies patches in
+// the unified (`diff -u`, `git diff`) and Codex (`*** Begin Patch`) formats.
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is how many unchanged lines surround each diff hunk.
+const contextLines = 3
+
+// maxEditDistance bounds the line diff search; beyond it the changed region
+// is reported as one replacement, which keeps huge rewrites cheap.
+const maxEditDistance = 4000
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	text string
+	// oldLine and newLine are 0-based positions in the old and new files.
+	oldLine, newLine int
+}
+
+// Unified returns a unified diff from old to new, labeled with oldPath and
+// newPath (use "" for a file that does not exist on that side). It returns ""
+// when the contents are equal.
+func Unified(oldPath, newPath, old, new string) string {
+	if old == new {
+		return ""
+	}
+	a := markEOF(SplitLines(old))
+	b := markEOF(SplitLines(new))
+	ops := diffLines(a, b)
+	var out strings.Builder
+	out.WriteString("--- " + label("a/", oldPath) + "\n")
+	out.WriteString("+++ " + label("b/", newPath) + "\n")
+	for _, hunk := range groupHunks(ops) {
+		writeHunk(&out, ops[hunk[0]:hunk[1]], len(a), len(b))
+	}
+	return out.String()
+}
+
+// noEOF tags a final line that lacks a newline, so adding or removing the
+// newline shows up as a change to that line.
+const noEOF = "\x00"
+
+func markEOF(lines []string, eol bool) []string {
+	if len(lines) > 0 && !eol {
+		lines[len(lines)-1] += noEOF
+	}
+	return lines
+}
+
+// Count returns how many lines a unified diff adds and removes.
+func Count(diff string) (added, removed int) {
+	lines := strings.Split(diff, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			i++ // file header
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// SplitLines splits s into lines without their "\n" terminators and reports
+// whether s ended with a newline. Carriage returns stay on the line.
+func SplitLines(s string) ([]string, bool) {
+	if s == "" {
+		return nil, false
+	}
+	eol := strings.HasSuffix(s, "\n")
+	s = strings.TrimSuffix(s, "\n")
+	return strings.Split(s, "\n"), eol
+}
+
+// JoinLines reverses SplitLines.
+func JoinLines(lines []string, eol bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	s := strings.Join(lines, "\n")
+	if eol {
+		s += "\n"
+	}
+	return s
+}
+
+func label(prefix, path string) string {
+	if path == "" {
+		return "/dev/null"
+	}
+	return prefix + path
+}
+
+// diffLines returns the edit script turning a into b. Common prefixes and
+// suffixes are trimmed before running Myers' algorithm on the rest.
+func diffLines(a, b []string) []op {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]op, 0, len(a)+len(b))
+	for i := 0; i < pre; i++ {
+		ops = append(ops, op{kind: opEqual, text: a[i], oldLine: i, newLine: i})
+	}
+	ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf], pre, pre)...)
+	for i := 0; i < suf; i++ {
+		ai, bi := len(a)-suf+i, len(b)-suf+i
+		ops = append(ops, op{kind: opEqual, text: a[ai], oldLine: ai, newLine: bi})
+	}
+	return ops
+}
+
+// myers implements the greedy O((N+M)D) shortest edit script search,
+// falling back to delete-all/insert-all past maxEditDistance.
+func myers(a, b []string, aOff, bOff int) []op {
+	n, m := len(a), len(b)
+	replace := func() []op {
+		ops := make([]op, 0, n+m)
+		for i, line := range a {
+			ops = append(ops, op{kind: opDelete, text: line, oldLine: aOff + i, newLine: bOff})
+		}
+		for j, line := range b {
+			ops = append(ops, op{kind: opInsert, text: line, oldLine: aOff + n, newLine: bOff + j})
+		}
+		return ops
+	}
+	if n == 0 || m == 0 {
+		return replace()
+	}
+	limit := min(n+m, maxEditDistance)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	found := -1
+	for d := 0; d <= limit && found < 0; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return replace()
+	}
+	// Walk the trace backwards to recover the path.
+	var rev []op
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[offset+k-1] < prev[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, op{kind: opEqual, text: a[x], oldLine: aOff + x, newLine: bOff + y})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, op{kind: opInsert, text: b[y], oldLine: aOff + x, newLine: bOff + y})
+		} else {
+			x--
+			rev = append(rev, op{kind: opDelete, text: a[x], oldLine: aOff + x, newLine: bOff + y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, op{kind: opEqual, text: a[x], oldLine: aOff + x, newLine: bOff + y})
+	}
+	ops := make([]op, len(rev))
+	for i := range rev {
+		ops[i] = rev[len(rev)-1-i]
+	}
+	return ops
+}
+
+// groupHunks returns [start, end) ranges of ops that form hunks: each change
+// plus up to contextLines of surrounding equal lines, merging hunks whose
+// context would overlap.
+func groupHunks(ops []op) [][2]int {
+	var hunks [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == opEqual {
+			continue
+		}
+		start := max(i-contextLines, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				end = min(end+contextLines, len(ops))
+				break
+			}
+			end = run
+		}
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+		i = end - 1
+	}
+	return hunks
+}
+
+func writeHunk(out *strings.Builder, ops []op, oldTotal, newTotal int) {
+	oldStart, newStart := ops[0].oldLine, ops[0].newLine
+	oldCount, newCount := 0, 0
+	for _, o := range ops {
+		if o.kind != opInsert {
+			oldCount++
+		}
+		if o.kind != opDelete {
+			newCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount, oldTotal), hunkRange(newStart, newCount, newTotal))
+	for _, o := range ops {
+		out.WriteByte(byte(o.kind))
+		out.WriteString(strings.TrimSuffix(o.text, noEOF))
+		out.WriteByte('\n')
+		if strings.HasSuffix(o.text, noEOF) {
+			out.WriteString("\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a 0-based start and count the way diff -u does: empty
+// ranges name the line before them.
+func hunkRange(start, count, total int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", min(start, total))
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,435 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Action is what a patch does to one file.
+type Action string
+
+const (
+	ActionAdd    Action = "add"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// FileChange is one file's part of a patch.
+type FileChange struct {
+	Action Action
+	Path   string
+	// MoveTo renames the file after updating it (Codex "*** Move to:").
+	MoveTo string
+	// Content is the full text of an added file.
+	Content string
+	Hunks   []Hunk
+}
+
+// Hunk replaces the context and "-" lines with the context and "+" lines.
+type Hunk struct {
+	// Anchor is a line (Codex "@@ func main() {") that precedes the hunk;
+	// matching starts after it.
+	Anchor string
+	// OldStart is the 1-based line a unified hunk claims to start at, used as
+	// a hint; zero means unknown.
+	OldStart int
+	// AtEOF requires the hunk to match at the end of the file.
+	AtEOF bool
+	// NoNewline means the patched file ends without a trailing newline.
+	NoNewline bool
+	Lines     []Line
+}
+
+// Line is one hunk line: Kind is ' ', '-', or '+'.
+type Line struct {
+	Kind byte
+	Text string
+}
+
+// Parse reads a Codex-style patch (*** Begin Patch … *** End Patch) or a
+// unified diff with one or more files.
+func Parse(text string) ([]FileChange, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var (
+		changes []FileChange
+		err     error
+	)
+	if strings.Contains(text, "*** Begin Patch") {
+		changes, err = parseCodex(text)
+	} else {
+		changes, err = parseUnified(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, errors.New("patch contains no file changes")
+	}
+	return changes, nil
+}
+
+func parseCodex(text string) ([]FileChange, error) {
+	lines := strings.Split(text, "\n")
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) != "*** Begin Patch" {
+		start++
+	}
+	var changes []FileChange
+	var cur *FileChange
+	var hunk *Hunk
+	flushHunk := func() {
+		if hunk != nil {
+			hunk.trimBlank()
+		}
+		if cur != nil && hunk != nil && len(hunk.Lines) > 0 {
+			cur.Hunks = append(cur.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() error {
+		flushHunk()
+		if cur == nil {
+			return nil
+		}
+		if cur.Action == ActionUpdate && len(cur.Hunks) == 0 && cur.MoveTo == "" {
+			return fmt.Errorf("update of %s has no hunks", cur.Path)
+		}
+		changes = append(changes, *cur)
+		cur = nil
+		return nil
+	}
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "*** End Patch":
+			err := flushFile()
+			return changes, err
+		case strings.HasPrefix(line, "*** Add File: "), strings.HasPrefix(line, "*** Update File: "), strings.HasPrefix(line, "*** Delete File: "):
+			if err := flushFile(); err != nil {
+				return nil, err
+			}
+			header, path, _ := strings.Cut(line, ": ")
+			cur = &FileChange{Path: strings.TrimSpace(path)}
+			switch header {
+			case "*** Add File":
+				cur.Action = ActionAdd
+			case "*** Update File":
+				cur.Action = ActionUpdate
+			default:
+				cur.Action = ActionDelete
+			}
+		case strings.HasPrefix(line, "*** Move to: "):
+			if cur == nil || cur.Action != ActionUpdate {
+				return nil, fmt.Errorf("line %d: Move to outside an Update File section", i+1)
+			}
+			cur.MoveTo = strings.TrimSpace(strings.TrimPrefix(line, "*** Move to: "))
+		case trimmed == "*** End of File":
+			if hunk != nil {
+				hunk.AtEOF = true
+			}
+		case cur == nil:
+			if trimmed != "" {
+				return nil, fmt.Errorf("line %d: expected a file header, got %q", i+1, line)
+			}
+		case cur.Action == ActionAdd:
+			if !strings.HasPrefix(line, "+") {
+				if trimmed == "" && i == len(lines)-1 {
+					continue
+				}
+				return nil, fmt.Errorf("line %d: added file lines must start with +", i+1)
+			}
+			cur.Content += line[1:] + "\n"
+		case cur.Action == ActionDelete:
+			if trimmed != "" {
+				return nil, fmt.Errorf("line %d: unexpected content after Delete File", i+1)
+			}
+		case strings.HasPrefix(line, "@@"):
+			flushHunk()
+			hunk = &Hunk{Anchor: strings.TrimSpace(strings.TrimPrefix(line, "@@"))}
+		default:
+			if hunk == nil {
+				hunk = &Hunk{}
+			}
+			l, ok := hunkLine(line)
+			if !ok {
+				return nil, fmt.Errorf("line %d: hunk lines must start with ' ', '-', or '+', got %q", i+1, line)
+			}
+			hunk.Lines = append(hunk.Lines, l)
+		}
+	}
+	// Tolerate a missing "*** End Patch".
+	if err := flushFile(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func parseUnified(text string) ([]FileChange, error) {
+	lines := strings.Split(text, "\n")
+	var changes []FileChange
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		oldPath := unifiedPath(lines[i][4:], "a/")
+		newPath := unifiedPath(lines[i+1][4:], "b/")
+		change := FileChange{Action: ActionUpdate, Path: oldPath}
+		switch {
+		case oldPath == "" && newPath == "":
+			return nil, fmt.Errorf("line %d: both sides are /dev/null", i+1)
+		case oldPath == "":
+			change.Action, change.Path = ActionAdd, newPath
+		case newPath == "":
+			change.Action = ActionDelete
+		case newPath != oldPath:
+			change.MoveTo = newPath
+		}
+		i += 2
+		var hunk *Hunk
+		for ; i < len(lines); i++ {
+			line := lines[i]
+			if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") ||
+				strings.HasPrefix(line, "diff ") {
+				i--
+				break
+			}
+			if strings.HasPrefix(line, "@@") {
+				h, err := parseHunkHeader(line)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", i+1, err)
+				}
+				if hunk != nil {
+					hunk.trimBlank()
+					change.Hunks = append(change.Hunks, *hunk)
+				}
+				hunk = &h
+				continue
+			}
+			if hunk == nil {
+				continue
+			}
+			if strings.HasPrefix(line, `\`) {
+				if n := len(hunk.Lines); n > 0 && hunk.Lines[n-1].Kind != '-' {
+					hunk.NoNewline = true
+				}
+				continue
+			}
+			l, ok := hunkLine(line)
+			if !ok {
+				// Trailing commentary or the next "diff --git" preamble.
+				continue
+			}
+			hunk.Lines = append(hunk.Lines, l)
+		}
+		if hunk != nil {
+			hunk.trimBlank()
+			change.Hunks = append(change.Hunks, *hunk)
+		}
+		if change.Action == ActionAdd {
+			var b strings.Builder
+			noNewline := false
+			for _, h := range change.Hunks {
+				for _, l := range h.Lines {
+					if l.Kind != '-' {
+						b.WriteString(l.Text + "\n")
+					}
+				}
+				noNewline = noNewline || h.NoNewline
+			}
+			change.Content = b.String()
+			if noNewline {
+				change.Content = strings.TrimSuffix(change.Content, "\n")
+			}
+			change.Hunks = nil
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// unifiedPath strips the a/ or b/ prefix and any tab-separated timestamp;
+// "/dev/null" becomes "".
+func unifiedPath(raw, prefix string) string {
+	path, _, _ := strings.Cut(raw, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// parseHunkHeader reads "@@ -l,s +l,s @@ section".
+func parseHunkHeader(line string) (Hunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		// A bare "@@" separator: no position hint.
+		return Hunk{}, nil
+	}
+	startText, countText, hasCount := strings.Cut(fields[1][1:], ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return Hunk{}, fmt.Errorf("bad hunk header %q", line)
+	}
+	if hasCount && countText == "0" {
+		// "-N,0" inserts after line N.
+		start++
+	}
+	return Hunk{OldStart: start}, nil
+}
+
+// trimBlank drops trailing empty lines, which separate sections rather than
+// being context.
+func (h *Hunk) trimBlank() {
+	for n := len(h.Lines); n > 0 && h.Lines[n-1] == (Line{Kind: ' '}); n-- {
+		h.Lines = h.Lines[:n-1]
+	}
+}
+
+// hunkLine splits a hunk body line. Blank lines count as empty context,
+// since editors and models often strip the leading space.
+func hunkLine(line string) (Line, bool) {
+	if line == "" {
+		return Line{Kind: ' '}, true
+	}
+	switch line[0] {
+	case ' ', '-', '+':
+		return Line{Kind: line[0], Text: line[1:]}, true
+	}
+	return Line{}, false
+}
+
+// Apply applies hunks to content in order. Each hunk must match after the
+// previous one; matching tolerates trailing and then surrounding whitespace
+// differences. Inserted lines follow the file's CRLF convention.
+func Apply(content string, hunks []Hunk) (string, error) {
+	lines, eol := SplitLines(content)
+	if content == "" {
+		eol = true
+	}
+	crlf := usesCRLF(lines)
+	cursor := 0
+	for n, h := range hunks {
+		if h.Anchor != "" {
+			at := findAnchor(lines, h.Anchor, cursor)
+			if at < 0 {
+				return "", fmt.Errorf("hunk %d: could not find the line %q", n+1, h.Anchor)
+			}
+			cursor = at + 1
+		}
+		var old, repl []string
+		for _, l := range h.Lines {
+			if l.Kind != '+' {
+				old = append(old, l.Text)
+			}
+			if l.Kind != '-' {
+				text := l.Text
+				if crlf && !strings.HasSuffix(text, "\r") {
+					text += "\r"
+				}
+				repl = append(repl, text)
+			}
+		}
+		at, err := locate(lines, old, cursor, h)
+		if err != nil {
+			return "", fmt.Errorf("hunk %d: %w", n+1, err)
+		}
+		if len(old) > 0 && at+len(old) == len(lines) && h.NoNewline {
+			eol = false
+		}
+		// Keep the file's exact text for context lines.
+		for oi, j, i := 0, 0, 0; i < len(h.Lines); i++ {
+			switch h.Lines[i].Kind {
+			case ' ':
+				repl[j] = lines[at+oi]
+				oi++
+				j++
+			case '-':
+				oi++
+			case '+':
+				j++
+			}
+		}
+		spliced := make([]string, 0, len(lines)-len(old)+len(repl))
+		spliced = append(spliced, lines[:at]...)
+		spliced = append(spliced, repl...)
+		spliced = append(spliced, lines[at+len(old):]...)
+		lines = spliced
+		cursor = at + len(repl)
+	}
+	return JoinLines(lines, eol), nil
+}
+
+// locate finds where old starts in lines, at or after cursor.
+func locate(lines, old []string, cursor int, h Hunk) (int, error) {
+	if len(old) == 0 {
+		switch {
+		case h.OldStart > 0:
+			return min(h.OldStart-1, len(lines)), nil
+		case h.Anchor != "":
+			return cursor, nil
+		default:
+			return len(lines), nil
+		}
+	}
+	for _, norm := range []func(string) string{
+		func(s string) string { return s },
+		func(s string) string { return strings.TrimRight(s, " \t\r") },
+		strings.TrimSpace,
+	} {
+		if h.AtEOF {
+			if at := len(lines) - len(old); at >= cursor && matchAt(lines, old, at, norm) {
+				return at, nil
+			}
+			continue
+		}
+		if hint := h.OldStart - 1; hint >= cursor && matchAt(lines, old, hint, norm) {
+			return hint, nil
+		}
+		for at := cursor; at+len(old) <= len(lines); at++ {
+			if matchAt(lines, old, at, norm) {
+				return at, nil
+			}
+		}
+	}
+	preview := old
+	if len(preview) > 5 {
+		preview = preview[:5]
+	}
+	return 0, fmt.Errorf("could not find the lines to replace:\n%s", strings.Join(preview, "\n"))
+}
+
+func matchAt(lines, old []string, at int, norm func(string) string) bool {
+	if at < 0 || at+len(old) > len(lines) {
+		return false
+	}
+	for i, want := range old {
+		if norm(lines[at+i]) != norm(want) {
+			return false
+		}
+	}
+	return true
+}
+
+func findAnchor(lines []string, anchor string, cursor int) int {
+	anchor = strings.TrimSpace(anchor)
+	for i := cursor; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == anchor {
+			return i
+		}
+	}
+	return -1
+}
+
+// usesCRLF reports whether most lines end with a carriage return.
+func usesCRLF(lines []string) bool {
+	crlf := 0
+	for _, line := range lines {
+		if strings.HasSuffix(line, "\r") {
+			crlf++
+		}
+	}
+	return len(lines) > 0 && crlf*2 > len(lines)
+}
@@ -0,0 +1,105 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiffRoundTrips(t *testing.T) {
+	var oldText, newText strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&oldText, "line %d\n", i)
+		switch i {
+		case 3:
+			newText.WriteString("line three\n")
+		case 15:
+		default:
+			fmt.Fprintf(&newText, "line %d\n", i)
+		}
+		if i == 18 {
+			newText.WriteString("inserted\n")
+		}
+	}
+	diff := Unified("f.txt", "f.txt", oldText.String(), newText.String())
+	want := "--- a/f.txt\n+++ b/f.txt\n" +
+		"@@ -1,6 +1,6 @@\n line 1\n line 2\n-line 3\n+line three\n line 4\n line 5\n line 6\n" +
+		"@@ -12,9 +12,9 @@\n line 12\n line 13\n line 14\n-line 15\n line 16\n line 17\n line 18\n+inserted\n line 19\n line 20\n"
+	if diff != want {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+	if added, removed := Count(diff); added != 2 || removed != 2 {
+		t.Fatalf("Count = +%d -%d", added, removed)
+	}
+
+	changes, err := Parse(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Action != ActionUpdate || changes[0].Path != "f.txt" {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	got, err := Apply(oldText.String(), changes[0].Hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != newText.String() {
+		t.Fatalf("applied diff does not reproduce the new text:\n%s", got)
+	}
+
+	if diff := Unified("f", "f", "a\nb", "a\nb\n"); !strings.Contains(diff, "-b\n\\ No newline at end of file\n+b\n") {
+		t.Fatalf("expected a newline-only change, got:\n%s", diff)
+	}
+}
+
+func TestApplyCodexPatch(t *testing.T) {
+	text := `*** Begin Patch
+*** Update File: main.go
+@@ func main() {
+-	println("hi")
++	println("hello")
+*** Add File: docs/new.md
++# New
++text
+*** Delete File: old.txt
+*** Update File: a.txt
+*** Move to: b.txt
+@@
+ keep
+-drop
+*** End of File
+*** End Patch`
+	changes, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Fatalf("expected 4 changes, got %+v", changes)
+	}
+	if changes[1].Action != ActionAdd || changes[1].Content != "# New\ntext\n" {
+		t.Fatalf("unexpected add %+v", changes[1])
+	}
+	if changes[2].Action != ActionDelete || changes[2].Path != "old.txt" {
+		t.Fatalf("unexpected delete %+v", changes[2])
+	}
+	if changes[3].MoveTo != "b.txt" || !changes[3].Hunks[0].AtEOF {
+		t.Fatalf("unexpected move %+v", changes[3])
+	}
+
+	// The anchor skips the first println; CRLF endings are kept.
+	src := "func init() {\r\n\tprintln(\"hi\")\r\n}\r\nfunc main() {\r\n\tprintln(\"hi\")\r\n}\r\n"
+	got, err := Apply(src, changes[0].Hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func init() {\r\n\tprintln(\"hi\")\r\n}\r\nfunc main() {\r\n\tprintln(\"hello\")\r\n}\r\n"; got != want {
+		t.Fatalf("got %q", got)
+	}
+
+	if got, err := Apply("keep\ndrop\nkeep\ndrop\n", changes[3].Hunks); err != nil || got != "keep\ndrop\nkeep\n" {
+		t.Fatalf("end-of-file hunk: %q, %v", got, err)
+	}
+	if _, err := Apply("other\n", changes[3].Hunks); err == nil || !strings.Contains(err.Error(), "hunk 1") {
+		t.Fatalf("expected a mismatch error, got %v", err)
+	}
+}
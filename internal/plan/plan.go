@@ -0,0 +1,141 @@
+// Package plan models the step checklist the operator and the model keep
+// for a session: the plan drawer, PLAN.md, and the update_plan tool.
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Status is where a step stands.
+type Status string
+
+const (
+	Pending    Status = "pending"
+	InProgress Status = "in_progress"
+	Done       Status = "done"
+)
+
+// ParseStatus accepts the canonical names plus common spellings such as
+// "in-progress" or "completed". Empty means pending.
+func ParseStatus(raw string) (Status, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "pending", "todo":
+		return Pending, nil
+	case "in_progress", "in-progress", "in progress", "active", "doing":
+		return InProgress, nil
+	case "done", "completed", "complete":
+		return Done, nil
+	}
+	return "", fmt.Errorf("unknown step status %q (use pending, in_progress, or done)", raw)
+}
+
+// Box returns the checkbox the drawer shows for the status.
+func (s Status) Box() string {
+	switch s {
+	case Done:
+		return "[x]"
+	case InProgress:
+		return "[~]"
+	}
+	return "[ ]"
+}
+
+// Label is the status as words, for "was ..." notes.
+func (s Status) Label() string {
+	if s == InProgress {
+		return "in progress"
+	}
+	if s == "" {
+		return string(Pending)
+	}
+	return string(s)
+}
+
+// Step is one checklist entry. Note is an optional explanation (why a step
+// is blocked, what was found) shown next to it.
+type Step struct {
+	Text   string `json:"step"`
+	Status Status `json:"status"`
+	Note   string `json:"note,omitempty"`
+}
+
+// Revision is a plan the model replaced or patched, with its reason.
+type Revision struct {
+	Steps       []Step
+	Explanation string
+}
+
+// Line renders a step for the drawer or scrollback, numbered from 1.
+func Line(index int, step Step) string {
+	line := fmt.Sprintf("%d. %s %s", index+1, step.Status.Box(), step.Text)
+	if step.Note != "" {
+		line += " — " + step.Note
+	}
+	return line
+}
+
+// Diff describes how after differs from before, one line per change:
+// "+" for added steps, "-" for removed ones, and "~" for steps whose
+// status or note changed. Steps are matched by text, so reordering alone
+// reports nothing.
+func Diff(before, after []Step) []string {
+	used := make([]bool, len(before))
+	var lines []string
+	for i, step := range after {
+		match := -1
+		for j, old := range before {
+			if !used[j] && old.Text == step.Text {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			lines = append(lines, "+ "+Line(i, step))
+			continue
+		}
+		used[match] = true
+		old := before[match]
+		if old.Status == step.Status && old.Note == step.Note {
+			continue
+		}
+		line := "~ " + Line(i, step)
+		if old.Status != step.Status {
+			line += fmt.Sprintf(" (was %s)", old.Status.Label())
+		}
+		lines = append(lines, line)
+	}
+	for j, old := range before {
+		if !used[j] {
+			lines = append(lines, fmt.Sprintf("- %s %s", old.Status.Box(), old.Text))
+		}
+	}
+	return lines
+}
+
+// Markdown renders steps as the PLAN.md checklist. In-progress steps stay
+// unchecked and are marked in words, since Markdown task lists only know
+// two states.
+func Markdown(steps []Step) string {
+	var b strings.Builder
+	b.WriteString("# Plan\n\n")
+	if len(steps) == 0 {
+		b.WriteString("_No steps yet_\n")
+		return b.String()
+	}
+	for i, step := range steps {
+		box := "[ ]"
+		if step.Status == Done {
+			box = "[x]"
+		}
+		b.WriteString(fmt.Sprintf("%d. %s %s", i+1, box, step.Text))
+		if step.Status == InProgress {
+			b.WriteString(" _(in progress)_")
+		}
+		b.WriteString("\n")
+		if step.Note != "" {
+			b.WriteString(fmt.Sprintf("   - %s\n", step.Note))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package plan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffReportsAddedRemovedAndChangedSteps(t *testing.T) {
+	before := []Step{
+		{Text: "read the code", Status: Done},
+		{Text: "write the fix", Status: InProgress},
+		{Text: "update docs", Status: Pending},
+	}
+	after := []Step{
+		{Text: "read the code", Status: Done},
+		{Text: "write the fix", Status: Done},
+		{Text: "run the tests", Status: InProgress, Note: "go test ./..."},
+	}
+	want := []string{
+		"~ 2. [x] write the fix (was in progress)",
+		"+ 3. [~] run the tests — go test ./...",
+		"- [ ] update docs",
+	}
+	if got := Diff(before, after); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff:\n got %q\nwant %q", got, want)
+	}
+	if got := Diff(after, after); len(got) != 0 {
+		t.Fatalf("identical plans should not differ, got %q", got)
+	}
+}
+
+func TestParseStatusAndMarkdown(t *testing.T) {
+	for raw, want := range map[string]Status{"": Pending, "in-progress": InProgress, "Completed": Done} {
+		if got, err := ParseStatus(raw); err != nil || got != want {
+			t.Fatalf("ParseStatus(%q) = %q, %v", raw, got, err)
+		}
+	}
+	if _, err := ParseStatus("blocked"); err == nil {
+		t.Fatal("expected an unknown status to fail")
+	}
+	md := Markdown([]Step{{Text: "a", Status: Done}, {Text: "b", Status: InProgress, Note: "halfway"}})
+	if !strings.Contains(md, "1. [x] a\n2. [ ] b _(in progress)_\n   - halfway\n") {
+		t.Fatalf("unexpected markdown:\n%s", md)
+	}
+}
@@ -98,6 +98,10 @@ func (c *Client) StartChat(ctx context.Context, opts provider.StartChatOptions)
 	return provider.NewSession("claude", opts.SessionID), nil
 }
 
+// maxTokens caps each reply. Tool inputs for write_file, apply_patch and
+// multi_edit carry whole files, so this sits well above a chat-sized answer.
+const maxTokens = 16384
+
 func (c *Client) StreamChat(ctx context.Context, req provider.ChatCompletionRequest) (<-chan provider.StreamChunk, error) {
 	if strings.TrimSpace(c.token) == "" {
 		return nil, fmt.Errorf("%s: API key missing; run pfui --configuration", c.name)
@@ -106,13 +110,30 @@ func (c *Client) StreamChat(ctx context.Context, req provider.ChatCompletionRequ
 	if model == "" {
 		model = "claude-4.5-sonnet"
 	}
+	system, messages := buildMessages(req.Messages)
 	payload := map[string]any{
-		"model": model,
-		"messages": []map[string]string{
-			{"role": "user", "content": joinContent(req.Messages)},
-		},
+		"model":      model,
+		"messages":   messages,
 		"stream":     true,
-		"max_tokens": 1024,
+		"max_tokens": maxTokens,
+	}
+	if system != "" {
+		payload["system"] = system
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			schema := tool.Parameters
+			if schema == nil {
+				schema = map[string]any{"type": "object"}
+			}
+			tools = append(tools, map[string]any{
+				"name":         tool.Name,
+				"description":  tool.Description,
+				"input_schema": schema,
+			})
+		}
+		payload["tools"] = tools
 	}
 	body, _ := json.Marshal(payload)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+"/v1/messages", bytes.NewReader(body))
@@ -136,6 +157,9 @@ func (c *Client) StreamChat(ctx context.Context, req provider.ChatCompletionRequ
 		defer resp.Body.Close()
 		defer close(ch)
 		reader := bufio.NewReader(resp.Body)
+		tools := map[int]*provider.ToolCall{}
+		var finished []provider.ToolCall
+		var usage provider.Usage
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
@@ -162,20 +186,48 @@ func (c *Client) StreamChat(ctx context.Context, req provider.ChatCompletionRequ
 				return
 			}
 			switch event.Type {
+			case "content_block_start":
+				if event.ContentBlock.Type == "tool_use" {
+					tools[event.Index] = &provider.ToolCall{ID: event.ContentBlock.ID, Name: event.ContentBlock.Name}
+				}
 			case "content_block_delta":
 				if event.Delta.Text != "" {
 					ch <- provider.StreamChunk{Content: event.Delta.Text}
 				}
+				if call := tools[event.Index]; call != nil {
+					call.Arguments += event.Delta.PartialJSON
+				}
+			case "content_block_stop":
+				if call := tools[event.Index]; call != nil {
+					delete(tools, event.Index)
+					if strings.TrimSpace(call.Arguments) == "" {
+						call.Arguments = "{}"
+					}
+					finished = append(finished, *call)
+				}
+			case "message_start":
+				usage.InputTokens = event.Message.Usage.InputTokens
 			case "message_delta":
+				usage.OutputTokens = event.Usage.OutputTokens
+				if event.Delta.StopReason == "max_tokens" && len(finished)+len(tools) > 0 {
+					ch <- provider.StreamChunk{Err: fmt.Errorf("%s: reply hit the %d token limit mid tool call; dropping the truncated call", c.name, maxTokens), Done: true}
+					return
+				}
 				if len(event.Delta.StopReason) > 0 {
-					ch <- provider.StreamChunk{Done: true}
+					if len(finished) > 0 {
+						ch <- provider.StreamChunk{ToolCalls: finished}
+					}
+					ch <- provider.StreamChunk{Usage: &usage, Done: true}
 					return
 				}
 			case "error":
 				ch <- provider.StreamChunk{Err: errors.New(event.Error.Message), Done: true}
 				return
 			case "message_stop":
-				ch <- provider.StreamChunk{Done: true}
+				if len(finished) > 0 {
+					ch <- provider.StreamChunk{ToolCalls: finished}
+				}
+				ch <- provider.StreamChunk{Usage: &usage, Done: true}
 				return
 			}
 		}
@@ -183,28 +235,87 @@ func (c *Client) StreamChat(ctx context.Context, req provider.ChatCompletionRequ
 	return ch, nil
 }
 
-func joinContent(messages []provider.ChatMessage) string {
-	if len(messages) == 0 {
-		return ""
-	}
-	var buf strings.Builder
+// buildMessages splits out the system prompt and maps the rest onto Messages
+// API content blocks. Tool calls become tool_use blocks; consecutive tool
+// results share one user turn as tool_result blocks.
+func buildMessages(messages []provider.ChatMessage) (string, []map[string]any) {
+	var system []string
+	out := make([]map[string]any, 0, len(messages))
 	for _, msg := range messages {
-		if msg.Content == "" {
-			continue
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+		case "assistant":
+			var blocks []map[string]any
+			if msg.Content != "" {
+				blocks = append(blocks, map[string]any{"type": "text", "text": msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				var input map[string]any
+				if err := json.Unmarshal([]byte(call.Arguments), &input); err != nil || input == nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, map[string]any{"type": "tool_use", "id": call.ID, "name": call.Name, "input": input})
+			}
+			if len(blocks) > 0 {
+				out = append(out, map[string]any{"role": "assistant", "content": blocks})
+			}
+		case "tool":
+			block := map[string]any{"type": "tool_result", "tool_use_id": msg.ToolCallID, "content": msg.Content}
+			if len(msg.Images) > 0 {
+				block["content"] = append([]map[string]any{{"type": "text", "text": msg.Content}}, imageBlocks(msg.Images)...)
+			}
+			if n := len(out); n > 0 && out[n-1]["role"] == "user" {
+				if blocks, ok := out[n-1]["content"].([]map[string]any); ok {
+					out[n-1]["content"] = append(blocks, block)
+					continue
+				}
+			}
+			out = append(out, map[string]any{"role": "user", "content": []map[string]any{block}})
+		default:
+			blocks := append([]map[string]any{{"type": "text", "text": msg.Content}}, imageBlocks(msg.Images)...)
+			out = append(out, map[string]any{"role": "user", "content": blocks})
 		}
-		buf.WriteString(msg.Content)
-		buf.WriteString("\n\n")
 	}
-	return strings.TrimSpace(buf.String())
+	return strings.Join(system, "\n\n"), out
+}
+
+func imageBlocks(images []provider.Image) []map[string]any {
+	blocks := make([]map[string]any, 0, len(images))
+	for _, img := range images {
+		blocks = append(blocks, map[string]any{
+			"type":   "image",
+			"source": map[string]any{"type": "base64", "media_type": img.MediaType, "data": img.Base64()},
+		})
+	}
+	return blocks
 }
 
 type anthropicEvent struct {
-	Type  string `json:"type"`
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
 	Delta struct {
-		Text       string `json:"text"`
-		StopReason string `json:"stop_reason"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
 	} `json:"delta"`
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
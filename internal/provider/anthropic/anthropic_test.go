@@ -0,0 +1,138 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+func TestStreamChatEmitsToolUse(t *testing.T) {
+	var sent map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &sent)
+		for _, line := range []string{
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"exec"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"command\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"ls\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+	}))
+	defer srv.Close()
+
+	client := New(srv.URL, "key")
+	stream, err := client.StreamChat(context.Background(), provider.ChatCompletionRequest{
+		Messages: []provider.ChatMessage{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "list files"},
+		},
+		Tools: []provider.ToolSpec{{Name: "exec", Description: "run"}},
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	var text strings.Builder
+	var calls []provider.ToolCall
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		text.WriteString(chunk.Content)
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	if text.String() != "Checking." {
+		t.Fatalf("unexpected text %q", text.String())
+	}
+	if len(calls) != 1 || calls[0].ID != "toolu_1" || calls[0].Arguments != `{"command":"ls"}` {
+		t.Fatalf("unexpected tool calls %#v", calls)
+	}
+	if sent["system"] != "be brief" {
+		t.Fatalf("system prompt not sent top-level: %#v", sent["system"])
+	}
+	if tools, ok := sent["tools"].([]any); !ok || len(tools) != 1 {
+		t.Fatalf("tools missing from payload: %#v", sent["tools"])
+	}
+}
+
+func TestBuildMessagesGroupsToolResults(t *testing.T) {
+	_, msgs := buildMessages([]provider.ChatMessage{
+		{Role: "user", Content: "go"},
+		{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "a", Name: "exec", Arguments: "{}"}, {ID: "b", Name: "exec", Arguments: "{}"}}},
+		{Role: "tool", ToolCallID: "a", Content: "one"},
+		{Role: "tool", ToolCallID: "b", Content: "two"},
+	})
+	if len(msgs) != 3 {
+		t.Fatalf("expected user/assistant/user turns, got %d", len(msgs))
+	}
+	if blocks := msgs[2]["content"].([]map[string]any); len(blocks) != 2 {
+		t.Fatalf("expected both tool results in one turn, got %#v", blocks)
+	}
+}
+
+func TestBuildMessagesSendsToolImages(t *testing.T) {
+	_, msgs := buildMessages([]provider.ChatMessage{
+		{Role: "user", Content: "look"},
+		{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "a", Name: "read_file", Arguments: "{}"}}},
+		{Role: "tool", ToolCallID: "a", Content: `{"path":"shot.png"}`, Images: []provider.Image{{MediaType: "image/png", Data: []byte("png")}}},
+	})
+	result := msgs[2]["content"].([]map[string]any)[0]
+	content, ok := result["content"].([]map[string]any)
+	if !ok || len(content) != 2 || content[1]["type"] != "image" {
+		t.Fatalf("expected text and image blocks, got %#v", result["content"])
+	}
+	source := content[1]["source"].(map[string]any)
+	if source["media_type"] != "image/png" || source["data"] != "cG5n" {
+		t.Fatalf("unexpected image source %#v", source)
+	}
+}
+
+func TestStreamChatRejectsTruncatedToolUse(t *testing.T) {
+	var sent map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &sent)
+		for _, line := range []string{
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"write_file"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"path\":\"a.go\",\"content\":\"pack"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"max_tokens"}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+		}
+	}))
+	defer srv.Close()
+
+	stream, err := New(srv.URL, "key").StreamChat(context.Background(), provider.ChatCompletionRequest{
+		Messages: []provider.ChatMessage{{Role: "user", Content: "write it"}},
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	var streamErr error
+	for chunk := range stream {
+		if len(chunk.ToolCalls) > 0 {
+			t.Fatalf("truncated tool call handed back: %#v", chunk.ToolCalls)
+		}
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+		}
+	}
+	if streamErr == nil || !strings.Contains(streamErr.Error(), "token limit") {
+		t.Fatalf("expected a token limit error, got %v", streamErr)
+	}
+	if sent["max_tokens"] != float64(maxTokens) {
+		t.Fatalf("unexpected max_tokens %#v", sent["max_tokens"])
+	}
+}
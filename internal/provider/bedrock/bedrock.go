@@ -0,0 +1,425 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+// modelAliases maps the Claude names pfui uses elsewhere onto Bedrock model
+// IDs. Bedrock serves these only through cross-region inference profiles,
+// so ModelID prefixes them with the profile for the client's region.
+var modelAliases = map[string]string{
+	"claude-4.5-sonnet": "anthropic.claude-sonnet-4-5-20250929-v1:0",
+	"claude-4.5-haiku":  "anthropic.claude-haiku-4-5-20251001-v1:0",
+	"claude-4.1-opus":   "anthropic.claude-opus-4-1-20250805-v1:0",
+}
+
+// Client calls Bedrock's ConverseStream API with SigV4-signed requests.
+type Client struct {
+	name       string
+	region     string
+	profile    string
+	endpoint   string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// New builds a Bedrock client from a provider manifest. Host overrides both the
+// runtime and control-plane endpoints, which is how tests point at a stub.
+func New(m provider.Manifest) *Client {
+	name := m.Name
+	if name == "" {
+		name = "Bedrock"
+	}
+	return &Client{
+		name:       name,
+		region:     resolveRegion(m.Region, m.Profile),
+		profile:    strings.TrimSpace(m.Profile),
+		endpoint:   strings.TrimRight(strings.TrimSpace(m.Host), "/"),
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+		now:        time.Now,
+	}
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+func (c *Client) Kind() provider.Kind {
+	return provider.KindBedrock
+}
+
+// ListModels queries ListFoundationModels for streaming text models.
+func (c *Client) ListModels(ctx context.Context) ([]provider.Model, error) {
+	endpoint := c.baseURL("bedrock") + "/foundation-models?byOutputModality=TEXT"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.sign(httpReq, nil, "bedrock"); err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s models error: %s", c.name, strings.TrimSpace(string(data)))
+	}
+	var listing struct {
+		ModelSummaries []struct {
+			ModelID                    string `json:"modelId"`
+			ModelName                  string `json:"modelName"`
+			ProviderName               string `json:"providerName"`
+			ResponseStreamingSupported bool   `json:"responseStreamingSupported"`
+		} `json:"modelSummaries"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("%s models: %w", c.name, err)
+	}
+	models := make([]provider.Model, 0, len(listing.ModelSummaries))
+	for _, s := range listing.ModelSummaries {
+		if !s.ResponseStreamingSupported {
+			continue
+		}
+		models = append(models, provider.Model{
+			Name:         s.ModelID,
+			Description:  fmt.Sprintf("%s %s via Bedrock (%s).", s.ProviderName, s.ModelName, c.region),
+			Capabilities: []string{"chat", "code", "tools"},
+			Tags:         map[string]string{"provider": strings.ToLower(s.ProviderName)},
+		})
+	}
+	return models, nil
+}
+
+func (c *Client) StartChat(ctx context.Context, opts provider.StartChatOptions) (provider.Session, error) {
+	_ = ctx
+	return provider.NewSession("bedrock", opts.SessionID), nil
+}
+
+func (c *Client) StreamChat(ctx context.Context, req provider.ChatCompletionRequest) (<-chan provider.StreamChunk, error) {
+	modelID := ModelID(req.Model, c.region)
+	body, err := json.Marshal(buildConverseRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("%s: encoding request: %w", c.name, err)
+	}
+	escaped := "/model/" + uriEncode(modelID) + "/converse-stream"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL("bedrock-runtime")+escaped, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	if err := c.sign(httpReq, body, "bedrock"); err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s converse error: %s", c.name, strings.TrimSpace(string(data)))
+	}
+	ch := make(chan provider.StreamChunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+		decoder := newEventDecoder(resp.Body)
+		tools := map[int]*pendingToolUse{}
+		var usage *provider.Usage
+		for {
+			msg, err := decoder.Next()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					ch <- provider.StreamChunk{Usage: usage, Done: true}
+				} else {
+					ch <- provider.StreamChunk{Err: err, Done: true}
+				}
+				return
+			}
+			if msg.Headers[":message-type"] == "exception" || msg.Headers[":message-type"] == "error" {
+				ch <- provider.StreamChunk{Err: streamException(msg), Done: true}
+				return
+			}
+			var event converseEvent
+			if err := json.Unmarshal(msg.Payload, &event); err != nil {
+				ch <- provider.StreamChunk{Err: err, Done: true}
+				return
+			}
+			switch msg.Headers[":event-type"] {
+			case "contentBlockStart":
+				if use := event.Start.ToolUse; use != nil {
+					tools[event.ContentBlockIndex] = &pendingToolUse{id: use.ToolUseID, name: use.Name}
+				}
+			case "contentBlockDelta":
+				if event.Delta.Text != "" {
+					ch <- provider.StreamChunk{Content: event.Delta.Text}
+				}
+				if event.Delta.ToolUse != nil {
+					if pending := tools[event.ContentBlockIndex]; pending != nil {
+						pending.input.WriteString(event.Delta.ToolUse.Input)
+					}
+				}
+			case "contentBlockStop":
+				if pending := tools[event.ContentBlockIndex]; pending != nil {
+					delete(tools, event.ContentBlockIndex)
+					args := pending.input.String()
+					if strings.TrimSpace(args) == "" {
+						args = "{}"
+					}
+					ch <- provider.StreamChunk{ToolCalls: []provider.ToolCall{{ID: pending.id, Name: pending.name, Arguments: args}}}
+				}
+			case "messageStop":
+				// Token counts follow in a metadata event; the stream ends after it.
+			case "metadata":
+				if u := event.Usage; u != nil {
+					usage = &provider.Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
+				}
+				ch <- provider.StreamChunk{Usage: usage, Done: true}
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// ModelID maps pfui model names to inference profile IDs for region. IDs
+// that already look like Bedrock identifiers (anthropic.claude-...,
+// us.anthropic..., ARNs) pass through.
+func ModelID(model, region string) string {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		model = "claude-4.5-sonnet"
+	}
+	if id, ok := modelAliases[model]; ok {
+		return profilePrefix(region) + id
+	}
+	return model
+}
+
+// profilePrefix picks the inference profile geography serving region;
+// regions outside the geographic profiles use the global one.
+func profilePrefix(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "us-gov."
+	case strings.HasPrefix(region, "us-"):
+		return "us."
+	case strings.HasPrefix(region, "eu-"):
+		return "eu."
+	case region == "ap-northeast-1" || region == "ap-northeast-3":
+		return "jp."
+	case region == "ap-southeast-2" || region == "ap-southeast-4":
+		return "au."
+	case strings.HasPrefix(region, "ap-"):
+		return "apac."
+	default:
+		return "global."
+	}
+}
+
+func (c *Client) baseURL(service string) string {
+	if c.endpoint != "" {
+		return c.endpoint
+	}
+	return fmt.Sprintf("https://%s.%s.amazonaws.com", service, c.region)
+}
+
+func (c *Client) sign(req *http.Request, body []byte, service string) error {
+	creds, err := resolveCredentials(c.profile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.name, err)
+	}
+	signRequest(req, body, creds, c.region, service, c.now())
+	return nil
+}
+
+func streamException(msg eventMessage) error {
+	kind := msg.Headers[":exception-type"]
+	if kind == "" {
+		kind = msg.Headers[":error-code"]
+	}
+	var payload struct {
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(msg.Payload, &payload)
+	if payload.Message == "" {
+		payload.Message = msg.Headers[":error-message"]
+	}
+	return fmt.Errorf("bedrock %s: %s", kind, payload.Message)
+}
+
+// buildConverseRequest maps pfui messages onto the Converse schema: system
+// prompts go to the top-level system list, tool calls become toolUse blocks,
+// and tool results are returned as toolResult blocks in a user turn.
+func buildConverseRequest(req provider.ChatCompletionRequest) converseRequest {
+	out := converseRequest{InferenceConfig: inferenceConfig{MaxTokens: 4096}}
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				out.System = append(out.System, contentBlock{Text: msg.Content})
+			}
+		case "assistant":
+			m := converseMessage{Role: "assistant"}
+			if msg.Content != "" {
+				m.Content = append(m.Content, contentBlock{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				m.Content = append(m.Content, contentBlock{ToolUse: &toolUseBlock{ToolUseID: call.ID, Name: call.Name, Input: input}})
+			}
+			if len(m.Content) > 0 {
+				out.Messages = append(out.Messages, m)
+			}
+		case "tool":
+			out.Messages = appendUserBlock(out.Messages, contentBlock{ToolResult: &toolResultBlock{
+				ToolUseID: msg.ToolCallID,
+				Content:   append([]contentBlock{{Text: msg.Content}}, imageBlocks(msg.Images)...),
+			}})
+		default:
+			if msg.Content != "" {
+				out.Messages = appendUserBlock(out.Messages, contentBlock{Text: msg.Content})
+			}
+			for _, block := range imageBlocks(msg.Images) {
+				out.Messages = appendUserBlock(out.Messages, block)
+			}
+		}
+	}
+	if len(req.Tools) > 0 {
+		cfg := &toolConfig{}
+		for _, tool := range req.Tools {
+			schema := tool.Parameters
+			if schema == nil {
+				schema = map[string]any{"type": "object"}
+			}
+			cfg.Tools = append(cfg.Tools, toolEntry{ToolSpec: toolSpec{
+				Name:        tool.Name,
+				Description: tool.Description,
+				InputSchema: map[string]any{"json": schema},
+			}})
+		}
+		out.ToolConfig = cfg
+	}
+	return out
+}
+
+// appendUserBlock merges consecutive user blocks because Converse requires
+// strictly alternating roles.
+func appendUserBlock(messages []converseMessage, block contentBlock) []converseMessage {
+	if n := len(messages); n > 0 && messages[n-1].Role == "user" {
+		messages[n-1].Content = append(messages[n-1].Content, block)
+		return messages
+	}
+	return append(messages, converseMessage{Role: "user", Content: []contentBlock{block}})
+}
+
+type pendingToolUse struct {
+	id    string
+	name  string
+	input strings.Builder
+}
+
+type converseRequest struct {
+	Messages        []converseMessage `json:"messages"`
+	System          []contentBlock    `json:"system,omitempty"`
+	ToolConfig      *toolConfig       `json:"toolConfig,omitempty"`
+	InferenceConfig inferenceConfig   `json:"inferenceConfig"`
+}
+
+type converseMessage struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Text       string           `json:"text,omitempty"`
+	Image      *imageBlock      `json:"image,omitempty"`
+	ToolUse    *toolUseBlock    `json:"toolUse,omitempty"`
+	ToolResult *toolResultBlock `json:"toolResult,omitempty"`
+}
+
+// imageBlock carries raw image bytes; encoding/json base64-encodes them as
+// Converse expects.
+type imageBlock struct {
+	Format string      `json:"format"`
+	Source imageSource `json:"source"`
+}
+
+type imageSource struct {
+	Bytes []byte `json:"bytes"`
+}
+
+func imageBlocks(images []provider.Image) []contentBlock {
+	blocks := make([]contentBlock, 0, len(images))
+	for _, img := range images {
+		format := strings.TrimPrefix(img.MediaType, "image/")
+		blocks = append(blocks, contentBlock{Image: &imageBlock{Format: format, Source: imageSource{Bytes: img.Data}}})
+	}
+	return blocks
+}
+
+type toolUseBlock struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type toolResultBlock struct {
+	ToolUseID string         `json:"toolUseId"`
+	Content   []contentBlock `json:"content"`
+}
+
+type toolConfig struct {
+	Tools []toolEntry `json:"tools"`
+}
+
+type toolEntry struct {
+	ToolSpec toolSpec `json:"toolSpec"`
+}
+
+type toolSpec struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type inferenceConfig struct {
+	MaxTokens int `json:"maxTokens"`
+}
+
+type converseEvent struct {
+	ContentBlockIndex int `json:"contentBlockIndex"`
+	Start             struct {
+		ToolUse *struct {
+			ToolUseID string `json:"toolUseId"`
+			Name      string `json:"name"`
+		} `json:"toolUse"`
+	} `json:"start"`
+	Delta struct {
+		Text    string `json:"text"`
+		ToolUse *struct {
+			Input string `json:"input"`
+		} `json:"toolUse"`
+	} `json:"delta"`
+	Usage *struct {
+		InputTokens  int `json:"inputTokens"`
+		OutputTokens int `json:"outputTokens"`
+	} `json:"usage"`
+}
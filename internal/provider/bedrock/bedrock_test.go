@@ -0,0 +1,159 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+// TestSignRequestMatchesAWSVector reproduces the get-vanilla case from the AWS SigV4 test suite.
+func TestSignRequestMatchesAWSVector(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signRequest(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("unexpected Authorization\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestConverseStreamAgainstStub(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	var gotPath, gotAuth, gotToken string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		gotToken = r.Header.Get("X-Amz-Security-Token")
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(frame("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hello"}}`))
+		w.Write(frame("contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"tu-1","name":"exec"}}}`))
+		w.Write(frame("contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"command\":"}}}`))
+		w.Write(frame("contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"\"ls\"}"}}}`))
+		w.Write(frame("contentBlockStop", `{"contentBlockIndex":1}`))
+		w.Write(frame("messageStop", `{"stopReason":"tool_use"}`))
+	}))
+	defer srv.Close()
+
+	client := New(provider.Manifest{Name: "bedrock", Adapter: provider.AdapterBedrock, Host: srv.URL, Region: "eu-central-1"})
+	stream, err := client.StreamChat(context.Background(), provider.ChatCompletionRequest{
+		Model:    "claude-4.5-sonnet",
+		Messages: []provider.ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	var text string
+	var calls []provider.ToolCall
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		text += chunk.Content
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	if text != "Hello" {
+		t.Fatalf("unexpected text %q", text)
+	}
+	if len(calls) != 1 || calls[0].ID != "tu-1" || calls[0].Arguments != `{"command":"ls"}` {
+		t.Fatalf("unexpected tool calls %#v", calls)
+	}
+	if gotPath != "/model/eu.anthropic.claude-sonnet-4-5-20250929-v1%3A0/converse-stream" {
+		t.Fatalf("unexpected path %s", gotPath)
+	}
+	if !strings.Contains(gotAuth, "Credential=AKIDTEST/") || !strings.Contains(gotAuth, "/eu-central-1/bedrock/aws4_request") {
+		t.Fatalf("unexpected Authorization %s", gotAuth)
+	}
+	if gotToken != "session" {
+		t.Fatalf("expected session token header, got %q", gotToken)
+	}
+}
+
+func TestModelIDUsesTheRegionsInferenceProfile(t *testing.T) {
+	for region, want := range map[string]string{
+		"us-east-1":      "us.anthropic.claude-haiku-4-5-20251001-v1:0",
+		"us-gov-west-1":  "us-gov.anthropic.claude-haiku-4-5-20251001-v1:0",
+		"eu-central-1":   "eu.anthropic.claude-haiku-4-5-20251001-v1:0",
+		"ap-northeast-1": "jp.anthropic.claude-haiku-4-5-20251001-v1:0",
+		"ap-southeast-2": "au.anthropic.claude-haiku-4-5-20251001-v1:0",
+		"ap-south-1":     "apac.anthropic.claude-haiku-4-5-20251001-v1:0",
+		"sa-east-1":      "global.anthropic.claude-haiku-4-5-20251001-v1:0",
+	} {
+		if got := ModelID("claude-4.5-haiku", region); got != want {
+			t.Errorf("%s: got %s, want %s", region, got, want)
+		}
+	}
+	if got := ModelID("eu.anthropic.claude-opus-4-1-20250805-v1:0", "us-east-1"); got != "eu.anthropic.claude-opus-4-1-20250805-v1:0" {
+		t.Fatalf("explicit IDs must pass through, got %s", got)
+	}
+}
+
+func TestEventDecoderRejectsCorruptFrames(t *testing.T) {
+	raw := frame("messageStop", `{}`)
+	raw[len(raw)-1] ^= 0xff
+	if _, err := newEventDecoder(bytes.NewReader(raw)).Next(); err == nil {
+		t.Fatal("expected checksum error")
+	}
+}
+
+func TestResolveCredentialsFromProfile(t *testing.T) {
+	dir := t.TempDir()
+	credsPath := filepath.Join(dir, "credentials")
+	configPath := filepath.Join(dir, "config")
+	os.WriteFile(credsPath, []byte("[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = s1\n\n[work]\naws_access_key_id = AKIDWORK\naws_secret_access_key = s2\n"), 0o600)
+	os.WriteFile(configPath, []byte("[profile work]\nregion = eu-west-1\n"), 0o600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credsPath)
+	t.Setenv("AWS_CONFIG_FILE", configPath)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_PROFILE", "")
+
+	creds, err := resolveCredentials("work")
+	if err != nil || creds.AccessKeyID != "AKIDWORK" {
+		t.Fatalf("unexpected profile credentials %#v (err %v)", creds, err)
+	}
+	creds, err = resolveCredentials("")
+	if err != nil || creds.AccessKeyID != "AKIDDEFAULT" {
+		t.Fatalf("unexpected default credentials %#v (err %v)", creds, err)
+	}
+	if region := resolveRegion("", "work"); region != "eu-west-1" {
+		t.Fatalf("expected profile region, got %s", region)
+	}
+}
+
+// frame encodes a single event-stream message with string headers.
+func frame(eventType, payload string) []byte {
+	var headers bytes.Buffer
+	for _, kv := range [][2]string{{":event-type", eventType}, {":message-type", "event"}, {":content-type", "application/json"}} {
+		headers.WriteByte(byte(len(kv[0])))
+		headers.WriteString(kv[0])
+		headers.WriteByte(7)
+		binary.Write(&headers, binary.BigEndian, uint16(len(kv[1])))
+		headers.WriteString(kv[1])
+	}
+	total := uint32(12 + headers.Len() + len(payload) + 4)
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, total)
+	binary.Write(&msg, binary.BigEndian, uint32(headers.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()[:8]))
+	msg.Write(headers.Bytes())
+	msg.WriteString(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	return msg.Bytes()
+}
@@ -0,0 +1,143 @@
+package bedrock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials are the static AWS keys used to sign requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// resolveCredentials follows the AWS SDK order: an explicit profile reads the
+// shared files, otherwise AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY win before
+// falling back to AWS_PROFILE (or "default") in the shared files.
+func resolveCredentials(profile string) (Credentials, error) {
+	if profile == "" {
+		env := Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if env.AccessKeyID != "" && env.SecretAccessKey != "" {
+			return env, nil
+		}
+		profile = envProfile()
+	}
+	for _, source := range []struct {
+		path    string
+		section string
+	}{
+		{sharedCredentialsPath(), profile},
+		{sharedConfigPath(), configSection(profile)},
+	} {
+		values, err := readINISection(source.path, source.section)
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds := Credentials{
+			AccessKeyID:     values["aws_access_key_id"],
+			SecretAccessKey: values["aws_secret_access_key"],
+			SessionToken:    values["aws_session_token"],
+		}
+		if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+			return creds, nil
+		}
+	}
+	return Credentials{}, fmt.Errorf("no AWS credentials for profile %q; export AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY or run `aws configure export-credentials` for SSO profiles", profile)
+}
+
+// resolveRegion prefers the manifest, then AWS_REGION/AWS_DEFAULT_REGION, then the profile config.
+func resolveRegion(region, profile string) string {
+	if region = strings.TrimSpace(region); region != "" {
+		return region
+	}
+	for _, key := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	if profile == "" {
+		profile = envProfile()
+	}
+	values, err := readINISection(sharedConfigPath(), configSection(profile))
+	if err == nil && values["region"] != "" {
+		return values["region"]
+	}
+	return "us-east-1"
+}
+
+func envProfile() string {
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+func configSection(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+	return "profile " + profile
+}
+
+func sharedCredentialsPath() string {
+	if p := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); p != "" {
+		return p
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".aws", "credentials")
+}
+
+func sharedConfigPath() string {
+	if p := os.Getenv("AWS_CONFIG_FILE"); p != "" {
+		return p
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".aws", "config")
+}
+
+// readINISection returns the key/value pairs of one section. A missing file
+// yields an empty map so callers can fall through to the next source.
+func readINISection(path, section string) (map[string]string, error) {
+	values := map[string]string{}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	defer f.Close()
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			continue
+		}
+		if current != section {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,109 @@
+package bedrock
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// maxEventSize bounds a single event-stream message (AWS caps them at 16 MiB).
+const maxEventSize = 16 << 20
+
+// eventMessage is one decoded application/vnd.amazon.eventstream frame.
+type eventMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// eventDecoder reads AWS event-stream binary framing:
+//
+//	total length (4) | headers length (4) | prelude CRC (4) | headers | payload | message CRC (4)
+type eventDecoder struct {
+	r io.Reader
+}
+
+func newEventDecoder(r io.Reader) *eventDecoder {
+	return &eventDecoder{r: r}
+}
+
+// Next returns the next message or io.EOF when the stream ends cleanly.
+func (d *eventDecoder) Next() (eventMessage, error) {
+	var prelude [12]byte
+	if _, err := io.ReadFull(d.r, prelude[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return eventMessage{}, fmt.Errorf("event stream: truncated prelude")
+		}
+		return eventMessage{}, err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[0:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return eventMessage{}, fmt.Errorf("event stream: prelude checksum mismatch")
+	}
+	if total < 16 || total > maxEventSize || headersLen > total-16 {
+		return eventMessage{}, fmt.Errorf("event stream: invalid frame lengths (total %d, headers %d)", total, headersLen)
+	}
+	rest := make([]byte, total-12)
+	if _, err := io.ReadFull(d.r, rest); err != nil {
+		return eventMessage{}, fmt.Errorf("event stream: truncated message: %w", err)
+	}
+	body := rest[:len(rest)-4]
+	crc := crc32.NewIEEE()
+	crc.Write(prelude[:])
+	crc.Write(body)
+	if crc.Sum32() != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+		return eventMessage{}, fmt.Errorf("event stream: message checksum mismatch")
+	}
+	headers, err := decodeHeaders(body[:headersLen])
+	if err != nil {
+		return eventMessage{}, err
+	}
+	return eventMessage{Headers: headers, Payload: body[headersLen:]}, nil
+}
+
+// decodeHeaders parses typed headers. Only string values are kept; other types
+// are skipped because Bedrock routes events purely on string headers.
+func decodeHeaders(raw []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(raw) > 0 {
+		nameLen := int(raw[0])
+		if len(raw) < 1+nameLen+1 {
+			return nil, fmt.Errorf("event stream: truncated header name")
+		}
+		name := string(raw[1 : 1+nameLen])
+		typ := raw[1+nameLen]
+		raw = raw[2+nameLen:]
+		var size int
+		switch typ {
+		case 0, 1: // bool true / false
+			size = 0
+		case 2: // byte
+			size = 1
+		case 3: // int16
+			size = 2
+		case 4: // int32
+			size = 4
+		case 5, 8: // int64, timestamp
+			size = 8
+		case 9: // uuid
+			size = 16
+		case 6, 7: // byte array, string
+			if len(raw) < 2 {
+				return nil, fmt.Errorf("event stream: truncated header %s", name)
+			}
+			size = 2 + int(binary.BigEndian.Uint16(raw[:2]))
+		default:
+			return nil, fmt.Errorf("event stream: unknown header type %d for %s", typ, name)
+		}
+		if len(raw) < size {
+			return nil, fmt.Errorf("event stream: truncated header %s", name)
+		}
+		if typ == 7 {
+			headers[name] = string(raw[2:size])
+		}
+		raw = raw[size:]
+	}
+	return headers, nil
+}
@@ -0,0 +1,396 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+const defaultHost = "https://generativelanguage.googleapis.com"
+
+// Client speaks the Generative Language API (streamGenerateContent over SSE).
+type Client struct {
+	host       string
+	token      string
+	name       string
+	httpClient *http.Client
+}
+
+// New builds a Client for the provided host/API key.
+func New(host, token string) *Client {
+	return newClient(host, token, "Gemini")
+}
+
+// NewWithName lets callers override the provider label (used for custom manifests).
+func NewWithName(host, token, name string) *Client {
+	return newClient(host, token, name)
+}
+
+func newClient(host, token, name string) *Client {
+	if host == "" {
+		host = defaultHost
+	}
+	if name == "" {
+		name = "Gemini"
+	}
+	return &Client{
+		host:       strings.TrimRight(host, "/"),
+		token:      token,
+		name:       name,
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+func (c *Client) Kind() provider.Kind {
+	return provider.KindGemini
+}
+
+// ListModels pages through models.list and keeps models that support generateContent.
+func (c *Client) ListModels(ctx context.Context) ([]provider.Model, error) {
+	if strings.TrimSpace(c.token) == "" {
+		return nil, fmt.Errorf("%s: API key missing", c.name)
+	}
+	var out []provider.Model
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"100"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+"/v1beta/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("x-goog-api-key", c.token)
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		var page modelsPage
+		err = decodeResponse(resp, &page)
+		if err != nil {
+			return nil, fmt.Errorf("%s models error: %w", c.name, err)
+		}
+		for _, m := range page.Models {
+			if !supports(m.SupportedGenerationMethods, "generateContent") {
+				continue
+			}
+			tags := map[string]string{}
+			if m.InputTokenLimit > 0 {
+				tags["context"] = strconv.Itoa(m.InputTokenLimit)
+			}
+			desc := m.Description
+			if desc == "" {
+				desc = m.DisplayName
+			}
+			out = append(out, provider.Model{
+				Name:         strings.TrimPrefix(m.Name, "models/"),
+				Description:  desc,
+				Capabilities: []string{"chat", "code", "tools"},
+				Tags:         tags,
+			})
+		}
+		if page.NextPageToken == "" {
+			return out, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+func (c *Client) StartChat(ctx context.Context, opts provider.StartChatOptions) (provider.Session, error) {
+	_ = ctx
+	return provider.NewSession("gemini", opts.SessionID), nil
+}
+
+func (c *Client) StreamChat(ctx context.Context, req provider.ChatCompletionRequest) (<-chan provider.StreamChunk, error) {
+	if strings.TrimSpace(c.token) == "" {
+		return nil, fmt.Errorf("%s: API key missing; set token in the manifest or GEMINI_API_KEY", c.name)
+	}
+	model := strings.TrimPrefix(req.Model, "models/")
+	if model == "" {
+		model = "gemini-2.5-pro"
+	}
+	body, err := json.Marshal(buildRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("%s: encoding request: %w", c.name, err)
+	}
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?alt=sse", c.host, url.PathEscape(model))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("x-goog-api-key", c.token)
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s generate error: %s", c.name, strings.TrimSpace(string(data)))
+	}
+	ch := make(chan provider.StreamChunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ch)
+		reader := bufio.NewReader(resp.Body)
+		calls := 0
+		var usage *provider.Usage
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				if err == io.EOF {
+					ch <- provider.StreamChunk{Usage: usage, Done: true}
+				} else {
+					ch <- provider.StreamChunk{Err: err}
+				}
+				return
+			}
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+			payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if payload == "" {
+				continue
+			}
+			var event generateResponse
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				ch <- provider.StreamChunk{Err: err, Done: true}
+				return
+			}
+			if event.Error.Message != "" {
+				ch <- provider.StreamChunk{Err: errors.New(event.Error.Message), Done: true}
+				return
+			}
+			if u := event.UsageMetadata; u != nil {
+				// Each chunk carries running totals; the last one wins.
+				usage = &provider.Usage{InputTokens: u.PromptTokenCount, OutputTokens: u.CandidatesTokenCount}
+			}
+			for _, cand := range event.Candidates {
+				for _, part := range cand.Content.Parts {
+					if part.Text != "" {
+						ch <- provider.StreamChunk{Content: part.Text}
+					}
+					if part.FunctionCall != nil {
+						calls++
+						ch <- provider.StreamChunk{ToolCalls: []provider.ToolCall{toToolCall(part.FunctionCall, calls)}}
+					}
+				}
+				if cand.FinishReason != "" && cand.FinishReason != "STOP" && cand.FinishReason != "MAX_TOKENS" {
+					ch <- provider.StreamChunk{Err: fmt.Errorf("gemini stopped: %s", cand.FinishReason), Done: true}
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// buildRequest maps pfui chat messages onto Gemini contents. System messages
+// become systemInstruction, assistant turns use the "model" role, and tool
+// results are sent back as functionResponse parts.
+func buildRequest(req provider.ChatCompletionRequest) generateRequest {
+	var out generateRequest
+	var system []string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+		case "assistant":
+			content := geminiContent{Role: "model"}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, geminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				content.Parts = append(content.Parts, geminiPart{FunctionCall: &functionCall{
+					ID:   call.ID,
+					Name: call.Name,
+					Args: parseArgs(call.Arguments),
+				}})
+			}
+			if len(content.Parts) > 0 {
+				out.Contents = append(out.Contents, content)
+			}
+		case "tool":
+			// Every result of one parallel call turn belongs in a single content.
+			out.Contents = appendUserParts(out.Contents, append([]geminiPart{{FunctionResponse: &functionResponse{
+				ID:       msg.ToolCallID,
+				Name:     msg.Name,
+				Response: map[string]any{"content": msg.Content},
+			}}}, imageParts(msg.Images)...)...)
+		default:
+			if msg.Content == "" {
+				continue
+			}
+			out.Contents = append(out.Contents, geminiContent{
+				Role:  "user",
+				Parts: append([]geminiPart{{Text: msg.Content}}, imageParts(msg.Images)...),
+			})
+		}
+	}
+	if len(system) > 0 {
+		out.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: strings.Join(system, "\n\n")}}}
+	}
+	if len(req.Tools) > 0 {
+		decls := make([]functionDeclaration, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			decls = append(decls, functionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			})
+		}
+		out.Tools = []geminiTool{{FunctionDeclarations: decls}}
+	}
+	return out
+}
+
+// appendUserParts adds parts to a trailing user content, or starts one.
+func appendUserParts(contents []geminiContent, parts ...geminiPart) []geminiContent {
+	if n := len(contents); n > 0 && contents[n-1].Role == "user" {
+		contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+		return contents
+	}
+	return append(contents, geminiContent{Role: "user", Parts: parts})
+}
+
+func toToolCall(fc *functionCall, seq int) provider.ToolCall {
+	args := "{}"
+	if len(fc.Args) > 0 {
+		if data, err := json.Marshal(fc.Args); err == nil {
+			args = string(data)
+		}
+	}
+	id := fc.ID
+	if id == "" {
+		id = fmt.Sprintf("gemini-call-%d", seq)
+	}
+	return provider.ToolCall{ID: id, Name: fc.Name, Arguments: args}
+}
+
+func parseArgs(raw string) map[string]any {
+	args := map[string]any{}
+	if strings.TrimSpace(raw) == "" {
+		return args
+	}
+	_ = json.Unmarshal([]byte(raw), &args)
+	return args
+}
+
+func supports(methods []string, want string) bool {
+	for _, m := range methods {
+		if m == want {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeResponse(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return errors.New(strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, v)
+}
+
+type generateRequest struct {
+	Contents          []geminiContent `json:"contents"`
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool    `json:"tools,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string            `json:"text,omitempty"`
+	InlineData       *inlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *functionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *functionResponse `json:"functionResponse,omitempty"`
+}
+
+type inlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+func imageParts(images []provider.Image) []geminiPart {
+	parts := make([]geminiPart, 0, len(images))
+	for _, img := range images {
+		parts = append(parts, geminiPart{InlineData: &inlineData{MimeType: img.MediaType, Data: img.Base64()}})
+	}
+	return parts
+}
+
+type functionCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+type functionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []functionDeclaration `json:"functionDeclarations"`
+}
+
+type functionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type generateResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+	} `json:"usageMetadata"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type modelsPage struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		DisplayName                string   `json:"displayName"`
+		Description                string   `json:"description"`
+		InputTokenLimit            int      `json:"inputTokenLimit"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
@@ -0,0 +1,126 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+func TestStreamChatMapsRolesAndToolCalls(t *testing.T) {
+	var got generateRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-pro:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.Header.Get("x-goog-api-key") != "key" {
+			t.Errorf("missing api key header")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Running\"}]}}]}\n\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"functionCall\":{\"name\":\"exec\",\"args\":{\"command\":\"ls\"}}}]},\"finishReason\":\"STOP\"}]}\n\n")
+	}))
+	defer srv.Close()
+
+	client := New(srv.URL, "key")
+	stream, err := client.StreamChat(context.Background(), provider.ChatCompletionRequest{
+		Model: "gemini-2.5-pro",
+		Messages: []provider.ChatMessage{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "list files"},
+			{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "1", Name: "exec", Arguments: `{"command":"pwd"}`}}},
+			{Role: "tool", ToolCallID: "1", Name: "exec", Content: "/tmp"},
+		},
+		Tools: []provider.ToolSpec{{Name: "exec", Description: "run", Parameters: map[string]any{"type": "object"}}},
+	})
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	var text string
+	var calls []provider.ToolCall
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		text += chunk.Content
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	if text != "Running" {
+		t.Fatalf("unexpected text %q", text)
+	}
+	if len(calls) != 1 || calls[0].Name != "exec" || calls[0].Arguments != `{"command":"ls"}` {
+		t.Fatalf("unexpected tool calls %#v", calls)
+	}
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "be brief" {
+		t.Fatalf("system instruction not mapped: %#v", got.SystemInstruction)
+	}
+	roles := []string{"user", "model", "user"}
+	if len(got.Contents) != len(roles) {
+		t.Fatalf("expected %d contents, got %d", len(roles), len(got.Contents))
+	}
+	for i, role := range roles {
+		if got.Contents[i].Role != role {
+			t.Fatalf("content %d role = %s, want %s", i, got.Contents[i].Role, role)
+		}
+	}
+	if got.Contents[2].Parts[0].FunctionResponse == nil {
+		t.Fatal("tool result not sent as functionResponse")
+	}
+	if len(got.Tools) != 1 || got.Tools[0].FunctionDeclarations[0].Name != "exec" {
+		t.Fatalf("function declarations missing: %#v", got.Tools)
+	}
+}
+
+func TestListModelsFiltersGenerateContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(w, `{"models":[{"name":"models/gemini-2.5-pro","inputTokenLimit":1048576,"supportedGenerationMethods":["generateContent"]},{"name":"models/embedding-001","supportedGenerationMethods":["embedContent"]}],"nextPageToken":"p2"}`)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"models/gemini-2.5-flash","supportedGenerationMethods":["generateContent","countTokens"]}]}`)
+	}))
+	defer srv.Close()
+
+	models, err := New(srv.URL, "key").ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(models) != 2 || models[0].Name != "gemini-2.5-pro" || models[1].Name != "gemini-2.5-flash" {
+		t.Fatalf("unexpected models %#v", models)
+	}
+	if models[0].Tags["context"] != "1048576" {
+		t.Fatalf("expected context tag, got %#v", models[0].Tags)
+	}
+}
+
+func TestParallelToolResultsShareOneContent(t *testing.T) {
+	req := buildRequest(provider.ChatCompletionRequest{
+		Messages: []provider.ChatMessage{
+			{Role: "user", Content: "look around"},
+			{Role: "assistant", ToolCalls: []provider.ToolCall{
+				{ID: "1", Name: "exec", Arguments: `{"command":"pwd"}`},
+				{ID: "2", Name: "read_file", Arguments: `{"path":"go.mod"}`},
+			}},
+			{Role: "tool", ToolCallID: "1", Name: "exec", Content: "/tmp"},
+			{Role: "tool", ToolCallID: "2", Name: "read_file", Content: "module x"},
+		},
+	})
+	if len(req.Contents) != 3 {
+		t.Fatalf("expected user, model and one result content, got %+v", req.Contents)
+	}
+	results := req.Contents[2]
+	if results.Role != "user" || len(results.Parts) != 2 {
+		t.Fatalf("unexpected result content %+v", results)
+	}
+	for i, id := range []string{"1", "2"} {
+		if fr := results.Parts[i].FunctionResponse; fr == nil || fr.ID != id {
+			t.Fatalf("part %d should answer call %s: %+v", i, id, results.Parts[i])
+		}
+	}
+}
@@ -15,6 +15,9 @@ const (
 	AdapterOpenAIChat       AdapterKind = "openai-chat"
 	AdapterOpenAIResponses  AdapterKind = "openai-responses"
 	AdapterAnthropicMessage AdapterKind = "anthropic-messages"
+	AdapterAzureOpenAI      AdapterKind = "azure-openai"
+	AdapterGemini           AdapterKind = "gemini"
+	AdapterBedrock          AdapterKind = "bedrock"
 )
 
 // Manifest describes a custom provider connector.
@@ -23,6 +26,20 @@ type Manifest struct {
 	Adapter AdapterKind `toml:"adapter"`
 	Host    string      `toml:"host"`
 	Token   string      `toml:"token"`
+
+	// Endpoint is the Azure OpenAI resource endpoint, e.g. https://my-resource.openai.azure.com.
+	Endpoint string `toml:"endpoint,omitempty"`
+	// APIVersion is sent as the Azure api-version query parameter.
+	APIVersion string `toml:"api_version,omitempty"`
+	// Auth selects how the token is sent to Azure: "api-key" (default) or "entra" bearer tokens.
+	Auth string `toml:"auth,omitempty"`
+	// Deployments maps Azure deployment names to the model each one serves.
+	Deployments map[string]string `toml:"deployments,omitempty"`
+
+	// Region is the AWS region for Bedrock (defaults to AWS_REGION or the profile's region).
+	Region string `toml:"region,omitempty"`
+	// Profile selects a named profile from ~/.aws/credentials and ~/.aws/config.
+	Profile string `toml:"profile,omitempty"`
 }
 
 // InitProvider writes a manifest to ~/.pfui/providers/<name>.toml.
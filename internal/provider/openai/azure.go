@@ -0,0 +1,225 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+const (
+	// DefaultAzureAPIVersion is used when a manifest omits api_version.
+	DefaultAzureAPIVersion = "2024-10-21"
+	// azureListAPIVersion is the last api-version that exposes GET /openai/deployments.
+	azureListAPIVersion = "2022-12-01"
+
+	AzureAuthAPIKey = "api-key"
+	AzureAuthEntra  = "entra"
+)
+
+// AzureClient talks to Azure OpenAI deployments using the chat completions API.
+type AzureClient struct {
+	name        string
+	endpoint    string
+	apiVersion  string
+	auth        string
+	token       string
+	deployments map[string]string
+	httpClient  *http.Client
+}
+
+// NewAzure builds an Azure OpenAI client from a provider manifest.
+func NewAzure(m provider.Manifest) (*AzureClient, error) {
+	endpoint := strings.TrimSpace(m.Endpoint)
+	if endpoint == "" {
+		endpoint = strings.TrimSpace(m.Host)
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("%s: azure-openai requires endpoint", m.Name)
+	}
+	auth := strings.ToLower(strings.TrimSpace(m.Auth))
+	switch auth {
+	case "", AzureAuthAPIKey:
+		auth = AzureAuthAPIKey
+	case AzureAuthEntra:
+	default:
+		return nil, fmt.Errorf("%s: unknown azure auth %q (use api-key or entra)", m.Name, m.Auth)
+	}
+	version := strings.TrimSpace(m.APIVersion)
+	if version == "" {
+		version = DefaultAzureAPIVersion
+	}
+	name := m.Name
+	if name == "" {
+		name = "Azure OpenAI"
+	}
+	deployments := make(map[string]string, len(m.Deployments))
+	for deployment, model := range m.Deployments {
+		deployments[strings.TrimSpace(deployment)] = strings.TrimSpace(model)
+	}
+	return &AzureClient{
+		name:        name,
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		apiVersion:  version,
+		auth:        auth,
+		token:       m.Token,
+		deployments: deployments,
+		httpClient:  &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (c *AzureClient) Name() string {
+	return c.name
+}
+
+func (c *AzureClient) Kind() provider.Kind {
+	return provider.KindAzure
+}
+
+// ListModels returns configured deployments, falling back to the deployments API
+// when the manifest does not declare any.
+func (c *AzureClient) ListModels(ctx context.Context) ([]provider.Model, error) {
+	if len(c.deployments) > 0 {
+		names := make([]string, 0, len(c.deployments))
+		for name := range c.deployments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		models := make([]provider.Model, 0, len(names))
+		for _, name := range names {
+			models = append(models, azureModel(name, c.deployments[name]))
+		}
+		return models, nil
+	}
+	endpoint := fmt.Sprintf("%s/openai/deployments?api-version=%s", c.endpoint, url.QueryEscape(azureListAPIVersion))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s deployments error: %s", c.name, strings.TrimSpace(string(data)))
+	}
+	var listing struct {
+		Data []struct {
+			ID    string `json:"id"`
+			Model string `json:"model"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("%s deployments: %w", c.name, err)
+	}
+	models := make([]provider.Model, 0, len(listing.Data))
+	for _, d := range listing.Data {
+		models = append(models, azureModel(d.ID, d.Model))
+	}
+	return models, nil
+}
+
+func azureModel(deployment, model string) provider.Model {
+	desc := fmt.Sprintf("Azure deployment %s.", deployment)
+	if model != "" {
+		desc = fmt.Sprintf("Azure deployment %s serving %s.", deployment, model)
+	}
+	return provider.Model{
+		Name:         deployment,
+		Description:  desc,
+		Capabilities: []string{"chat", "code", "tools"},
+		Tags:         map[string]string{"deployment": deployment, "model": model},
+	}
+}
+
+func (c *AzureClient) StartChat(ctx context.Context, opts provider.StartChatOptions) (provider.Session, error) {
+	_ = ctx
+	return provider.NewSession("azure", opts.SessionID), nil
+}
+
+func (c *AzureClient) StreamChat(ctx context.Context, req provider.ChatCompletionRequest) (<-chan provider.StreamChunk, error) {
+	if strings.TrimSpace(c.token) == "" {
+		return nil, fmt.Errorf("%s: credentials missing; set token in the manifest or store an API key", c.name)
+	}
+	deployment, err := c.deploymentFor(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(chatCompletionPayload(c.modelFor(deployment), req))
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		c.endpoint, url.PathEscape(deployment), url.QueryEscape(c.apiVersion))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s chat error: %s", c.name, strings.TrimSpace(string(data)))
+	}
+	return streamChatCompletionBody(resp.Body), nil
+}
+
+func (c *AzureClient) authorize(req *http.Request) {
+	if c.auth == AzureAuthEntra {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		return
+	}
+	req.Header.Set("api-key", c.token)
+}
+
+// deploymentFor maps a requested model to a deployment. Callers may pass either
+// a deployment name or the model a deployment serves; unknown names are used
+// verbatim so unlisted deployments still work.
+func (c *AzureClient) deploymentFor(model string) (string, error) {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		if len(c.deployments) == 0 {
+			return "", fmt.Errorf("%s: no deployment selected; pick one with /model or add deployments to the manifest", c.name)
+		}
+		names := make([]string, 0, len(c.deployments))
+		for name := range c.deployments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names[0], nil
+	}
+	if _, ok := c.deployments[model]; ok {
+		return model, nil
+	}
+	var matches []string
+	for deployment, served := range c.deployments {
+		if served == model {
+			matches = append(matches, deployment)
+		}
+	}
+	if len(matches) > 0 {
+		sort.Strings(matches)
+		return matches[0], nil
+	}
+	return model, nil
+}
+
+func (c *AzureClient) modelFor(deployment string) string {
+	if model := c.deployments[deployment]; model != "" {
+		return model
+	}
+	return deployment
+}
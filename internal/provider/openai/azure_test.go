@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+func TestAzureStreamsFromDeployment(t *testing.T) {
+	var gotPath, gotVersion, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	client, err := NewAzure(provider.Manifest{
+		Name:        "azure",
+		Adapter:     provider.AdapterAzureOpenAI,
+		Endpoint:    srv.URL,
+		APIVersion:  "2024-06-01",
+		Token:       "secret",
+		Deployments: map[string]string{"prod-codex": "gpt-5.1-codex"},
+	})
+	if err != nil {
+		t.Fatalf("NewAzure: %v", err)
+	}
+	if client.Kind() != provider.KindAzure {
+		t.Fatalf("unexpected kind %s", client.Kind())
+	}
+	out, err := provider.Collect(context.Background(), client, provider.ChatCompletionRequest{
+		Model:    "gpt-5.1-codex",
+		Messages: []provider.ChatMessage{{Role: "user", Content: "hello"}},
+	})
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if out != "hi" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if gotPath != "/openai/deployments/prod-codex/chat/completions" {
+		t.Fatalf("unexpected path %s", gotPath)
+	}
+	if gotVersion != "2024-06-01" || gotKey != "secret" {
+		t.Fatalf("unexpected api-version %q or api-key %q", gotVersion, gotKey)
+	}
+}
+
+func TestAzureRejectsUnknownAuth(t *testing.T) {
+	_, err := NewAzure(provider.Manifest{Name: "azure", Endpoint: "https://x", Auth: "oauth"})
+	if err == nil {
+		t.Fatal("expected unknown auth mode to fail")
+	}
+}
@@ -125,14 +125,7 @@ func (c *Client) streamChatCompletions(ctx context.Context, req provider.ChatCom
 	if model == "" {
 		model = "gpt-5.1-codex"
 	}
-	payload := map[string]any{
-		"model": model,
-		"messages": []map[string]any{
-			{"role": "user", "content": joinContent(req.Messages)},
-		},
-		"stream": true,
-	}
-	body, _ := json.Marshal(payload)
+	body, _ := json.Marshal(chatCompletionPayload(model, req))
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+"/v1/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
@@ -148,16 +141,119 @@ func (c *Client) streamChatCompletions(ctx context.Context, req provider.ChatCom
 		data, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("%s chat error: %s", c.name, strings.TrimSpace(string(data)))
 	}
+	return streamChatCompletionBody(resp.Body), nil
+}
+
+// chatCompletionPayload builds the /chat/completions body shared by OpenAI and Azure.
+func chatCompletionPayload(model string, req provider.ChatCompletionRequest) map[string]any {
+	payload := map[string]any{
+		"model":    model,
+		"messages": chatMessages(req.Messages),
+		"stream":   true,
+		// Ask for a trailing usage chunk so token counts can be audited.
+		"stream_options": map[string]any{"include_usage": true},
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			tools = append(tools, map[string]any{
+				"type": "function",
+				"function": map[string]any{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"parameters":  tool.Parameters,
+				},
+			})
+		}
+		payload["tools"] = tools
+	}
+	return payload
+}
+
+// chatMessages maps pfui messages onto chat completions roles, carrying
+// assistant tool_calls and tool results keyed by tool_call_id.
+func chatMessages(messages []provider.ChatMessage) []map[string]any {
+	out := make([]map[string]any, 0, len(messages))
+	// Tool messages are text-only and must directly follow the assistant
+	// tool_calls turn, so images from a round follow as one user turn.
+	var toolImages []map[string]any
+	for i, msg := range messages {
+		switch msg.Role {
+		case "assistant":
+			entry := map[string]any{"role": "assistant", "content": msg.Content}
+			if len(msg.ToolCalls) > 0 {
+				calls := make([]map[string]any, 0, len(msg.ToolCalls))
+				for _, call := range msg.ToolCalls {
+					calls = append(calls, map[string]any{
+						"id":   call.ID,
+						"type": "function",
+						"function": map[string]any{
+							"name":      call.Name,
+							"arguments": call.Arguments,
+						},
+					})
+				}
+				entry["tool_calls"] = calls
+			}
+			out = append(out, entry)
+		case "tool":
+			out = append(out, map[string]any{"role": "tool", "tool_call_id": msg.ToolCallID, "content": msg.Content})
+			if len(msg.Images) > 0 {
+				toolImages = append(toolImages, map[string]any{"type": "text", "text": "Image returned by tool call " + msg.ToolCallID})
+				toolImages = append(toolImages, chatImageParts(msg.Images)...)
+			}
+			if len(toolImages) > 0 && (i+1 == len(messages) || messages[i+1].Role != "tool") {
+				out = append(out, map[string]any{"role": "user", "content": toolImages})
+				toolImages = nil
+			}
+		case "system":
+			out = append(out, map[string]any{"role": "system", "content": msg.Content})
+		default:
+			if len(msg.Images) > 0 {
+				parts := []map[string]any{{"type": "text", "text": msg.Content}}
+				out = append(out, map[string]any{"role": "user", "content": append(parts, chatImageParts(msg.Images)...)})
+				continue
+			}
+			out = append(out, map[string]any{"role": "user", "content": msg.Content})
+		}
+	}
+	return out
+}
+
+func chatImageParts(images []provider.Image) []map[string]any {
+	parts := make([]map[string]any, 0, len(images))
+	for _, img := range images {
+		parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]string{"url": img.DataURL()}})
+	}
+	return parts
+}
+
+// streamChatCompletionBody decodes a chat.completion.chunk SSE stream and closes body when done.
+func streamChatCompletionBody(body io.ReadCloser) <-chan provider.StreamChunk {
 	ch := make(chan provider.StreamChunk)
 	go func() {
-		defer resp.Body.Close()
+		defer body.Close()
 		defer close(ch)
-		reader := bufio.NewReader(resp.Body)
+		reader := bufio.NewReader(body)
+		// Tool call names and arguments arrive in fragments keyed by index.
+		var calls []provider.ToolCall
+		var usage *provider.Usage
+		// The usage chunk follows finish_reason, so the stream ends at [DONE]
+		// (or EOF after a finish_reason from servers that omit it).
+		finished := false
+		finish := func() {
+			if len(calls) > 0 {
+				ch <- provider.StreamChunk{ToolCalls: calls}
+			}
+			ch <- provider.StreamChunk{Usage: usage, Done: true}
+		}
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
 				if err != io.EOF {
 					ch <- provider.StreamChunk{Err: err}
+				} else if finished {
+					finish()
 				}
 				return
 			}
@@ -168,7 +264,7 @@ func (c *Client) streamChatCompletions(ctx context.Context, req provider.ChatCom
 			if strings.HasPrefix(line, "data:") {
 				payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
 				if payload == "[DONE]" {
-					ch <- provider.StreamChunk{Done: true}
+					finish()
 					return
 				}
 				var chunk openAIChatChunk
@@ -176,19 +272,32 @@ func (c *Client) streamChatCompletions(ctx context.Context, req provider.ChatCom
 					ch <- provider.StreamChunk{Err: err, Done: true}
 					return
 				}
+				if chunk.Usage != nil {
+					usage = &provider.Usage{InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+				}
 				for _, choice := range chunk.Choices {
 					if text := choice.Delta.Content; text != "" {
 						ch <- provider.StreamChunk{Content: text}
 					}
+					for _, delta := range choice.Delta.ToolCalls {
+						for len(calls) <= delta.Index {
+							calls = append(calls, provider.ToolCall{})
+						}
+						call := &calls[delta.Index]
+						if delta.ID != "" {
+							call.ID = delta.ID
+						}
+						call.Name += delta.Function.Name
+						call.Arguments += delta.Function.Arguments
+					}
 					if choice.FinishReason != "" {
-						ch <- provider.StreamChunk{Done: true}
-						return
+						finished = true
 					}
 				}
 			}
 		}
 	}()
-	return ch, nil
+	return ch
 }
 
 func (c *Client) streamResponses(ctx context.Context, req provider.ChatCompletionRequest) (<-chan provider.StreamChunk, error) {
@@ -196,19 +305,23 @@ func (c *Client) streamResponses(ctx context.Context, req provider.ChatCompletio
 	if model == "" {
 		model = "gpt-5.1-codex"
 	}
-	content := joinContent(req.Messages)
 	payload := map[string]any{
-		"model": model,
-		"input": []map[string]any{
-			{
-				"role": "user",
-				"content": []map[string]string{
-					{"type": "text", "text": content},
-				},
-			},
-		},
+		"model":  model,
+		"input":  responsesInput(req.Messages),
 		"stream": true,
 	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			tools = append(tools, map[string]any{
+				"type":        "function",
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			})
+		}
+		payload["tools"] = tools
+	}
 	body, _ := json.Marshal(payload)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+"/v1/responses", bytes.NewReader(body))
 	if err != nil {
@@ -225,11 +338,20 @@ func (c *Client) streamResponses(ctx context.Context, req provider.ChatCompletio
 		data, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("%s responses error: %s", c.name, strings.TrimSpace(string(data)))
 	}
+	return streamResponsesBody(resp.Body), nil
+}
+
+// streamResponsesBody decodes a Responses API SSE stream and closes body when
+// done. Only output_text deltas become content; function call arguments are
+// gathered per item and emitted once the item is done, and reasoning or
+// other deltas are dropped.
+func streamResponsesBody(body io.ReadCloser) <-chan provider.StreamChunk {
 	ch := make(chan provider.StreamChunk)
 	go func() {
-		defer resp.Body.Close()
+		defer body.Close()
 		defer close(ch)
-		reader := bufio.NewReader(resp.Body)
+		reader := bufio.NewReader(body)
+		args := make(map[string]*strings.Builder)
 		for {
 			line, err := reader.ReadString('\n')
 			if err != nil {
@@ -239,61 +361,130 @@ func (c *Client) streamResponses(ctx context.Context, req provider.ChatCompletio
 				return
 			}
 			line = strings.TrimSpace(line)
-			if line == "" {
+			if !strings.HasPrefix(line, "data:") {
 				continue
 			}
-			if strings.HasPrefix(line, "data:") {
-				payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
-				if payload == "[DONE]" {
-					ch <- provider.StreamChunk{Done: true}
-					return
+			payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if payload == "[DONE]" {
+				ch <- provider.StreamChunk{Done: true}
+				return
+			}
+			var event openAIResponseEvent
+			if err := json.Unmarshal([]byte(payload), &event); err != nil {
+				ch <- provider.StreamChunk{Err: err, Done: true}
+				return
+			}
+			if event.Error.Message != "" {
+				ch <- provider.StreamChunk{Err: errors.New(event.Error.Message), Done: true}
+				return
+			}
+			switch event.Type {
+			case "response.output_text.delta":
+				if text := event.deltaText(); text != "" {
+					ch <- provider.StreamChunk{Content: text}
 				}
-				var event openAIResponseEvent
-				if err := json.Unmarshal([]byte(payload), &event); err != nil {
-					ch <- provider.StreamChunk{Err: err, Done: true}
-					return
+			case "response.function_call_arguments.delta":
+				var fragment string
+				if err := json.Unmarshal(event.Delta, &fragment); err == nil {
+					b := args[event.ItemID]
+					if b == nil {
+						b = &strings.Builder{}
+						args[event.ItemID] = b
+					}
+					b.WriteString(fragment)
 				}
-				if event.Error.Message != "" {
-					ch <- provider.StreamChunk{Err: errors.New(event.Error.Message), Done: true}
-					return
+			case "response.output_item.done":
+				if event.Item.Type != "function_call" {
+					continue
 				}
-				for _, delta := range event.Delta.Content {
-					if delta.Text != "" {
-						ch <- provider.StreamChunk{Content: delta.Text}
-					}
+				arguments := event.Item.Arguments
+				if b := args[event.Item.ID]; arguments == "" && b != nil {
+					arguments = b.String()
 				}
-				if event.Type == "response.completed" {
-					ch <- provider.StreamChunk{Done: true}
-					return
+				delete(args, event.Item.ID)
+				ch <- provider.StreamChunk{ToolCalls: []provider.ToolCall{{
+					ID:        event.Item.CallID,
+					Name:      event.Item.Name,
+					Arguments: arguments,
+				}}}
+			case "response.completed":
+				var usage *provider.Usage
+				if u := event.Response.Usage; u != nil {
+					usage = &provider.Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
 				}
+				ch <- provider.StreamChunk{Usage: usage, Done: true}
+				return
 			}
 		}
 	}()
-	return ch, nil
+	return ch
 }
 
-func joinContent(messages []provider.ChatMessage) string {
-	if len(messages) == 0 {
-		return ""
-	}
-	var buf strings.Builder
+// responsesInput maps pfui messages onto Responses API input items. Tool calls
+// and their results become function_call / function_call_output items.
+func responsesInput(messages []provider.ChatMessage) []map[string]any {
+	out := make([]map[string]any, 0, len(messages))
 	for _, msg := range messages {
-		if msg.Content == "" {
+		switch msg.Role {
+		case "tool":
+			out = append(out, map[string]any{"type": "function_call_output", "call_id": msg.ToolCallID, "output": msg.Content})
+			if len(msg.Images) > 0 {
+				parts := []map[string]string{{"type": "input_text", "text": "Image returned by tool call " + msg.ToolCallID}}
+				out = append(out, map[string]any{"role": "user", "content": append(parts, responsesImageParts(msg.Images)...)})
+			}
+			continue
+		case "assistant":
+			if msg.Content != "" {
+				out = append(out, map[string]any{
+					"role":    "assistant",
+					"content": []map[string]string{{"type": "output_text", "text": msg.Content}},
+				})
+			}
+			for _, call := range msg.ToolCalls {
+				out = append(out, map[string]any{"type": "function_call", "call_id": call.ID, "name": call.Name, "arguments": call.Arguments})
+			}
 			continue
 		}
-		buf.WriteString(msg.Content)
-		buf.WriteString("\n\n")
+		role := msg.Role
+		if role != "system" {
+			role = "user"
+		}
+		parts := []map[string]string{{"type": "input_text", "text": msg.Content}}
+		if role == "user" {
+			parts = append(parts, responsesImageParts(msg.Images)...)
+		}
+		out = append(out, map[string]any{"role": role, "content": parts})
+	}
+	return out
+}
+
+func responsesImageParts(images []provider.Image) []map[string]string {
+	parts := make([]map[string]string, 0, len(images))
+	for _, img := range images {
+		parts = append(parts, map[string]string{"type": "input_image", "image_url": img.DataURL()})
 	}
-	return strings.TrimSpace(buf.String())
+	return parts
 }
 
 type openAIChatChunk struct {
 	Choices []struct {
 		Delta struct {
-			Content string `json:"content"`
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
 		} `json:"delta"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
 }
 
 type openAIResponseEvent struct {
@@ -301,10 +492,45 @@ type openAIResponseEvent struct {
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
-	Delta struct {
+	// Delta is a plain string on response.output_text.delta and an object
+	// with content parts on older event shapes.
+	Delta json.RawMessage `json:"delta"`
+	// ItemID ties argument deltas to their function_call item.
+	ItemID string `json:"item_id"`
+	Item   struct {
+		ID        string `json:"id"`
+		Type      string `json:"type"`
+		CallID    string `json:"call_id"`
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"item"`
+	Response struct {
+		Usage *struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"response"`
+}
+
+func (e openAIResponseEvent) deltaText() string {
+	if len(e.Delta) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(e.Delta, &text); err == nil {
+		return text
+	}
+	var parts struct {
 		Content []struct {
-			Type string `json:"type"`
 			Text string `json:"text"`
 		} `json:"content"`
-	} `json:"delta"`
+	}
+	if err := json.Unmarshal(e.Delta, &parts); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range parts.Content {
+		b.WriteString(part.Text)
+	}
+	return b.String()
 }
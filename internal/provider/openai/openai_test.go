@@ -0,0 +1,134 @@
+package openai
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+func TestChatStreamAssemblesToolCalls(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"exec","arguments":"{\"comm"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"and\":\"ls\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		"",
+	}, "\n")
+	var calls []provider.ToolCall
+	for chunk := range streamChatCompletionBody(io.NopCloser(strings.NewReader(body))) {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Name != "exec" || calls[0].Arguments != `{"command":"ls"}` {
+		t.Fatalf("unexpected tool calls %#v", calls)
+	}
+}
+
+func TestChatPayloadCarriesToolTurns(t *testing.T) {
+	payload := chatCompletionPayload("gpt", provider.ChatCompletionRequest{
+		Messages: []provider.ChatMessage{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "list files"},
+			{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "call_1", Name: "exec", Arguments: `{"command":"ls"}`}}},
+			{Role: "tool", ToolCallID: "call_1", Name: "exec", Content: "a.go"},
+		},
+		Tools: []provider.ToolSpec{{Name: "exec", Parameters: map[string]any{"type": "object"}}},
+	})
+	data, _ := json.Marshal(payload)
+	for _, want := range []string{`"role":"system"`, `"tool_calls":[{`, `"tool_call_id":"call_1"`, `"type":"function"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("payload missing %s: %s", want, data)
+		}
+	}
+}
+
+func TestChatStreamReportsTrailingUsage(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"choices":[{"delta":{"content":"hi"},"finish_reason":"stop"}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3}}`,
+		`data: [DONE]`,
+		"",
+	}, "\n")
+	var usage *provider.Usage
+	for chunk := range streamChatCompletionBody(io.NopCloser(strings.NewReader(body))) {
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+	if usage == nil || usage.InputTokens != 12 || usage.OutputTokens != 3 {
+		t.Fatalf("unexpected usage %#v", usage)
+	}
+}
+
+func TestToolImagesFollowAsUserContent(t *testing.T) {
+	messages := []provider.ChatMessage{
+		{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path":"a.png"}`}}},
+		{Role: "tool", ToolCallID: "call_1", Name: "read_file", Content: "{}", Images: []provider.Image{{MediaType: "image/png", Data: []byte("png")}}},
+	}
+	chat, _ := json.Marshal(chatMessages(messages))
+	if !strings.Contains(string(chat), `"image_url":{"url":"data:image/png;base64,cG5n"}`) {
+		t.Fatalf("chat payload missing image: %s", chat)
+	}
+	responses, _ := json.Marshal(responsesInput(messages))
+	if !strings.Contains(string(responses), `"type":"input_image"`) {
+		t.Fatalf("responses payload missing image: %s", responses)
+	}
+}
+
+func TestToolImagesFollowTheWholeToolRound(t *testing.T) {
+	png := []provider.Image{{MediaType: "image/png", Data: []byte("png")}}
+	out := chatMessages([]provider.ChatMessage{
+		{Role: "assistant", ToolCalls: []provider.ToolCall{{ID: "call_1", Name: "read_file"}, {ID: "call_2", Name: "read_file"}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "{}", Images: png},
+		{Role: "tool", ToolCallID: "call_2", Content: "{}", Images: png},
+	})
+	var roles []string
+	for _, msg := range out {
+		roles = append(roles, msg["role"].(string))
+	}
+	if strings.Join(roles, ",") != "assistant,tool,tool,user" {
+		t.Fatalf("tool messages split by image turns: %v", roles)
+	}
+	if parts := out[3]["content"].([]map[string]any); len(parts) != 4 {
+		t.Fatalf("expected both images in one user turn, got %#v", parts)
+	}
+}
+
+func TestResponsesStreamKeepsArgumentDeltasOutOfContent(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"type":"response.reasoning_summary_text.delta","item_id":"rs_1","delta":"thinking about it"}`,
+		`data: {"type":"response.output_text.delta","item_id":"msg_1","delta":"Listing files."}`,
+		`data: {"type":"response.output_item.added","item":{"id":"fc_1","type":"function_call","call_id":"call_1","name":"exec","arguments":""}}`,
+		`data: {"type":"response.function_call_arguments.delta","item_id":"fc_1","delta":"{\"comm"}`,
+		`data: {"type":"response.function_call_arguments.delta","item_id":"fc_1","delta":"and\":\"ls\"}"}`,
+		`data: {"type":"response.output_item.done","item":{"id":"fc_1","type":"function_call","call_id":"call_1","name":"exec"}}`,
+		`data: {"type":"response.completed","response":{"usage":{"input_tokens":9,"output_tokens":4}}}`,
+		"",
+	}, "\n")
+	var content strings.Builder
+	var calls []provider.ToolCall
+	var usage *provider.Usage
+	for chunk := range streamResponsesBody(io.NopCloser(strings.NewReader(body))) {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		content.WriteString(chunk.Content)
+		calls = append(calls, chunk.ToolCalls...)
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+	if content.String() != "Listing files." {
+		t.Fatalf("content should only carry output text, got %q", content.String())
+	}
+	if len(calls) != 1 || calls[0].ID != "call_1" || calls[0].Name != "exec" || calls[0].Arguments != `{"command":"ls"}` {
+		t.Fatalf("unexpected tool calls %#v", calls)
+	}
+	if usage == nil || usage.OutputTokens != 4 {
+		t.Fatalf("unexpected usage %#v", usage)
+	}
+}
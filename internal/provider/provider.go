@@ -2,7 +2,9 @@ package provider
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"strings"
 )
 
 // Kind identifies the provider family.
@@ -10,7 +12,10 @@ type Kind string
 
 const (
 	KindOpenAI    Kind = "openai"
+	KindAzure     Kind = "azure"
 	KindAnthropic Kind = "anthropic"
+	KindGemini    Kind = "gemini"
+	KindBedrock   Kind = "bedrock"
 	KindCustom    Kind = "custom"
 )
 
@@ -22,23 +27,76 @@ type Model struct {
 	Tags         map[string]string
 }
 
-// ChatMessage models a basic role/content pair.
+// ChatMessage models a basic role/content pair. Roles are "system", "user",
+// "assistant", and "tool"; adapters translate them to their wire format.
 type ChatMessage struct {
 	Role    string
 	Content string
+	// ToolCalls lists the tools an assistant turn asked to run.
+	ToolCalls []ToolCall
+	// ToolCallID links a "tool" message to the call it answers.
+	ToolCallID string
+	// Name carries the tool name for "tool" messages.
+	Name string
+	// Images attaches pictures to user and tool messages. Adapters send them
+	// as native image content; text-only endpoints never see them.
+	Images []Image
+}
+
+// Image is an inline picture such as a screenshot the model asked to read.
+type Image struct {
+	// MediaType is the MIME type: image/png, image/jpeg, image/gif, or image/webp.
+	MediaType string
+	Data      []byte
+}
+
+// Base64 returns the image data in standard base64.
+func (img Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// DataURL returns the image as a data: URL.
+func (img Image) DataURL() string {
+	return "data:" + img.MediaType + ";base64," + img.Base64()
+}
+
+// ToolSpec declares a function the model may call.
+type ToolSpec struct {
+	Name        string
+	Description string
+	// Parameters is a JSON schema object describing the arguments.
+	Parameters map[string]any
+}
+
+// ToolCall is a function invocation requested by the model.
+type ToolCall struct {
+	ID   string
+	Name string
+	// Arguments holds the raw JSON arguments object.
+	Arguments string
 }
 
 // ChatCompletionRequest describes a streaming completion.
 type ChatCompletionRequest struct {
 	Model    string
 	Messages []ChatMessage
+	Tools    []ToolSpec
+}
+
+// Usage reports the tokens a completion consumed, when the provider says.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
 }
 
 // StreamChunk is emitted while a provider streams a response.
 type StreamChunk struct {
-	Content string
-	Err     error
-	Done    bool
+	Content   string
+	ToolCalls []ToolCall
+	// Usage is set on the final chunk when the provider reported token counts.
+	Usage *Usage
+	Err   error
+	Done  bool
 }
 
 // StartChatOptions configure new sessions.
@@ -86,3 +144,23 @@ func (r Registry) ProviderByKind(kind Kind) (Provider, error) {
 	}
 	return nil, fmt.Errorf("provider %s not registered", kind)
 }
+
+// Collect drains a streaming completion into a single string. It is meant for
+// short utility calls (titles, summaries) where incremental rendering is not needed.
+func Collect(ctx context.Context, p Provider, req ChatCompletionRequest) (string, error) {
+	stream, err := p.StreamChat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	for chunk := range stream {
+		if chunk.Err != nil {
+			return buf.String(), chunk.Err
+		}
+		buf.WriteString(chunk.Content)
+		if chunk.Done {
+			break
+		}
+	}
+	return buf.String(), nil
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/fbettag/pfui/internal/config"
 	"github.com/fbettag/pfui/internal/provider"
 	"github.com/fbettag/pfui/internal/provider/anthropic"
+	"github.com/fbettag/pfui/internal/provider/bedrock"
+	"github.com/fbettag/pfui/internal/provider/gemini"
 	"github.com/fbettag/pfui/internal/provider/openai"
 )
 
@@ -50,6 +52,16 @@ func instantiateCustom(manifest provider.Manifest) provider.Provider {
 		fmt.Fprintf(os.Stderr, "pfui: skipping custom provider with empty name\n")
 		return nil
 	}
+	if manifest.Adapter == provider.AdapterBedrock {
+		// Bedrock signs with AWS credentials resolved per request, not a bearer token.
+		return bedrock.New(manifest)
+	}
+	if manifest.Token == "" && manifest.Adapter == provider.AdapterAzureOpenAI {
+		manifest.Token = azureEnvToken(manifest)
+	}
+	if manifest.Token == "" && manifest.Adapter == provider.AdapterGemini {
+		manifest.Token = geminiEnvToken()
+	}
 	if manifest.Token == "" {
 		fmt.Fprintf(os.Stderr, "pfui: skipping %s (missing token). Use pfui provider init --token ... or store a matching API key.\n", manifest.Name)
 		return nil
@@ -59,8 +71,33 @@ func instantiateCustom(manifest provider.Manifest) provider.Provider {
 		return openai.NewWithAdapter(manifest.Host, manifest.Token, manifest.Name, manifest.Adapter)
 	case provider.AdapterAnthropicMessage:
 		return anthropic.NewWithName(manifest.Host, manifest.Token, manifest.Name)
+	case provider.AdapterGemini:
+		return gemini.NewWithName(manifest.Host, manifest.Token, manifest.Name)
+	case provider.AdapterAzureOpenAI:
+		client, err := openai.NewAzure(manifest)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "pfui: skipping %s: %v\n", manifest.Name, err)
+			return nil
+		}
+		return client
 	default:
 		fmt.Fprintf(os.Stderr, "pfui: adapter %s for %s is not supported yet\n", manifest.Adapter, manifest.Name)
 		return nil
 	}
 }
+
+// geminiEnvToken mirrors the variables honored by Google's SDKs.
+func geminiEnvToken() string {
+	if key := os.Getenv("GEMINI_API_KEY"); key != "" {
+		return key
+	}
+	return os.Getenv("GOOGLE_API_KEY")
+}
+
+// azureEnvToken falls back to the environment variables the Azure SDKs use.
+func azureEnvToken(manifest provider.Manifest) string {
+	if strings.EqualFold(strings.TrimSpace(manifest.Auth), openai.AzureAuthEntra) {
+		return os.Getenv("AZURE_OPENAI_AD_TOKEN")
+	}
+	return os.Getenv("AZURE_OPENAI_API_KEY")
+}
@@ -0,0 +1,189 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/config"
+	"github.com/fbettag/pfui/internal/modelcatalog"
+)
+
+// Phase identifies which part of a session a model serves.
+type Phase string
+
+const (
+	PhasePlan      Phase = "plan"
+	PhaseExecution Phase = "execution"
+	PhaseUtility   Phase = "utility"
+)
+
+// Phases lists every routable phase in display order.
+var Phases = []Phase{PhasePlan, PhaseExecution, PhaseUtility}
+
+// ParsePhase maps user input (including a few aliases) to a Phase.
+func ParsePhase(raw string) (Phase, bool) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "plan", "planning":
+		return PhasePlan, true
+	case "execution", "exec", "auto":
+		return PhaseExecution, true
+	case "utility", "cheap", "title", "summary", "compact":
+		return PhaseUtility, true
+	default:
+		return "", false
+	}
+}
+
+// Selector picks a model either by explicit name or by catalog tag.
+//
+// Supported forms:
+//
+//	claude-4.5-sonnet          model name on any provider
+//	Claude/claude-4.5-sonnet   model name on a specific provider
+//	tag:mode=plan              first model carrying the tag
+type Selector struct {
+	Raw      string
+	Name     string
+	TagKey   string
+	TagValue string
+}
+
+// ParseSelector validates a selector string.
+func ParseSelector(raw string) (Selector, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return Selector{}, fmt.Errorf("selector is empty")
+	}
+	if rest, ok := strings.CutPrefix(trimmed, "tag:"); ok {
+		key, value, found := strings.Cut(rest, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !found || key == "" || value == "" {
+			return Selector{}, fmt.Errorf("tag selector %q must look like tag:key=value", trimmed)
+		}
+		return Selector{Raw: trimmed, TagKey: key, TagValue: value}, nil
+	}
+	return Selector{Raw: trimmed, Name: trimmed}, nil
+}
+
+// Matches reports whether the catalog model satisfies the selector.
+func (s Selector) Matches(m modelcatalog.Model) bool {
+	if s.TagKey != "" {
+		return strings.EqualFold(m.Tags[s.TagKey], s.TagValue)
+	}
+	if m.Name == s.Name {
+		return true
+	}
+	return strings.EqualFold(m.Provider+"/"+m.Name, s.Name)
+}
+
+// Route is the outcome of resolving a phase against the catalog.
+type Route struct {
+	Phase      Phase
+	Provider   string
+	Model      string
+	Selector   string
+	Overridden bool
+}
+
+// Policy holds the configured selectors plus session-level overrides.
+type Policy struct {
+	selectors map[Phase]string
+	overrides map[Phase]string
+	disabled  bool
+}
+
+// NewPolicy builds a Policy from persisted configuration.
+func NewPolicy(cfg config.RoutingConfig) *Policy {
+	return &Policy{
+		selectors: map[Phase]string{
+			PhasePlan:      strings.TrimSpace(cfg.Plan),
+			PhaseExecution: strings.TrimSpace(cfg.Execution),
+			PhaseUtility:   strings.TrimSpace(cfg.Utility),
+		},
+		overrides: make(map[Phase]string),
+	}
+}
+
+// Enabled reports whether routing applies to new responses.
+func (p *Policy) Enabled() bool {
+	return p != nil && !p.disabled
+}
+
+// SetEnabled toggles routing for the current session.
+func (p *Policy) SetEnabled(enabled bool) {
+	p.disabled = !enabled
+}
+
+// Selector returns the effective selector for phase and whether it is a session override.
+func (p *Policy) Selector(phase Phase) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	if raw, ok := p.overrides[phase]; ok {
+		return raw, true
+	}
+	return p.selectors[phase], false
+}
+
+// Override replaces the selector for phase until Reset is called.
+func (p *Policy) Override(phase Phase, raw string) error {
+	if _, err := ParseSelector(raw); err != nil {
+		return err
+	}
+	p.overrides[phase] = strings.TrimSpace(raw)
+	return nil
+}
+
+// Reset drops the session override for phase (or every phase when empty).
+func (p *Policy) Reset(phase Phase) {
+	if phase == "" {
+		for k := range p.overrides {
+			delete(p.overrides, k)
+		}
+		return
+	}
+	delete(p.overrides, phase)
+}
+
+// Resolve picks a model for phase. Models from preferProvider win ties so a
+// tag selector keeps the conversation on the active backend when possible.
+func (p *Policy) Resolve(phase Phase, models []modelcatalog.Model, preferProvider string) (Route, error) {
+	if !p.Enabled() {
+		return Route{}, fmt.Errorf("routing disabled")
+	}
+	raw, overridden := p.Selector(phase)
+	if raw == "" {
+		return Route{}, fmt.Errorf("no route configured for %s", phase)
+	}
+	sel, err := ParseSelector(raw)
+	if err != nil {
+		return Route{}, err
+	}
+	var fallback *modelcatalog.Model
+	for i := range models {
+		if !sel.Matches(models[i]) {
+			continue
+		}
+		if strings.EqualFold(models[i].Provider, preferProvider) {
+			return newRoute(phase, models[i], raw, overridden), nil
+		}
+		if fallback == nil {
+			fallback = &models[i]
+		}
+	}
+	if fallback == nil {
+		return Route{}, fmt.Errorf("no model matches %s route %q", phase, raw)
+	}
+	return newRoute(phase, *fallback, raw, overridden), nil
+}
+
+func newRoute(phase Phase, m modelcatalog.Model, raw string, overridden bool) Route {
+	return Route{
+		Phase:      phase,
+		Provider:   m.Provider,
+		Model:      m.Name,
+		Selector:   raw,
+		Overridden: overridden,
+	}
+}
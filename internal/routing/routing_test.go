@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/fbettag/pfui/internal/config"
+	"github.com/fbettag/pfui/internal/modelcatalog"
+)
+
+func testModels() []modelcatalog.Model {
+	return []modelcatalog.Model{
+		{Name: "gpt-5.1-codex", Provider: "OpenAI", Tags: map[string]string{"mode": "codex"}},
+		{Name: "claude-4.5-sonnet", Provider: "Claude", Tags: map[string]string{"mode": "plan"}},
+		{Name: "claude-4.5-haiku", Provider: "Claude", Tags: map[string]string{"mode": "execution"}},
+		{Name: "claude-4.1-opus", Provider: "Claude", Tags: map[string]string{"tier": "opus"}},
+	}
+}
+
+func TestResolveByTagAndName(t *testing.T) {
+	policy := NewPolicy(config.RoutingConfig{
+		Plan:      "tag:tier=opus",
+		Execution: "OpenAI/gpt-5.1-codex",
+		Utility:   "claude-4.5-haiku",
+	})
+	cases := map[Phase]string{
+		PhasePlan:      "claude-4.1-opus",
+		PhaseExecution: "gpt-5.1-codex",
+		PhaseUtility:   "claude-4.5-haiku",
+	}
+	for phase, want := range cases {
+		route, err := policy.Resolve(phase, testModels(), "Claude")
+		if err != nil {
+			t.Fatalf("Resolve(%s): %v", phase, err)
+		}
+		if route.Model != want {
+			t.Fatalf("Resolve(%s) = %s, want %s", phase, route.Model, want)
+		}
+	}
+}
+
+func TestResolvePrefersActiveProvider(t *testing.T) {
+	models := append(testModels(), modelcatalog.Model{Name: "zai-plan", Provider: "zai", Tags: map[string]string{"mode": "plan"}})
+	policy := NewPolicy(config.RoutingConfig{Plan: "tag:mode=plan"})
+	route, err := policy.Resolve(PhasePlan, models, "zai")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if route.Provider != "zai" {
+		t.Fatalf("expected zai route, got %#v", route)
+	}
+}
+
+func TestOverrideAndReset(t *testing.T) {
+	policy := NewPolicy(config.RoutingConfig{Plan: "tag:mode=plan"})
+	if err := policy.Override(PhasePlan, "tag:broken"); err == nil {
+		t.Fatal("expected invalid tag selector to be rejected")
+	}
+	if err := policy.Override(PhasePlan, "claude-4.1-opus"); err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	route, err := policy.Resolve(PhasePlan, testModels(), "")
+	if err != nil || route.Model != "claude-4.1-opus" || !route.Overridden {
+		t.Fatalf("unexpected override route %#v (err %v)", route, err)
+	}
+	policy.Reset(PhasePlan)
+	route, err = policy.Resolve(PhasePlan, testModels(), "")
+	if err != nil || route.Model != "claude-4.5-sonnet" {
+		t.Fatalf("unexpected reset route %#v (err %v)", route, err)
+	}
+	if _, err := policy.Resolve(PhaseExecution, testModels(), ""); err == nil {
+		t.Fatal("expected unconfigured phase to fail")
+	}
+}
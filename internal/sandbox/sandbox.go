@@ -0,0 +1,237 @@
+// Package sandbox confines exec tool commands. On Linux, commands are
+// re-executed through a small pfui helper that applies Landlock filesystem
+// rules, a seccomp filter, and (when requested) a private network namespace
+// before exec'ing the real program.
+package sandbox
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/config"
+)
+
+// HelperCommand is the hidden pfui subcommand that applies a policy and execs the target.
+const HelperCommand = "__sandbox-exec"
+
+// Level selects how much of the system a command may touch.
+type Level string
+
+const (
+	// LevelReadOnly allows reads anywhere and writes nowhere (besides /dev/null and friends).
+	LevelReadOnly Level = "read-only"
+	// LevelWorkspaceWrite adds write access to the project root, temp dirs, and allowlisted paths.
+	LevelWorkspaceWrite Level = "workspace-write"
+	// LevelFull runs commands unconfined.
+	LevelFull Level = "full"
+)
+
+// Levels lists sandbox levels from most to least restrictive.
+var Levels = []Level{LevelReadOnly, LevelWorkspaceWrite, LevelFull}
+
+// ParseLevel maps user input onto a Level.
+func ParseLevel(raw string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "read-only", "readonly", "ro":
+		return LevelReadOnly, nil
+	case "workspace-write", "workspace", "write", "rw":
+		return LevelWorkspaceWrite, nil
+	case "full", "off", "none", "danger-full-access":
+		return LevelFull, nil
+	default:
+		return "", fmt.Errorf("unknown sandbox level %q (use read-only, workspace-write, or full)", raw)
+	}
+}
+
+// DefaultLevel is workspace-write where the sandbox is supported and full elsewhere.
+func DefaultLevel() Level {
+	if Available() != nil {
+		return LevelFull
+	}
+	return LevelWorkspaceWrite
+}
+
+// Policy describes what sandboxed commands may access.
+type Policy struct {
+	Level Level `json:"level"`
+	// ProjectRoot is writable under workspace-write.
+	ProjectRoot string `json:"project_root"`
+	// WritablePaths extends the writable set under workspace-write.
+	WritablePaths []string `json:"writable_paths,omitempty"`
+	// IsolateNetwork blocks internet sockets; Unix sockets keep working.
+	IsolateNetwork bool `json:"isolate_network,omitempty"`
+}
+
+// NewPolicy builds a policy from config for the given project root. An empty
+// configured level falls back to DefaultLevel.
+func NewPolicy(cfg config.SandboxConfig, projectRoot string) (Policy, error) {
+	level := DefaultLevel()
+	if strings.TrimSpace(cfg.Level) != "" {
+		parsed, err := ParseLevel(cfg.Level)
+		if err != nil {
+			return Policy{Level: level, ProjectRoot: projectRoot}, err
+		}
+		level = parsed
+	}
+	return Policy{
+		Level:          level,
+		ProjectRoot:    projectRoot,
+		WritablePaths:  append([]string(nil), cfg.WritablePaths...),
+		IsolateNetwork: cfg.IsolateNetwork,
+	}, nil
+}
+
+// Confined reports whether the policy restricts commands at all.
+func (p Policy) Confined() bool {
+	return p.Level != LevelFull || p.IsolateNetwork
+}
+
+// Summary renders the policy for status lines, e.g. "workspace-write, no network".
+func (p Policy) Summary() string {
+	if p.IsolateNetwork {
+		return string(p.Level) + ", no network"
+	}
+	return string(p.Level)
+}
+
+// WritableRoots returns the absolute paths a command may write beneath.
+func (p Policy) WritableRoots() []string {
+	roots := append([]string(nil), devicePaths...)
+	if p.Level != LevelWorkspaceWrite {
+		return roots
+	}
+	if p.ProjectRoot != "" {
+		roots = append(roots, p.ProjectRoot)
+	}
+	roots = append(roots, tempDirs()...)
+	for _, path := range p.WritablePaths {
+		if expanded := expandPath(path); expanded != "" {
+			roots = append(roots, expanded)
+		}
+	}
+	return dedupe(roots)
+}
+
+// devicePaths stay writable at every level so redirects to /dev/null and
+// terminal I/O keep working.
+var devicePaths = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty", "/dev/ptmx", "/dev/pts"}
+
+func tempDirs() []string {
+	return dedupe([]string{os.TempDir(), "/tmp", "/var/tmp", "/dev/shm"})
+}
+
+func expandPath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return ""
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	return abs
+}
+
+func dedupe(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	out := make([]string, 0, len(paths))
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		out = append(out, path)
+	}
+	return out
+}
+
+// Sandbox rewrites commands so they start under the pfui helper.
+type Sandbox struct {
+	policy Policy
+	helper string
+}
+
+// New prepares a Sandbox for policy. It fails when the policy needs kernel
+// features this host lacks.
+func New(policy Policy) (*Sandbox, error) {
+	if policy.Confined() {
+		if err := Available(); err != nil {
+			return nil, err
+		}
+	}
+	helper, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: locating pfui binary: %w", err)
+	}
+	return &Sandbox{policy: policy, helper: helper}, nil
+}
+
+// Policy returns the policy this sandbox enforces.
+func (s *Sandbox) Policy() Policy {
+	return s.policy
+}
+
+// Wrap points cmd at the helper, passing the policy and original argv along.
+func (s *Sandbox) Wrap(cmd *exec.Cmd) error {
+	if !s.policy.Confined() || cmd.Err != nil {
+		return nil
+	}
+	encoded, err := encodePolicy(s.policy)
+	if err != nil {
+		return err
+	}
+	args := append([]string{s.helper, HelperCommand, encoded, cmd.Path}, cmd.Args...)
+	cmd.Path = s.helper
+	cmd.Args = args
+	if s.policy.IsolateNetwork {
+		configureNamespaces(cmd)
+	}
+	return nil
+}
+
+// RunHelper is the body of the hidden helper command: args are the encoded
+// policy, the program path, and its argv. It only returns on failure.
+func RunHelper(args []string) error {
+	if len(args) < 3 {
+		return errors.New("sandbox helper: expected policy, program, and argv")
+	}
+	policy, err := decodePolicy(args[0])
+	if err != nil {
+		return err
+	}
+	return apply(policy, args[1], args[2:])
+}
+
+func encodePolicy(p Policy) (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("sandbox: encoding policy: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePolicy(raw string) (Policy, error) {
+	var p Policy
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return p, fmt.Errorf("sandbox helper: decoding policy: %w", err)
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("sandbox helper: decoding policy: %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	userNSOnce sync.Once
+	userNSOK   bool
+)
+
+// Available reports whether this kernel can enforce sandbox policies.
+func Available() error {
+	if seccompArch == 0 {
+		return fmt.Errorf("sandbox: seccomp filters are not supported on %s", runtime.GOARCH)
+	}
+	if _, err := landlockABI(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// configureNamespaces starts the helper in fresh user and network namespaces
+// when the kernel allows unprivileged ones. The seccomp socket filter still
+// blocks networking when it does not.
+func configureNamespaces(cmd *exec.Cmd) {
+	if !userNamespacesAvailable() {
+		return
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	setNamespaceAttrs(cmd.SysProcAttr)
+}
+
+func setNamespaceAttrs(attr *syscall.SysProcAttr) {
+	uid, gid := os.Getuid(), os.Getgid()
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// userNamespacesAvailable probes once by starting a trivial process in new
+// namespaces; distributions often disable them for unprivileged users.
+func userNamespacesAvailable() bool {
+	userNSOnce.Do(func() {
+		path, err := exec.LookPath("true")
+		if err != nil {
+			return
+		}
+		probe := exec.Command(path)
+		probe.SysProcAttr = &syscall.SysProcAttr{}
+		setNamespaceAttrs(probe.SysProcAttr)
+		userNSOK = probe.Run() == nil
+	})
+	return userNSOK
+}
+
+// apply confines the current thread and execs program. Landlock, seccomp and
+// no_new_privs are per-thread until execve, so everything runs on one locked
+// OS thread.
+func apply(policy Policy, program string, argv []string) error {
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("sandbox: no_new_privs: %w", err)
+	}
+	if policy.Level != LevelFull {
+		if err := restrictFilesystem([]string{"/"}, policy.WritableRoots()); err != nil {
+			return err
+		}
+	}
+	if err := installSeccomp(policy.IsolateNetwork); err != nil {
+		return err
+	}
+	err := syscall.Exec(program, argv, os.Environ())
+	if errors.Is(err, syscall.EACCES) && policy.Level != LevelFull {
+		return fmt.Errorf("sandbox: exec %s: %w (blocked by the %s sandbox?)", program, err, policy.Level)
+	}
+	return fmt.Errorf("sandbox: exec %s: %w", program, err)
+}
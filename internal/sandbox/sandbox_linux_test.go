@@ -0,0 +1,71 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestBuildFilterEndsWithAllow(t *testing.T) {
+	plain := buildFilter(seccompArch, deniedSyscalls, false)
+	isolated := buildFilter(seccompArch, deniedSyscalls, true)
+	if len(isolated) != len(plain)+4 {
+		t.Fatalf("network isolation should add 4 instructions, got %d vs %d", len(isolated), len(plain))
+	}
+	last := isolated[len(isolated)-1]
+	if last.Code != unix.BPF_RET|unix.BPF_K || last.K != unix.SECCOMP_RET_ALLOW {
+		t.Fatalf("filter must end with allow, got %#v", last)
+	}
+}
+
+// runSandboxed starts a shell command under policy through the test binary helper.
+func runSandboxed(t *testing.T, policy Policy, script string) error {
+	t.Helper()
+	if err := Available(); err != nil {
+		t.Skip(err)
+	}
+	sb, err := New(policy)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	cmd := exec.CommandContext(context.Background(), "sh", "-c", script)
+	if err := sb.Wrap(cmd); err != nil {
+		t.Fatalf("Wrap: %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Logf("output: %s", out)
+	}
+	return err
+}
+
+func TestWorkspaceWriteConfinesWrites(t *testing.T) {
+	root := t.TempDir()
+	policy := Policy{Level: LevelReadOnly, ProjectRoot: root}
+	if err := runSandboxed(t, policy, "echo hi > "+filepath.Join(root, "blocked")); err == nil {
+		t.Fatal("read-only sandbox allowed a write")
+	}
+	policy.Level = LevelWorkspaceWrite
+	if err := runSandboxed(t, policy, "echo hi > "+filepath.Join(root, "ok")+" && cat /etc/hostname >/dev/null"); err != nil {
+		t.Fatalf("workspace-write blocked the project root: %v", err)
+	}
+}
+
+func TestIsolateNetworkBlocksInetSockets(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not installed")
+	}
+	policy := Policy{Level: LevelFull, IsolateNetwork: true}
+	if err := runSandboxed(t, policy, python+" -c 'import socket; socket.socket(socket.AF_INET)'"); err == nil {
+		t.Fatal("expected AF_INET socket to be refused")
+	}
+	if err := runSandboxed(t, policy, python+" -c 'import socket; socket.socket(socket.AF_UNIX)'"); err != nil {
+		t.Fatalf("AF_UNIX socket should work: %v", err)
+	}
+}
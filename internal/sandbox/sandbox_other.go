@@ -0,0 +1,20 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// Available reports whether this platform can enforce sandbox policies.
+func Available() error {
+	return errors.New("sandbox: only supported on Linux (running on " + runtime.GOOS + ")")
+}
+
+func configureNamespaces(cmd *exec.Cmd) {}
+
+func apply(policy Policy, program string, argv []string) error {
+	return Available()
+}
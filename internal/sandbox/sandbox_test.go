@@ -0,0 +1,77 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/config"
+)
+
+// TestMain lets the test binary double as the sandbox helper, mirroring how
+// pfui re-executes itself.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == HelperCommand {
+		err := RunHelper(os.Args[2:])
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(126)
+	}
+	os.Exit(m.Run())
+}
+
+func TestParseLevelAliases(t *testing.T) {
+	cases := map[string]Level{
+		"read-only":       LevelReadOnly,
+		"RO":              LevelReadOnly,
+		"workspace-write": LevelWorkspaceWrite,
+		"workspace":       LevelWorkspaceWrite,
+		"full":            LevelFull,
+		"off":             LevelFull,
+	}
+	for input, want := range cases {
+		got, err := ParseLevel(input)
+		if err != nil || got != want {
+			t.Fatalf("ParseLevel(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("yolo"); err == nil {
+		t.Fatal("expected unknown level to fail")
+	}
+}
+
+func TestWritableRootsFollowLevel(t *testing.T) {
+	root := t.TempDir()
+	policy, err := NewPolicy(config.SandboxConfig{Level: "read-only", WritablePaths: []string{"~/cache"}}, root)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	if slices.Contains(policy.WritableRoots(), root) {
+		t.Fatal("read-only policy must not expose the project root")
+	}
+	policy.Level = LevelWorkspaceWrite
+	roots := policy.WritableRoots()
+	home, _ := os.UserHomeDir()
+	if !slices.Contains(roots, root) || !slices.Contains(roots, filepath.Join(home, "cache")) {
+		t.Fatalf("workspace-write roots missing project or allowlist: %v", roots)
+	}
+	if !slices.Contains(roots, "/dev/null") {
+		t.Fatalf("expected /dev/null to stay writable: %v", roots)
+	}
+}
+
+func TestPolicyRoundTrip(t *testing.T) {
+	in := Policy{Level: LevelWorkspaceWrite, ProjectRoot: "/src", WritablePaths: []string{"/cache"}, IsolateNetwork: true}
+	encoded, err := encodePolicy(in)
+	if err != nil {
+		t.Fatalf("encodePolicy: %v", err)
+	}
+	out, err := decodePolicy(encoded)
+	if err != nil {
+		t.Fatalf("decodePolicy: %v", err)
+	}
+	if out.Level != in.Level || out.ProjectRoot != in.ProjectRoot || !out.IsolateNetwork || len(out.WritablePaths) != 1 {
+		t.Fatalf("round trip mismatch: %#v", out)
+	}
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// deniedSyscalls cover kernel surfaces a coding agent never needs: debugging
+// other processes, namespace and mount games, kernel modules, and keyrings.
+var deniedSyscalls = []uint32{
+	unix.SYS_PTRACE,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_UNSHARE,
+	unix.SYS_SETNS,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_KEYCTL,
+	unix.SYS_ADD_KEY,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_IO_URING_SETUP,
+	unix.SYS_REBOOT,
+	unix.SYS_SWAPON,
+	unix.SYS_SWAPOFF,
+}
+
+const (
+	seccompDeny  = unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+	seccompAllow = unix.SECCOMP_RET_ALLOW
+
+	// Offsets into struct seccomp_data.
+	seccompDataNR   = 0
+	seccompDataArch = 4
+	seccompDataArg0 = 16 // low word on little-endian arches
+
+	// x32 syscalls on amd64 share the arch token but set this bit.
+	x32SyscallBit = 0x40000000
+)
+
+// buildFilter assembles the classic BPF program: reject foreign arches and
+// x32, deny the listed syscalls with EPERM, and (when isolating the network)
+// only let socket(2) create AF_UNIX sockets.
+func buildFilter(arch uint32, denied []uint32, isolateNetwork bool) []unix.SockFilter {
+	prog := []unix.SockFilter{
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArch),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, seccompDeny),
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNR),
+		bpfJump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32SyscallBit, 0, 1),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, seccompDeny),
+	}
+	for _, nr := range denied {
+		prog = append(prog,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, 0, 1),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, seccompDeny),
+		)
+	}
+	if isolateNetwork {
+		prog = append(prog,
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_SOCKET, 0, 3),
+			bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArg0),
+			bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.AF_UNIX, 1, 0),
+			bpfStmt(unix.BPF_RET|unix.BPF_K, seccompDeny),
+		)
+	}
+	return append(prog, bpfStmt(unix.BPF_RET|unix.BPF_K, seccompAllow))
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
+
+// installSeccomp loads the filter on the calling thread; no_new_privs must
+// already be set.
+func installSeccomp(isolateNetwork bool) error {
+	filter := buildFilter(seccompArch, deniedSyscalls, isolateNetwork)
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return fmt.Errorf("sandbox: installing seccomp filter: %w", err)
+	}
+	return nil
+}
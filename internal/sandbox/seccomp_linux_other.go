@@ -0,0 +1,7 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+// seccompArch is zero where pfui does not ship a filter; Available reports the
+// sandbox as unsupported there.
+const seccompArch = 0
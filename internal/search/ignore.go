@@ -0,0 +1,213 @@
+package search
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFiles are read in every directory, in this order; later files (and
+// deeper directories) override earlier ones, as in git.
+var IgnoreFiles = []string{".gitignore", ".pfuiignore"}
+
+type ignoreRule struct {
+	// base is the directory of the ignore file, relative to the root ("" at the root).
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher answers whether a path under root is ignored by the .gitignore and
+// .pfuiignore files between root and the path (plus .git/info/exclude). The
+// .git directory itself is always ignored. Ignore files are loaded lazily and
+// cached, so a Matcher is meant for one search; it is not safe for
+// concurrent use.
+type Matcher struct {
+	root  string
+	rules map[string][]ignoreRule
+	dirs  map[string]bool
+}
+
+// NewMatcher returns a matcher for the tree at root.
+func NewMatcher(root string) *Matcher {
+	return &Matcher{root: root, rules: make(map[string][]ignoreRule), dirs: make(map[string]bool)}
+}
+
+// Ignored reports whether rel (slash-separated, relative to the root) is
+// ignored, either directly or because a parent directory is.
+func (m *Matcher) Ignored(rel string, isDir bool) bool {
+	rel = path.Clean(filepath.ToSlash(rel))
+	if rel == "." || rel == "" {
+		return false
+	}
+	parent := path.Dir(rel)
+	if parent != "." && m.dirIgnored(parent) {
+		return true
+	}
+	if isDir {
+		return m.dirIgnored(rel)
+	}
+	return m.match(parent, rel, false)
+}
+
+func (m *Matcher) dirIgnored(dir string) bool {
+	if ignored, ok := m.dirs[dir]; ok {
+		return ignored
+	}
+	parent := path.Dir(dir)
+	ignored := path.Base(dir) == ".git" ||
+		(parent != "." && m.dirIgnored(parent)) ||
+		m.match(parent, dir, true)
+	m.dirs[dir] = ignored
+	return ignored
+}
+
+// match applies the rules in effect in dir to rel; the last matching rule wins.
+func (m *Matcher) match(dir, rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rulesFor(dir) {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if rule.base != "" {
+			sub = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if rule.re.MatchString(sub) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// rulesFor returns the rules of dir's ignore files appended to its parent's.
+func (m *Matcher) rulesFor(dir string) []ignoreRule {
+	if dir == "" {
+		dir = "."
+	}
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+	var rules []ignoreRule
+	base := ""
+	if dir != "." {
+		rules = append(rules, m.rulesFor(path.Dir(dir))...)
+		base = dir
+	} else {
+		rules = append(rules, readIgnoreFile(filepath.Join(m.root, ".git", "info", "exclude"), "")...)
+	}
+	for _, name := range IgnoreFiles {
+		rules = append(rules, readIgnoreFile(filepath.Join(m.root, filepath.FromSlash(base), name), base)...)
+	}
+	m.rules[dir] = rules
+	return rules
+}
+
+func readIgnoreFile(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine follows gitignore syntax: "#" comments, "!" negation, a
+// trailing "/" for directories only, and a leading or inner "/" anchoring
+// the pattern to the ignore file's directory.
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	expr := globRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "(^|/)" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globRegexp translates a glob into an unanchored regular expression: "*"
+// and "?" stay within one path segment, "**" spans segments, and [...]
+// classes pass through ("[!x]" negates).
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				rest := glob[i+2:]
+				switch {
+				case strings.HasPrefix(rest, "/"):
+					b.WriteString("(.*/)?")
+					i += 2
+				default:
+					b.WriteString(".*")
+					i++
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
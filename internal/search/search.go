@@ -0,0 +1,522 @@
+// Package search implements the grep and glob tools: it runs the best
+// available backend (ast-grep for structural patterns, then rg, then grep,
+// then a pure-Go walker), honors .gitignore and .pfuiignore files, and
+// normalizes every backend's output to path/line/text matches.
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Backend names a search implementation.
+type Backend string
+
+const (
+	BackendAstGrep Backend = "ast-grep"
+	BackendRipgrep Backend = "rg"
+	BackendGrep    Backend = "grep"
+	// BackendGo is the built-in walker and regexp matcher, always available.
+	BackendGo Backend = "go"
+)
+
+const (
+	// maxLineText truncates matched lines, which may be minified or data.
+	maxLineText = 300
+	// maxFileSize skips larger files in the Go backend.
+	maxFileSize = 16 << 20
+	// grepBatch is how many files are handed to one grep invocation.
+	grepBatch = 200
+)
+
+// ErrNoAstGrep means a structural search was asked for without ast-grep installed.
+var ErrNoAstGrep = errors.New("ast-grep is not installed; use a regular expression instead")
+
+// Match is one matching line.
+type Match struct {
+	// Path is relative to the search root, slash-separated.
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// Query describes a content search.
+type Query struct {
+	// Pattern is a regular expression (RE2/Rust syntax) unless Literal or
+	// Structural is set.
+	Pattern string
+	// Root is the project root; ignore files are resolved against it and
+	// match paths are relative to it.
+	Root string
+	// Path is an absolute file or directory inside Root to search; empty
+	// means Root.
+	Path string
+	// Glob keeps only files whose name (or, if it contains a slash, whose
+	// path relative to Root) matches.
+	Glob       string
+	IgnoreCase bool
+	Literal    bool
+	// Structural treats Pattern as an ast-grep pattern, optionally for Lang.
+	Structural bool
+	Lang       string
+	// Limit stops the search after this many matches (0 means no limit).
+	Limit int
+	// Backend forces an implementation; empty picks the best available.
+	Backend Backend
+}
+
+// Available lists the search programs found on PATH, best first.
+func Available() []Backend {
+	var found []Backend
+	for _, b := range []Backend{BackendAstGrep, BackendRipgrep, BackendGrep} {
+		if _, err := exec.LookPath(string(b)); err == nil {
+			found = append(found, b)
+		}
+	}
+	return found
+}
+
+// pick chooses the backend for q.
+func pick(q Query) Backend {
+	if q.Backend != "" {
+		return q.Backend
+	}
+	if q.Structural {
+		return BackendAstGrep
+	}
+	for _, b := range Available() {
+		if b != BackendAstGrep {
+			return b
+		}
+	}
+	return BackendGo
+}
+
+// Grep runs q and returns matches in path then line order, with the backend
+// that produced them.
+func Grep(ctx context.Context, q Query) ([]Match, Backend, error) {
+	if strings.TrimSpace(q.Pattern) == "" {
+		return nil, "", errors.New("pattern is required")
+	}
+	if q.Path == "" {
+		q.Path = q.Root
+	}
+	backend := pick(q)
+	if q.Structural && backend != BackendAstGrep {
+		return nil, backend, errors.New("structural patterns need the ast-grep backend")
+	}
+	var re *regexp.Regexp
+	if !q.Structural {
+		// Validate up front so every backend rejects bad patterns the same way.
+		var err error
+		if re, err = compile(q); err != nil {
+			return nil, backend, fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	var glob *regexp.Regexp
+	if q.Glob != "" {
+		var err error
+		if glob, err = fileGlob(q.Glob); err != nil {
+			return nil, backend, err
+		}
+	}
+	s := &searcher{q: q, ignore: NewMatcher(q.Root), glob: glob}
+	var err error
+	switch backend {
+	case BackendAstGrep:
+		if _, lookErr := exec.LookPath(string(BackendAstGrep)); lookErr != nil {
+			return nil, backend, ErrNoAstGrep
+		}
+		err = s.astGrep(ctx)
+	case BackendRipgrep:
+		err = s.ripgrep(ctx)
+	case BackendGrep:
+		err = s.grep(ctx)
+	default:
+		backend = BackendGo
+		err = s.walk(ctx, func(rel, abs string) (bool, error) { return s.scanFile(rel, abs, re) })
+	}
+	if errors.Is(err, errLimit) {
+		err = nil
+	}
+	return s.matches, backend, err
+}
+
+// Glob returns files under dir (inside root) whose path relative to dir
+// matches pattern, sorted, skipping ignored files; at most limit when limit
+// is positive. "*" stays within a directory and "**" crosses them.
+func Glob(ctx context.Context, root, dir, pattern string, limit int) ([]string, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, errors.New("pattern is required")
+	}
+	re, err := regexp.Compile("^" + globRegexp(strings.TrimPrefix(filepath.ToSlash(pattern), "./")) + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	if dir == "" {
+		dir = root
+	}
+	s := &searcher{q: Query{Root: root, Path: dir, Limit: limit}, ignore: NewMatcher(root)}
+	var files []string
+	err = s.walk(ctx, func(rel, abs string) (bool, error) {
+		sub, err := filepath.Rel(dir, abs)
+		if err != nil || !re.MatchString(filepath.ToSlash(sub)) {
+			return false, nil
+		}
+		files = append(files, rel)
+		return limit > 0 && len(files) >= limit, nil
+	})
+	if errors.Is(err, errLimit) {
+		err = nil
+	}
+	return files, err
+}
+
+// errLimit stops a walk or process once enough results are in.
+var errLimit = errors.New("result limit reached")
+
+type searcher struct {
+	q       Query
+	ignore  *Matcher
+	glob    *regexp.Regexp
+	matches []Match
+}
+
+func compile(q Query) (*regexp.Regexp, error) {
+	pattern := q.Pattern
+	if q.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if q.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// fileGlob compiles a file filter the way rg's --glob does: without a slash
+// it matches the file name at any depth.
+func fileGlob(glob string) (*regexp.Regexp, error) {
+	glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+	expr := globRegexp(glob)
+	if strings.Contains(glob, "/") {
+		expr = "^" + strings.TrimPrefix(expr, "/") + "$"
+	} else {
+		expr = "(^|/)" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob: %w", err)
+	}
+	return re, nil
+}
+
+// keep reports whether a file (relative to the root) passes the ignore files
+// and the glob filter.
+func (s *searcher) keep(rel string) bool {
+	if s.ignore.Ignored(rel, false) {
+		return false
+	}
+	return s.glob == nil || s.glob.MatchString(rel)
+}
+
+// add records a match, returning errLimit once the limit is reached.
+func (s *searcher) add(rel string, line int, text string) error {
+	text = strings.TrimRight(text, "\r\n")
+	if len(text) > maxLineText {
+		cut := maxLineText
+		for cut > 0 && text[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		text = text[:cut] + "…"
+	}
+	s.matches = append(s.matches, Match{Path: rel, Line: line, Text: text})
+	if s.q.Limit > 0 && len(s.matches) >= s.q.Limit {
+		return errLimit
+	}
+	return nil
+}
+
+// rel converts a backend's path to one relative to the root.
+func (s *searcher) rel(p string) (string, bool) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.q.Root, p)
+	}
+	rel, err := filepath.Rel(s.q.Root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// walk visits the files under q.Path in lexical order, pruning ignored
+// directories. visit returns true to stop early.
+func (s *searcher) walk(ctx context.Context, visit func(rel, abs string) (bool, error)) error {
+	return filepath.WalkDir(s.q.Path, func(abs string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if abs == s.q.Path {
+				return err
+			}
+			// Unreadable entries are skipped, like rg does.
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		rel, ok := s.rel(abs)
+		if !ok {
+			return nil
+		}
+		if d.IsDir() {
+			if rel != "." && s.ignore.Ignored(rel, true) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || s.ignore.Ignored(rel, false) {
+			return nil
+		}
+		stop, err := visit(rel, abs)
+		if err != nil {
+			return err
+		}
+		if stop {
+			return errLimit
+		}
+		return nil
+	})
+}
+
+// scanFile is the Go backend's matcher.
+func (s *searcher) scanFile(rel, abs string, re *regexp.Regexp) (bool, error) {
+	if s.glob != nil && !s.glob.MatchString(rel) {
+		return false, nil
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return false, nil
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() > maxFileSize {
+		return false, nil
+	}
+	reader := bufio.NewReader(f)
+	if head, _ := reader.Peek(8 * 1024); bytes.IndexByte(head, 0) >= 0 {
+		return false, nil
+	}
+	line := 0
+	for {
+		text, err := reader.ReadString('\n')
+		if text == "" && err != nil {
+			return false, nil
+		}
+		line++
+		if re.MatchString(strings.TrimRight(text, "\r\n")) {
+			if addErr := s.add(rel, line, text); addErr != nil {
+				return false, addErr
+			}
+		}
+		if err != nil {
+			return false, nil
+		}
+	}
+}
+
+// ripgrep runs rg sorted by path so pages stay stable between calls. rg
+// applies .gitignore itself; .pfuiignore is enforced when filtering its output.
+func (s *searcher) ripgrep(ctx context.Context) error {
+	args := []string{"--line-number", "--with-filename", "--no-heading", "--color=never", "--null",
+		"--hidden", "--no-require-git", "--sort=path", "--glob=!.git"}
+	if s.q.IgnoreCase {
+		args = append(args, "--ignore-case")
+	}
+	if s.q.Literal {
+		args = append(args, "--fixed-strings")
+	}
+	if s.q.Glob != "" {
+		args = append(args, "--glob="+s.q.Glob)
+	}
+	args = append(args, "--regexp="+s.q.Pattern, "--", s.q.Path)
+	return s.runLines(ctx, string(BackendRipgrep), args, s.parseNullLine)
+}
+
+// grep runs grep over the files the walker finds, so ignore files apply the
+// same way as for the other backends.
+func (s *searcher) grep(ctx context.Context) error {
+	args := []string{"--line-number", "--with-filename", "--null", "--binary-files=without-match", "-E"}
+	if s.q.IgnoreCase {
+		args = append(args, "-i")
+	}
+	if s.q.Literal {
+		args[len(args)-1] = "-F"
+	}
+	args = append(args, "-e", s.q.Pattern, "--")
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.runLines(ctx, string(BackendGrep), append(append([]string(nil), args...), batch...), s.parseNullLine)
+		batch = batch[:0]
+		return err
+	}
+	err := s.walk(ctx, func(rel, abs string) (bool, error) {
+		if s.glob != nil && !s.glob.MatchString(rel) {
+			return false, nil
+		}
+		batch = append(batch, abs)
+		if len(batch) < grepBatch {
+			return false, nil
+		}
+		return false, flush()
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// parseNullLine reads "path\x00line:text" as printed by rg and grep --null.
+func (s *searcher) parseNullLine(raw string) error {
+	file, rest, ok := strings.Cut(raw, "\x00")
+	if !ok {
+		return nil
+	}
+	num, text, ok := strings.Cut(rest, ":")
+	if !ok {
+		return nil
+	}
+	line := 0
+	if _, err := fmt.Sscanf(num, "%d", &line); err != nil {
+		return nil
+	}
+	rel, ok := s.rel(file)
+	if !ok || !s.keep(rel) {
+		return nil
+	}
+	return s.add(rel, line, text)
+}
+
+// astGrep runs a structural search. ast-grep prints matches in no
+// particular order, so they are collected and sorted before paging.
+func (s *searcher) astGrep(ctx context.Context) error {
+	args := []string{"run", "--pattern", s.q.Pattern, "--json=stream"}
+	if s.q.Lang != "" {
+		args = append(args, "--lang", s.q.Lang)
+	}
+	if s.q.Glob != "" {
+		args = append(args, "--globs", s.q.Glob)
+	}
+	args = append(args, s.q.Path)
+	limit := s.q.Limit
+	s.q.Limit = 0
+	var all []Match
+	err := s.runLines(ctx, string(BackendAstGrep), args, func(raw string) error {
+		var m struct {
+			File  string `json:"file"`
+			Lines string `json:"lines"`
+			Range struct {
+				Start struct {
+					Line int `json:"line"`
+				} `json:"start"`
+			} `json:"range"`
+		}
+		if json.Unmarshal([]byte(raw), &m) != nil {
+			return nil
+		}
+		rel, ok := s.rel(m.File)
+		if !ok || !s.keep(rel) {
+			return nil
+		}
+		first, _, _ := strings.Cut(m.Lines, "\n")
+		all = append(all, Match{Path: rel, Line: m.Range.Start.Line + 1, Text: first})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Path != all[j].Path {
+			return all[i].Path < all[j].Path
+		}
+		return all[i].Line < all[j].Line
+	})
+	s.q.Limit = limit
+	for _, m := range all {
+		if err := s.add(m.Path, m.Line, m.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runLines runs a search program in the root and feeds each output line to
+// handle, stopping the program once handle returns an error (such as
+// errLimit). Exit status 1 means "no matches" for all three programs.
+func (s *searcher) runLines(ctx context.Context, name string, args []string, handle func(string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = s.q.Root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting %s: %w", name, err)
+	}
+	reader := bufio.NewReaderSize(stdout, 64*1024)
+	var stop error
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line != "" {
+			if stop = handle(strings.TrimRight(line, "\n")); stop != nil {
+				cancel()
+				break
+			}
+		}
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) {
+				stop = readErr
+			}
+			break
+		}
+	}
+	_, _ = io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+	if stop != nil {
+		return stop
+	}
+	var exitErr *exec.ExitError
+	if errors.As(waitErr, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil
+	}
+	if waitErr != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", name, firstLine(msg))
+		}
+		return fmt.Errorf("%s: %w", name, waitErr)
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
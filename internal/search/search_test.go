@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, body := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMatcherFollowsGitignoreRules(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "*.log\n/build/\n!keep.log\ndocs/**/draft*\n",
+		".pfuiignore":     "secrets\n",
+		"sub/.gitignore":  "local.txt\n",
+		"sub/local.txt":   "",
+		"other/local.txt": "",
+	})
+	m := NewMatcher(root)
+	cases := []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{"app.log", false, true},
+		{"deep/x/app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build/out.bin", false, true},
+		{"sub/build", true, false},
+		{"docs/a/b/draft1.md", false, true},
+		{"secrets/key.pem", false, true},
+		{"sub/local.txt", false, true},
+		{"other/local.txt", false, false},
+		{".git/config", false, true},
+		{"main.go", false, false},
+	}
+	for _, c := range cases {
+		if got := m.Ignored(c.path, c.dir); got != c.want {
+			t.Errorf("Ignored(%q) = %t, want %t", c.path, got, c.want)
+		}
+	}
+}
+
+func TestGrepBackendsAgreeAndHonorIgnores(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":  "vendor/\n",
+		".pfuiignore": "*.gen.go\n",
+		"a.go":        "package a\n\nfunc Hello() {}\n",
+		"b/b.go":      "package b\n// hello there\nfunc hello() {}\n",
+		"b/notes.txt": "Hello from notes\n",
+		"vendor/v.go": "func Hello() {}\n",
+		"z.gen.go":    "func Hello() {}\n",
+		"bin/blob":    "Hello\x00\x01",
+	})
+	want := []Match{
+		{Path: "a.go", Line: 3, Text: "func Hello() {}"},
+		{Path: "b/b.go", Line: 2, Text: "// hello there"},
+		{Path: "b/b.go", Line: 3, Text: "func hello() {}"},
+	}
+	backends := []Backend{BackendGo}
+	for _, b := range []Backend{BackendRipgrep, BackendGrep} {
+		if _, err := exec.LookPath(string(b)); err == nil {
+			backends = append(backends, b)
+		}
+	}
+	for _, backend := range backends {
+		got, used, err := Grep(context.Background(), Query{Pattern: "hello", Root: root, Glob: "*.go", IgnoreCase: true, Backend: backend})
+		if err != nil {
+			t.Fatalf("%s: %v", backend, err)
+		}
+		if used != backend || !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got %+v", used, got)
+		}
+		limited, _, err := Grep(context.Background(), Query{Pattern: "hello", Root: root, Path: filepath.Join(root, "b"), IgnoreCase: true, Limit: 2, Backend: backend})
+		if err != nil || len(limited) != 2 || limited[0].Path != "b/b.go" {
+			t.Fatalf("%s: limited search got %+v, %v", backend, limited, err)
+		}
+	}
+	if _, _, err := Grep(context.Background(), Query{Pattern: "(", Root: root, Backend: BackendGo}); err == nil {
+		t.Fatal("expected an invalid pattern error")
+	}
+}
+
+func TestGlobSkipsIgnoredFiles(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":              "node_modules/\n",
+		"main.go":                 "",
+		"cmd/x/main.go":           "",
+		"cmd/x/x_test.go":         "",
+		"node_modules/m/index.go": "",
+	})
+	got, err := Glob(context.Background(), root, "", "**/*.go", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"cmd/x/main.go", "cmd/x/x_test.go", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+	got, _ = Glob(context.Background(), root, filepath.Join(root, "cmd"), "*/main.go", 0)
+	if want := []string{"cmd/x/main.go"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v", got)
+	}
+	if got, _ := Glob(context.Background(), root, "", "**/*.go", 1); len(got) != 1 {
+		t.Fatalf("expected the limit to apply, got %v", got)
+	}
+}
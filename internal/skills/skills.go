@@ -0,0 +1,226 @@
+// Package skills discovers skills: directories holding a SKILL.md whose
+// frontmatter names and describes the skill and whose body carries the
+// instructions, next to any scripts and resources they refer to. Only the
+// name and description reach the system prompt; the model pulls the body
+// in with the load_skill tool when a skill fits the task.
+package skills
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fbettag/pfui/internal/frontmatter"
+)
+
+// FileName is the file that makes a directory a skill.
+const FileName = "SKILL.md"
+
+// maxResources caps how many files a skill lists besides SKILL.md.
+const maxResources = 100
+
+// Scope records where a skill came from.
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"
+	ScopeProject Scope = "project"
+)
+
+// Skill is one discovered skill.
+type Skill struct {
+	Name        string
+	Description string
+	// Body is the Markdown after the frontmatter: the instructions
+	// load_skill hands the model.
+	Body string
+	Dir  string
+	// Resources lists the other files in Dir, relative to it.
+	Resources []string
+	Scope     Scope
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// UserDir returns ~/.pfui/skills, honoring PFUI_HOME.
+func UserDir() (string, error) {
+	if custom := os.Getenv("PFUI_HOME"); custom != "" {
+		return filepath.Join(custom, "skills"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".pfui", "skills"), nil
+}
+
+// ProjectDir returns the project's .pfui/skills directory.
+func ProjectDir(projectRoot string) string {
+	return filepath.Join(projectRoot, ".pfui", "skills")
+}
+
+// Load discovers the user skills and then the project ones; a project
+// skill replaces a user skill of the same name. Skills that fail to load
+// are reported in the error list and skipped.
+func Load(projectRoot string) ([]Skill, []error) {
+	byName := make(map[string]Skill)
+	var errs []error
+	if dir, err := UserDir(); err != nil {
+		errs = append(errs, err)
+	} else {
+		errs = append(errs, loadDir(dir, ScopeUser, byName)...)
+	}
+	if projectRoot != "" {
+		errs = append(errs, loadDir(ProjectDir(projectRoot), ScopeProject, byName)...)
+	}
+	out := make([]Skill, 0, len(byName))
+	for _, skill := range byName {
+		out = append(out, skill)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, errs
+}
+
+func loadDir(dir string, scope Scope, into map[string]Skill) []error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", FileName))
+	if err != nil {
+		return []error{fmt.Errorf("listing %s: %w", dir, err)}
+	}
+	sort.Strings(paths)
+	var errs []error
+	for _, path := range paths {
+		skill, err := LoadDir(filepath.Dir(path))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		skill.Scope = scope
+		into[skill.Name] = skill
+	}
+	return errs
+}
+
+// LoadDir reads the skill in dir. The name defaults to the directory name.
+func LoadDir(dir string) (Skill, error) {
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Skill{}, fmt.Errorf("reading skill: %w", err)
+	}
+	doc, err := frontmatter.Parse(data)
+	if err != nil {
+		return Skill{}, fmt.Errorf("%s: %w", path, err)
+	}
+	skill := Skill{
+		Name:        strings.ToLower(strings.TrimSpace(doc.Get("name"))),
+		Description: strings.TrimSpace(doc.Get("description")),
+		Body:        strings.TrimSpace(doc.Body),
+		Dir:         dir,
+	}
+	if skill.Name == "" {
+		skill.Name = strings.ToLower(filepath.Base(dir))
+	}
+	if !validName.MatchString(skill.Name) {
+		return Skill{}, fmt.Errorf("%s: name %q must be lowercase letters, digits, - or _", path, skill.Name)
+	}
+	if skill.Description == "" {
+		return Skill{}, fmt.Errorf("%s: description is required so the model knows when to load %s", path, skill.Name)
+	}
+	if skill.Body == "" {
+		return Skill{}, fmt.Errorf("%s: the skill has no instructions", path)
+	}
+	skill.Resources = listResources(dir)
+	return skill, nil
+}
+
+// listResources walks dir for scripts and reference files, skipping
+// hidden entries.
+func listResources(dir string) []string {
+	var out []string
+	errFull := errors.New("full")
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == FileName {
+			return nil
+		}
+		if len(out) == maxResources {
+			return errFull
+		}
+		out = append(out, filepath.ToSlash(rel))
+		return nil
+	})
+	return out
+}
+
+// ReadResource returns one of the skill's files. name is relative to the
+// skill directory and may not leave it.
+func (s Skill) ReadResource(name string, limit int) (content string, truncated bool, err error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("%s is outside the skill directory", name)
+	}
+	data, err := os.ReadFile(filepath.Join(s.Dir, clean))
+	if err != nil {
+		return "", false, fmt.Errorf("reading skill file: %w", err)
+	}
+	if len(data) > limit {
+		data, truncated = data[:limit], true
+		// Drop a rune the cut split in half.
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if !utf8.Valid(data) {
+		return "", false, fmt.Errorf("%s is not a text file", name)
+	}
+	return string(data), truncated, nil
+}
+
+// Entry is the line the system prompt carries for the skill.
+func (s Skill) Entry() string {
+	return s.Name + ": " + s.Description
+}
+
+// PromptTokens estimates what listing the skill in the system prompt costs.
+func (s Skill) PromptTokens() int {
+	return EstimateTokens(s.Entry())
+}
+
+// BodyTokens estimates what loading the skill costs.
+func (s Skill) BodyTokens() int {
+	return EstimateTokens(s.Body)
+}
+
+// EstimateTokens approximates a tokenizer at four characters per token;
+// close enough to compare skills without knowing the model.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// Find returns the skill called name.
+func Find(list []Skill, name string) (Skill, bool) {
+	for _, skill := range list {
+		if skill.Name == name {
+			return skill, true
+		}
+	}
+	return Skill{}, false
+}
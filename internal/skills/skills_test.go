@@ -0,0 +1,56 @@
+package skills
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDiscoversSkillDirectories(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("PFUI_HOME", home)
+	for path, content := range map[string]string{
+		filepath.Join(home, "skills", "release", FileName):                    "---\nname: release\ndescription: user release steps\n---\nTag it.\n",
+		filepath.Join(home, "skills", "pdf", FileName):                        "---\ndescription: Fill and merge PDF forms\n---\n# PDF\n\nRun scripts/fill.py.\n",
+		filepath.Join(home, "skills", "pdf", "scripts", "fill.py"):            "print('fill')\n",
+		filepath.Join(home, "skills", "pdf", "reference.md"):                  "Field names.\n",
+		filepath.Join(home, "skills", "pdf", ".cache", "x"):                   "hidden",
+		filepath.Join(project, ".pfui", "skills", "release", FileName):        "---\ndescription: project release steps\n---\nBump the version, then tag it.\n",
+		filepath.Join(project, ".pfui", "skills", "empty", FileName):          "---\ndescription: nothing inside\n---\n",
+		filepath.Join(project, ".pfui", "skills", "not-a-skill", "README.md"): "ignored",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, errs := Load(project)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "no instructions") {
+		t.Fatalf("expected one error for the empty skill, got %v", errs)
+	}
+	if len(list) != 2 || list[0].Name != "pdf" || list[1].Name != "release" {
+		t.Fatalf("unexpected skills %+v", list)
+	}
+	pdf, release := list[0], list[1]
+	if pdf.Scope != ScopeUser || pdf.Body != "# PDF\n\nRun scripts/fill.py." || strings.Join(pdf.Resources, ",") != "reference.md,scripts/fill.py" {
+		t.Fatalf("unexpected pdf skill %+v", pdf)
+	}
+	if release.Scope != ScopeProject || release.Description != "project release steps" {
+		t.Fatalf("the project skill should win: %+v", release)
+	}
+	if pdf.Entry() != "pdf: Fill and merge PDF forms" || pdf.PromptTokens() != 8 || pdf.BodyTokens() != 7 {
+		t.Fatalf("entry %q costs %d/%d tokens", pdf.Entry(), pdf.PromptTokens(), pdf.BodyTokens())
+	}
+
+	content, truncated, err := pdf.ReadResource("scripts/fill.py", 5)
+	if err != nil || content != "print" || !truncated {
+		t.Fatalf("ReadResource = %q, %v, %v", content, truncated, err)
+	}
+	if _, _, err := pdf.ReadResource("../release/SKILL.md", 1024); err == nil {
+		t.Fatal("expected paths outside the skill to be refused")
+	}
+}
@@ -20,6 +20,12 @@ func TestBuildIncludesExecTool(t *testing.T) {
 	if !strings.Contains(prompt, "PLAN describe the steps") {
 		t.Fatalf("prompt missing plan instructions: %s", prompt)
 	}
+	if !strings.Contains(prompt, "- unit-tests\n") || !strings.Contains(prompt, "load_skill") {
+		t.Fatalf("prompt missing skills: %s", prompt)
+	}
+	if !strings.Contains(prompt, "code-search") || !strings.Contains(prompt, "task tool") {
+		t.Fatalf("prompt missing subagents: %s", prompt)
+	}
 	if !strings.Contains(prompt, "project, user") {
 		t.Fatalf("prompt missing MCP scopes: %s", prompt)
 	}
@@ -0,0 +1,188 @@
+package toolexec
+
+import "sync"
+
+// DefaultSubscriberBuffer is how many events a subscriber may fall behind
+// before output lines are dropped.
+const DefaultSubscriberBuffer = 256
+
+// Bus fans executor events out to any number of subscribers. Each one has its
+// own bounded queue, so a slow consumer never blocks the executor or another
+// subscriber:
+//
+//   - status events are never dropped, and a job's terminal status is always
+//     delivered; a pending non-terminal status is replaced by a newer one;
+//   - screen events coalesce, keeping only the latest screen per job;
+//   - output lines are dropped once the queue is full, and the next event
+//     delivered to that subscriber counts them in Dropped.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewBus returns an empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives events published after it was created.
+type Subscription struct {
+	bus  *Bus
+	size int
+	out  chan Event
+
+	mu      sync.Mutex
+	queue   []Event
+	dropped int
+	wake    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Subscribe registers a subscriber whose queue holds up to size events
+// (DefaultSubscriberBuffer when size <= 0). Callers must Close it when done.
+func (b *Bus) Subscribe(size int) *Subscription {
+	if size <= 0 {
+		size = DefaultSubscriberBuffer
+	}
+	s := &Subscription{
+		bus:  b,
+		size: size,
+		out:  make(chan Event),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(s.done)
+		close(s.out)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	go s.pump()
+	return s
+}
+
+// Publish queues ev for every subscriber without blocking.
+func (b *Bus) Publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		s.push(ev)
+	}
+}
+
+// Close ends every subscription and closes their channels; undelivered
+// events are discarded.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = make(map[*Subscription]struct{})
+	b.closed = true
+	b.mu.Unlock()
+	for s := range subs {
+		s.stop()
+	}
+}
+
+// Events returns the subscriber's channel. It is closed after Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.out
+}
+
+// Close unsubscribes and closes the channel.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mu.Unlock()
+	s.stop()
+}
+
+func (s *Subscription) stop() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// push applies the queueing rules described on Bus.
+func (s *Subscription) push(ev Event) {
+	s.mu.Lock()
+	switch ev.Kind {
+	case EventStatus:
+		if !ev.Job.Status.Finished() {
+			if i := s.pendingLocked(EventStatus, ev.Job.ID); i >= 0 && !s.queue[i].Job.Status.Finished() {
+				s.queue[i] = ev
+				break
+			}
+		}
+		s.queue = append(s.queue, ev)
+	case EventScreen:
+		if i := s.pendingLocked(EventScreen, ev.Job.ID); i >= 0 {
+			s.queue[i] = ev
+			break
+		}
+		if len(s.queue) >= s.size {
+			s.dropped++
+			break
+		}
+		s.queue = append(s.queue, ev)
+	default:
+		if len(s.queue) >= s.size {
+			s.dropped++
+			break
+		}
+		s.queue = append(s.queue, ev)
+	}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pendingLocked finds the last queued event of kind for job, or -1. A status
+// event queued after it stops the search so updates never jump over a
+// status change.
+func (s *Subscription) pendingLocked(kind EventKind, job string) int {
+	for i := len(s.queue) - 1; i >= 0; i-- {
+		ev := s.queue[i]
+		if ev.Job.ID != job {
+			continue
+		}
+		if ev.Kind == kind {
+			return i
+		}
+		if ev.Kind == EventStatus {
+			return -1
+		}
+	}
+	return -1
+}
+
+// pump hands queued events to the channel in order.
+func (s *Subscription) pump() {
+	defer close(s.out)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		ev := s.queue[0]
+		s.queue[0] = Event{}
+		s.queue = s.queue[1:]
+		ev.Dropped, s.dropped = s.dropped, 0
+		s.mu.Unlock()
+		select {
+		case s.out <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
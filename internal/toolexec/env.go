@@ -0,0 +1,87 @@
+package toolexec
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultEnvDeny lists variables scrubbed from every command unless a policy
+// overrides the denylist: provider keys, cloud credentials, and pfui secrets.
+var DefaultEnvDeny = []string{
+	"*_TOKEN", "*_KEY", "*_SECRET", "*_SECRET_*", "*_PASSWORD", "*_PASSWD",
+	"*_CREDENTIALS", "*_ACCESS_KEY_ID", "*_API_KEY_*", "PFUI_*",
+}
+
+// redactedValue replaces every value recorded in job metadata.
+const redactedValue = "[redacted]"
+
+// EnvPolicy decides which environment variables a command sees. Patterns use
+// shell globs matched case-sensitively against variable names.
+type EnvPolicy struct {
+	// Inherit lists variables passed through from pfui; empty inherits everything.
+	Inherit []string
+	// Deny removes matching variables even when Inherit allows them.
+	Deny []string
+	// Allow exempts variables from Deny, e.g. a GITHUB_TOKEN the operator
+	// wants commands to see.
+	Allow []string
+	// Set injects variables after filtering; they bypass Deny.
+	Set map[string]string
+}
+
+// DefaultEnvPolicy inherits everything except DefaultEnvDeny.
+func DefaultEnvPolicy() EnvPolicy {
+	return EnvPolicy{Deny: append([]string(nil), DefaultEnvDeny...)}
+}
+
+// Apply filters base (KEY=VALUE pairs, usually os.Environ) and adds Set.
+// The result is sorted by name so job metadata is stable.
+func (p EnvPolicy) Apply(base []string) []string {
+	vars := make(map[string]string, len(base)+len(p.Set))
+	for _, kv := range base {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+		if len(p.Inherit) > 0 && !matchAnyEnv(p.Inherit, name) {
+			continue
+		}
+		if matchAnyEnv(p.Deny, name) && !matchAnyEnv(p.Allow, name) {
+			continue
+		}
+		vars[name] = value
+	}
+	for name, value := range p.Set {
+		vars[name] = value
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		env = append(env, name+"="+vars[name])
+	}
+	return env
+}
+
+// RedactEnv keeps variable names and hides their values.
+func RedactEnv(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		out = append(out, name+"="+redactedValue)
+	}
+	return out
+}
+
+func matchAnyEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,13 @@
 package toolexec
 
 import (
-	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,70 +16,309 @@ import (
 
 // Request captures a shell execution request exposed to the agent.
 type Request struct {
+	// Tool names the agent tool issuing the request; empty means "exec".
+	Tool       string
 	Command    string
 	Args       []string
 	Workdir    string
 	Background bool
+	// Timeout stops the command (via the same escalation as a cancel) once
+	// elapsed. Zero falls back to the executor's default timeout.
+	Timeout time.Duration
+	// Reason is the model's explanation, shown when asking for approval.
+	Reason string
+	// PTY runs the command on a pseudo-terminal so prompts, password reads,
+	// and progress bars behave; the operator can type into it via WriteInput.
+	PTY bool
+	// CallID is the model's tool call ID, carried into the job and audit log.
+	CallID string
+	// Preview is shown to the operator when approval is needed, e.g. the
+	// diff a file edit would apply.
+	Preview string
 }
 
 // Result captures the outcome of a foreground execution.
 type Result struct {
+	JobID string
+	// Command and Args echo what actually ran (an approver may edit them).
+	Command  string
+	Args     []string
 	Output   string
+	Stdout   string
+	Stderr   string
 	ExitCode int
+	// Detached reports that the operator moved the command to the background
+	// before it finished; JobID keeps tracking it.
+	Detached bool
+	// QueuePosition is set when a background job had to wait for a free
+	// slot (1 is next).
+	QueuePosition int
 }
 
 // JobStatus describes the lifecycle milestone of a background job.
 type JobStatus string
 
 const (
-	JobRunning JobStatus = "running"
-	JobSuccess JobStatus = "success"
-	JobFailed  JobStatus = "failed"
+	JobRunning  JobStatus = "running"
+	JobSuccess  JobStatus = "success"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+	JobTimedOut JobStatus = "timed_out"
+	// JobQueued marks a background job waiting for a free slot.
+	JobQueued JobStatus = "queued"
 )
 
+// Finished reports whether a job in this state has ended.
+func (s JobStatus) Finished() bool {
+	return s != "" && s != JobRunning && s != JobQueued
+}
+
+// waitPoll is how often Wait checks on a job.
+const waitPoll = 100 * time.Millisecond
+
+// DefaultKillGrace is how long a canceled command gets between SIGINT, SIGTERM, and SIGKILL.
+const DefaultKillGrace = 2 * time.Second
+
+const (
+	// DefaultMaxJobs is how many background jobs run at once before new ones queue.
+	DefaultMaxJobs = 4
+	// DefaultKeepJobs is how many finished jobs are kept for /jobs and the store.
+	DefaultKeepJobs = 100
+	// DefaultKeepFor is how long finished jobs are kept.
+	DefaultKeepFor = 7 * 24 * time.Hour
+)
+
+var errTimedOut = errors.New("command timed out")
+
+// Options tune executor behavior.
+type Options struct {
+	// KillGrace is the wait between escalation signals on cancel or timeout.
+	KillGrace time.Duration
+	// DefaultTimeout applies to requests without their own timeout (zero means none).
+	DefaultTimeout time.Duration
+	// Wrapper, when set, rewrites every command before it starts.
+	Wrapper Wrapper
+	// Approver, when set, vets every request before it runs.
+	Approver Approver
+	// Env filters the environment commands inherit; nil applies DefaultEnvPolicy.
+	Env *EnvPolicy
+	// Store, when set, persists background jobs so they outlive pfui.
+	Store *Store
+	// SessionID tags persisted jobs with the chat that started them.
+	SessionID string
+	// Auditor, when set, is told about every request, start, and end.
+	Auditor Auditor
+	// MaxJobs caps concurrently running background jobs; further jobs wait
+	// in a FIFO queue. Zero means DefaultMaxJobs, negative means no limit.
+	MaxJobs int
+	// Limits caps the CPU and memory of every command.
+	Limits Limits
+	// KeepJobs and KeepFor bound how many finished jobs are remembered and
+	// for how long. Zero means DefaultKeepJobs and DefaultKeepFor.
+	KeepJobs int
+	KeepFor  time.Duration
+}
+
+// Approver decides whether a request may run; a non-nil error blocks it. The
+// returned request replaces the original, so an operator can edit a command
+// before approving it.
+type Approver interface {
+	Approve(ctx context.Context, req Request) (Request, error)
+}
+
+// Auditor observes the executor, e.g. to keep a tamper-evident log. Request
+// sees the request before approval; Start and End see the job as it begins
+// and once it has finished (including background jobs). Files sees what an
+// approved tool that changes files without running a command (a file edit)
+// did, and the error that stopped it, if any.
+type Auditor interface {
+	AuditRequest(req Request)
+	AuditStart(job Job)
+	AuditEnd(job Job)
+	AuditFiles(req Request, files []FileChange, err error)
+}
+
+// FileChange is a file a tool changed without running a command.
+type FileChange struct {
+	Path string
+	// Op is created, modified, deleted, or moved.
+	Op string
+	// From is where a moved file came from.
+	From string
+}
+
+// Wrapper rewrites a prepared command before it starts, e.g. to run it inside a sandbox.
+type Wrapper interface {
+	Wrap(cmd *exec.Cmd) error
+}
+
 // Job carries metadata about a background execution.
 type Job struct {
 	ID        string
+	SessionID string
+	CallID    string
 	Command   string
 	Args      []string
-	StartedAt time.Time
-	EndedAt   time.Time
-	Status    JobStatus
-	ExitCode  int
-	Output    string
-	Error     string
+	Workdir   string
+	PID       int
+	// PIDStart is the process start time recorded with PID (clock ticks since
+	// boot on Linux, 0 where unknown), so a recycled PID is not taken for the job.
+	PIDStart   uint64
+	Foreground bool
+	StartedAt  time.Time
+	EndedAt    time.Time
+	Status     JobStatus
+	ExitCode   int
+	Timeout    time.Duration
+	PTY        bool
+	// QueuePosition is the 1-based place in the queue while Status is JobQueued.
+	QueuePosition int
+	// Env is the environment the command saw, with values redacted.
+	Env []string
+	// Output interleaves stdout and stderr; Stdout/Stderr hold each stream alone.
+	// All three reflect the bounded ring buffer and update while the job runs.
+	Output string
+	Stdout string
+	Stderr string
+	Error  string
 }
 
-// Event is emitted whenever a job changes status.
+// EventKind distinguishes status transitions from streamed output.
+type EventKind string
+
+const (
+	EventStatus EventKind = "status"
+	EventOutput EventKind = "output"
+	// EventScreen carries the visible lines of a PTY command, including an
+	// unfinished prompt line.
+	EventScreen EventKind = "screen"
+)
+
+// Event is emitted whenever a job changes status or prints a line. Output
+// events carry a Job snapshot without the Output/Stdout/Stderr fields.
 type Event struct {
-	Job Job
+	Kind   EventKind
+	Job    Job
+	Line   OutputLine
+	Screen []string
+	// Dropped counts output events this subscriber lost to a full queue
+	// since the previous event it received.
+	Dropped int
+}
+
+type jobRecord struct {
+	job    Job
+	output *outputRing
+	env    []string
+	// pty and screen are set while a PTY command runs.
+	pty    *os.File
+	screen *ptyScreen
+	// detach is closed when the operator backgrounds a foreground command.
+	detach chan struct{}
+	// log receives the job's output when it is persisted: the command writes
+	// to it directly, or (for PTY and detached jobs) pfui copies lines into it.
+	log    *jobLogs
+	direct bool
+	// scoped marks commands started inside a cgroup scope.
+	scoped bool
+}
+
+func (r *jobRecord) snapshot() Job {
+	job := r.job
+	job.Args = append([]string(nil), r.job.Args...)
+	job.Env = append([]string(nil), r.job.Env...)
+	job.Output = r.output.text("")
+	job.Stdout = r.output.text(StreamStdout)
+	job.Stderr = r.output.text(StreamStderr)
+	return job
 }
 
 type foregroundCmd struct {
 	cancel context.CancelFunc
+	record *jobRecord
+}
+
+type queuedJob struct {
+	rec *jobRecord
+	req Request
 }
 
 // Executor coordinates foreground/ background shell execution.
 type Executor struct {
 	mu         sync.Mutex
 	foreground *foregroundCmd
-	jobs       map[string]*Job
+	jobs       map[string]*jobRecord
 	cancels    map[string]context.CancelFunc
-	events     chan Event
+	bus        *Bus
+	opts       Options
+	wrapper    Wrapper
+	env        EnvPolicy
+	// queue holds background jobs waiting for a slot; running counts the
+	// background jobs (including detached ones) currently holding one.
+	queue   []queuedJob
+	running int
 }
 
-// NewExecutor creates an Executor instance.
+// NewExecutor creates an Executor instance with default options.
 func NewExecutor() *Executor {
+	return NewExecutorWithOptions(Options{})
+}
+
+// NewExecutorWithOptions creates an Executor with custom cancellation settings.
+func NewExecutorWithOptions(opts Options) *Executor {
+	if opts.KillGrace <= 0 {
+		opts.KillGrace = DefaultKillGrace
+	}
+	if opts.MaxJobs == 0 {
+		opts.MaxJobs = DefaultMaxJobs
+	}
+	if opts.KeepJobs <= 0 {
+		opts.KeepJobs = DefaultKeepJobs
+	}
+	if opts.KeepFor <= 0 {
+		opts.KeepFor = DefaultKeepFor
+	}
+	env := DefaultEnvPolicy()
+	if opts.Env != nil {
+		env = *opts.Env
+	}
 	return &Executor{
-		jobs:    make(map[string]*Job),
+		jobs:    make(map[string]*jobRecord),
 		cancels: make(map[string]context.CancelFunc),
-		events:  make(chan Event, 32),
+		bus:     NewBus(),
+		opts:    opts,
+		wrapper: opts.Wrapper,
+		env:     env,
 	}
 }
 
-// Events exposes a stream of job updates for UI consumers.
-func (e *Executor) Events() <-chan Event {
-	return e.events
+// SetWrapper swaps the command wrapper for subsequent runs (nil disables it).
+func (e *Executor) SetWrapper(w Wrapper) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.wrapper = w
+}
+
+// SetEnvPolicy replaces the environment policy for subsequent runs.
+func (e *Executor) SetEnvPolicy(p EnvPolicy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.env = p
+}
+
+// commandEnv applies the current policy to pfui's own environment.
+func (e *Executor) commandEnv() []string {
+	e.mu.Lock()
+	policy := e.env
+	e.mu.Unlock()
+	return policy.Apply(os.Environ())
+}
+
+// Subscribe returns a subscription to job updates published from now on.
+// Every subscriber (the TUI, hooks, the exec CLI) gets its own bounded queue;
+// see Bus for what may be coalesced or dropped.
+func (e *Executor) Subscribe() *Subscription {
+	return e.bus.Subscribe(DefaultSubscriberBuffer)
 }
 
 // Run executes the request in either foreground or background mode.
@@ -85,14 +326,61 @@ func (e *Executor) Run(ctx context.Context, req Request) (Result, string, error)
 	if req.Command == "" {
 		return Result{}, "", errors.New("command is required")
 	}
+	if req.Timeout < 0 {
+		return Result{}, "", errors.New("timeout must not be negative")
+	}
+	if req.Timeout == 0 {
+		req.Timeout = e.opts.DefaultTimeout
+	}
+	if req.Tool == "" {
+		req.Tool = "exec"
+	}
+	req, err := e.Authorize(ctx, req)
+	if err != nil {
+		return Result{}, "", err
+	}
 	if req.Background {
 		id, err := e.startBackground(req)
-		return Result{}, id, err
+		var res Result
+		if job, ok := e.Job(id); ok && job.Status == JobQueued {
+			res.QueuePosition = job.QueuePosition
+		}
+		return res, id, err
 	}
 	res, err := e.runForeground(ctx, req)
 	return res, "", err
 }
 
+// Authorize audits req and asks the Approver about it, returning the request
+// to carry out (an operator may have edited it). Tools that change files
+// without running a command call it directly.
+func (e *Executor) Authorize(ctx context.Context, req Request) (Request, error) {
+	if e.opts.Auditor != nil {
+		e.opts.Auditor.AuditRequest(req)
+	}
+	if e.opts.Approver == nil {
+		return req, nil
+	}
+	return e.opts.Approver.Approve(ctx, req)
+}
+
+// AuditRequest passes a tool request that does not run a command (such as
+// a file read) to the configured Auditor, keeping one audit trail for all
+// tools.
+func (e *Executor) AuditRequest(req Request) {
+	if e.opts.Auditor != nil {
+		e.opts.Auditor.AuditRequest(req)
+	}
+}
+
+// AuditFiles reports the files an authorized tool changed (and the error
+// that stopped it, if any) to the configured Auditor.
+func (e *Executor) AuditFiles(req Request, files []FileChange, err error) {
+	if e.opts.Auditor != nil {
+		e.opts.Auditor.AuditFiles(req, files, err)
+	}
+}
+
 // CancelForeground aborts the active foreground process if any.
 func (e *Executor) CancelForeground() bool {
 	e.mu.Lock()
@@ -119,102 +407,575 @@ func (e *Executor) CancelJob(id string) bool {
 	return ok
 }
 
+// Job returns a snapshot of a background job, including its buffered output.
+func (e *Executor) Job(id string) (Job, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rec, ok := e.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return rec.snapshot(), true
+}
+
+// Wait blocks until the job finishes or ctx ends, returning its latest
+// snapshot either way.
+func (e *Executor) Wait(ctx context.Context, id string) (Job, error) {
+	ticker := time.NewTicker(waitPoll)
+	defer ticker.Stop()
+	for {
+		job, ok := e.Job(id)
+		if !ok {
+			return Job{}, fmt.Errorf("job %s not found", id)
+		}
+		if job.Status.Finished() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tail returns the last n buffered lines of a background job (all when n <= 0).
+func (e *Executor) Tail(id string, n int) ([]OutputLine, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rec, ok := e.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	return rec.output.tail(n), nil
+}
+
 func (e *Executor) runForeground(ctx context.Context, req Request) (Result, error) {
-	ctx, cancel := context.WithCancel(ctx)
-	cmd := exec.CommandContext(ctx, req.Command, req.Args...)
-	if req.Workdir != "" {
-		cmd.Dir = filepath.Clean(req.Workdir)
+	// The command context outlives ctx only once detached; until then a
+	// canceled caller still stops it.
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	rec := newJobRecord(req, e.commandEnv())
+	rec.job.Foreground = true
+	rec.detach = make(chan struct{})
+
+	e.mu.Lock()
+	e.foreground = &foregroundCmd{cancel: cancel, record: rec}
+	e.mu.Unlock()
+	e.emitStatus(rec)
+
+	done := make(chan error, 1)
+	go func() { done <- e.runCommand(runCtx, req, rec) }()
+
+	var err error
+	exited := false
+	select {
+	case err = <-done:
+		exited = true
+	case <-rec.detach:
 	}
-	var buffer bytes.Buffer
-	cmd.Stdout = &buffer
-	cmd.Stderr = &buffer
+	stop()
 
+	// The command may exit just as the operator detaches it. Detach closes
+	// rec.detach under e.mu, so deciding under the same lock settles which
+	// happened: a detached job releases the slot Detach took exactly once,
+	// and a finished one can no longer be detached.
 	e.mu.Lock()
-	e.foreground = &foregroundCmd{cancel: cancel}
+	detached := false
+	select {
+	case <-rec.detach:
+		detached = true
+	default:
+		if e.foreground != nil && e.foreground.record == rec {
+			e.foreground = nil
+		}
+	}
+	job := rec.snapshot()
 	e.mu.Unlock()
 
-	err := cmd.Run()
+	if detached {
+		go func() {
+			if !exited {
+				<-done
+			}
+			cancel()
+			// Detach may have opened the log after the command finished.
+			e.finishPersisted(rec)
+			e.mu.Lock()
+			delete(e.cancels, job.ID)
+			e.mu.Unlock()
+			e.emitStatus(rec)
+			e.jobDone()
+		}()
+		e.emitStatus(rec)
+		return Result{
+			JobID:    job.ID,
+			Command:  job.Command,
+			Args:     job.Args,
+			Output:   job.Output,
+			Stdout:   job.Stdout,
+			Stderr:   job.Stderr,
+			Detached: true,
+		}, nil
+	}
+	cancel()
+	e.emitStatus(rec)
+
+	return Result{
+		JobID:    job.ID,
+		Command:  job.Command,
+		Args:     job.Args,
+		Output:   job.Output,
+		Stdout:   job.Stdout,
+		Stderr:   job.Stderr,
+		ExitCode: job.ExitCode,
+	}, err
+}
 
+// Detach moves the foreground command to the background, returning its job
+// ID. The pending Run call returns immediately with Result.Detached set.
+func (e *Executor) Detach() (string, bool) {
 	e.mu.Lock()
+	defer e.mu.Unlock()
+	fg := e.foreground
+	if fg == nil || fg.record.detach == nil {
+		return "", false
+	}
+	rec := fg.record
 	e.foreground = nil
+	rec.job.Foreground = false
+	e.jobs[rec.job.ID] = rec
+	// A detached command holds a job slot but never waits for one.
+	e.running++
+	e.cancels[rec.job.ID] = fg.cancel
+	close(rec.detach)
+	if err := e.persistLocked(rec, false); err != nil {
+		rec.output.add(OutputLine{Stream: StreamStderr, Text: "pfui: " + err.Error(), At: time.Now()})
+	}
+	return rec.job.ID, true
+}
+
+// WriteInput sends keystrokes to a running PTY command (foreground or job).
+func (e *Executor) WriteInput(id string, data []byte) error {
+	e.mu.Lock()
+	var rec *jobRecord
+	if e.foreground != nil && e.foreground.record.job.ID == id {
+		rec = e.foreground.record
+	} else {
+		rec = e.jobs[id]
+	}
+	var pty *os.File
+	if rec != nil {
+		pty = rec.pty
+	}
 	e.mu.Unlock()
+	if rec == nil {
+		return fmt.Errorf("job %s not found", id)
+	}
+	if pty == nil {
+		return fmt.Errorf("job %s has no terminal", id)
+	}
+	_, err := pty.Write(data)
+	return err
+}
 
-	exitCode := 0
-	if err != nil {
-		if ee, ok := err.(*exec.ExitError); ok {
-			exitCode = ee.ExitCode()
-		} else {
-			exitCode = -1
-		}
+// startBackground starts req as a background job, or queues it when
+// MaxJobs are already running.
+func (e *Executor) startBackground(req Request) (string, error) {
+	rec := newJobRecord(req, e.commandEnv())
+	id := rec.job.ID
+
+	e.mu.Lock()
+	e.jobs[id] = rec
+	if e.opts.MaxJobs > 0 && e.running >= e.opts.MaxJobs {
+		rec.job.Status = JobQueued
+		e.queue = append(e.queue, queuedJob{rec: rec, req: req})
+		e.cancels[id] = func() { e.cancelQueued(id) }
+		e.reindexQueueLocked()
+		e.mu.Unlock()
+		e.emitStatus(rec)
+		return id, nil
 	}
+	e.running++
+	ctx := e.claimLocked(rec)
+	e.mu.Unlock()
 
-	return Result{Output: buffer.String(), ExitCode: exitCode}, err
+	if err := e.launch(ctx, rec, req); err != nil {
+		e.mu.Lock()
+		delete(e.jobs, id)
+		e.releaseLocked(id)
+		e.running--
+		e.mu.Unlock()
+		return "", err
+	}
+	return id, nil
 }
 
-func (e *Executor) startBackground(req Request) (string, error) {
-	id := uuid.NewString()
-	job := &Job{
-		ID:        id,
-		Command:   req.Command,
-		Args:      req.Args,
-		StartedAt: time.Now(),
-		Status:    JobRunning,
+// claimLocked marks rec as running and registers its cancel func; callers
+// hold e.mu and have already counted rec in e.running.
+func (e *Executor) claimLocked(rec *jobRecord) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	rec.job.Status = JobRunning
+	rec.job.QueuePosition = 0
+	rec.job.StartedAt = time.Now()
+	e.cancels[rec.job.ID] = cancel
+	return ctx
+}
+
+// releaseLocked cancels and forgets the context claimLocked registered for
+// id; callers hold e.mu.
+func (e *Executor) releaseLocked(id string) {
+	if cancel, ok := e.cancels[id]; ok {
+		cancel()
+		delete(e.cancels, id)
 	}
+}
+
+// launch persists a claimed job and runs it; when it ends, its slot goes to
+// the next queued job.
+func (e *Executor) launch(ctx context.Context, rec *jobRecord, req Request) error {
+	if err := e.persist(rec, !req.PTY); err != nil {
+		return err
+	}
+	e.emitStatus(rec)
+	go func() {
+		_ = e.runCommand(ctx, req, rec)
+		e.mu.Lock()
+		e.releaseLocked(rec.job.ID)
+		e.mu.Unlock()
+		e.emitStatus(rec)
+		e.jobDone()
+	}()
+	return nil
+}
 
-	bgCtx, cancel := context.WithCancel(context.Background())
+// jobDone frees a slot, starts the next queued job if one is waiting, and
+// prunes old finished jobs.
+func (e *Executor) jobDone() {
+	e.mu.Lock()
+	e.running--
+	var next *queuedJob
+	var ctx context.Context
+	if len(e.queue) > 0 && (e.opts.MaxJobs <= 0 || e.running < e.opts.MaxJobs) {
+		next = &e.queue[0]
+		e.queue = e.queue[1:]
+		e.reindexQueueLocked()
+		e.running++
+		ctx = e.claimLocked(next.rec)
+	}
+	e.pruneLocked(time.Now())
+	e.mu.Unlock()
+	if next == nil {
+		return
+	}
+	if err := e.launch(ctx, next.rec, next.req); err != nil {
+		e.mu.Lock()
+		e.releaseLocked(next.rec.job.ID)
+		finishJob(next.rec, err, context.Background())
+		e.mu.Unlock()
+		e.emitStatus(next.rec)
+		e.jobDone()
+	}
+}
 
+// cancelQueued drops a job from the queue. If it has started in the
+// meantime, the running command is canceled instead.
+func (e *Executor) cancelQueued(id string) {
 	e.mu.Lock()
-	e.jobs[id] = job
-	e.cancels[id] = cancel
+	for i, q := range e.queue {
+		if q.rec.job.ID != id {
+			continue
+		}
+		e.queue = append(e.queue[:i:i], e.queue[i+1:]...)
+		e.reindexQueueLocked()
+		q.rec.job.Status = JobCanceled
+		q.rec.job.QueuePosition = 0
+		q.rec.job.ExitCode = -1
+		q.rec.job.Error = "canceled while queued"
+		q.rec.job.EndedAt = time.Now()
+		e.mu.Unlock()
+		e.emitStatus(q.rec)
+		return
+	}
+	cancel, ok := e.cancels[id]
+	delete(e.cancels, id)
 	e.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
 
-	e.emit(job)
+func (e *Executor) reindexQueueLocked() {
+	for i, q := range e.queue {
+		q.rec.job.QueuePosition = i + 1
+	}
+}
 
-	go func() {
-		cmd := exec.CommandContext(bgCtx, req.Command, req.Args...)
-		if req.Workdir != "" {
-			cmd.Dir = filepath.Clean(req.Workdir)
+// pruneLocked forgets finished jobs beyond KeepJobs or older than KeepFor.
+func (e *Executor) pruneLocked(now time.Time) {
+	var finished []*jobRecord
+	for _, rec := range e.jobs {
+		if rec.job.Status.Finished() && !rec.job.EndedAt.IsZero() {
+			finished = append(finished, rec)
 		}
-		var buffer bytes.Buffer
-		cmd.Stdout = &buffer
-		cmd.Stderr = &buffer
-		err := cmd.Run()
-		e.mu.Lock()
-		job.Output = buffer.String()
-		job.EndedAt = time.Now()
-		if err != nil {
-			job.Status = JobFailed
-			job.Error = err.Error()
-			job.ExitCode = -1
-			if ee, ok := err.(*exec.ExitError); ok {
-				job.ExitCode = ee.ExitCode()
-			}
-		} else {
-			job.Status = JobSuccess
-			job.ExitCode = 0
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].job.EndedAt.After(finished[j].job.EndedAt) })
+	for i, rec := range finished {
+		if i >= e.opts.KeepJobs || now.Sub(rec.job.EndedAt) > e.opts.KeepFor {
+			delete(e.jobs, rec.job.ID)
 		}
-		delete(e.cancels, id)
+	}
+}
+
+// runCommand executes req in its own process group, streams output into rec,
+// and records how the command ended. Cancellation and timeouts escalate from
+// SIGINT to SIGTERM to SIGKILL across the whole group.
+func (e *Executor) runCommand(ctx context.Context, req Request, rec *jobRecord) error {
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, req.Timeout, errTimedOut)
+		defer cancel()
+	}
+	e.audit(rec, false)
+	cmd := exec.CommandContext(ctx, req.Command, req.Args...)
+	if req.Workdir != "" {
+		cmd.Dir = filepath.Clean(req.Workdir)
+	}
+	cmd.Env = rec.env
+	if req.PTY {
+		configurePTYProcess(cmd)
+	} else {
+		configureProcessGroup(cmd)
+	}
+	e.mu.Lock()
+	wrapper := e.wrapper
+	e.mu.Unlock()
+	if wrapper != nil {
+		if err := wrapper.Wrap(cmd); err != nil {
+			e.mu.Lock()
+			finishJob(rec, err, ctx)
+			e.mu.Unlock()
+			e.finishPersisted(rec)
+			e.audit(rec, true)
+			return err
+		}
+	}
+	if cmd.Err == nil {
+		rec.scoped = e.opts.Limits.scope(cmd)
+	}
+	exited := make(chan struct{})
+	grace := e.opts.KillGrace
+	cmd.Cancel = func() error {
+		go terminateProcessTree(cmd.Process.Pid, grace, exited)
+		return nil
+	}
+	// Bound how long Wait lingers on descendants that keep our pipes open.
+	cmd.WaitDelay = 2*grace + time.Second
+	var err error
+	switch {
+	case req.PTY:
+		err = e.runPTY(cmd, rec)
+	case rec.direct:
+		err = e.runLogged(cmd, rec)
+	default:
+		stdout, stderr := e.attachOutput(cmd, rec)
+		if err = cmd.Start(); err == nil {
+			e.started(rec, cmd.Process.Pid)
+			err = cmd.Wait()
+		}
+		stdout.Flush()
+		stderr.Flush()
+	}
+	close(exited)
+
+	e.mu.Lock()
+	finishJob(rec, err, ctx)
+	e.mu.Unlock()
+	e.finishPersisted(rec)
+	e.audit(rec, true)
+	return err
+}
+
+// audit reports the start or end of rec to the auditor, if any.
+func (e *Executor) audit(rec *jobRecord, ended bool) {
+	if e.opts.Auditor == nil {
+		return
+	}
+	e.mu.Lock()
+	job := rec.snapshot()
+	e.mu.Unlock()
+	if ended {
+		e.opts.Auditor.AuditEnd(job)
+	} else {
+		e.opts.Auditor.AuditStart(job)
+	}
+}
+
+func newJobRecord(req Request, env []string) *jobRecord {
+	return &jobRecord{
+		env: env,
+		job: Job{
+			ID:        uuid.NewString(),
+			CallID:    req.CallID,
+			Command:   req.Command,
+			Workdir:   req.Workdir,
+			Args:      append([]string(nil), req.Args...),
+			StartedAt: time.Now(),
+			Status:    JobRunning,
+			Timeout:   req.Timeout,
+			PTY:       req.PTY,
+			Env:       RedactEnv(env),
+		},
+		output: newOutputRing(DefaultOutputLines),
+	}
+}
+
+// attachOutput wires stdout/stderr into the job's ring buffer and streams each
+// completed line as an EventOutput.
+func (e *Executor) attachOutput(cmd *exec.Cmd, rec *jobRecord) (*lineWriter, *lineWriter) {
+	emit := func(line OutputLine) {
+		e.mu.Lock()
+		rec.output.add(line)
+		rec.copyToLog(line)
+		job := rec.job
 		e.mu.Unlock()
-		e.emit(job)
+		e.send(Event{Kind: EventOutput, Job: job, Line: line})
+	}
+	stdout := &lineWriter{stream: StreamStdout, emit: emit}
+	stderr := &lineWriter{stream: StreamStderr, emit: emit}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return stdout, stderr
+}
+
+// runPTY runs cmd on a fresh pseudo-terminal, feeding what it prints
+// through a ptyScreen so the job output is a clean transcript.
+func (e *Executor) runPTY(cmd *exec.Cmd, rec *jobRecord) error {
+	master, tty, err := openPTY()
+	if err != nil {
+		return err
+	}
+	defer master.Close()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	e.mu.Lock()
+	rec.pty = master
+	rec.screen = &ptyScreen{}
+	e.mu.Unlock()
+	err = cmd.Start()
+	tty.Close()
+	if err != nil {
+		return err
+	}
+	e.started(rec, cmd.Process.Pid)
+	copied := make(chan struct{})
+	go func() {
+		e.copyPTY(master, rec)
+		close(copied)
 	}()
+	err = cmd.Wait()
+	// Descendants may keep the terminal open; do not wait on them forever.
+	select {
+	case <-copied:
+	case <-time.After(e.opts.KillGrace):
+	}
+	e.mu.Lock()
+	rec.pty = nil
+	if rest := rec.screen.flush(); rest != "" {
+		line := OutputLine{Stream: StreamStdout, Text: rest, At: time.Now()}
+		rec.output.add(line)
+		rec.copyToLog(line)
+	}
+	e.mu.Unlock()
+	return err
+}
 
-	return id, nil
+func (e *Executor) copyPTY(master *os.File, rec *jobRecord) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := master.Read(buf)
+		if n > 0 {
+			e.mu.Lock()
+			now := time.Now()
+			var lines []OutputLine
+			for _, text := range rec.screen.write(buf[:n]) {
+				line := OutputLine{Stream: StreamStdout, Text: text, At: now}
+				rec.output.add(line)
+				rec.copyToLog(line)
+				lines = append(lines, line)
+			}
+			job := rec.job
+			screen := FormatLines(rec.output.tail(ptyScreenLines - 1))
+			screen = append(screen, rec.screen.partial())
+			e.mu.Unlock()
+			for _, line := range lines {
+				e.send(Event{Kind: EventOutput, Job: job, Line: line})
+			}
+			e.send(Event{Kind: EventScreen, Job: job, Screen: screen})
+		}
+		if err != nil {
+			return
+		}
+	}
 }
 
-func (e *Executor) emit(job *Job) {
-	select {
-	case e.events <- Event{Job: *job}:
+// finishJob records the exit state, telling timeouts and cancellations apart
+// from ordinary failures; callers must hold e.mu.
+func finishJob(rec *jobRecord, err error, ctx context.Context) {
+	rec.job.EndedAt = time.Now()
+	if err == nil {
+		rec.job.Status = JobSuccess
+		rec.job.ExitCode = 0
+		return
+	}
+	rec.job.ExitCode = -1
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		rec.job.ExitCode = ee.ExitCode()
+	}
+	switch {
+	case errors.Is(context.Cause(ctx), errTimedOut):
+		rec.job.Status = JobTimedOut
+		rec.job.Error = fmt.Sprintf("timed out after %s", rec.job.Timeout)
+	case ctx.Err() != nil:
+		rec.job.Status = JobCanceled
+		rec.job.Error = "canceled"
 	default:
+		rec.job.Status = JobFailed
+		rec.job.Error = err.Error()
 	}
 }
 
+func (e *Executor) emitStatus(rec *jobRecord) {
+	e.mu.Lock()
+	job := rec.snapshot()
+	e.mu.Unlock()
+	e.send(Event{Kind: EventStatus, Job: job})
+}
+
+func (e *Executor) send(ev Event) {
+	e.bus.Publish(ev)
+}
+
 // ActiveJobs returns a snapshot of current jobs.
 func (e *Executor) ActiveJobs() []Job {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	out := make([]Job, 0, len(e.jobs))
-	for _, job := range e.jobs {
-		out = append(out, *job)
+	for _, rec := range e.jobs {
+		out = append(out, rec.snapshot())
+	}
+	return out
+}
+
+// FormatLines renders output lines for display, marking stderr lines.
+func FormatLines(lines []OutputLine) []string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line.Stream == StreamStderr {
+			out = append(out, "! "+line.Text)
+			continue
+		}
+		out = append(out, line.Text)
 	}
 	return out
 }
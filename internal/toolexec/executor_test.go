@@ -0,0 +1,531 @@
+package toolexec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputRingKeepsNewestLines(t *testing.T) {
+	ring := newOutputRing(3)
+	for _, text := range []string{"a", "b", "c", "d"} {
+		ring.add(OutputLine{Stream: StreamStdout, Text: text})
+	}
+	ring.add(OutputLine{Stream: StreamStderr, Text: "e"})
+	tail := ring.tail(0)
+	if len(tail) != 3 || tail[0].Text != "c" || tail[2].Text != "e" {
+		t.Fatalf("unexpected tail %#v", tail)
+	}
+	if got := ring.text(StreamStderr); got != "e\n" {
+		t.Fatalf("unexpected stderr text %q", got)
+	}
+	if !strings.HasPrefix(ring.text(""), "[… earlier output truncated …]") {
+		t.Fatal("expected truncation marker in combined output")
+	}
+}
+
+func TestLineWriterSplitsPartialWrites(t *testing.T) {
+	var got []string
+	w := &lineWriter{stream: StreamStdout, emit: func(l OutputLine) { got = append(got, l.Text) }}
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\r\nthree"))
+	w.Flush()
+	if strings.Join(got, "|") != "one|two|three" {
+		t.Fatalf("unexpected lines %q", got)
+	}
+}
+
+func TestBackgroundJobStreamsOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	e := NewExecutor()
+	sub := e.Subscribe()
+	defer sub.Close()
+	_, id, err := e.Run(context.Background(), Request{
+		Command:    "sh",
+		Args:       []string{"-c", "echo out; echo err 1>&2"},
+		Background: true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var streamed []OutputLine
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-sub.Events():
+			if ev.Kind == EventOutput {
+				streamed = append(streamed, ev.Line)
+			}
+			if ev.Kind == EventStatus && ev.Job.Status.Finished() {
+				if len(streamed) != 2 {
+					t.Fatalf("expected 2 streamed lines, got %#v", streamed)
+				}
+				job, ok := e.Job(id)
+				if !ok || job.Stdout != "out\n" || job.Stderr != "err\n" {
+					t.Fatalf("unexpected job output %#v", job)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for job")
+		}
+	}
+}
+
+func TestSubscriptionCoalescesAndKeepsStatus(t *testing.T) {
+	// Drive the queue directly so the pump goroutine cannot race the checks.
+	s := &Subscription{size: 3, wake: make(chan struct{}, 1)}
+	job := func(id string, status JobStatus, pid int) Job { return Job{ID: id, Status: status, PID: pid} }
+	s.push(Event{Kind: EventScreen, Job: job("b", JobRunning, 0), Screen: []string{"old"}})
+	for i := 0; i < 3; i++ {
+		s.push(Event{Kind: EventOutput, Job: job("a", JobRunning, 0), Line: OutputLine{Text: fmt.Sprint(i)}})
+	}
+	s.push(Event{Kind: EventScreen, Job: job("b", JobRunning, 0), Screen: []string{"new"}})
+	s.push(Event{Kind: EventStatus, Job: job("c", JobRunning, 0)})
+	s.push(Event{Kind: EventStatus, Job: job("c", JobRunning, 42)})
+	s.push(Event{Kind: EventStatus, Job: job("a", JobSuccess, 0)})
+	s.push(Event{Kind: EventStatus, Job: job("c", JobFailed, 42)})
+
+	var got []string
+	for _, ev := range s.queue {
+		switch ev.Kind {
+		case EventScreen:
+			got = append(got, "screen "+ev.Job.ID+" "+ev.Screen[0])
+		case EventOutput:
+			got = append(got, "output "+ev.Job.ID+" "+ev.Line.Text)
+		default:
+			got = append(got, fmt.Sprintf("status %s %s %d", ev.Job.ID, ev.Job.Status, ev.Job.PID))
+		}
+	}
+	want := []string{
+		"screen b new",
+		"output a 0",
+		"output a 1",
+		"status c running 42",
+		"status a success 0",
+		"status c failed 42",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("queue = %q, want %q", got, want)
+	}
+	if s.dropped != 1 {
+		t.Fatalf("expected 1 dropped line, got %d", s.dropped)
+	}
+}
+
+func TestBusDeliversToEverySubscriber(t *testing.T) {
+	bus := NewBus()
+	first, second := bus.Subscribe(0), bus.Subscribe(0)
+	defer first.Close()
+	bus.Publish(Event{Kind: EventStatus, Job: Job{ID: "j", Status: JobSuccess}})
+	for _, sub := range []*Subscription{first, second} {
+		select {
+		case ev := <-sub.Events():
+			if ev.Job.ID != "j" {
+				t.Fatalf("unexpected event %+v", ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("subscriber missed the event")
+		}
+	}
+	second.Close()
+	if _, ok := <-second.Events(); ok {
+		t.Fatal("expected a closed channel after Close")
+	}
+}
+
+func TestTimeoutStopsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	e := NewExecutorWithOptions(Options{KillGrace: 100 * time.Millisecond})
+	sub := e.Subscribe()
+	defer sub.Close()
+	start := time.Now()
+	res, _, err := e.Run(context.Background(), Request{
+		Command: "sh",
+		Args:    []string{"-c", "sleep 30 & sleep 30"},
+		Timeout: 200 * time.Millisecond,
+	})
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("process group outlived timeout: %s", elapsed)
+	}
+	job := finalEvent(t, sub, res.JobID)
+	if job.Status != JobTimedOut {
+		t.Fatalf("expected timed_out, got %s (%s)", job.Status, job.Error)
+	}
+}
+
+func TestCancelJobReportsCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	e := NewExecutorWithOptions(Options{KillGrace: 100 * time.Millisecond})
+	_, id, err := e.Run(context.Background(), Request{Command: "sleep", Args: []string{"30"}, Background: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !e.CancelJob(id) {
+		t.Fatal("expected CancelJob to find the job")
+	}
+	job := lastStatus(t, e, id)
+	if job.Status != JobCanceled {
+		t.Fatalf("expected canceled, got %s (%s)", job.Status, job.Error)
+	}
+}
+
+// finalEvent reads sub until the job's terminal status arrives; foreground
+// commands are only visible through events.
+func finalEvent(t *testing.T, sub *Subscription, id string) Job {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-sub.Events():
+			if ev.Kind == EventStatus && ev.Job.ID == id && ev.Job.Status.Finished() {
+				return ev.Job
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for job status")
+		}
+	}
+}
+
+// lastStatus waits for a background job to finish.
+func lastStatus(t *testing.T, e *Executor, id string) Job {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := e.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("waiting for job status: %v", err)
+	}
+	return job
+}
+
+func TestMaxJobsQueuesBackgroundJobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	e := NewExecutorWithOptions(Options{MaxJobs: 1, KillGrace: 100 * time.Millisecond})
+	_, first, err := e.Run(context.Background(), Request{Command: "sleep", Args: []string{"30"}, Background: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	res, second, err := e.Run(context.Background(), Request{Command: "true", Background: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	_, third, err := e.Run(context.Background(), Request{Command: "true", Background: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.QueuePosition != 1 {
+		t.Fatalf("expected second job at queue position 1, got %d", res.QueuePosition)
+	}
+	if job, _ := e.Job(third); job.Status != JobQueued || job.QueuePosition != 2 {
+		t.Fatalf("expected third job queued at 2, got %s at %d", job.Status, job.QueuePosition)
+	}
+
+	if !e.CancelJob(third) {
+		t.Fatal("expected CancelJob to find the queued job")
+	}
+	if job := lastStatus(t, e, third); job.Status != JobCanceled {
+		t.Fatalf("expected canceled queued job, got %s", job.Status)
+	}
+	e.CancelJob(first)
+	if job := lastStatus(t, e, second); job.Status != JobSuccess {
+		t.Fatalf("expected queued job to run after the first ended, got %s (%s)", job.Status, job.Error)
+	}
+	if job, _ := e.Job(first); job.Status != JobCanceled {
+		t.Fatalf("expected first job canceled, got %s", job.Status)
+	}
+}
+
+func TestStorePrunesFinishedJobs(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, job := range []Job{
+		{ID: "new", Status: JobSuccess, EndedAt: now},
+		{ID: "older", Status: JobFailed, EndedAt: now.Add(-time.Hour)},
+		{ID: "stale", Status: JobSuccess, EndedAt: now.Add(-48 * time.Hour)},
+		{ID: "live", Status: JobRunning, StartedAt: now.Add(-72 * time.Hour)},
+	} {
+		if err := os.MkdirAll(store.Dir(job.ID), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(job); err != nil {
+			t.Fatalf("Save %d: %v", i, err)
+		}
+	}
+	removed, err := store.Prune(1, 24*time.Hour)
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune = %d, %v; want 2 removed", removed, err)
+	}
+	jobs, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	if got := strings.Join(ids, ","); !strings.Contains(got, "new") || !strings.Contains(got, "live") || len(ids) != 2 {
+		t.Fatalf("unexpected remaining jobs %q", got)
+	}
+}
+
+func TestEnvPolicyFiltersAndInjects(t *testing.T) {
+	base := []string{"PATH=/bin", "HOME=/home/op", "OPENAI_API_KEY=sk-1", "GITHUB_TOKEN=gh", "PFUI_SECRET=x", "AWS_ACCESS_KEY_ID=AK"}
+	policy := DefaultEnvPolicy()
+	policy.Allow = []string{"GITHUB_TOKEN"}
+	policy.Set = map[string]string{"CI": "1"}
+	got := strings.Join(policy.Apply(base), " ")
+	want := "CI=1 GITHUB_TOKEN=gh HOME=/home/op PATH=/bin"
+	if got != want {
+		t.Fatalf("Apply = %q, want %q", got, want)
+	}
+
+	policy = EnvPolicy{Inherit: []string{"PATH"}, Deny: DefaultEnvDeny}
+	if got := strings.Join(policy.Apply(base), " "); got != "PATH=/bin" {
+		t.Fatalf("allowlist Apply = %q", got)
+	}
+}
+
+func TestJobRecordsRedactedEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	t.Setenv("PFUI_TEST_TOKEN", "hunter2")
+	e := NewExecutorWithOptions(Options{Env: &EnvPolicy{Deny: DefaultEnvDeny, Set: map[string]string{"INJECTED": "yes"}}})
+	sub := e.Subscribe()
+	defer sub.Close()
+	res, _, err := e.Run(context.Background(), Request{Command: "sh", Args: []string{"-c", "echo ${PFUI_TEST_TOKEN:-unset} $INJECTED"}})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if strings.TrimSpace(res.Output) != "unset yes" {
+		t.Fatalf("unexpected output %q", res.Output)
+	}
+	job := finalEvent(t, sub, res.JobID)
+	env := strings.Join(job.Env, " ")
+	if !strings.Contains(env, "INJECTED=[redacted]") || strings.Contains(env, "yes") || strings.Contains(env, "PFUI_TEST_TOKEN") {
+		t.Fatalf("unexpected recorded env %q", env)
+	}
+}
+
+func TestStripANSICollapsesOverwrites(t *testing.T) {
+	raw := "\x1b[1;32mok\x1b[0m\r\n10%\r55%\r100%\nabc\bX\x1b[K\n\x1b]0;title\x07done"
+	want := "ok\n100%\nabX\ndone"
+	if got := StripANSI(raw); got != want {
+		t.Fatalf("StripANSI = %q, want %q", got, want)
+	}
+}
+
+func TestPTYCommandSeesTerminal(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("PTY mode is Linux-only")
+	}
+	e := NewExecutorWithOptions(Options{KillGrace: 100 * time.Millisecond})
+	sub := e.Subscribe()
+	defer sub.Close()
+	done := make(chan Result, 1)
+	go func() {
+		res, _, err := e.Run(context.Background(), Request{
+			Command: "sh",
+			Args:    []string{"-c", `test -t 0 && printf 'name? '; read name; printf '\033[1mhi %s\033[0m\n' "$name"`},
+			PTY:     true,
+		})
+		if err != nil {
+			t.Errorf("Run: %v", err)
+		}
+		done <- res
+	}()
+	var id string
+	deadline := time.After(5 * time.Second)
+	for id == "" {
+		select {
+		case ev := <-sub.Events():
+			if ev.Kind == EventScreen && strings.Contains(strings.Join(ev.Screen, "\n"), "name?") {
+				id = ev.Job.ID
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for the prompt")
+		}
+	}
+	if err := e.WriteInput(id, []byte("pfui\r")); err != nil {
+		t.Fatalf("WriteInput: %v", err)
+	}
+	select {
+	case res := <-done:
+		if !strings.Contains(res.Output, "hi pfui") || strings.Contains(res.Output, "\x1b") {
+			t.Fatalf("unexpected transcript %q", res.Output)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PTY command did not finish")
+	}
+}
+
+func TestDetachMovesForegroundToBackground(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	e := NewExecutorWithOptions(Options{KillGrace: 100 * time.Millisecond})
+	done := make(chan Result, 1)
+	go func() {
+		res, _, _ := e.Run(context.Background(), Request{Command: "sleep", Args: []string{"30"}})
+		done <- res
+	}()
+	var id string
+	deadline := time.After(5 * time.Second)
+	for id == "" {
+		select {
+		case <-deadline:
+			t.Fatal("foreground command never started")
+		default:
+			id, _ = e.Detach()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	res := <-done
+	if !res.Detached || res.JobID != id {
+		t.Fatalf("expected detached result for %s, got %+v", id, res)
+	}
+	if !e.CancelJob(id) {
+		t.Fatal("expected detached job to be cancelable")
+	}
+	if job := lastStatus(t, e, id); job.Status != JobCanceled {
+		t.Fatalf("expected canceled, got %s", job.Status)
+	}
+}
+
+func TestStorePersistsBackgroundJobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewExecutorWithOptions(Options{Store: store, SessionID: "s1"})
+	_, id, err := e.Run(context.Background(), Request{Command: "sh", Args: []string{"-c", "echo out; echo err >&2"}, Background: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if job := lastStatus(t, e, id); job.Status != JobSuccess || strings.TrimSpace(job.Stdout) != "out" || strings.TrimSpace(job.Stderr) != "err" {
+		t.Fatalf("unexpected job %s (%s), stdout %q, stderr %q", job.Status, job.Error, job.Stdout, job.Stderr)
+	}
+	saved, err := store.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != JobSuccess || saved.PID == 0 || saved.SessionID != "s1" {
+		t.Fatalf("unexpected saved job %+v", saved)
+	}
+	for stream, want := range map[OutputStream]string{StreamStdout: "out\n", StreamStderr: "err\n"} {
+		if data, err := os.ReadFile(store.LogPath(id, stream)); err != nil || string(data) != want {
+			t.Fatalf("unexpected %s log %q (%v)", stream, data, err)
+		}
+	}
+
+	restored := NewExecutorWithOptions(Options{Store: store, SessionID: "s1"})
+	jobs, err := restored.Restore("s1")
+	if err != nil || len(jobs) != 1 || jobs[0].ID != id {
+		t.Fatalf("Restore = %+v, %v", jobs, err)
+	}
+	if job, ok := restored.Job(id); !ok || strings.TrimSpace(job.Stdout) != "out" || strings.TrimSpace(job.Stderr) != "err" {
+		t.Fatalf("restored job missing output: %+v", job)
+	}
+	if other, _ := restored.Restore("s2"); len(other) != 0 {
+		t.Fatalf("expected no jobs for another session, got %d", len(other))
+	}
+}
+
+func TestRestoreAdoptsRunningJobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := NewExecutorWithOptions(Options{Store: store, SessionID: "s1", KillGrace: 100 * time.Millisecond})
+	_, id, err := first.Run(context.Background(), Request{Command: "sh", Args: []string{"-c", "echo ready; sleep 30"}, Background: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if job, err := store.Load(id); err == nil && job.PID != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job never recorded its PID")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	second := NewExecutorWithOptions(Options{Store: store, SessionID: "s1", KillGrace: 100 * time.Millisecond})
+	jobs, err := second.Restore("s1")
+	if err != nil || len(jobs) != 1 || jobs[0].Status != JobRunning {
+		t.Fatalf("Restore = %+v, %v", jobs, err)
+	}
+	if !second.CancelJob(id) {
+		t.Fatal("expected adopted job to be cancelable")
+	}
+	if job := lastStatus(t, second, id); job.Status != JobCanceled {
+		t.Fatalf("expected canceled, got %s (%s)", job.Status, job.Error)
+	}
+}
+
+func TestStoreTreatsRecycledPIDAsLost(t *testing.T) {
+	start := processStartTime(os.Getpid())
+	if start == 0 {
+		t.Skip("process start times need /proc")
+	}
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// This test process stands in for an unrelated one that reused the PID.
+	stale := Job{ID: "job-stale", Command: "sleep", PID: os.Getpid(), PIDStart: start + 1, Status: JobRunning, StartedAt: time.Now()}
+	live := Job{ID: "job-live", Command: "sleep", PID: os.Getpid(), PIDStart: start, Status: JobRunning, StartedAt: time.Now()}
+	for _, job := range []Job{stale, live} {
+		if err := os.MkdirAll(store.Dir(job.ID), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Save(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Kill(stale.ID, 10*time.Millisecond); err == nil {
+		t.Fatal("expected Kill to refuse a recycled PID")
+	}
+	if job, _ := store.Load(stale.ID); job.Status != JobLost {
+		t.Fatalf("expected lost, got %s", job.Status)
+	}
+	if err := store.Save(stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := store.Load(stale.ID); job.Status != JobLost {
+		t.Fatalf("Reconcile: expected lost, got %s", job.Status)
+	}
+	if job, _ := store.Load(live.ID); job.Status != JobRunning {
+		t.Fatalf("Reconcile: expected the matching job to stay running, got %s", job.Status)
+	}
+}
@@ -0,0 +1,49 @@
+package toolexec
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Limits caps the resources of each command. Zero fields mean no limit.
+type Limits struct {
+	// CPUs caps CPU bandwidth (1.5 = one and a half cores). It needs cgroups
+	// v2 through a systemd user manager and is ignored without one.
+	CPUs float64
+	// Memory caps memory in bytes: memory.max in a cgroup, or RLIMIT_AS
+	// (address space, which overcounts for some runtimes) without cgroups.
+	Memory int64
+	// CPUTime caps the CPU time each process may use (RLIMIT_CPU).
+	CPUTime time.Duration
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l.CPUs <= 0 && l.Memory <= 0 && l.CPUTime <= 0
+}
+
+// scope rewrites cmd to start inside a transient systemd scope carrying the
+// cgroup limits, reporting whether it did. The scope execs the command, so
+// its PID and process group stay the same.
+func (l Limits) scope(cmd *exec.Cmd) bool {
+	if l.CPUs <= 0 && l.Memory <= 0 {
+		return false
+	}
+	runner, ok := cgroupScopeRunner()
+	if !ok {
+		return false
+	}
+	args := []string{runner, "--user", "--scope", "--quiet", "--collect"}
+	if l.Memory > 0 {
+		args = append(args, "-p", "MemoryMax="+strconv.FormatInt(l.Memory, 10))
+	}
+	if l.CPUs > 0 {
+		args = append(args, "-p", fmt.Sprintf("CPUQuota=%d%%", int(l.CPUs*100)))
+	}
+	args = append(args, "--", cmd.Path)
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = runner
+	return true
+}
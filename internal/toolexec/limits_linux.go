@@ -0,0 +1,54 @@
+package toolexec
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	scopeOnce   sync.Once
+	scopeRunner string
+)
+
+// cgroupScopeRunner finds systemd-run when cgroups v2 and a systemd user
+// manager (which delegates cgroup controllers to the user) are present.
+func cgroupScopeRunner() (string, bool) {
+	scopeOnce.Do(func() {
+		if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+			return
+		}
+		runtime := os.Getenv("XDG_RUNTIME_DIR")
+		if runtime == "" {
+			return
+		}
+		if _, err := os.Stat(filepath.Join(runtime, "systemd", "private")); err != nil {
+			return
+		}
+		if path, err := exec.LookPath("systemd-run"); err == nil {
+			scopeRunner = path
+		}
+	})
+	return scopeRunner, scopeRunner != ""
+}
+
+// applyRlimits sets the per-process limits on a started command. Memory is
+// only capped here when no cgroup scope does it already.
+func (l Limits) applyRlimits(pid int, scoped bool) error {
+	if l.CPUTime > 0 {
+		secs := uint64(max(l.CPUTime.Seconds(), 1))
+		if err := unix.Prlimit(pid, unix.RLIMIT_CPU, &unix.Rlimit{Cur: secs, Max: secs}, nil); err != nil {
+			return err
+		}
+	}
+	if l.Memory > 0 && !scoped {
+		limit := uint64(l.Memory)
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, &unix.Rlimit{Cur: limit, Max: limit}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package toolexec
+
+func cgroupScopeRunner() (string, bool) { return "", false }
+
+// applyRlimits is a no-op: setting limits on another process needs prlimit.
+func (l Limits) applyRlimits(pid int, scoped bool) error { return nil }
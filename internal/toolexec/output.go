@@ -0,0 +1,122 @@
+package toolexec
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultOutputLines bounds how many lines each job keeps in memory.
+	DefaultOutputLines = 2000
+	// maxLineBytes splits pathological lines (minified JS, progress bars) so a
+	// single write cannot grow the buffer without bound.
+	maxLineBytes = 4096
+)
+
+// OutputStream identifies which pipe produced a line.
+type OutputStream string
+
+const (
+	StreamStdout OutputStream = "stdout"
+	StreamStderr OutputStream = "stderr"
+)
+
+// OutputLine is a single line captured from a running command.
+type OutputLine struct {
+	Stream OutputStream
+	Text   string
+	At     time.Time
+}
+
+// outputRing keeps the most recent lines of a job.
+type outputRing struct {
+	lines   []OutputLine
+	start   int
+	count   int
+	dropped int
+}
+
+func newOutputRing(capacity int) *outputRing {
+	if capacity <= 0 {
+		capacity = DefaultOutputLines
+	}
+	return &outputRing{lines: make([]OutputLine, capacity)}
+}
+
+func (r *outputRing) add(line OutputLine) {
+	if r.count < len(r.lines) {
+		r.lines[(r.start+r.count)%len(r.lines)] = line
+		r.count++
+		return
+	}
+	r.lines[r.start] = line
+	r.start = (r.start + 1) % len(r.lines)
+	r.dropped++
+}
+
+// tail returns the last n lines (all retained lines when n <= 0).
+func (r *outputRing) tail(n int) []OutputLine {
+	if n <= 0 || n > r.count {
+		n = r.count
+	}
+	out := make([]OutputLine, 0, n)
+	for i := r.count - n; i < r.count; i++ {
+		out = append(out, r.lines[(r.start+i)%len(r.lines)])
+	}
+	return out
+}
+
+// text joins retained lines, optionally filtered to one stream.
+func (r *outputRing) text(stream OutputStream) string {
+	var b strings.Builder
+	if r.dropped > 0 && stream == "" {
+		b.WriteString("[… earlier output truncated …]\n")
+	}
+	for _, line := range r.tail(0) {
+		if stream != "" && line.Stream != stream {
+			continue
+		}
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// lineWriter splits a byte stream into lines and hands each one to emit.
+type lineWriter struct {
+	stream OutputStream
+	buf    []byte
+	emit   func(OutputLine)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		w.emitLine(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+	}
+	for len(w.buf) >= maxLineBytes {
+		w.emitLine(w.buf[:maxLineBytes])
+		w.buf = w.buf[maxLineBytes:]
+	}
+	w.buf = append([]byte(nil), w.buf...)
+	return len(p), nil
+}
+
+// Flush emits a trailing partial line, if any.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emitLine(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emitLine(raw []byte) {
+	text := strings.TrimRight(string(raw), "\r")
+	w.emit(OutputLine{Stream: w.stream, Text: text, At: time.Now()})
+}
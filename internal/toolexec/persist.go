@@ -0,0 +1,308 @@
+package toolexec
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	// logPollInterval is how often pfui rereads a job log the command writes itself.
+	logPollInterval = 100 * time.Millisecond
+	// processPollInterval is how often adopted jobs are checked for exit.
+	processPollInterval = 500 * time.Millisecond
+	// restoreTailBytes bounds how much of an old log is loaded on restore.
+	restoreTailBytes = 1 << 20
+)
+
+// logStreams are the streams a persisted job logs, one file each.
+var logStreams = []OutputStream{StreamStdout, StreamStderr}
+
+// jobLogs holds a persisted job's stdout.log and stderr.log.
+type jobLogs struct {
+	stdout *os.File
+	stderr *os.File
+}
+
+func (l *jobLogs) file(stream OutputStream) *os.File {
+	if stream == StreamStderr {
+		return l.stderr
+	}
+	return l.stdout
+}
+
+func (l *jobLogs) Close() error {
+	var errs []error
+	for _, f := range []*os.File{l.stdout, l.stderr} {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// persist records rec in the store, if any. With direct set, the command
+// writes to the log file itself, so it keeps running (and logging) after
+// pfui exits; otherwise pfui copies lines into the log.
+func (e *Executor) persist(rec *jobRecord, direct bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.persistLocked(rec, direct)
+}
+
+func (e *Executor) persistLocked(rec *jobRecord, direct bool) error {
+	if e.opts.Store == nil || rec.log != nil {
+		return nil
+	}
+	rec.job.SessionID = e.opts.SessionID
+	logs, err := e.opts.Store.create(rec.snapshot())
+	if err != nil {
+		return err
+	}
+	rec.log = logs
+	rec.direct = direct
+	if !direct {
+		for _, line := range rec.output.tail(0) {
+			rec.copyToLog(line)
+		}
+	}
+	return nil
+}
+
+// copyToLog appends a line to its stream's log when pfui owns the writes;
+// callers hold e.mu.
+func (r *jobRecord) copyToLog(line OutputLine) {
+	if r.log == nil || r.direct {
+		return
+	}
+	_, _ = r.log.file(line.Stream).WriteString(line.Text + "\n")
+}
+
+// started applies rlimits, then records the PID and saves it so later pfui
+// runs can find the process. The limits land just after the process starts;
+// anything it forks afterwards inherits them.
+func (e *Executor) started(rec *jobRecord, pid int) {
+	limitErr := e.opts.Limits.applyRlimits(pid, rec.scoped)
+	start := processStartTime(pid)
+	e.mu.Lock()
+	if limitErr != nil {
+		rec.output.add(OutputLine{Stream: StreamStderr, Text: "pfui: applying limits: " + limitErr.Error(), At: time.Now()})
+	}
+	rec.job.PID, rec.job.PIDStart = pid, start
+	persisted := rec.log != nil
+	job := rec.snapshot()
+	e.mu.Unlock()
+	if persisted {
+		_ = e.opts.Store.Save(job)
+	}
+}
+
+// finishPersisted saves the final status and closes the log. It is safe to
+// call again, as a job detached just after finishing does.
+func (e *Executor) finishPersisted(rec *jobRecord) {
+	e.mu.Lock()
+	log := rec.log
+	rec.log = nil
+	job := rec.snapshot()
+	e.mu.Unlock()
+	if log == nil {
+		return
+	}
+	_ = e.opts.Store.Save(job)
+	_ = log.Close()
+}
+
+// runLogged runs cmd with stdout and stderr pointed at their job logs and
+// streams both logs back into the job's buffer, so Stdout and Stderr stay
+// apart. Lines from the two streams are interleaved as they are read.
+func (e *Executor) runLogged(cmd *exec.Cmd, rec *jobRecord) error {
+	cmd.Stdout = rec.log.stdout
+	cmd.Stderr = rec.log.stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	e.started(rec, cmd.Process.Pid)
+	exited := make(chan struct{})
+	var followed sync.WaitGroup
+	for _, stream := range logStreams {
+		path := rec.log.file(stream).Name()
+		followed.Go(func() { e.followLog(path, 0, stream, rec, exited) })
+	}
+	err := cmd.Wait()
+	close(exited)
+	followed.Wait()
+	return err
+}
+
+// followLog tails the stream's log at path from offset into rec until stop
+// is closed, then drains what is left.
+func (e *Executor) followLog(path string, offset int64, stream OutputStream, rec *jobRecord, stop <-chan struct{}) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return
+	}
+	w := &lineWriter{stream: stream, emit: func(line OutputLine) {
+		e.mu.Lock()
+		rec.output.add(line)
+		job := rec.job
+		e.mu.Unlock()
+		e.send(Event{Kind: EventOutput, Job: job, Line: line})
+	}}
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			_, _ = w.Write(buf[:n])
+		}
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			break
+		}
+		select {
+		case <-stop:
+			// One last read picks up anything written just before exit.
+			for {
+				n, err := f.Read(buf)
+				if n > 0 {
+					_, _ = w.Write(buf[:n])
+				}
+				if err != nil {
+					break
+				}
+			}
+			w.Flush()
+			return
+		case <-time.After(logPollInterval):
+		}
+	}
+	w.Flush()
+}
+
+// Restore loads the stored jobs of sessionID into the executor so /jobs,
+// tail, and cancel work after a restart. Jobs whose process is still alive
+// are followed until it exits; their exit code is unknown, so they end as
+// JobLost unless canceled.
+func (e *Executor) Restore(sessionID string) ([]Job, error) {
+	store := e.opts.Store
+	if store == nil || sessionID == "" {
+		return nil, nil
+	}
+	jobs, err := store.Reconcile()
+	if err != nil {
+		return nil, err
+	}
+	var restored []Job
+	for _, job := range jobs {
+		if job.SessionID != sessionID {
+			continue
+		}
+		e.mu.Lock()
+		_, known := e.jobs[job.ID]
+		e.mu.Unlock()
+		if known {
+			continue
+		}
+		rec := &jobRecord{job: job, output: newOutputRing(DefaultOutputLines)}
+		// The logs carry no timing, so restored output lists each stream's
+		// tail in turn.
+		offsets := make(map[OutputStream]int64, len(logStreams))
+		for _, stream := range logStreams {
+			offsets[stream] = loadLogTail(store.LogPath(job.ID, stream), stream, rec.output)
+		}
+		e.mu.Lock()
+		e.jobs[job.ID] = rec
+		e.mu.Unlock()
+		if job.Status == JobRunning {
+			e.adopt(rec, store, offsets)
+		}
+		restored = append(restored, job)
+	}
+	return restored, nil
+}
+
+// adopt follows a job started by an earlier pfui process, reading each log
+// from its offset.
+func (e *Executor) adopt(rec *jobRecord, store *Store, offsets map[OutputStream]int64) {
+	id, pid, start := rec.job.ID, rec.job.PID, rec.job.PIDStart
+	exited := make(chan struct{})
+	canceled := make(chan struct{})
+	e.mu.Lock()
+	e.cancels[id] = func() {
+		close(canceled)
+		if jobProcessAlive(pid, start) {
+			go terminateProcessTree(pid, e.opts.KillGrace, exited)
+		}
+	}
+	e.mu.Unlock()
+	go func() {
+		for jobProcessAlive(pid, start) {
+			time.Sleep(processPollInterval)
+		}
+		close(exited)
+	}()
+	go func() {
+		var followed sync.WaitGroup
+		for _, stream := range logStreams {
+			followed.Go(func() { e.followLog(store.LogPath(id, stream), offsets[stream], stream, rec, exited) })
+		}
+		followed.Wait()
+		e.mu.Lock()
+		delete(e.cancels, id)
+		rec.job.EndedAt = time.Now()
+		rec.job.ExitCode = -1
+		select {
+		case <-canceled:
+			rec.job.Status = JobCanceled
+			rec.job.Error = "canceled"
+		default:
+			rec.job.Status = JobLost
+			rec.job.Error = "process was started by an earlier pfui; exit status unknown"
+		}
+		job := rec.snapshot()
+		e.mu.Unlock()
+		_ = store.Save(job)
+		e.emitStatus(rec)
+		e.audit(rec, true)
+	}()
+}
+
+// loadLogTail fills ring with the end of a stream's log and returns the log
+// size.
+func loadLogTail(path string, stream OutputStream, ring *outputRing) int64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+	size := info.Size()
+	start := max(size-restoreTailBytes, 0)
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return size
+	}
+	scanner := bufio.NewScanner(io.LimitReader(f, size-start))
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes*4)
+	first := start > 0
+	for scanner.Scan() {
+		if first {
+			// The first line after a mid-file seek is likely partial.
+			first = false
+			continue
+		}
+		ring.add(OutputLine{Stream: stream, Text: scanner.Text()})
+	}
+	return size
+}
@@ -0,0 +1,33 @@
+//go:build !unix
+
+package toolexec
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// configureProcessGroup is a no-op where POSIX process groups are unavailable.
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessTree kills the direct child; there is no portable way to
+// signal descendants without process groups, so no grace period applies.
+func terminateProcessTree(pid int, grace time.Duration, exited <-chan struct{}) {
+	if proc, err := os.FindProcess(pid); err == nil {
+		_ = proc.Kill()
+	}
+}
+
+// processAlive reports whether pid still exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = proc.Release()
+	return true
+}
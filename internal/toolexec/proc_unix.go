@@ -0,0 +1,44 @@
+//go:build unix
+
+package toolexec
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// configureProcessGroup starts the command in its own process group so
+// cancellation reaches every descendant, not just the direct child.
+func configureProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// terminateProcessTree escalates SIGINT → SIGTERM → SIGKILL against the
+// process group, waiting grace between steps. Once the leader exits, any
+// stragglers left in the group are killed outright.
+func terminateProcessTree(pid int, grace time.Duration, exited <-chan struct{}) {
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		_ = syscall.Kill(-pid, sig)
+		select {
+		case <-exited:
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+			return
+		case <-time.After(grace):
+		}
+	}
+	_ = syscall.Kill(-pid, syscall.SIGKILL)
+}
+
+// processAlive reports whether pid still exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
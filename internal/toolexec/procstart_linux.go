@@ -0,0 +1,36 @@
+package toolexec
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStartTime reads when pid started, in clock ticks since boot (field
+// 22 of /proc/<pid>/stat), or 0 when it cannot be read. Together with the PID
+// it names one process: a recycled PID belongs to a process that started
+// later.
+func processStartTime(pid int) uint64 {
+	if pid <= 0 {
+		return 0
+	}
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0
+	}
+	// The command name in field 2 may hold spaces and parentheses, so count
+	// fields from the last ')': state is field 3, starttime field 22.
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return 0
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return 0
+	}
+	start, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return start
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package toolexec
+
+// processStartTime is unknown without /proc, so stored jobs fall back to
+// checking only that their PID exists.
+func processStartTime(pid int) uint64 { return 0 }
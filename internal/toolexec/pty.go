@@ -0,0 +1,193 @@
+package toolexec
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrPTYUnsupported is returned for PTY requests on platforms without PTY support.
+var ErrPTYUnsupported = errors.New("interactive PTY mode is not supported on this platform")
+
+const (
+	// PTYRows and PTYCols size the terminal handed to PTY commands.
+	PTYRows = 24
+	PTYCols = 120
+	// ptyScreenLines is how many lines an EventScreen carries.
+	ptyScreenLines = 12
+)
+
+type escState int
+
+const (
+	escGround escState = iota
+	escStart
+	escCSI
+	escString
+	escStringEnd
+	escCharset
+)
+
+// ptyScreen turns raw terminal output into plain lines. It strips escape
+// sequences and applies the cursor motions that matter for transcripts:
+// carriage returns and backspaces overwrite (so progress bars collapse to
+// their final state), and erase-line clears the rest of the line.
+type ptyScreen struct {
+	line    []rune
+	col     int
+	state   escState
+	params  []byte
+	pending []byte
+}
+
+// write consumes p and returns the lines completed by it.
+func (s *ptyScreen) write(p []byte) []string {
+	var done []string
+	data := append(s.pending, p...)
+	s.pending = nil
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 && !utf8.FullRune(data) {
+			s.pending = append([]byte(nil), data...)
+			break
+		}
+		data = data[size:]
+		if line, ok := s.feed(r); ok {
+			done = append(done, line)
+		}
+		if len(s.line) >= maxLineBytes {
+			done = append(done, s.flush())
+		}
+	}
+	return done
+}
+
+// partial returns the unfinished current line, e.g. a password prompt.
+func (s *ptyScreen) partial() string {
+	return string(s.line)
+}
+
+// flush completes the current line.
+func (s *ptyScreen) flush() string {
+	line := strings.TrimRight(string(s.line), " ")
+	s.line = s.line[:0]
+	s.col = 0
+	return line
+}
+
+func (s *ptyScreen) feed(r rune) (string, bool) {
+	switch s.state {
+	case escStart:
+		switch r {
+		case '[':
+			s.state = escCSI
+			s.params = s.params[:0]
+		case ']', 'P', 'X', '^', '_':
+			s.state = escString
+		case '(', ')', '*', '+':
+			s.state = escCharset
+		default:
+			s.state = escGround
+		}
+		return "", false
+	case escCSI:
+		if r >= 0x40 && r <= 0x7e {
+			s.state = escGround
+			s.csi(r)
+		} else {
+			s.params = append(s.params, byte(r))
+		}
+		return "", false
+	case escString:
+		switch r {
+		case 0x07:
+			s.state = escGround
+		case 0x1b:
+			s.state = escStringEnd
+		}
+		return "", false
+	case escStringEnd:
+		s.state = escGround
+		if r != '\\' {
+			s.state = escString
+		}
+		return "", false
+	case escCharset:
+		s.state = escGround
+		return "", false
+	}
+	switch r {
+	case 0x1b:
+		s.state = escStart
+	case '\n':
+		return s.flush(), true
+	case '\r':
+		s.col = 0
+	case '\b':
+		if s.col > 0 {
+			s.col--
+		}
+	case '\t':
+		s.put(' ')
+		for s.col%8 != 0 {
+			s.put(' ')
+		}
+	default:
+		if r >= 0x20 && r != 0x7f {
+			s.put(r)
+		}
+	}
+	return "", false
+}
+
+func (s *ptyScreen) put(r rune) {
+	for len(s.line) < s.col {
+		s.line = append(s.line, ' ')
+	}
+	if s.col < len(s.line) {
+		s.line[s.col] = r
+	} else {
+		s.line = append(s.line, r)
+	}
+	s.col++
+}
+
+// csi applies the few control sequences that change line content.
+func (s *ptyScreen) csi(final rune) {
+	n, err := strconv.Atoi(strings.TrimLeft(string(s.params), "?"))
+	if err != nil {
+		n = 0
+	}
+	switch final {
+	case 'K': // erase in line
+		switch n {
+		case 0:
+			if s.col < len(s.line) {
+				s.line = s.line[:s.col]
+			}
+		case 2:
+			s.line = s.line[:0]
+		}
+	case 'D': // cursor back
+		s.col -= max(n, 1)
+		if s.col < 0 {
+			s.col = 0
+		}
+	case 'C': // cursor forward
+		s.col += max(n, 1)
+	case 'G': // cursor to column
+		s.col = max(n, 1) - 1
+	}
+}
+
+// StripANSI removes terminal escape sequences and applies carriage-return
+// overwrites, returning plain text.
+func StripANSI(text string) string {
+	var s ptyScreen
+	lines := s.write([]byte(text))
+	if rest := s.partial(); rest != "" {
+		lines = append(lines, strings.TrimRight(rest, " "))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package toolexec
+
+import (
+	"os"
+	"os/exec"
+)
+
+func openPTY() (master, tty *os.File, err error) {
+	return nil, nil, ErrPTYUnsupported
+}
+
+func configurePTYProcess(cmd *exec.Cmd) {}
@@ -0,0 +1,343 @@
+package toolexec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	jobMetaFile = "job.json"
+	// JobStdoutLog and JobStderrLog receive each stream of a job as it runs.
+	JobStdoutLog = "stdout.log"
+	JobStderrLog = "stderr.log"
+)
+
+// JobLost marks a job that was running when pfui last exited and whose
+// process has since disappeared; its exit code is unknown.
+const JobLost JobStatus = "lost"
+
+// Store persists background jobs under a directory (normally ~/.pfui/jobs),
+// one subdirectory per job holding job.json, stdout.log, and stderr.log.
+type Store struct {
+	dir string
+}
+
+// storedJob is the on-disk form of a Job; output lives in the logs.
+type storedJob struct {
+	ID        string        `json:"id"`
+	SessionID string        `json:"session_id,omitempty"`
+	CallID    string        `json:"call_id,omitempty"`
+	Command   string        `json:"command"`
+	Args      []string      `json:"args,omitempty"`
+	Workdir   string        `json:"workdir,omitempty"`
+	PID       int           `json:"pid,omitempty"`
+	PIDStart  uint64        `json:"pid_start,omitempty"`
+	Status    JobStatus     `json:"status"`
+	ExitCode  int           `json:"exit_code"`
+	Error     string        `json:"error,omitempty"`
+	PTY       bool          `json:"pty,omitempty"`
+	Timeout   time.Duration `json:"timeout,omitempty"`
+	Env       []string      `json:"env,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	EndedAt   time.Time     `json:"ended_at,omitzero"`
+}
+
+// DefaultJobsDir resolves $PFUI_HOME/jobs or ~/.pfui/jobs.
+func DefaultJobsDir() (string, error) {
+	if custom := os.Getenv("PFUI_HOME"); custom != "" {
+		return filepath.Join(custom, "jobs"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".pfui", "jobs"), nil
+}
+
+// NewStore returns a store rooted at dir, creating it if needed.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("ensuring jobs dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the job's directory.
+func (s *Store) Dir(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// LogPath returns the job's log for stream.
+func (s *Store) LogPath(id string, stream OutputStream) string {
+	name := JobStdoutLog
+	if stream == StreamStderr {
+		name = JobStderrLog
+	}
+	return filepath.Join(s.dir, id, name)
+}
+
+// create makes the job directory, writes its metadata, and opens both logs
+// for appending.
+func (s *Store) create(job Job) (*jobLogs, error) {
+	if err := os.MkdirAll(s.Dir(job.ID), 0o700); err != nil {
+		return nil, fmt.Errorf("creating job dir: %w", err)
+	}
+	if err := s.Save(job); err != nil {
+		return nil, err
+	}
+	logs := &jobLogs{}
+	for _, stream := range logStreams {
+		f, err := os.OpenFile(s.LogPath(job.ID, stream), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			_ = logs.Close()
+			return nil, fmt.Errorf("opening job log: %w", err)
+		}
+		if stream == StreamStderr {
+			logs.stderr = f
+		} else {
+			logs.stdout = f
+		}
+	}
+	return logs, nil
+}
+
+// Save writes job metadata atomically.
+func (s *Store) Save(job Job) error {
+	data, err := json.MarshalIndent(toStored(job), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding job %s: %w", job.ID, err)
+	}
+	path := filepath.Join(s.Dir(job.ID), jobMetaFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing job %s: %w", job.ID, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// Load reads one job's metadata.
+func (s *Store) Load(id string) (Job, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir(id), jobMetaFile))
+	if err != nil {
+		return Job{}, fmt.Errorf("reading job %s: %w", id, err)
+	}
+	var stored storedJob
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return Job{}, fmt.Errorf("parsing job %s: %w", id, err)
+	}
+	return stored.job(), nil
+}
+
+// List returns every stored job, oldest first. Unreadable entries are skipped.
+func (s *Store) List() ([]Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading jobs dir: %w", err)
+	}
+	var jobs []Job
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		job, err := s.Load(entry.Name())
+		if err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.Before(jobs[j].StartedAt) })
+	return jobs, nil
+}
+
+// Resolve finds a job by full ID or unique prefix.
+func (s *Store) Resolve(prefix string) (Job, error) {
+	if job, err := s.Load(prefix); err == nil {
+		return job, nil
+	}
+	jobs, err := s.List()
+	if err != nil {
+		return Job{}, err
+	}
+	var match *Job
+	for i := range jobs {
+		if strings.HasPrefix(jobs[i].ID, prefix) {
+			if match != nil {
+				return Job{}, fmt.Errorf("job prefix %s is ambiguous", prefix)
+			}
+			match = &jobs[i]
+		}
+	}
+	if match == nil {
+		return Job{}, fmt.Errorf("job %s not found", prefix)
+	}
+	return *match, nil
+}
+
+// Reconcile marks jobs recorded as running whose process is gone as lost and
+// returns the refreshed list.
+func (s *Store) Reconcile() ([]Job, error) {
+	jobs, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for i, job := range jobs {
+		if job.Status != JobRunning || jobProcessAlive(job.PID, job.PIDStart) {
+			continue
+		}
+		markLost(&job)
+		if err := s.Save(job); err != nil {
+			return jobs, err
+		}
+		jobs[i] = job
+	}
+	return jobs, nil
+}
+
+// Prune deletes finished jobs beyond the keep newest or older than age,
+// returning how many were removed. Running jobs are never pruned; zero
+// values mean DefaultKeepJobs and DefaultKeepFor, as in Options.
+func (s *Store) Prune(keep int, age time.Duration) (int, error) {
+	if keep <= 0 {
+		keep = DefaultKeepJobs
+	}
+	if age <= 0 {
+		age = DefaultKeepFor
+	}
+	jobs, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+	var finished []Job
+	for _, job := range jobs {
+		if job.Status.Finished() {
+			finished = append(finished, job)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].EndedAt.After(finished[j].EndedAt) })
+	removed := 0
+	now := time.Now()
+	for i, job := range finished {
+		if i < keep && now.Sub(job.EndedAt) <= age {
+			continue
+		}
+		if err := os.RemoveAll(s.Dir(job.ID)); err != nil {
+			return removed, fmt.Errorf("removing job %s: %w", job.ID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Kill stops a job recorded in the store by signalling its process group.
+func (s *Store) Kill(id string, grace time.Duration) (Job, error) {
+	job, err := s.Resolve(id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.Status != JobRunning {
+		return job, fmt.Errorf("job %s is not running", job.ID)
+	}
+	if !jobProcessAlive(job.PID, job.PIDStart) {
+		markLost(&job)
+		if err := s.Save(job); err != nil {
+			return job, err
+		}
+		return job, fmt.Errorf("job %s is not running: its process is gone", job.ID)
+	}
+	exited := make(chan struct{})
+	go func() {
+		for jobProcessAlive(job.PID, job.PIDStart) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		close(exited)
+	}()
+	terminateProcessTree(job.PID, grace, exited)
+	select {
+	case <-exited:
+	case <-time.After(grace + time.Second):
+		return job, fmt.Errorf("job %s did not exit after SIGKILL", job.ID)
+	}
+	job.Status = JobCanceled
+	job.ExitCode = -1
+	job.Error = "canceled"
+	job.EndedAt = time.Now()
+	return job, s.Save(job)
+}
+
+// jobProcessAlive reports whether pid still exists and, when start is known,
+// is the same process: a PID reused since the job started has a different
+// start time and must never be signalled.
+func jobProcessAlive(pid int, start uint64) bool {
+	if !processAlive(pid) {
+		return false
+	}
+	if start == 0 {
+		return true
+	}
+	now := processStartTime(pid)
+	return now == 0 || now == start
+}
+
+// markLost records that a job's process ended while nobody was watching.
+func markLost(job *Job) {
+	job.Status = JobLost
+	job.ExitCode = -1
+	job.Error = "process ended while pfui was not watching; exit status unknown"
+	if job.EndedAt.IsZero() {
+		job.EndedAt = time.Now()
+	}
+}
+
+func toStored(job Job) storedJob {
+	return storedJob{
+		ID:        job.ID,
+		SessionID: job.SessionID,
+		CallID:    job.CallID,
+		Command:   job.Command,
+		Args:      job.Args,
+		Workdir:   job.Workdir,
+		PID:       job.PID,
+		PIDStart:  job.PIDStart,
+		Status:    job.Status,
+		ExitCode:  job.ExitCode,
+		Error:     job.Error,
+		PTY:       job.PTY,
+		Timeout:   job.Timeout,
+		Env:       job.Env,
+		StartedAt: job.StartedAt,
+		EndedAt:   job.EndedAt,
+	}
+}
+
+func (s storedJob) job() Job {
+	return Job{
+		ID:        s.ID,
+		SessionID: s.SessionID,
+		CallID:    s.CallID,
+		Command:   s.Command,
+		Args:      s.Args,
+		Workdir:   s.Workdir,
+		PID:       s.PID,
+		PIDStart:  s.PIDStart,
+		Status:    s.Status,
+		ExitCode:  s.ExitCode,
+		Error:     s.Error,
+		PTY:       s.PTY,
+		Timeout:   s.Timeout,
+		Env:       s.Env,
+		StartedAt: s.StartedAt,
+		EndedAt:   s.EndedAt,
+	}
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+// AskUserName lets the model put a question to the operator.
+const AskUserName = "ask_user"
+
+// maxAskOptions matches the number keys the question prompt offers.
+const maxAskOptions = 9
+
+// ErrDismissed is returned by an Asker when the operator closes the
+// question without answering.
+var ErrDismissed = errors.New("the operator dismissed the question")
+
+// Question is what ask_user shows the operator.
+type Question struct {
+	Prompt  string
+	Options []string
+	// Multi lets the operator pick several options.
+	Multi bool
+	// FreeText accepts answers that are not one of the options.
+	FreeText bool
+}
+
+// Answer is the operator's reply: the options picked and any typed text.
+type Answer struct {
+	Selected []string `json:"selected,omitempty"`
+	Text     string   `json:"text,omitempty"`
+}
+
+// Asker puts a question to the operator and blocks until it is answered,
+// dismissed (ErrDismissed), or ctx ends.
+type Asker func(ctx context.Context, q Question) (Answer, error)
+
+// AskUserSpec declares the ask_user tool.
+func AskUserSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        AskUserName,
+		Description: "Ask the operator a question and wait for the answer. Use it when a decision is theirs to make or the request is ambiguous, not for things you can find out yourself. Offer up to 9 options when the choices are known.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"question":        map[string]any{"type": "string"},
+				"options":         map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Choices the operator can pick by number."},
+				"multi_select":    map[string]any{"type": "boolean", "description": "Let the operator pick several options."},
+				"allow_free_text": map[string]any{"type": "boolean", "description": "Accept an answer that is not one of the options (default true)."},
+			},
+			"required": []string{"question"},
+		},
+	}
+}
+
+type askArgs struct {
+	Question      string   `json:"question"`
+	Options       []string `json:"options"`
+	MultiSelect   bool     `json:"multi_select"`
+	AllowFreeText *bool    `json:"allow_free_text"`
+}
+
+type askResult struct {
+	// Answer is the whole reply as one string: the picked options joined
+	// by commas, then any typed text.
+	Answer   string   `json:"answer"`
+	Selected []string `json:"selected,omitempty"`
+	Text     string   `json:"text,omitempty"`
+}
+
+func parseAskArgs(raw string) (Question, error) {
+	var args askArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return Question{}, fmt.Errorf("invalid ask_user arguments: %w", err)
+		}
+	}
+	q := Question{Prompt: strings.TrimSpace(args.Question), Multi: args.MultiSelect, FreeText: true}
+	if q.Prompt == "" {
+		return Question{}, errors.New("ask_user requires a question")
+	}
+	for _, opt := range args.Options {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			q.Options = append(q.Options, opt)
+		}
+	}
+	if len(q.Options) > maxAskOptions {
+		return Question{}, fmt.Errorf("ask_user takes at most %d options", maxAskOptions)
+	}
+	if args.AllowFreeText != nil {
+		q.FreeText = *args.AllowFreeText
+	}
+	if len(q.Options) == 0 {
+		q.FreeText, q.Multi = true, false
+	}
+	return q, nil
+}
+
+// runAskUser asks r.Ask, or fails fast when nobody is there to answer.
+func (r Runner) runAskUser(ctx context.Context, raw string) (string, string) {
+	q, err := parseAskArgs(raw)
+	if err != nil {
+		return ErrorResult("invalid_arguments", err.Error(), ""), fmt.Sprintf("ask_user: %v", err)
+	}
+	if r.Ask == nil {
+		return ErrorResult("no_operator", "pfui is running non-interactively, so nobody can answer", "decide yourself, state the assumption you made, and continue"), fmt.Sprintf("ask_user %q: no operator to ask", q.Prompt)
+	}
+	answer, err := r.Ask(ctx, q)
+	if errors.Is(err, ErrDismissed) {
+		return ErrorResult("dismissed", err.Error(), "continue with your best judgment, or ask differently if you are truly blocked"), fmt.Sprintf("ask_user %q: dismissed", q.Prompt)
+	}
+	if err != nil {
+		return ErrorResult("ask_failed", err.Error(), ""), fmt.Sprintf("ask_user %q: %v", q.Prompt, err)
+	}
+	res := askResult{Answer: answer.String(), Selected: answer.Selected, Text: answer.Text}
+	data, _ := json.Marshal(res)
+	return string(data), fmt.Sprintf("ask_user %q: %s", q.Prompt, res.Answer)
+}
+
+// String joins the picked options and the typed text.
+func (a Answer) String() string {
+	parts := append([]string(nil), a.Selected...)
+	if a.Text != "" {
+		parts = append(parts, a.Text)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"strings"
+
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/checkpoint"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+// maxCheckpointSummary bounds the command line kept in a checkpoint.
+const maxCheckpointSummary = 80
+
+// checkpointFiles snapshots the files an edit is about to change and
+// returns the checkpoint ID (zero when checkpoints are off).
+func (r Runner) checkpointFiles(call provider.ToolCall, summary string, paths []string) (int, error) {
+	if r.Checkpoints == nil {
+		return 0, nil
+	}
+	cp, err := r.Checkpoints.Snapshot(checkpoint.Checkpoint{
+		Turn:    r.Turn,
+		Tool:    call.Name,
+		CallID:  call.ID,
+		Summary: summary,
+	}, paths)
+	if err != nil {
+		return 0, err
+	}
+	return cp.ID, nil
+}
+
+// checkpointExec snapshots the project tree before an exec command that may
+// change files. Read-only commands get no checkpoint.
+func (r Runner) checkpointExec(call provider.ToolCall, req toolexec.Request) (int, error) {
+	if r.Checkpoints == nil || !r.CheckpointExec || r.ProjectRoot == "" || !approvals.IsMutating(req.Command, req.Args) {
+		return 0, nil
+	}
+	summary := strings.Join(append([]string{"exec", req.Command}, req.Args...), " ")
+	if runes := []rune(summary); len(runes) > maxCheckpointSummary {
+		summary = string(runes[:maxCheckpointSummary-1]) + "…"
+	}
+	cp, err := r.Checkpoints.SnapshotTree(checkpoint.Checkpoint{
+		Turn:    r.Turn,
+		Tool:    call.Name,
+		CallID:  call.ID,
+		Summary: summary,
+	}, r.ProjectRoot)
+	if err != nil {
+		return 0, err
+	}
+	return cp.ID, nil
+}
+
+// sealCheckpoint records what a finished call changed, so restoring its
+// checkpoint reverts only that.
+func (r Runner) sealCheckpoint(id int) {
+	if r.Checkpoints != nil && id != 0 {
+		_ = r.Checkpoints.Seal(id)
+	}
+}
+
+// discardCheckpoint drops the snapshot of a call that never ran.
+func (r Runner) discardCheckpoint(id int) {
+	if r.Checkpoints != nil && id != 0 {
+		_ = r.Checkpoints.Discard(id)
+	}
+}
+
+func checkpointError(err error) string {
+	return ErrorResult("checkpoint_failed", err.Error()+"; nothing was changed", "ask the operator to fix the checkpoint store or disable [checkpoints]")
+}
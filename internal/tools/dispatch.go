@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/checkpoint"
+	"github.com/fbettag/pfui/internal/plan"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/skills"
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/workspace"
+)
+
+// Outcome is the result of one tool call.
+type Outcome struct {
+	Call provider.ToolCall
+	// Content is the JSON tool result sent back to the model.
+	Content string
+	// Summary is a one-line description for scrollback.
+	Summary string
+	// Request is the request that ran (after any operator edit).
+	Request toolexec.Request
+	JobID   string
+	// Images are pictures the tool returned (read_file on an image).
+	Images []provider.Image
+	// Diff is the unified diff a file edit applied.
+	Diff string
+	// Checkpoint is the ID of the snapshot taken before the call changed
+	// anything (zero when none was taken).
+	Checkpoint int
+	// Plan is the plan after an update_plan call, for the caller to install.
+	Plan *plan.Revision
+	// Task is the subagent run behind a task call.
+	Task *TaskReport
+	// Skill names the skill a load_skill call brought into the conversation.
+	Skill string
+}
+
+// Runner executes tool calls on behalf of the agent loop.
+type Runner struct {
+	Executor    *toolexec.Executor
+	ProjectRoot string
+	// Workspace confines the file tools and remembers reads; nil confines
+	// them to ProjectRoot without remembering anything between calls.
+	Workspace *workspace.Workspace
+	// ReadOnly refuses file edits, matching a read-only sandbox.
+	ReadOnly bool
+	// Checkpoints, when set, snapshots files before edits change them;
+	// CheckpointExec also snapshots the project tree before exec commands
+	// that may change files. Snapshots are tagged with Turn.
+	Checkpoints    *checkpoint.Store
+	CheckpointExec bool
+	Turn           int
+	// Plan is the current plan that update_plan patches.
+	Plan []plan.Step
+	// Ask puts ask_user questions to the operator. Without it ask_user
+	// fails fast with no_operator.
+	Ask Asker
+	// Delegate runs task calls on subagents; nil makes task unavailable.
+	Delegate Delegator
+	// Skills are the skills load_skill may load.
+	Skills []skills.Skill
+}
+
+func (r Runner) workspace() *workspace.Workspace {
+	if r.Workspace != nil {
+		return r.Workspace
+	}
+	return workspace.New(r.ProjectRoot, nil)
+}
+
+// Run executes call and always returns an Outcome; failures are encoded as
+// structured tool errors so the model can adapt.
+func (r Runner) Run(ctx context.Context, call provider.ToolCall) Outcome {
+	out := Outcome{Call: call}
+	switch call.Name {
+	case ExecName:
+		req, err := ParseExec(call.Arguments, r.ProjectRoot)
+		if err != nil {
+			out.Content = ErrorResult("invalid_arguments", err.Error(), "")
+			out.Summary = fmt.Sprintf("exec: %v", err)
+			return out
+		}
+		req.CallID = call.ID
+		out.Request = req
+		cp, err := r.checkpointExec(call, req)
+		if err != nil {
+			out.Content = checkpointError(err)
+			out.Summary = fmt.Sprintf("exec %s: %v", req.Command, err)
+			return out
+		}
+		res, jobID, err := r.Executor.Run(ctx, req)
+		var denied *approvals.DeniedError
+		if errors.As(err, &denied) {
+			r.discardCheckpoint(cp)
+			out.Content = ErrorResult("denied", denied.Reason, denied.Feedback)
+			out.Summary = fmt.Sprintf("exec %s denied: %s", req.Command, denied.Reason)
+			return out
+		}
+		out.Checkpoint = cp
+		out.JobID = jobID
+		if res.Detached {
+			out.JobID = res.JobID
+		}
+		if out.JobID == "" {
+			// Background jobs keep changing files, so their checkpoints
+			// stay unsealed and only a forced restore reverts them.
+			r.sealCheckpoint(cp)
+		}
+		if jobID != "" {
+			if job, ok := r.Executor.Job(jobID); ok {
+				res.Command, res.Args = job.Command, job.Args
+			}
+		}
+		if res.Command != "" {
+			out.Request.Command, out.Request.Args = res.Command, res.Args
+		}
+		out.Content = ExecResult(res, jobID, err)
+		if edited := out.Request.Command != req.Command || strings.Join(out.Request.Args, "\x00") != strings.Join(req.Args, "\x00"); edited {
+			out.Content = withEditedCommand(out.Content, out.Request)
+		}
+		req = out.Request
+		switch {
+		case jobID != "" && res.QueuePosition > 0:
+			out.Summary = fmt.Sprintf("exec %s queued in background (job %s, position %d)", req.Command, jobID, res.QueuePosition)
+		case jobID != "":
+			out.Summary = fmt.Sprintf("exec %s started in background (job %s)", req.Command, jobID)
+		case res.Detached:
+			out.Summary = fmt.Sprintf("exec %s detached to background (job %s)", req.Command, res.JobID)
+		case err != nil && res.JobID == "":
+			out.Summary = fmt.Sprintf("exec %s failed: %v", req.Command, err)
+		default:
+			out.Summary = fmt.Sprintf("exec %s exited %d", req.Command, res.ExitCode)
+		}
+		return out
+	case ReadFileName:
+		r.auditRequest(call, ReadFileName)
+		out.Content, out.Summary, out.Images = r.runReadFile(call.Arguments)
+		return out
+	case GrepName:
+		r.auditRequest(call, GrepName)
+		out.Content, out.Summary = r.runGrep(ctx, call.Arguments)
+		return out
+	case GlobName:
+		r.auditRequest(call, GlobName)
+		out.Content, out.Summary = r.runGlob(ctx, call.Arguments)
+		return out
+	case EditFileName, MultiEditName, WriteFileName, ApplyPatchName:
+		return r.runFileEdit(ctx, call)
+	case LoadSkillName:
+		r.auditRequest(call, LoadSkillName)
+		out.Content, out.Summary, out.Skill = r.runLoadSkill(call.Arguments)
+		return out
+	case TaskName:
+		r.auditRequest(call, TaskName)
+		out.Content, out.Summary, out.Task = r.runTask(ctx, call)
+		return out
+	case AskUserName:
+		out.Content, out.Summary = r.runAskUser(ctx, call.Arguments)
+		return out
+	case UpdatePlanName:
+		out.Content, out.Summary, out.Plan = r.runUpdatePlan(call.Arguments)
+		return out
+	case JobStatusName:
+		out.Content, out.Summary = r.runJobStatus(ctx, call.Arguments)
+		return out
+	case JobOutputName:
+		out.Content, out.Summary = r.runJobOutput(call.Arguments)
+		return out
+	default:
+		out.Content = ErrorResult("unknown_tool", fmt.Sprintf("no tool named %q", call.Name), "")
+		out.Summary = fmt.Sprintf("unknown tool %s", call.Name)
+		return out
+	}
+}
+
+// auditRequest records a file tool call in the audit log alongside exec
+// requests; the raw arguments stand in for the command line.
+func (r Runner) auditRequest(call provider.ToolCall, tool string) {
+	if r.Executor == nil {
+		return
+	}
+	r.Executor.AuditRequest(toolexec.Request{
+		CallID:  call.ID,
+		Tool:    tool,
+		Command: tool,
+		Args:    []string{call.Arguments},
+		Workdir: r.ProjectRoot,
+	})
+}
@@ -0,0 +1,610 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/patch"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/workspace"
+)
+
+// File editing tools. Each one checks that existing files are unchanged since
+// the model last read them, shows the operator a diff, and asks the approval
+// engine before writing.
+const (
+	EditFileName   = "edit_file"
+	MultiEditName  = "multi_edit"
+	WriteFileName  = "write_file"
+	ApplyPatchName = "apply_patch"
+)
+
+// EditFileSpec declares the edit_file tool.
+func EditFileSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        EditFileName,
+		Description: "Replace an exact string in a file you have read. old_string must match the file exactly (including indentation) and be unique unless replace_all is set; include surrounding lines to make it unique. Fails if the file changed since your last read_file.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":        map[string]any{"type": "string", "description": "File path, relative to the project root or absolute."},
+				"old_string":  map[string]any{"type": "string", "description": "Exact text to replace (without read_file line numbers)."},
+				"new_string":  map[string]any{"type": "string", "description": "Replacement text."},
+				"replace_all": map[string]any{"type": "boolean", "description": "Replace every occurrence instead of requiring a unique match."},
+			},
+			"required": []string{"path", "old_string", "new_string"},
+		},
+	}
+}
+
+// MultiEditSpec declares the multi_edit tool.
+func MultiEditSpec() provider.ToolSpec {
+	edit := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"old_string":  map[string]any{"type": "string"},
+			"new_string":  map[string]any{"type": "string"},
+			"replace_all": map[string]any{"type": "boolean"},
+		},
+		"required": []string{"old_string", "new_string"},
+	}
+	return provider.ToolSpec{
+		Name:        MultiEditName,
+		Description: "Apply several edit_file replacements to one file in order, as a single change: if any edit fails, nothing is written. Later edits see the result of earlier ones.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":  map[string]any{"type": "string", "description": "File path, relative to the project root or absolute."},
+				"edits": map[string]any{"type": "array", "items": edit},
+			},
+			"required": []string{"path", "edits"},
+		},
+	}
+}
+
+// WriteFileSpec declares the write_file tool.
+func WriteFileSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        WriteFileName,
+		Description: "Create a file, or replace the whole contents of one you have read. Missing parent directories are created. Prefer edit_file for changes to existing files.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":    map[string]any{"type": "string", "description": "File path, relative to the project root or absolute."},
+				"content": map[string]any{"type": "string", "description": "Complete new file contents."},
+			},
+			"required": []string{"path", "content"},
+		},
+	}
+}
+
+// ApplyPatchSpec declares the apply_patch tool.
+func ApplyPatchSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        ApplyPatchName,
+		Description: "Apply a patch that may add, update, delete, or move several files at once. Accepts a unified diff (--- a/x, +++ b/x, @@ hunks) or the Codex format (*** Begin Patch, *** Update File: x, @@ anchor, lines prefixed with space, - or +, *** End Patch). Files being updated or deleted must have been read and be unchanged since.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"patch": map[string]any{"type": "string", "description": "The patch text."},
+			},
+			"required": []string{"patch"},
+		},
+	}
+}
+
+type editArgs struct {
+	Path       string `json:"path"`
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all"`
+}
+
+type multiEditArgs struct {
+	Path  string     `json:"path"`
+	Edits []editArgs `json:"edits"`
+}
+
+type writeArgs struct {
+	Path    string  `json:"path"`
+	Content *string `json:"content"`
+}
+
+type patchArgs struct {
+	Patch string `json:"patch"`
+}
+
+type editResult struct {
+	Files []editedFile `json:"files"`
+	// Replacements counts matches replaced by edit_file and multi_edit.
+	Replacements int    `json:"replacements,omitempty"`
+	Note         string `json:"note,omitempty"`
+}
+
+type editedFile struct {
+	Path    string `json:"path"`
+	Action  string `json:"action"`
+	From    string `json:"from,omitempty"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// fileEdit is one planned change. A zero from means the file stays put.
+type fileEdit struct {
+	path, display     string
+	from, fromDisplay string
+	old, new          string
+	create, delete    bool
+	mode              fs.FileMode
+}
+
+// editError carries a structured tool error out of the planning helpers.
+type editError struct {
+	kind, reason, feedback string
+}
+
+func (e *editError) Error() string { return e.reason }
+
+// runFileEdit plans the edits a call asks for, gets them approved, and
+// writes them.
+func (r Runner) runFileEdit(ctx context.Context, call provider.ToolCall) Outcome {
+	out := Outcome{Call: call}
+	ws := r.workspace()
+	var (
+		edits        []fileEdit
+		replacements int
+		err          error
+	)
+	switch call.Name {
+	case EditFileName:
+		var args editArgs
+		if err = decodeArgs(call.Arguments, &args); err == nil {
+			edits, replacements, err = r.planReplace(ws, args.Path, []editArgs{args})
+		}
+	case MultiEditName:
+		var args multiEditArgs
+		if err = decodeArgs(call.Arguments, &args); err == nil {
+			if len(args.Edits) == 0 {
+				err = &editError{kind: "invalid_arguments", reason: "multi_edit needs at least one edit"}
+			} else {
+				edits, replacements, err = r.planReplace(ws, args.Path, args.Edits)
+			}
+		}
+	case WriteFileName:
+		var args writeArgs
+		if err = decodeArgs(call.Arguments, &args); err == nil {
+			edits, err = r.planWrite(ws, args)
+		}
+	case ApplyPatchName:
+		var args patchArgs
+		if err = decodeArgs(call.Arguments, &args); err == nil {
+			edits, err = r.planPatch(ws, args.Patch)
+		}
+	}
+	if err != nil {
+		return editFailure(out, err)
+	}
+
+	var diff strings.Builder
+	result := editResult{Replacements: replacements}
+	displays := make([]string, 0, len(edits))
+	for _, edit := range edits {
+		oldLabel, newLabel := edit.fromDisplay, edit.display
+		if edit.from == "" {
+			oldLabel = edit.display
+		}
+		action := "updated"
+		switch {
+		case edit.create:
+			oldLabel, action = "", "created"
+		case edit.delete:
+			newLabel, action = "", "deleted"
+		case edit.from != "":
+			action = "moved"
+		}
+		text := patch.Unified(oldLabel, newLabel, edit.old, edit.new)
+		if text == "" && action != "updated" {
+			// A move without changes, or an empty file created or deleted.
+			text = fmt.Sprintf("--- %s\n+++ %s\n", diffLabel("a/", oldLabel), diffLabel("b/", newLabel))
+		}
+		diff.WriteString(text)
+		added, removed := patch.Count(text)
+		result.Files = append(result.Files, editedFile{Path: edit.display, Action: action, From: edit.fromDisplay, Added: added, Removed: removed})
+		displays = append(displays, edit.display)
+	}
+	preview := diff.String()
+	if preview == "" {
+		result.Files[0].Action = "unchanged"
+		result.Note = "the file already has this content; nothing was written"
+		data, _ := json.Marshal(result)
+		out.Content = string(data)
+		out.Summary = fmt.Sprintf("%s %s unchanged", call.Name, displays[0])
+		return out
+	}
+	if r.ReadOnly {
+		out.Content = ErrorResult("read_only", "the sandbox is read-only, so files cannot be changed", "ask the operator to switch /sandbox to workspace-write")
+		out.Summary = fmt.Sprintf("%s refused: read-only sandbox", call.Name)
+		return out
+	}
+
+	req := toolexec.Request{
+		Tool:    call.Name,
+		Command: call.Name,
+		Args:    displays,
+		Workdir: ws.Root(),
+		CallID:  call.ID,
+		Preview: preview,
+	}
+	out.Request = req
+	if r.Executor != nil {
+		if _, err := r.Executor.Authorize(ctx, req); err != nil {
+			var denied *approvals.DeniedError
+			if errors.As(err, &denied) {
+				out.Content = ErrorResult("denied", denied.Reason, denied.Feedback)
+				out.Summary = fmt.Sprintf("%s %s denied: %s", call.Name, strings.Join(displays, " "), denied.Reason)
+				return out
+			}
+			out.Content = ErrorResult("approval_failed", err.Error(), "")
+			out.Summary = fmt.Sprintf("%s %s: %v", call.Name, strings.Join(displays, " "), err)
+			return out
+		}
+	}
+	// The operator may have taken a while; make sure nothing moved underneath.
+	for _, edit := range edits {
+		if err := checkUnchanged(ws, edit); err != nil {
+			r.auditFiles(req, nil, err)
+			return editFailure(out, err)
+		}
+	}
+	target := displays[0]
+	if len(displays) > 1 {
+		target = fmt.Sprintf("%d files", len(displays))
+	}
+	touched := make([]string, 0, len(edits))
+	for _, edit := range edits {
+		touched = append(touched, edit.path)
+		if edit.from != "" {
+			touched = append(touched, edit.from)
+		}
+	}
+	cp, err := r.checkpointFiles(call, call.Name+" "+target, touched)
+	if err != nil {
+		r.auditFiles(req, nil, err)
+		out.Content = checkpointError(err)
+		out.Summary = fmt.Sprintf("%s %s: %v", call.Name, target, err)
+		return out
+	}
+	out.Checkpoint = cp
+	defer r.sealCheckpoint(cp)
+	for i, edit := range edits {
+		if err := writeEdit(ws, edit); err != nil {
+			reason := fmt.Sprintf("writing %s: %v", edit.display, err)
+			if i > 0 {
+				reason += fmt.Sprintf(" (after %d of %d files were written)", i, len(edits))
+			}
+			r.auditFiles(req, result.Files[:i], errors.New(reason))
+			out.Content = ErrorResult("write_failed", reason, "")
+			out.Summary = fmt.Sprintf("%s failed: %s", call.Name, reason)
+			return out
+		}
+	}
+	r.auditFiles(req, result.Files, nil)
+	data, _ := json.Marshal(result)
+	out.Content = string(data)
+	out.Diff = preview
+	added, removed := patch.Count(preview)
+	out.Summary = fmt.Sprintf("%s %s (+%d -%d)", call.Name, target, added, removed)
+	return out
+}
+
+// auditFiles logs the end of an approved edit: the files it wrote and the
+// error that stopped it, if any.
+func (r Runner) auditFiles(req toolexec.Request, files []editedFile, err error) {
+	if r.Executor == nil {
+		return
+	}
+	changes := make([]toolexec.FileChange, 0, len(files))
+	for _, file := range files {
+		op := file.Action
+		if op == "updated" {
+			op = "modified"
+		}
+		changes = append(changes, toolexec.FileChange{Path: file.Path, Op: op, From: file.From})
+	}
+	r.Executor.AuditFiles(req, changes, err)
+}
+
+func diffLabel(prefix, path string) string {
+	if path == "" {
+		return "/dev/null"
+	}
+	return prefix + path
+}
+
+func decodeArgs(raw string, v any) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), v); err != nil {
+		return &editError{kind: "invalid_arguments", reason: fmt.Sprintf("invalid arguments: %v", err)}
+	}
+	return nil
+}
+
+func editFailure(out Outcome, err error) Outcome {
+	var e *editError
+	if !errors.As(err, &e) {
+		e = &editError{kind: "edit_failed", reason: err.Error()}
+	}
+	out.Content = ErrorResult(e.kind, e.reason, e.feedback)
+	out.Summary = fmt.Sprintf("%s: %s", out.Call.Name, e.reason)
+	return out
+}
+
+// planReplace applies string replacements to one file in memory.
+func (r Runner) planReplace(ws *workspace.Workspace, rawPath string, edits []editArgs) ([]fileEdit, int, error) {
+	path, display, err := resolveEditPath(ws, rawPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	old, mode, err := loadForEdit(ws, path, display)
+	if err != nil {
+		return nil, 0, err
+	}
+	content, total := old, 0
+	for i, edit := range edits {
+		next, n, err := replaceString(content, edit)
+		if err != nil {
+			if len(edits) > 1 {
+				err.reason = fmt.Sprintf("edit %d: %s", i+1, err.reason)
+			}
+			return nil, 0, err
+		}
+		content = next
+		total += n
+	}
+	return []fileEdit{{path: path, display: display, old: old, new: content, mode: mode}}, total, nil
+}
+
+// replaceString replaces edit.OldString in content, enforcing uniqueness
+// unless ReplaceAll is set. LF-only strings still match CRLF files.
+func replaceString(content string, edit editArgs) (string, int, *editError) {
+	oldStr, newStr := edit.OldString, edit.NewString
+	if oldStr == "" {
+		return "", 0, &editError{kind: "invalid_arguments", reason: "old_string is empty", feedback: "use write_file to create a file or replace all of it"}
+	}
+	if oldStr == newStr {
+		return "", 0, &editError{kind: "invalid_arguments", reason: "old_string and new_string are identical"}
+	}
+	count := strings.Count(content, oldStr)
+	if count == 0 && strings.Contains(content, "\r\n") && !strings.Contains(oldStr, "\r\n") {
+		crlfOld := strings.ReplaceAll(oldStr, "\n", "\r\n")
+		if n := strings.Count(content, crlfOld); n > 0 {
+			oldStr, newStr, count = crlfOld, strings.ReplaceAll(newStr, "\n", "\r\n"), n
+		}
+	}
+	switch {
+	case count == 0:
+		return "", 0, &editError{kind: "not_found", reason: "old_string was not found in the file",
+			feedback: "old_string must match exactly, including whitespace and indentation, and must not include read_file line numbers"}
+	case count > 1 && !edit.ReplaceAll:
+		return "", 0, &editError{kind: "not_unique", reason: fmt.Sprintf("old_string appears %d times in the file", count),
+			feedback: "include more surrounding lines to pick one occurrence, or set replace_all"}
+	}
+	if edit.ReplaceAll {
+		return strings.ReplaceAll(content, oldStr, newStr), count, nil
+	}
+	return strings.Replace(content, oldStr, newStr, 1), 1, nil
+}
+
+// planWrite creates a file or replaces one the model has read.
+func (r Runner) planWrite(ws *workspace.Workspace, args writeArgs) ([]fileEdit, error) {
+	if args.Content == nil {
+		return nil, &editError{kind: "invalid_arguments", reason: "content is required"}
+	}
+	path, display, err := resolveEditPath(ws, args.Path)
+	if err != nil {
+		return nil, err
+	}
+	edit := fileEdit{path: path, display: display, new: *args.Content, mode: 0o644}
+	switch _, err := os.Lstat(path); {
+	case errors.Is(err, fs.ErrNotExist):
+		edit.create = true
+		return []fileEdit{edit}, nil
+	case err != nil:
+		return nil, &editError{kind: "edit_failed", reason: err.Error()}
+	}
+	edit.old, edit.mode, err = loadForEdit(ws, path, display)
+	if err != nil {
+		return nil, err
+	}
+	return []fileEdit{edit}, nil
+}
+
+// planPatch parses a patch and applies it to each file in memory.
+func (r Runner) planPatch(ws *workspace.Workspace, text string) ([]fileEdit, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, &editError{kind: "invalid_arguments", reason: "patch is required"}
+	}
+	changes, err := patch.Parse(text)
+	if err != nil {
+		return nil, &editError{kind: "invalid_patch", reason: err.Error()}
+	}
+	seen := map[string]bool{}
+	claim := func(path, display string) error {
+		if seen[path] {
+			return &editError{kind: "invalid_patch", reason: fmt.Sprintf("the patch changes %s more than once", display)}
+		}
+		seen[path] = true
+		return nil
+	}
+	edits := make([]fileEdit, 0, len(changes))
+	for _, change := range changes {
+		path, display, err := resolveEditPath(ws, change.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := claim(path, display); err != nil {
+			return nil, err
+		}
+		edit := fileEdit{path: path, display: display, mode: 0o644}
+		switch change.Action {
+		case patch.ActionAdd:
+			if _, err := os.Lstat(path); err == nil {
+				return nil, &editError{kind: "file_exists", reason: display + " already exists", feedback: "read it and use an Update File section instead"}
+			}
+			edit.create, edit.new = true, change.Content
+		case patch.ActionDelete:
+			if edit.old, edit.mode, err = loadForEdit(ws, path, display); err != nil {
+				return nil, err
+			}
+			edit.delete = true
+		default:
+			if edit.old, edit.mode, err = loadForEdit(ws, path, display); err != nil {
+				return nil, err
+			}
+			if edit.new, err = patch.Apply(edit.old, change.Hunks); err != nil {
+				return nil, &editError{kind: "patch_failed", reason: fmt.Sprintf("%s: %v", display, err),
+					feedback: "read_file the region again and make the context lines match the file exactly"}
+			}
+			if change.MoveTo != "" {
+				to, toDisplay, err := resolveEditPath(ws, change.MoveTo)
+				if err != nil {
+					return nil, err
+				}
+				if err := claim(to, toDisplay); err != nil {
+					return nil, err
+				}
+				if _, err := os.Lstat(to); err == nil {
+					return nil, &editError{kind: "file_exists", reason: toDisplay + " already exists"}
+				}
+				edit.from, edit.fromDisplay = path, display
+				edit.path, edit.display = to, toDisplay
+			}
+		}
+		edits = append(edits, edit)
+	}
+	return edits, nil
+}
+
+func resolveEditPath(ws *workspace.Workspace, raw string) (string, string, error) {
+	path, err := ws.Resolve(raw)
+	if err != nil {
+		var outside *workspace.OutsideError
+		if errors.As(err, &outside) {
+			return "", "", &editError{kind: "outside_workspace", reason: err.Error(), feedback: "ask the operator to add the directory to [files] allowed_dirs"}
+		}
+		return "", "", &editError{kind: "invalid_arguments", reason: err.Error()}
+	}
+	return path, ws.Rel(path), nil
+}
+
+// loadForEdit reads an existing text file after checking the model's last
+// read of it is still current.
+func loadForEdit(ws *workspace.Workspace, path, display string) (string, fs.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", 0, &editError{kind: "not_found", reason: display + " does not exist", feedback: "use write_file to create it"}
+		}
+		return "", 0, &editError{kind: "edit_failed", reason: err.Error()}
+	}
+	if info.IsDir() {
+		return "", 0, &editError{kind: "invalid_arguments", reason: display + " is a directory"}
+	}
+	if err := freshness(ws, path, display); err != nil {
+		return "", 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, &editError{kind: "edit_failed", reason: err.Error()}
+	}
+	if isBinary(data[:min(len(data), sniffBytes)]) {
+		return "", 0, &editError{kind: "binary_file", reason: display + " is a binary file", feedback: "binary files cannot be edited with these tools"}
+	}
+	return string(data), info.Mode().Perm(), nil
+}
+
+// freshness turns the workspace's read tracking into a tool error.
+func freshness(ws *workspace.Workspace, path, display string) error {
+	switch err := ws.CheckFresh(path); {
+	case err == nil:
+		return nil
+	case errors.Is(err, workspace.ErrNotRead):
+		return &editError{kind: "stale_file", reason: display + " has not been read in this session", feedback: "read_file it first, then retry the edit"}
+	case errors.Is(err, workspace.ErrStale):
+		return &editError{kind: "stale_file", reason: display + " changed since it was last read", feedback: "read_file it again and redo the edit against the current contents"}
+	default:
+		return &editError{kind: "edit_failed", reason: err.Error()}
+	}
+}
+
+// checkUnchanged repeats the freshness checks right before writing.
+func checkUnchanged(ws *workspace.Workspace, edit fileEdit) error {
+	if edit.create {
+		if _, err := os.Lstat(edit.path); err == nil {
+			return &editError{kind: "file_exists", reason: edit.display + " was created while waiting for approval"}
+		}
+		return nil
+	}
+	source, display := edit.path, edit.display
+	if edit.from != "" {
+		source, display = edit.from, edit.fromDisplay
+	}
+	return freshness(ws, source, display)
+}
+
+// writeEdit carries out one planned change and records the result as read,
+// so follow-up edits do not need another read_file.
+func writeEdit(ws *workspace.Workspace, edit fileEdit) error {
+	if edit.delete {
+		if err := os.Remove(edit.path); err != nil {
+			return err
+		}
+		ws.Forget(edit.path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(edit.path), 0o755); err != nil {
+		return err
+	}
+	if err := writeAtomic(edit.path, []byte(edit.new), edit.mode); err != nil {
+		return err
+	}
+	if edit.from != "" {
+		if err := os.Remove(edit.from); err != nil {
+			return err
+		}
+		ws.Forget(edit.from)
+	}
+	return ws.RecordRead(edit.path)
+}
+
+// writeAtomic replaces path via a temporary file in the same directory, so a
+// crash never leaves it half written.
+func writeAtomic(path string, data []byte, mode fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".pfui-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
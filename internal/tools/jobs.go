@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+const (
+	// JobStatusName reports on, and optionally waits for, a background job.
+	JobStatusName = "job_status"
+	// JobOutputName returns the buffered output of a background job.
+	JobOutputName = "job_output"
+)
+
+const (
+	// maxJobWait caps how long job_status may block one tool round.
+	maxJobWait = 10 * time.Minute
+	// defaultJobLines and maxJobLines bound job_output.
+	defaultJobLines = 100
+	maxJobLines     = 1000
+)
+
+// JobStatusSpec declares the job_status tool.
+func JobStatusSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        JobStatusName,
+		Description: "Check a background job started with exec (background=true). Set wait to block until it finishes, up to that many seconds.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"job_id": map[string]any{"type": "string", "description": "Job id returned by exec."},
+				"wait":   map[string]any{"type": "number", "description": "Seconds to wait for the job to finish (max 600); 0 returns immediately."},
+			},
+			"required": []string{"job_id"},
+		},
+	}
+}
+
+// JobOutputSpec declares the job_output tool.
+func JobOutputSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        JobOutputName,
+		Description: "Read the most recent output lines of a background job; stderr lines start with \"! \".",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"job_id": map[string]any{"type": "string", "description": "Job id returned by exec."},
+				"lines":  map[string]any{"type": "integer", "description": "How many trailing lines to return (default 100, max 1000)."},
+			},
+			"required": []string{"job_id"},
+		},
+	}
+}
+
+type jobArgs struct {
+	JobID string  `json:"job_id"`
+	Wait  float64 `json:"wait"`
+	Lines int     `json:"lines"`
+}
+
+func parseJobArgs(name, raw string) (jobArgs, error) {
+	var args jobArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return args, fmt.Errorf("invalid %s arguments: %w", name, err)
+		}
+	}
+	args.JobID = strings.TrimSpace(args.JobID)
+	if args.JobID == "" {
+		return args, fmt.Errorf("%s requires a job_id", name)
+	}
+	if args.Wait < 0 || args.Lines < 0 {
+		return args, errors.New("wait and lines must not be negative")
+	}
+	return args, nil
+}
+
+type jobResult struct {
+	JobID         string `json:"job_id"`
+	Status        string `json:"status"`
+	Command       string `json:"command"`
+	ExitCode      *int   `json:"exit_code,omitempty"`
+	QueuePosition int    `json:"queue_position,omitempty"`
+	StartedAt     string `json:"started_at,omitempty"`
+	EndedAt       string `json:"ended_at,omitempty"`
+	Error         string `json:"error,omitempty"`
+	Output        string `json:"output,omitempty"`
+	// StillRunning is set when job_status gave up waiting before the job ended.
+	StillRunning bool `json:"still_running,omitempty"`
+}
+
+func newJobResult(job toolexec.Job) jobResult {
+	out := jobResult{
+		JobID:         job.ID,
+		Status:        string(job.Status),
+		Command:       strings.TrimSpace(job.Command + " " + strings.Join(job.Args, " ")),
+		QueuePosition: job.QueuePosition,
+		Error:         job.Error,
+	}
+	if job.Status != toolexec.JobQueued && !job.StartedAt.IsZero() {
+		out.StartedAt = job.StartedAt.Format(time.RFC3339)
+	}
+	if job.Status.Finished() {
+		code := job.ExitCode
+		out.ExitCode = &code
+		if !job.EndedAt.IsZero() {
+			out.EndedAt = job.EndedAt.Format(time.RFC3339)
+		}
+	}
+	return out
+}
+
+// JobStatusResult encodes a job snapshot for job_status.
+func JobStatusResult(job toolexec.Job, stillRunning bool) string {
+	out := newJobResult(job)
+	out.StillRunning = stillRunning
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+// JobOutputResult encodes a job snapshot plus its output tail for job_output.
+func JobOutputResult(job toolexec.Job, lines []toolexec.OutputLine) string {
+	out := newJobResult(job)
+	out.Output = clip(strings.Join(toolexec.FormatLines(lines), "\n"))
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+// runJobStatus answers job_status, waiting for the job when asked to.
+func (r Runner) runJobStatus(ctx context.Context, raw string) (string, string) {
+	args, err := parseJobArgs(JobStatusName, raw)
+	if err != nil {
+		return ErrorResult("invalid_arguments", err.Error(), ""), fmt.Sprintf("%s: %v", JobStatusName, err)
+	}
+	job, ok := r.Executor.Job(args.JobID)
+	if !ok {
+		return unknownJob(JobStatusName, args.JobID)
+	}
+	stillRunning := false
+	if args.Wait > 0 && !job.Status.Finished() {
+		wait := min(time.Duration(args.Wait*float64(time.Second)), maxJobWait)
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		job, err = r.Executor.Wait(waitCtx, args.JobID)
+		cancel()
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return ErrorResult("wait_failed", err.Error(), ""), fmt.Sprintf("%s %s: %v", JobStatusName, args.JobID, err)
+		}
+		stillRunning = err != nil
+	}
+	return JobStatusResult(job, stillRunning), fmt.Sprintf("%s %s: %s", JobStatusName, args.JobID, job.Status)
+}
+
+// runJobOutput answers job_output with the job's buffered output tail.
+func (r Runner) runJobOutput(raw string) (string, string) {
+	args, err := parseJobArgs(JobOutputName, raw)
+	if err != nil {
+		return ErrorResult("invalid_arguments", err.Error(), ""), fmt.Sprintf("%s: %v", JobOutputName, err)
+	}
+	job, ok := r.Executor.Job(args.JobID)
+	if !ok {
+		return unknownJob(JobOutputName, args.JobID)
+	}
+	n := args.Lines
+	if n == 0 {
+		n = defaultJobLines
+	}
+	lines, err := r.Executor.Tail(args.JobID, min(n, maxJobLines))
+	if err != nil {
+		return unknownJob(JobOutputName, args.JobID)
+	}
+	return JobOutputResult(job, lines), fmt.Sprintf("%s %s: %d lines", JobOutputName, args.JobID, len(lines))
+}
+
+func unknownJob(tool, id string) (string, string) {
+	return ErrorResult("unknown_job", fmt.Sprintf("no background job %q in this session", id), "use the job_id returned by exec"),
+		fmt.Sprintf("%s %s: unknown job", tool, id)
+}
+
+// noticeLines bounds the output tail included in a job notice.
+const noticeLines = 20
+
+// JobNotice tells the model a background job it started has finished. It is
+// sent as a user-role note on the next turn because most providers hoist
+// system messages out of the conversation.
+func JobNotice(job toolexec.Job) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[pfui] Background job %s (%s) finished: status %s, exit %d.",
+		job.ID, strings.TrimSpace(job.Command+" "+strings.Join(job.Args, " ")), job.Status, job.ExitCode)
+	if job.Error != "" && job.Status != toolexec.JobFailed {
+		fmt.Fprintf(&b, " %s.", job.Error)
+	}
+	lines := strings.Split(strings.TrimRight(job.Output, "\n"), "\n")
+	more := len(lines) > noticeLines
+	if more {
+		lines = lines[len(lines)-noticeLines:]
+	}
+	if tail := strings.Join(lines, "\n"); strings.TrimSpace(tail) != "" {
+		fmt.Fprintf(&b, " Last output:\n%s", clip(tail))
+	}
+	if more {
+		fmt.Fprintf(&b, "\n(Use %s for earlier lines.)", JobOutputName)
+	}
+	return b.String()
+}
@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/plan"
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+// UpdatePlanName lets the model keep the session's step checklist.
+const UpdatePlanName = "update_plan"
+
+// UpdatePlanSpec declares the update_plan tool.
+func UpdatePlanSpec() provider.ToolSpec {
+	stepStatus := map[string]any{"type": "string", "enum": []string{string(plan.Pending), string(plan.InProgress), string(plan.Done)}}
+	return provider.ToolSpec{
+		Name:        UpdatePlanName,
+		Description: "Keep the plan checklist the operator sees in pfui's plan drawer. Pass plan to replace every step, updates to change steps by number, or add to append steps. Keep at most one step in_progress and mark steps done as you finish them. Returns the whole plan.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"explanation": map[string]any{"type": "string", "description": "One sentence on why the plan changed; shown to the operator."},
+				"plan": map[string]any{
+					"type":        "array",
+					"description": "Replaces the whole plan.",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"step":   map[string]any{"type": "string"},
+							"status": stepStatus,
+							"note":   map[string]any{"type": "string", "description": "Optional explanation shown next to the step."},
+						},
+						"required": []string{"step"},
+					},
+				},
+				"updates": map[string]any{
+					"type":        "array",
+					"description": "Changes existing steps, numbered from 1; omitted fields stay as they are.",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"index":  map[string]any{"type": "integer"},
+							"step":   map[string]any{"type": "string"},
+							"status": stepStatus,
+							"note":   map[string]any{"type": "string"},
+						},
+						"required": []string{"index"},
+					},
+				},
+				"add": map[string]any{
+					"type":        "array",
+					"description": "Appends steps after any replace and updates.",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"step":   map[string]any{"type": "string"},
+							"status": stepStatus,
+							"note":   map[string]any{"type": "string"},
+						},
+						"required": []string{"step"},
+					},
+				},
+			},
+		},
+	}
+}
+
+type planStepArgs struct {
+	Step   string `json:"step"`
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
+
+type planPatchArgs struct {
+	Index  int     `json:"index"`
+	Step   *string `json:"step"`
+	Status *string `json:"status"`
+	Note   *string `json:"note"`
+}
+
+type updatePlanArgs struct {
+	Explanation string          `json:"explanation"`
+	Plan        *[]planStepArgs `json:"plan"`
+	Updates     []planPatchArgs `json:"updates"`
+	Add         []planStepArgs  `json:"add"`
+}
+
+type planResultStep struct {
+	Index int `json:"index"`
+	plan.Step
+}
+
+type updatePlanResult struct {
+	Plan []planResultStep `json:"plan"`
+}
+
+// runUpdatePlan applies the call to r.Plan and returns the new plan; the
+// caller installs it (the runner itself holds no state).
+func (r Runner) runUpdatePlan(raw string) (string, string, *plan.Revision) {
+	var args updatePlanArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return ErrorResult("invalid_arguments", fmt.Sprintf("invalid update_plan arguments: %v", err), ""), "update_plan: invalid arguments", nil
+		}
+	}
+	steps, err := applyPlanUpdate(r.Plan, args)
+	if err != nil {
+		return ErrorResult("invalid_plan", err.Error(), "the plan was not changed"), fmt.Sprintf("update_plan: %v", err), nil
+	}
+	res := updatePlanResult{Plan: make([]planResultStep, 0, len(steps))}
+	done := 0
+	for i, step := range steps {
+		res.Plan = append(res.Plan, planResultStep{Index: i + 1, Step: step})
+		if step.Status == plan.Done {
+			done++
+		}
+	}
+	data, _ := json.Marshal(res)
+	summary := fmt.Sprintf("update_plan: %d/%d steps done", done, len(steps))
+	return string(data), summary, &plan.Revision{Steps: steps, Explanation: strings.TrimSpace(args.Explanation)}
+}
+
+func applyPlanUpdate(current []plan.Step, args updatePlanArgs) ([]plan.Step, error) {
+	if args.Plan == nil && len(args.Updates) == 0 && len(args.Add) == 0 {
+		return nil, errors.New("update_plan needs plan, updates, or add")
+	}
+	steps := append([]plan.Step(nil), current...)
+	if args.Plan != nil {
+		steps = steps[:0]
+		for _, raw := range *args.Plan {
+			step, err := newPlanStep(raw)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		}
+	}
+	for _, patch := range args.Updates {
+		if patch.Index < 1 || patch.Index > len(steps) {
+			return nil, fmt.Errorf("step %d does not exist (the plan has %d steps)", patch.Index, len(steps))
+		}
+		step := &steps[patch.Index-1]
+		if patch.Step != nil {
+			if strings.TrimSpace(*patch.Step) == "" {
+				return nil, fmt.Errorf("step %d: text must not be empty", patch.Index)
+			}
+			step.Text = strings.TrimSpace(*patch.Step)
+		}
+		if patch.Status != nil {
+			status, err := plan.ParseStatus(*patch.Status)
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %w", patch.Index, err)
+			}
+			step.Status = status
+		}
+		if patch.Note != nil {
+			step.Note = strings.TrimSpace(*patch.Note)
+		}
+	}
+	for _, raw := range args.Add {
+		step, err := newPlanStep(raw)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	active := 0
+	for _, step := range steps {
+		if step.Status == plan.InProgress {
+			active++
+		}
+	}
+	if active > 1 {
+		return nil, fmt.Errorf("%d steps are in_progress; keep at most one", active)
+	}
+	return steps, nil
+}
+
+func newPlanStep(raw planStepArgs) (plan.Step, error) {
+	text := strings.TrimSpace(raw.Step)
+	if text == "" {
+		return plan.Step{}, errors.New("every step needs text")
+	}
+	status, err := plan.ParseStatus(raw.Status)
+	if err != nil {
+		return plan.Step{}, fmt.Errorf("step %q: %w", text, err)
+	}
+	return plan.Step{Text: text, Status: status, Note: strings.TrimSpace(raw.Note)}, nil
+}
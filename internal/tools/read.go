@@ -0,0 +1,251 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/workspace"
+)
+
+// ReadFileName reads a file from the project.
+const ReadFileName = "read_file"
+
+const (
+	// defaultReadLines and maxReadLines bound one read_file call.
+	defaultReadLines = 2000
+	maxReadLines     = 5000
+	// maxReadBytes caps the numbered text returned by one call.
+	maxReadBytes = 64 * 1024
+	// maxLineChars truncates very long lines (minified files, data blobs).
+	maxLineChars = 2000
+	// maxImageBytes is the largest image sent to the model.
+	maxImageBytes = 5 << 20
+	// sniffBytes is how much of a file is inspected for binary content.
+	sniffBytes = 8 * 1024
+)
+
+// imageTypes are the image formats providers accept inline.
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// ReadFileSpec declares the read_file tool.
+func ReadFileSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        ReadFileName,
+		Description: "Read a file in the project. Returns numbered lines (cat -n style); use offset and limit to page through large files. Images come back as pictures; other binary files are reported, not dumped. Prefer this over exec cat/head/sed.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":   map[string]any{"type": "string", "description": "File path, relative to the project root or absolute."},
+				"offset": map[string]any{"type": "integer", "description": "First line to return, 1-based (default 1)."},
+				"limit":  map[string]any{"type": "integer", "description": "Maximum lines to return (default 2000, max 5000)."},
+			},
+			"required": []string{"path"},
+		},
+	}
+}
+
+type readArgs struct {
+	Path   string `json:"path"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
+type readResult struct {
+	Path       string `json:"path"`
+	Content    string `json:"content,omitempty"`
+	StartLine  int    `json:"start_line,omitempty"`
+	EndLine    int    `json:"end_line,omitempty"`
+	TotalLines int    `json:"total_lines,omitempty"`
+	// NextOffset is set when more lines follow; pass it as offset to continue.
+	NextOffset int    `json:"next_offset,omitempty"`
+	Binary     bool   `json:"binary,omitempty"`
+	MediaType  string `json:"media_type,omitempty"`
+	Size       int64  `json:"size,omitempty"`
+	Note       string `json:"note,omitempty"`
+}
+
+// runReadFile answers read_file. Images are returned alongside the JSON
+// result so adapters can send them as native image content.
+func (r Runner) runReadFile(raw string) (string, string, []provider.Image) {
+	var args readArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return ErrorResult("invalid_arguments", fmt.Sprintf("invalid read_file arguments: %v", err), ""), "read_file: invalid arguments", nil
+		}
+	}
+	if args.Offset < 0 || args.Limit < 0 {
+		return ErrorResult("invalid_arguments", "offset and limit must not be negative", ""), "read_file: invalid arguments", nil
+	}
+	ws := r.workspace()
+	path, err := ws.Resolve(args.Path)
+	if err != nil {
+		var outside *workspace.OutsideError
+		if errors.As(err, &outside) {
+			return ErrorResult("outside_workspace", err.Error(), "ask the operator to add the directory to [files] allowed_dirs"), fmt.Sprintf("read_file %s refused: outside the project", args.Path), nil
+		}
+		return ErrorResult("invalid_arguments", err.Error(), ""), "read_file: " + err.Error(), nil
+	}
+	display := ws.Rel(path)
+	result, images, err := readFile(path, args)
+	if err != nil {
+		return ErrorResult("read_failed", err.Error(), ""), fmt.Sprintf("read_file %s failed: %v", display, err), nil
+	}
+	result.Path = display
+	if err := ws.RecordRead(path); err != nil {
+		return ErrorResult("read_failed", err.Error(), ""), fmt.Sprintf("read_file %s failed: %v", display, err), nil
+	}
+	data, _ := json.Marshal(result)
+	summary := fmt.Sprintf("read_file %s lines %d-%d of %d", display, result.StartLine, result.EndLine, result.TotalLines)
+	switch {
+	case len(images) > 0:
+		summary = fmt.Sprintf("read_file %s (%s image, %d bytes)", display, result.MediaType, result.Size)
+	case result.Binary:
+		summary = fmt.Sprintf("read_file %s (binary, %d bytes)", display, result.Size)
+	case result.TotalLines == 0:
+		summary = fmt.Sprintf("read_file %s (empty)", display)
+	}
+	return string(data), summary, images
+}
+
+// readFile returns a numbered slice of a text file, or describes a binary
+// file, returning images inline.
+func readFile(path string, args readArgs) (readResult, []provider.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return readResult{}, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return readResult{}, nil, err
+	}
+	if info.IsDir() {
+		return readResult{}, nil, fmt.Errorf("%s is a directory", path)
+	}
+	head := make([]byte, sniffBytes)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return readResult{}, nil, err
+	}
+	head = head[:n]
+	if mediaType := http.DetectContentType(head); imageTypes[mediaType] {
+		res := readResult{Binary: true, MediaType: mediaType, Size: info.Size()}
+		if info.Size() > maxImageBytes {
+			res.Note = fmt.Sprintf("image is larger than %d MB and was not attached", maxImageBytes>>20)
+			return res, nil, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return readResult{}, nil, err
+		}
+		res.Note = "image attached"
+		return res, []provider.Image{{MediaType: mediaType, Data: data}}, nil
+	}
+	if isBinary(head) {
+		return readResult{Binary: true, MediaType: http.DetectContentType(head), Size: info.Size(),
+			Note: "binary file; inspect it with exec (for example file, xxd, or a format-specific tool) if needed"}, nil, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return readResult{}, nil, err
+	}
+	return numberLines(f, args)
+}
+
+// numberLines renders lines offset..offset+limit in cat -n style and counts
+// the rest, stopping early once maxReadBytes is reached.
+func numberLines(r io.Reader, args readArgs) (readResult, []provider.Image, error) {
+	start := max(args.Offset, 1)
+	limit := args.Limit
+	if limit == 0 {
+		limit = defaultReadLines
+	}
+	limit = min(limit, maxReadLines)
+	res := readResult{}
+	var b strings.Builder
+	reader := bufio.NewReader(r)
+	line := 0
+	full := false
+	for {
+		text, err := reader.ReadString('\n')
+		if text == "" && err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return readResult{}, nil, err
+		}
+		line++
+		if line < start || full {
+			continue
+		}
+		if line >= start+limit {
+			full = true
+			res.NextOffset = line
+			continue
+		}
+		text = strings.TrimRight(text, "\r\n")
+		if len(text) > maxLineChars {
+			cut := maxLineChars
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			text = text[:cut] + fmt.Sprintf("… [%d more bytes]", len(text)-cut)
+		}
+		entry := fmt.Sprintf("%6d\t%s\n", line, text)
+		if b.Len()+len(entry) > maxReadBytes && b.Len() > 0 {
+			full = true
+			res.NextOffset = line
+			continue
+		}
+		b.WriteString(entry)
+		if res.StartLine == 0 {
+			res.StartLine = line
+		}
+		res.EndLine = line
+	}
+	res.TotalLines = line
+	res.Content = b.String()
+	switch {
+	case line == 0:
+		res.Note = "file is empty"
+	case start > line:
+		res.Note = fmt.Sprintf("offset %d is past the end of the file (%d lines)", start, line)
+	}
+	return res, nil, nil
+}
+
+// isBinary treats NUL bytes or mostly invalid UTF-8 as binary content.
+func isBinary(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	invalid := 0
+	for i := 0; i < len(head); {
+		r, size := utf8.DecodeRune(head[i:])
+		if r == utf8.RuneError && size == 1 {
+			// A rune cut off at the end of the sniffed block is fine.
+			if len(head)-i < utf8.UTFMax {
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+	return invalid*10 > len(head)
+}
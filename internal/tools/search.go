@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/search"
+	"github.com/fbettag/pfui/internal/workspace"
+)
+
+// Read-only search tools; they need no approval.
+const (
+	GrepName = "grep"
+	GlobName = "glob"
+)
+
+const (
+	defaultGrepResults = 100
+	maxGrepResults     = 500
+	defaultGlobResults = 200
+	maxGlobResults     = 1000
+)
+
+// GrepSpec declares the grep tool.
+func GrepSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        GrepName,
+		Description: "Search file contents in the project. Returns {path, line, text} matches sorted by path and line, skipping files ignored by .gitignore or .pfuiignore and binary files. Use offset to page. Prefer this over exec rg/grep; it needs no approval.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"pattern":     map[string]any{"type": "string", "description": "Regular expression (RE2/Rust syntax), or an ast-grep pattern when structural is true."},
+				"path":        map[string]any{"type": "string", "description": "File or directory to search; defaults to the project root."},
+				"glob":        map[string]any{"type": "string", "description": "Only search matching files, e.g. \"*.go\" (any depth) or \"cmd/**/*.go\"."},
+				"ignore_case": map[string]any{"type": "boolean"},
+				"literal":     map[string]any{"type": "boolean", "description": "Treat pattern as plain text."},
+				"structural":  map[string]any{"type": "boolean", "description": "Match syntax trees with ast-grep, e.g. \"fmt.Errorf($$$)\"; requires ast-grep."},
+				"lang":        map[string]any{"type": "string", "description": "Language for structural patterns, e.g. go, ts, python."},
+				"offset":      map[string]any{"type": "integer", "description": "Matches to skip (default 0)."},
+				"limit":       map[string]any{"type": "integer", "description": "Maximum matches to return (default 100, max 500)."},
+			},
+			"required": []string{"pattern"},
+		},
+	}
+}
+
+// GlobSpec declares the glob tool.
+func GlobSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        GlobName,
+		Description: "List project files whose path matches a glob, sorted, skipping files ignored by .gitignore or .pfuiignore. \"*\" stays within a directory and \"**\" spans directories, e.g. \"**/*_test.go\". Use offset to page.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"pattern": map[string]any{"type": "string", "description": "Glob relative to path."},
+				"path":    map[string]any{"type": "string", "description": "Directory to search; defaults to the project root."},
+				"offset":  map[string]any{"type": "integer", "description": "Files to skip (default 0)."},
+				"limit":   map[string]any{"type": "integer", "description": "Maximum files to return (default 200, max 1000)."},
+			},
+			"required": []string{"pattern"},
+		},
+	}
+}
+
+type grepArgs struct {
+	Pattern    string `json:"pattern"`
+	Path       string `json:"path"`
+	Glob       string `json:"glob"`
+	IgnoreCase bool   `json:"ignore_case"`
+	Literal    bool   `json:"literal"`
+	Structural bool   `json:"structural"`
+	Lang       string `json:"lang"`
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit"`
+}
+
+type grepResult struct {
+	Matches []search.Match `json:"matches"`
+	// NextOffset is set when more matches follow; pass it as offset to continue.
+	NextOffset int    `json:"next_offset,omitempty"`
+	Backend    string `json:"backend"`
+}
+
+type globArgs struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path"`
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+}
+
+type globResult struct {
+	Files      []string `json:"files"`
+	NextOffset int      `json:"next_offset,omitempty"`
+}
+
+// page clamps offset and limit and returns how many results to ask for: one
+// more than the page, to learn whether another page follows.
+func page(offset, limit, def, most int) (int, int, int) {
+	offset = max(offset, 0)
+	if limit <= 0 {
+		limit = def
+	}
+	limit = min(limit, most)
+	return offset, limit, offset + limit + 1
+}
+
+// searchPath resolves an optional path argument, defaulting to the root.
+func searchPath(ws *workspace.Workspace, raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return ws.Root(), nil
+	}
+	return ws.Resolve(raw)
+}
+
+func searchPathError(tool string, raw string, err error) (string, string) {
+	var outside *workspace.OutsideError
+	if errors.As(err, &outside) {
+		return ErrorResult("outside_workspace", err.Error(), "ask the operator to add the directory to [files] allowed_dirs"), fmt.Sprintf("%s %s refused: outside the project", tool, raw)
+	}
+	return ErrorResult("invalid_arguments", err.Error(), ""), fmt.Sprintf("%s: %v", tool, err)
+}
+
+func (r Runner) runGrep(ctx context.Context, raw string) (string, string) {
+	var args grepArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return ErrorResult("invalid_arguments", fmt.Sprintf("invalid grep arguments: %v", err), ""), "grep: invalid arguments"
+		}
+	}
+	ws := r.workspace()
+	path, err := searchPath(ws, args.Path)
+	if err != nil {
+		return searchPathError(GrepName, args.Path, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ErrorResult("invalid_arguments", err.Error(), ""), fmt.Sprintf("grep: %v", err)
+	}
+	offset, limit, want := page(args.Offset, args.Limit, defaultGrepResults, maxGrepResults)
+	matches, backend, err := search.Grep(ctx, search.Query{
+		Pattern:    args.Pattern,
+		Root:       ws.Root(),
+		Path:       path,
+		Glob:       args.Glob,
+		IgnoreCase: args.IgnoreCase,
+		Literal:    args.Literal,
+		Structural: args.Structural,
+		Lang:       args.Lang,
+		Limit:      want,
+	})
+	if err != nil {
+		feedback := ""
+		if errors.Is(err, search.ErrNoAstGrep) {
+			feedback = "retry without structural"
+		}
+		return ErrorResult("search_failed", err.Error(), feedback), fmt.Sprintf("grep %q failed: %v", args.Pattern, err)
+	}
+	res := grepResult{Matches: []search.Match{}, Backend: string(backend)}
+	if offset < len(matches) {
+		res.Matches = matches[offset:]
+	}
+	if len(res.Matches) > limit {
+		res.Matches = res.Matches[:limit]
+		res.NextOffset = offset + limit
+	}
+	data, _ := json.Marshal(res)
+	summary := fmt.Sprintf("grep %q: %d matches", args.Pattern, len(res.Matches))
+	if res.NextOffset > 0 {
+		summary += " (more follow)"
+	}
+	return string(data), summary
+}
+
+func (r Runner) runGlob(ctx context.Context, raw string) (string, string) {
+	var args globArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return ErrorResult("invalid_arguments", fmt.Sprintf("invalid glob arguments: %v", err), ""), "glob: invalid arguments"
+		}
+	}
+	ws := r.workspace()
+	dir, err := searchPath(ws, args.Path)
+	if err != nil {
+		return searchPathError(GlobName, args.Path, err)
+	}
+	offset, limit, want := page(args.Offset, args.Limit, defaultGlobResults, maxGlobResults)
+	files, err := search.Glob(ctx, ws.Root(), dir, args.Pattern, want)
+	if err != nil {
+		return ErrorResult("search_failed", err.Error(), ""), fmt.Sprintf("glob %q failed: %v", args.Pattern, err)
+	}
+	res := globResult{Files: []string{}}
+	if offset < len(files) {
+		res.Files = files[offset:]
+	}
+	if len(res.Files) > limit {
+		res.Files = res.Files[:limit]
+		res.NextOffset = offset + limit
+	}
+	data, _ := json.Marshal(res)
+	summary := fmt.Sprintf("glob %q: %d files", args.Pattern, len(res.Files))
+	if res.NextOffset > 0 {
+		summary += " (more follow)"
+	}
+	return string(data), summary
+}
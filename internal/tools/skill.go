@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/skills"
+)
+
+// LoadSkillName pulls a skill's instructions into the conversation.
+const LoadSkillName = "load_skill"
+
+// maxSkillFileBytes caps one skill resource read through load_skill.
+const maxSkillFileBytes = 256 << 10
+
+// LoadSkillSpec declares the load_skill tool for the enabled skills.
+func LoadSkillSpec(list []skills.Skill) provider.ToolSpec {
+	names := make([]string, 0, len(list))
+	for _, skill := range list {
+		names = append(names, skill.Name)
+	}
+	return provider.ToolSpec{
+		Name:        LoadSkillName,
+		Description: "Load a skill listed in the system prompt: returns its instructions and the scripts and resources that come with it. Load a skill when its description fits the task, then follow its instructions. Pass file to read one of its resources.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name": map[string]any{"type": "string", "enum": names},
+				"file": map[string]any{"type": "string", "description": "A resource path from the skill's resources list, relative to its directory."},
+			},
+			"required": []string{"name"},
+		},
+	}
+}
+
+type loadSkillArgs struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+type loadSkillResult struct {
+	Name         string   `json:"name"`
+	Dir          string   `json:"dir"`
+	Instructions string   `json:"instructions,omitempty"`
+	Resources    []string `json:"resources,omitempty"`
+	File         string   `json:"file,omitempty"`
+	Content      string   `json:"content,omitempty"`
+	Truncated    bool     `json:"truncated,omitempty"`
+}
+
+// runLoadSkill returns the skill's body, or one of its files; the third
+// result names the skill whose instructions were loaded.
+func (r Runner) runLoadSkill(raw string) (string, string, string) {
+	var args loadSkillArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return ErrorResult("invalid_arguments", fmt.Sprintf("invalid load_skill arguments: %v", err), ""), "load_skill: invalid arguments", ""
+		}
+	}
+	name := strings.TrimSpace(args.Name)
+	skill, ok := skills.Find(r.Skills, name)
+	if !ok {
+		known := make([]string, 0, len(r.Skills))
+		for _, s := range r.Skills {
+			known = append(known, s.Name)
+		}
+		reason := fmt.Sprintf("no enabled skill named %q", name)
+		if len(known) > 0 {
+			reason += " (available: " + strings.Join(known, ", ") + ")"
+		}
+		return ErrorResult("unknown_skill", reason, ""), fmt.Sprintf("load_skill %s: unknown skill", name), ""
+	}
+	res := loadSkillResult{Name: skill.Name, Dir: skill.Dir}
+	if file := strings.TrimSpace(args.File); file != "" {
+		content, truncated, err := skill.ReadResource(file, maxSkillFileBytes)
+		if err != nil {
+			return ErrorResult("read_failed", err.Error(), ""), fmt.Sprintf("load_skill %s %s: %v", skill.Name, file, err), ""
+		}
+		res.File, res.Content, res.Truncated = file, content, truncated
+		data, _ := json.Marshal(res)
+		return string(data), fmt.Sprintf("load_skill %s: read %s", skill.Name, file), ""
+	}
+	res.Instructions, res.Resources = skill.Body, skill.Resources
+	data, _ := json.Marshal(res)
+	return string(data), fmt.Sprintf("load_skill %s: ~%d tokens of instructions", skill.Name, skill.BodyTokens()), skill.Name
+}
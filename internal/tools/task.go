@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+// TaskName hands a self-contained job to a subagent.
+const TaskName = "task"
+
+// AgentInfo describes a subagent the task tool can start.
+type AgentInfo struct {
+	Name        string
+	Description string
+}
+
+// TaskRequest is one task tool call.
+type TaskRequest struct {
+	Agent  string
+	Prompt string
+	CallID string
+}
+
+// TaskReport is what a subagent run hands back: only its final message
+// reaches the calling model.
+type TaskReport struct {
+	RunID     int
+	Agent     string
+	Report    string
+	Rounds    int
+	ToolCalls int
+	// Checkpoints lists the snapshots the subagent's edits and commands took.
+	Checkpoints []int
+}
+
+// Delegator runs a subagent to completion. parent is the runner of the
+// calling agent, whose executor, workspace and checkpoints the subagent
+// shares.
+type Delegator func(ctx context.Context, parent Runner, req TaskRequest) (TaskReport, error)
+
+// TaskSpec declares the task tool for the given subagents.
+func TaskSpec(agents []AgentInfo) provider.ToolSpec {
+	names := make([]string, 0, len(agents))
+	var list strings.Builder
+	for _, agent := range agents {
+		names = append(names, agent.Name)
+		list.WriteString(fmt.Sprintf("\n- %s: %s", agent.Name, agent.Description))
+	}
+	return provider.ToolSpec{
+		Name:        TaskName,
+		Description: "Hand a self-contained task to a subagent. It works in its own conversation with its own tools and returns only a final report, so its exploration does not fill your context. Give it everything it needs in prompt; it cannot see this conversation. Subagents:" + list.String(),
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"agent":  map[string]any{"type": "string", "enum": names},
+				"prompt": map[string]any{"type": "string", "description": "The task, with the context and the form of report you want back."},
+			},
+			"required": []string{"agent", "prompt"},
+		},
+	}
+}
+
+type taskArgs struct {
+	Agent  string `json:"agent"`
+	Prompt string `json:"prompt"`
+}
+
+type taskResult struct {
+	Agent     string `json:"agent"`
+	Report    string `json:"report"`
+	Rounds    int    `json:"rounds"`
+	ToolCalls int    `json:"tool_calls"`
+}
+
+func (r Runner) runTask(ctx context.Context, call provider.ToolCall) (string, string, *TaskReport) {
+	var args taskArgs
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return ErrorResult("invalid_arguments", fmt.Sprintf("invalid task arguments: %v", err), ""), "task: invalid arguments", nil
+		}
+	}
+	args.Agent, args.Prompt = strings.TrimSpace(args.Agent), strings.TrimSpace(args.Prompt)
+	if args.Agent == "" || args.Prompt == "" {
+		return ErrorResult("invalid_arguments", "task requires agent and prompt", ""), "task: missing agent or prompt", nil
+	}
+	if r.Delegate == nil {
+		return ErrorResult("unavailable", "subagents are not available here", "do the work yourself"), fmt.Sprintf("task %s: subagents unavailable", args.Agent), nil
+	}
+	report, err := r.Delegate(ctx, r, TaskRequest{Agent: args.Agent, Prompt: args.Prompt, CallID: call.ID})
+	if err != nil {
+		kind := "task_failed"
+		var unknown *UnknownAgentError
+		if errors.As(err, &unknown) {
+			kind = "unknown_agent"
+		}
+		summary := fmt.Sprintf("task %s failed: %v", args.Agent, err)
+		if report.RunID != 0 {
+			return ErrorResult(kind, err.Error(), ""), summary, &report
+		}
+		return ErrorResult(kind, err.Error(), ""), summary, nil
+	}
+	data, _ := json.Marshal(taskResult{Agent: report.Agent, Report: report.Report, Rounds: report.Rounds, ToolCalls: report.ToolCalls})
+	summary := fmt.Sprintf("task %s finished (run %d, %d rounds, %d tool calls)", report.Agent, report.RunID, report.Rounds, report.ToolCalls)
+	return string(data), summary, &report
+}
+
+// UnknownAgentError reports a task for a subagent nobody defined.
+type UnknownAgentError struct {
+	Name  string
+	Known []string
+}
+
+func (e *UnknownAgentError) Error() string {
+	if len(e.Known) == 0 {
+		return fmt.Sprintf("no subagent named %q (none are defined)", e.Name)
+	}
+	return fmt.Sprintf("no subagent named %q (available: %s)", e.Name, strings.Join(e.Known, ", "))
+}
@@ -0,0 +1,163 @@
+// Package tools defines the functions pfui exposes to models and how their
+// arguments and results travel over the provider tool-calling contract.
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+// ExecName is the shell execution tool.
+const ExecName = "exec"
+
+// maxResultOutput caps how much command output is returned to the model.
+const maxResultOutput = 16 * 1024
+
+// Specs lists every tool offered to the model.
+func Specs() []provider.ToolSpec {
+	return []provider.ToolSpec{
+		ExecSpec(), ReadFileSpec(), GrepSpec(), GlobSpec(),
+		EditFileSpec(), MultiEditSpec(), WriteFileSpec(), ApplyPatchSpec(),
+		UpdatePlanSpec(), AskUserSpec(), JobStatusSpec(), JobOutputSpec(),
+	}
+}
+
+// ExecSpec declares the exec tool.
+func ExecSpec() provider.ToolSpec {
+	return provider.ToolSpec{
+		Name:        ExecName,
+		Description: "Run a program on the operator's machine. Foreground runs return output; background runs return a job id shown in /jobs. Every call is checked against the operator's approval rules.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command":    map[string]any{"type": "string", "description": "Program to run (no shell quoting)."},
+				"args":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"workdir":    map[string]any{"type": "string", "description": "Working directory; defaults to the project root."},
+				"background": map[string]any{"type": "boolean", "description": "Run as a background job."},
+				"timeout":    map[string]any{"type": "number", "description": "Seconds before the command is stopped."},
+				"pty":        map[string]any{"type": "boolean", "description": "Run on a terminal the operator can type into (interactive prompts, git rebase -i, npm init). The result is the ANSI-stripped transcript."},
+				"reason":     map[string]any{"type": "string", "description": "One sentence telling the operator why this command is needed."},
+			},
+			"required": []string{"command"},
+		},
+	}
+}
+
+type execArgs struct {
+	Command    string   `json:"command"`
+	Args       []string `json:"args"`
+	Workdir    string   `json:"workdir"`
+	Background bool     `json:"background"`
+	Timeout    float64  `json:"timeout"`
+	Reason     string   `json:"reason"`
+	PTY        bool     `json:"pty"`
+}
+
+// ParseExec decodes exec arguments into a toolexec.Request. Relative or empty
+// workdirs resolve against projectRoot.
+func ParseExec(raw, projectRoot string) (toolexec.Request, error) {
+	var args execArgs
+	if strings.TrimSpace(raw) != "" {
+		if err := json.Unmarshal([]byte(raw), &args); err != nil {
+			return toolexec.Request{}, fmt.Errorf("invalid exec arguments: %w", err)
+		}
+	}
+	if strings.TrimSpace(args.Command) == "" {
+		return toolexec.Request{}, errors.New("exec requires a command")
+	}
+	if args.Timeout < 0 {
+		return toolexec.Request{}, errors.New("timeout must not be negative")
+	}
+	workdir := args.Workdir
+	if workdir == "" {
+		workdir = projectRoot
+	} else if !filepath.IsAbs(workdir) && projectRoot != "" {
+		workdir = filepath.Join(projectRoot, workdir)
+	}
+	return toolexec.Request{
+		Tool:       ExecName,
+		Command:    args.Command,
+		Args:       args.Args,
+		Workdir:    workdir,
+		Background: args.Background,
+		Timeout:    time.Duration(args.Timeout * float64(time.Second)),
+		Reason:     args.Reason,
+		PTY:        args.PTY,
+	}, nil
+}
+
+// Error is the structured failure returned to the model so it can adapt.
+type Error struct {
+	Error    string `json:"error"`
+	Reason   string `json:"reason,omitempty"`
+	Feedback string `json:"feedback,omitempty"`
+}
+
+// ErrorResult encodes a tool failure.
+func ErrorResult(kind, reason, feedback string) string {
+	data, _ := json.Marshal(Error{Error: kind, Reason: reason, Feedback: feedback})
+	return string(data)
+}
+
+type execResult struct {
+	ExitCode      *int   `json:"exit_code,omitempty"`
+	Status        string `json:"status,omitempty"`
+	JobID         string `json:"job_id,omitempty"`
+	QueuePosition int    `json:"queue_position,omitempty"`
+	Output        string `json:"output,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ExecResult encodes the outcome of an exec call.
+func ExecResult(res toolexec.Result, jobID string, runErr error) string {
+	out := execResult{JobID: jobID}
+	switch {
+	case jobID != "" && res.QueuePosition > 0:
+		// Too many jobs are running; it starts when a slot frees up.
+		out.Status = string(toolexec.JobQueued)
+		out.QueuePosition = res.QueuePosition
+	case jobID != "":
+		out.Status = string(toolexec.JobRunning)
+	case res.Detached:
+		// The operator moved it to the background; report what it printed so far.
+		out.JobID = res.JobID
+		out.Status = "detached"
+		out.Output = clip(res.Output)
+	default:
+		code := res.ExitCode
+		out.ExitCode = &code
+		out.Output = clip(res.Output)
+	}
+	if runErr != nil {
+		out.Error = runErr.Error()
+	}
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+// withEditedCommand tells the model the operator changed the command before
+// approving it.
+func withEditedCommand(result string, req toolexec.Request) string {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(result), &fields); err != nil {
+		return result
+	}
+	fields["edited_command"] = append([]string{req.Command}, req.Args...)
+	data, _ := json.Marshal(fields)
+	return string(data)
+}
+
+// clip keeps the tail of long output, where errors usually are.
+func clip(s string) string {
+	if len(s) <= maxResultOutput {
+		return s
+	}
+	return "[… output clipped …]\n" + s[len(s)-maxResultOutput:]
+}
@@ -0,0 +1,446 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/checkpoint"
+	"github.com/fbettag/pfui/internal/plan"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/skills"
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/workspace"
+)
+
+func TestParseExecResolvesWorkdir(t *testing.T) {
+	root := t.TempDir()
+	req, err := ParseExec(`{"command":"ls","args":["-la"],"workdir":"sub","timeout":1.5,"reason":"look around"}`, root)
+	if err != nil {
+		t.Fatalf("ParseExec: %v", err)
+	}
+	if req.Workdir != filepath.Join(root, "sub") {
+		t.Fatalf("workdir = %q", req.Workdir)
+	}
+	if req.Timeout != 1500*time.Millisecond || req.Reason != "look around" || req.Tool != ExecName {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if _, err := ParseExec(`{"args":["x"]}`, root); err == nil {
+		t.Fatal("expected missing command error")
+	}
+}
+
+func TestRunnerReportsDenialAsToolError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses unix commands")
+	}
+	dir := t.TempDir()
+	engine, err := approvals.Load(filepath.Join(dir, "user.toml"), filepath.Join(dir, "project.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gate := approvals.NewGate(engine)
+	gate.SetPrompter(func(ctx context.Context, req toolexec.Request, v approvals.Verdict) (approvals.Answer, error) {
+		return approvals.Answer{Decision: approvals.Deny, Feedback: "use make clean"}, nil
+	})
+	runner := Runner{
+		Executor:    toolexec.NewExecutorWithOptions(toolexec.Options{Approver: gate}),
+		ProjectRoot: dir,
+	}
+	out := runner.Run(context.Background(), provider.ToolCall{ID: "call_1", Name: ExecName, Arguments: `{"command":"rm","args":["-rf","build"]}`})
+	var got Error
+	if err := json.Unmarshal([]byte(out.Content), &got); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if got.Error != "denied" || got.Feedback != "use make clean" {
+		t.Fatalf("unexpected denial %+v", got)
+	}
+
+	out = runner.Run(context.Background(), provider.ToolCall{ID: "call_2", Name: "nope"})
+	if err := json.Unmarshal([]byte(out.Content), &got); err != nil || got.Error != "unknown_tool" {
+		t.Fatalf("unexpected unknown tool result %q", out.Content)
+	}
+}
+
+func TestJobToolsReportBackgroundJobs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses unix commands")
+	}
+	runner := Runner{Executor: toolexec.NewExecutor(), ProjectRoot: t.TempDir()}
+	started := runner.Run(context.Background(), provider.ToolCall{ID: "call_1", Name: ExecName, Arguments: `{"command":"sh","args":["-c","echo one; echo two; exit 3"],"background":true}`})
+	if started.JobID == "" {
+		t.Fatalf("expected a job id, got %q", started.Content)
+	}
+
+	out := runner.Run(context.Background(), provider.ToolCall{ID: "call_2", Name: JobStatusName, Arguments: `{"job_id":"` + started.JobID + `","wait":5}`})
+	var status jobResult
+	if err := json.Unmarshal([]byte(out.Content), &status); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if status.Status != string(toolexec.JobFailed) || status.ExitCode == nil || *status.ExitCode != 3 || status.StillRunning {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	out = runner.Run(context.Background(), provider.ToolCall{ID: "call_3", Name: JobOutputName, Arguments: `{"job_id":"` + started.JobID + `","lines":1}`})
+	var output jobResult
+	if err := json.Unmarshal([]byte(out.Content), &output); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if output.Output != "two" {
+		t.Fatalf("expected the last line, got %q", output.Output)
+	}
+
+	job, _ := runner.Executor.Job(started.JobID)
+	if notice := JobNotice(job); !strings.Contains(notice, "status failed, exit 3") || !strings.Contains(notice, "one\ntwo") {
+		t.Fatalf("unexpected notice %q", notice)
+	}
+
+	out = runner.Run(context.Background(), provider.ToolCall{ID: "call_4", Name: JobStatusName, Arguments: `{"job_id":"nope"}`})
+	var got Error
+	if err := json.Unmarshal([]byte(out.Content), &got); err != nil || got.Error != "unknown_job" {
+		t.Fatalf("unexpected unknown job result %q", out.Content)
+	}
+}
+
+func TestReadFileNumbersPagesAndDetectsBinaries(t *testing.T) {
+	root := t.TempDir()
+	var body strings.Builder
+	for i := 1; i <= 10; i++ {
+		body.WriteString(fmt.Sprintf("line %d\n", i))
+	}
+	files := map[string][]byte{
+		"a.txt":   []byte(body.String()),
+		"blob":    {0x7f, 'E', 'L', 'F', 0, 1, 2},
+		"pic.png": append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(root, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws := workspace.New(root, nil)
+	runner := Runner{ProjectRoot: root, Workspace: ws}
+	read := func(args string) (readResult, Outcome) {
+		t.Helper()
+		out := runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: ReadFileName, Arguments: args})
+		var res readResult
+		if err := json.Unmarshal([]byte(out.Content), &res); err != nil {
+			t.Fatalf("content %q: %v", out.Content, err)
+		}
+		return res, out
+	}
+
+	res, _ := read(`{"path":"a.txt","offset":3,"limit":2}`)
+	if res.Content != "     3\tline 3\n     4\tline 4\n" || res.TotalLines != 10 || res.NextOffset != 5 {
+		t.Fatalf("unexpected page %+v", res)
+	}
+	if err := ws.CheckFresh(filepath.Join(ws.Root(), "a.txt")); err != nil {
+		t.Fatalf("expected the read to be recorded: %v", err)
+	}
+	if res, _ := read(`{"path":"blob"}`); !res.Binary || res.Content != "" {
+		t.Fatalf("expected binary description, got %+v", res)
+	}
+	if res, out := read(`{"path":"pic.png"}`); res.MediaType != "image/png" || len(out.Images) != 1 {
+		t.Fatalf("expected an attached image, got %+v", res)
+	}
+
+	out := runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: ReadFileName, Arguments: `{"path":"../etc/passwd"}`})
+	var got Error
+	if err := json.Unmarshal([]byte(out.Content), &got); err != nil || got.Error != "outside_workspace" {
+		t.Fatalf("unexpected result for a path outside the project %q", out.Content)
+	}
+}
+
+func TestEditToolsRequireFreshReadsAndApproval(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc a() {}\nfunc a() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	engine, err := approvals.Load(filepath.Join(dir, "user.toml"), filepath.Join(dir, "project.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gate := approvals.NewGate(engine)
+	var previews []string
+	gate.SetPrompter(func(ctx context.Context, req toolexec.Request, v approvals.Verdict) (approvals.Answer, error) {
+		previews = append(previews, req.Preview)
+		return approvals.Answer{Decision: approvals.Allow}, nil
+	})
+	ws := workspace.New(root, nil)
+	runner := Runner{Executor: toolexec.NewExecutorWithOptions(toolexec.Options{Approver: gate}), ProjectRoot: root, Workspace: ws}
+	run := func(name, args string) (Outcome, Error) {
+		t.Helper()
+		out := runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: name, Arguments: args})
+		var got Error
+		_ = json.Unmarshal([]byte(out.Content), &got)
+		return out, got
+	}
+
+	if _, got := run(EditFileName, `{"path":"main.go","old_string":"func a() {}","new_string":"func b() {}"}`); got.Error != "stale_file" {
+		t.Fatalf("expected an unread file to be refused, got %+v", got)
+	}
+	run(ReadFileName, `{"path":"main.go"}`)
+	if _, got := run(EditFileName, `{"path":"main.go","old_string":"func a() {}","new_string":"func b() {}"}`); got.Error != "not_unique" {
+		t.Fatalf("expected an ambiguous match to be refused, got %+v", got)
+	}
+	out, got := run(MultiEditName, `{"path":"main.go","edits":[{"old_string":"func a() {}","new_string":"func b() {}","replace_all":true},{"old_string":"package main","new_string":"package app"}]}`)
+	if got.Error != "" {
+		t.Fatalf("multi_edit failed: %s", out.Content)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "package app\n\nfunc b() {}\nfunc b() {}\n" {
+		t.Fatalf("unexpected contents %q", data)
+	}
+	if len(previews) != 1 || !strings.Contains(previews[0], "-package main\n+package app\n") || out.Diff != previews[0] {
+		t.Fatalf("expected the diff in the approval prompt, got %q", previews)
+	}
+
+	// The edit recorded the new contents, so a follow-up works; an outside
+	// change makes the next edit stale.
+	if err := os.WriteFile(path, []byte("package app\n\n// changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, got := run(EditFileName, `{"path":"main.go","old_string":"changed","new_string":"x"}`); got.Error != "stale_file" {
+		t.Fatalf("expected a stale file to be refused, got %+v", got)
+	}
+
+	patchText := "*** Begin Patch\n*** Add File: docs/notes.md\n+hello\n*** End Patch"
+	if out, got := run(ApplyPatchName, `{"patch":`+strconv.Quote(patchText)+`}`); got.Error != "" || out.Summary != "apply_patch docs/notes.md (+1 -0)" {
+		t.Fatalf("apply_patch: %s (%s)", out.Content, out.Summary)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "docs", "notes.md")); string(data) != "hello\n" {
+		t.Fatalf("unexpected new file %q", data)
+	}
+	if _, got := run(WriteFileName, `{"path":"docs/notes.md","content":"bye\n"}`); got.Error != "" {
+		t.Fatalf("expected write_file to accept a file pfui just wrote, got %+v", got)
+	}
+
+	gate.SetPrompter(func(ctx context.Context, req toolexec.Request, v approvals.Verdict) (approvals.Answer, error) {
+		return approvals.Answer{Decision: approvals.Deny, Feedback: "not yet"}, nil
+	})
+	if _, got := run(WriteFileName, `{"path":"new.txt","content":"x"}`); got.Error != "denied" || got.Feedback != "not yet" {
+		t.Fatalf("expected a denial, got %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "new.txt")); !os.IsNotExist(err) {
+		t.Fatalf("denied write created the file: %v", err)
+	}
+}
+
+func TestSearchToolsPage(t *testing.T) {
+	root := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%d.go", i)), []byte("// TODO one\n// TODO two\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runner := Runner{ProjectRoot: root, Workspace: workspace.New(root, nil)}
+	out := runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: GrepName, Arguments: `{"pattern":"TODO","offset":2,"limit":3}`})
+	var res grepResult
+	if err := json.Unmarshal([]byte(out.Content), &res); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if len(res.Matches) != 3 || res.Matches[0].Path != "f2.go" || res.Matches[0].Line != 1 || res.NextOffset != 5 {
+		t.Fatalf("unexpected page %+v", res)
+	}
+
+	out = runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: GlobName, Arguments: `{"pattern":"*.go","offset":2}`})
+	var files globResult
+	if err := json.Unmarshal([]byte(out.Content), &files); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if len(files.Files) != 1 || files.Files[0] != "f3.go" || files.NextOffset != 0 {
+		t.Fatalf("unexpected glob page %+v", files)
+	}
+}
+
+func TestMutatingCallsLeaveCheckpoints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses touch")
+	}
+	root := t.TempDir()
+	path := filepath.Join(root, "notes.md")
+	if err := os.WriteFile(path, []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := checkpoint.Open(filepath.Join(t.TempDir(), "session"))
+	runner := Runner{Executor: toolexec.NewExecutor(), ProjectRoot: root, Workspace: workspace.New(root, nil), Checkpoints: store, CheckpointExec: true, Turn: 1}
+	run := func(name, args string) Outcome {
+		t.Helper()
+		return runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: name, Arguments: args})
+	}
+
+	if out := run(ExecName, `{"command":"ls"}`); out.Checkpoint != 0 {
+		t.Fatalf("read-only commands need no checkpoint, got %d", out.Checkpoint)
+	}
+	run(ReadFileName, `{"path":"notes.md"}`)
+	edit := run(EditFileName, `{"path":"notes.md","old_string":"one","new_string":"two"}`)
+	if edit.Checkpoint != 1 {
+		t.Fatalf("expected checkpoint 1 for the edit, got %d (%s)", edit.Checkpoint, edit.Content)
+	}
+	if out := run(ExecName, `{"command":"touch","args":["made.txt"]}`); out.Checkpoint != 2 {
+		t.Fatalf("expected a tree checkpoint before touch, got %d (%s)", out.Checkpoint, out.Content)
+	}
+
+	res, err := store.Restore(edit.Checkpoint, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "one\n" {
+		t.Fatalf("edit not reverted: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "made.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file created by exec not removed (removed %v)", res.Removed)
+	}
+}
+
+func TestUpdatePlanReplacesAndPatchesSteps(t *testing.T) {
+	runner := Runner{Plan: []plan.Step{{Text: "old step", Status: plan.Done}}}
+	run := func(args string) Outcome {
+		t.Helper()
+		out := runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: UpdatePlanName, Arguments: args})
+		if out.Plan != nil {
+			runner.Plan = out.Plan.Steps
+		}
+		return out
+	}
+
+	out := run(`{"explanation":"starting over","plan":[{"step":"read code","status":"in_progress"},{"step":"fix bug"}]}`)
+	if out.Plan == nil || out.Plan.Explanation != "starting over" || len(out.Plan.Steps) != 2 || out.Plan.Steps[1].Status != plan.Pending {
+		t.Fatalf("unexpected replacement %+v (%s)", out.Plan, out.Content)
+	}
+	out = run(`{"updates":[{"index":1,"status":"done"},{"index":2,"status":"in-progress","note":"in parser.go"}],"add":[{"step":"run tests"}]}`)
+	var res updatePlanResult
+	if err := json.Unmarshal([]byte(out.Content), &res); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if len(res.Plan) != 3 || res.Plan[0].Status != plan.Done || res.Plan[1].Note != "in parser.go" || res.Plan[2].Index != 3 {
+		t.Fatalf("unexpected plan %+v", res.Plan)
+	}
+	if out.Summary != "update_plan: 1/3 steps done" {
+		t.Fatalf("unexpected summary %q", out.Summary)
+	}
+
+	for _, bad := range []string{
+		`{}`,
+		`{"updates":[{"index":4,"status":"done"}]}`,
+		`{"updates":[{"index":3,"status":"in_progress"}]}`,
+		`{"add":[{"step":"x","status":"blocked"}]}`,
+	} {
+		out := run(bad)
+		var got Error
+		_ = json.Unmarshal([]byte(out.Content), &got)
+		if got.Error == "" || out.Plan != nil {
+			t.Fatalf("%s: expected an error and no plan change, got %s", bad, out.Content)
+		}
+	}
+	if len(runner.Plan) != 3 || runner.Plan[2].Status != plan.Pending {
+		t.Fatalf("failed updates must leave the plan alone: %+v", runner.Plan)
+	}
+}
+
+func TestAskUserUsesTheAskerOrFailsFast(t *testing.T) {
+	call := provider.ToolCall{ID: "call", Name: AskUserName, Arguments: `{"question":"Which database?","options":["postgres","sqlite"],"multi_select":true,"allow_free_text":false}`}
+	var asked Question
+	runner := Runner{Ask: func(ctx context.Context, q Question) (Answer, error) {
+		asked = q
+		return Answer{Selected: []string{"postgres", "sqlite"}}, nil
+	}}
+	out := runner.Run(context.Background(), call)
+	var res askResult
+	if err := json.Unmarshal([]byte(out.Content), &res); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if !asked.Multi || asked.FreeText || len(asked.Options) != 2 || res.Answer != "postgres, sqlite" || len(res.Selected) != 2 {
+		t.Fatalf("unexpected question %+v / result %+v", asked, res)
+	}
+
+	runner.Ask = func(ctx context.Context, q Question) (Answer, error) { return Answer{}, ErrDismissed }
+	var got Error
+	_ = json.Unmarshal([]byte(runner.Run(context.Background(), call).Content), &got)
+	if got.Error != "dismissed" {
+		t.Fatalf("expected dismissed, got %+v", got)
+	}
+
+	// Without an operator ask_user fails fast.
+	runner.Ask = nil
+	got = Error{}
+	_ = json.Unmarshal([]byte(runner.Run(context.Background(), call).Content), &got)
+	if got.Error != "no_operator" {
+		t.Fatalf("expected no_operator, got %+v", got)
+	}
+}
+
+func TestTaskHandsTheJobToTheDelegator(t *testing.T) {
+	call := provider.ToolCall{ID: "call", Name: TaskName, Arguments: `{"agent":"explorer","prompt":"Find the config loader"}`}
+	var got Error
+	_ = json.Unmarshal([]byte(Runner{}.Run(context.Background(), call).Content), &got)
+	if got.Error != "unavailable" {
+		t.Fatalf("expected unavailable without a delegator, got %+v", got)
+	}
+
+	var req TaskRequest
+	runner := Runner{Delegate: func(ctx context.Context, parent Runner, r TaskRequest) (TaskReport, error) {
+		req = r
+		if r.Agent != "explorer" {
+			return TaskReport{}, &UnknownAgentError{Name: r.Agent, Known: []string{"explorer"}}
+		}
+		return TaskReport{RunID: 3, Agent: r.Agent, Report: "internal/config/config.go", Rounds: 2, ToolCalls: 4, Checkpoints: []int{7}}, nil
+	}}
+	out := runner.Run(context.Background(), call)
+	var res taskResult
+	if err := json.Unmarshal([]byte(out.Content), &res); err != nil {
+		t.Fatalf("content %q: %v", out.Content, err)
+	}
+	if req.Prompt != "Find the config loader" || req.CallID != "call" || res.Report != "internal/config/config.go" || res.ToolCalls != 4 {
+		t.Fatalf("unexpected request %+v / result %+v", req, res)
+	}
+	if out.Task == nil || len(out.Task.Checkpoints) != 1 || !strings.Contains(out.Summary, "run 3") {
+		t.Fatalf("outcome should carry the run, got %+v", out)
+	}
+
+	call.Arguments = `{"agent":"writer","prompt":"x"}`
+	got = Error{}
+	_ = json.Unmarshal([]byte(runner.Run(context.Background(), call).Content), &got)
+	if got.Error != "unknown_agent" || !strings.Contains(got.Reason, "explorer") {
+		t.Fatalf("expected unknown_agent, got %+v", got)
+	}
+}
+
+func TestLoadSkillReturnsInstructionsAndResources(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "check.sh"), []byte("echo ok\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	runner := Runner{Skills: []skills.Skill{{Name: "release", Description: "Cut a release", Body: "Run check.sh first.", Dir: dir, Resources: []string{"check.sh"}}}}
+	load := func(args string) (loadSkillResult, Outcome) {
+		out := runner.Run(context.Background(), provider.ToolCall{ID: "call", Name: LoadSkillName, Arguments: args})
+		var res loadSkillResult
+		_ = json.Unmarshal([]byte(out.Content), &res)
+		return res, out
+	}
+	res, out := load(`{"name":"release"}`)
+	if res.Instructions != "Run check.sh first." || res.Dir != dir || len(res.Resources) != 1 || out.Skill != "release" {
+		t.Fatalf("unexpected result %+v / outcome %+v", res, out)
+	}
+	res, out = load(`{"name":"release","file":"check.sh"}`)
+	if res.Content != "echo ok\n" || res.Instructions != "" || out.Skill != "" {
+		t.Fatalf("unexpected file result %+v", res)
+	}
+	_, out = load(`{"name":"deploy"}`)
+	var got Error
+	_ = json.Unmarshal([]byte(out.Content), &got)
+	if got.Error != "unknown_skill" || !strings.Contains(got.Reason, "release") {
+		t.Fatalf("expected unknown_skill, got %+v", got)
+	}
+}
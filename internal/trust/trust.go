@@ -0,0 +1,136 @@
+// Package trust remembers which project files the operator has accepted.
+// Files such as .pfui/approvals.toml and .pfui/env.toml arrive with a
+// repository, so pfui only honors the parts of them that loosen its defaults
+// once the operator has trusted them. Trust is recorded by path and SHA-256:
+// any change to the file, from a pull or a checkout, lapses it until the
+// operator accepts the new contents.
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FileName is the trust record under ~/.pfui.
+const FileName = "trusted.toml"
+
+// Store reads and writes the trust record.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+type record struct {
+	// Files maps absolute paths to the hex SHA-256 of their trusted contents.
+	Files map[string]string `toml:"files"`
+}
+
+// DefaultPath resolves $PFUI_HOME/trusted.toml or ~/.pfui/trusted.toml.
+func DefaultPath() (string, error) {
+	if custom := os.Getenv("PFUI_HOME"); custom != "" {
+		return filepath.Join(custom, FileName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".pfui", FileName), nil
+}
+
+// Open returns a store backed by path; the file is created on first Trust.
+func Open(path string) *Store {
+	return &Store{path: path}
+}
+
+// Trusted reports whether file exists and matches the contents the operator
+// accepted. A nil store trusts nothing.
+func (s *Store) Trusted(file string) bool {
+	if s == nil {
+		return false
+	}
+	sum, err := hashFile(file)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.read()
+	if err != nil {
+		return false
+	}
+	return rec.Files[absPath(file)] == sum
+}
+
+// Trust records file's current contents as accepted.
+func (s *Store) Trust(file string) error {
+	if s == nil {
+		return errors.New("no trust store configured")
+	}
+	sum, err := hashFile(file)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, err := s.read()
+	if err != nil {
+		return err
+	}
+	if rec.Files == nil {
+		rec.Files = map[string]string{}
+	}
+	rec.Files[absPath(file)] = sum
+	data, err := toml.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding trust record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("ensuring trust dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("writing %s: %w", s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("writing %s: %w", s.path, err)
+	}
+	return nil
+}
+
+func (s *Store) read() (record, error) {
+	var rec record
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return rec, nil
+	}
+	if err != nil {
+		return rec, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	if err := toml.Unmarshal(raw, &rec); err != nil {
+		return rec, fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	return rec, nil
+}
+
+func hashFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", file, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func absPath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
@@ -0,0 +1,35 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrustLapsesWhenFileChanges(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "env.toml")
+	if err := os.WriteFile(file, []byte("[set]\nA = \"1\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := Open(filepath.Join(dir, "home", FileName))
+	if store.Trusted(file) {
+		t.Fatal("a new file must not be trusted")
+	}
+	if err := store.Trust(file); err != nil {
+		t.Fatal(err)
+	}
+	if !Open(store.path).Trusted(file) {
+		t.Fatal("trust should persist")
+	}
+	if err := os.WriteFile(file, []byte("[set]\nA = \"2\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if store.Trusted(file) {
+		t.Fatal("changed contents must lapse trust")
+	}
+	var none *Store
+	if none.Trusted(file) {
+		t.Fatal("a nil store trusts nothing")
+	}
+}
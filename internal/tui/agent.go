@@ -0,0 +1,489 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/plan"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/sandbox"
+	"github.com/fbettag/pfui/internal/systemprompt"
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/tools"
+)
+
+// maxAgentTurns bounds how many tool round-trips one prompt may trigger.
+const maxAgentTurns = 25
+
+type toolResultsMsg struct {
+	outcomes []tools.Outcome
+}
+
+// approvalAsk travels from the executor goroutine to the TUI; the goroutine
+// blocks on reply until the operator answers.
+type approvalAsk struct {
+	req     toolexec.Request
+	verdict approvals.Verdict
+	reply   chan approvals.Answer
+}
+
+type approvalAskMsg struct {
+	ask approvalAsk
+}
+
+// approvalPromptFunc returns the gate prompter that hands asks to the TUI.
+func approvalPromptFunc(asks chan<- approvalAsk) approvals.Prompter {
+	return func(ctx context.Context, req toolexec.Request, verdict approvals.Verdict) (approvals.Answer, error) {
+		ask := approvalAsk{req: req, verdict: verdict, reply: make(chan approvals.Answer, 1)}
+		select {
+		case asks <- ask:
+		case <-ctx.Done():
+			return approvals.Answer{}, ctx.Err()
+		}
+		select {
+		case answer := <-ask.reply:
+			return answer, nil
+		case <-ctx.Done():
+			return approvals.Answer{}, ctx.Err()
+		}
+	}
+}
+
+func listenApprovalAsks(asks <-chan approvalAsk) tea.Cmd {
+	if asks == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		ask, ok := <-asks
+		if !ok {
+			return nil
+		}
+		return approvalAskMsg{ask: ask}
+	}
+}
+
+// appendUserTurn records a prompt in the conversation, seeding the system
+// prompt on the first turn.
+func (m *model) appendUserTurn(text string) {
+	if len(m.conversation) == 0 {
+		m.conversation = append(m.conversation, provider.ChatMessage{Role: "system", Content: m.systemPrompt()})
+	}
+	m.flushJobNotices()
+	m.conversation = append(m.conversation, provider.ChatMessage{Role: "user", Content: text})
+	m.agentTurns = 0
+	m.turn++
+}
+
+func (m *model) systemPrompt() string {
+	return systemprompt.Build(systemprompt.BuildOptions{
+		ProviderName: providerLabel(m.activeProvider),
+		Model:        m.defaultModel,
+		PlanMode:     string(m.plan),
+		Sandbox:      m.sandbox.Summary(),
+		Skills:       m.skillEntries(),
+		Subagents:    m.subagentNames(),
+	})
+}
+
+// refreshSystemPrompt rewrites a started conversation's system prompt after
+// something it lists changed.
+func (m *model) refreshSystemPrompt() {
+	if len(m.conversation) > 0 && m.conversation[0].Role == "system" {
+		m.conversation[0].Content = m.systemPrompt()
+	}
+}
+
+// flushJobNotices adds pending finished-job notes to the conversation.
+func (m *model) flushJobNotices() {
+	if len(m.jobNotices) == 0 {
+		return
+	}
+	m.conversation = append(m.conversation, provider.ChatMessage{Role: "user", Content: strings.Join(m.jobNotices, "\n\n")})
+	m.jobNotices = nil
+}
+
+// followUpJobs starts a turn for finished background jobs when the
+// operator enabled exec.auto_follow_up and pfui is idle in AUTO mode. The
+// turn counts against the current prompt's tool round limit.
+func (m *model) followUpJobs() tea.Cmd {
+	if !m.cfg.Exec.AutoFollowUp || m.plan != planModeAuto || len(m.jobNotices) == 0 {
+		return nil
+	}
+	if m.activeProvider == nil || m.pendingResponse != nil || m.toolsRunning || len(m.conversation) == 0 {
+		return nil
+	}
+	if m.agentTurns >= maxAgentTurns {
+		return nil
+	}
+	m.agentTurns++
+	m.messages = append(m.messages, fmt.Sprintf("pfui: %d background job(s) finished; continuing (AUTO)", len(m.jobNotices)))
+	m.flushJobNotices()
+	return m.beginResponseStream()
+}
+
+// completeTurn stores the assistant reply and runs any requested tools.
+func (m *model) completeTurn() tea.Cmd {
+	resp := m.pendingResponse
+	m.finishResponseStream()
+	if resp == nil {
+		return nil
+	}
+	m.conversation = append(m.conversation, provider.ChatMessage{
+		Role:      "assistant",
+		Content:   resp.buffer,
+		ToolCalls: resp.toolCalls,
+	})
+	if len(resp.toolCalls) == 0 {
+		return m.followUpJobs()
+	}
+	if m.agentTurns >= maxAgentTurns {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: stopped after %d tool rounds; send a message to continue", maxAgentTurns))
+		for _, call := range resp.toolCalls {
+			m.conversation = append(m.conversation, provider.ChatMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Name:       call.Name,
+				Content:    tools.ErrorResult("turn_limit", "tool round limit reached; wait for the operator", ""),
+			})
+		}
+		return nil
+	}
+	m.agentTurns++
+	m.toolsRunning = true
+	for _, call := range resp.toolCalls {
+		m.messages = append(m.messages, fmt.Sprintf("[tool] %s %s", call.Name, call.Arguments))
+	}
+	if m.tasksNeedCatalog(resp.toolCalls) {
+		m.pendingToolCalls = resp.toolCalls
+		return m.loadRouteCatalogCmd()
+	}
+	return m.runToolCallsCmd(resp.toolCalls)
+}
+
+func (m *model) runToolCallsCmd(calls []provider.ToolCall) tea.Cmd {
+	runner := tools.Runner{
+		Executor:       m.executor,
+		ProjectRoot:    m.opts.ProjectPath,
+		Workspace:      m.workspace,
+		ReadOnly:       m.sandbox.Level == sandbox.LevelReadOnly,
+		Checkpoints:    m.checkpoints,
+		CheckpointExec: m.cfg.Checkpoints.Exec,
+		Turn:           m.turn,
+		Plan:           append([]plan.Step(nil), m.planSteps...),
+		Ask:            userAskFunc(m.userAsks),
+		Delegate:       m.subagentDelegate(),
+		Skills:         m.enabledSkills(),
+	}
+	ctx := m.ctx
+	return func() tea.Msg {
+		outcomes := make([]tools.Outcome, 0, len(calls))
+		for _, call := range calls {
+			out := runner.Run(ctx, call)
+			if out.Plan != nil {
+				// Later update_plan calls in the same round patch this one.
+				runner.Plan = out.Plan.Steps
+			}
+			outcomes = append(outcomes, out)
+		}
+		return toolResultsMsg{outcomes: outcomes}
+	}
+}
+
+// handleToolResults feeds tool outcomes back to the model and continues the turn.
+func (m *model) handleToolResults(msg toolResultsMsg) tea.Cmd {
+	m.toolsRunning = false
+	for _, outcome := range msg.outcomes {
+		m.messages = append(m.messages, "[tool] "+outcome.Summary)
+		m.recordCheckpoint(outcome.Checkpoint)
+		m.recordTask(outcome.Task)
+		if outcome.Skill != "" {
+			m.loadedSkills[outcome.Skill] = true
+		}
+		if outcome.Plan != nil {
+			m.applyPlanRevision(*outcome.Plan)
+		}
+		if outcome.Diff != "" {
+			m.messages = append(m.messages, renderDiff(outcome.Diff, maxDiffScrollbackLines)...)
+		}
+		m.conversation = append(m.conversation, provider.ChatMessage{
+			Role:       "tool",
+			ToolCallID: outcome.Call.ID,
+			Name:       outcome.Call.Name,
+			Content:    outcome.Content,
+			Images:     outcome.Images,
+		})
+	}
+	m.flushJobNotices()
+	return m.beginResponseStream()
+}
+
+// approvalPrompt is the inline chooser shown while a tool call waits for the operator.
+type approvalPrompt struct {
+	ask approvalAsk
+}
+
+func (m *model) openApprovalPrompt(ask approvalAsk) {
+	m.approval = &approvalPrompt{ask: ask}
+	if isFileEdit(ask.req) {
+		m.messages = append(m.messages, fmt.Sprintf("[approval] %s wants to change %s", ask.req.Tool, strings.Join(ask.req.Args, " ")))
+		return
+	}
+	m.messages = append(m.messages, fmt.Sprintf("[approval] %s wants to run %s", ask.req.Tool, commandLine(ask.req)))
+}
+
+// answerApproval replies to the waiting executor and logs the decision.
+func (m *model) answerApproval(answer approvals.Answer, note string) {
+	if m.approval == nil {
+		return
+	}
+	m.approval.ask.reply <- answer
+	m.approval = nil
+	m.messages = append(m.messages, "[approval] "+note)
+	m.compose.Focus()
+	m.refreshComposeStatus()
+}
+
+func (m model) updateApproval(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	req := m.approval.ask.req
+	risky := len(m.approval.ask.verdict.Risks) > 0
+	switch msg.String() {
+	case "y", "enter":
+		if risky && msg.String() == "enter" {
+			// Risky commands need a deliberate y, not a reflexive enter.
+			return m, nil
+		}
+		m.answerApproval(approvals.Answer{Decision: approvals.Allow}, "approved once: "+commandLine(req))
+	case "a", "A":
+		if risky {
+			// A remembered rule would not skip the next risk prompt anyway.
+			return m, nil
+		}
+		scope := approvals.ScopeSession
+		label := "this session"
+		if msg.String() == "A" {
+			scope = approvals.ScopeUser
+			label = "always (saved)"
+		}
+		rule, ok := prefixRule(req)
+		if !ok {
+			// Shells and interpreters are approved one call at a time.
+			return m, nil
+		}
+		m.answerApproval(approvals.Answer{Decision: approvals.Allow, Remember: scope, Rule: rule},
+			fmt.Sprintf("approved %s for %s", label, rule.String()))
+	case "n", "d":
+		m.askApprovalFeedback()
+	case "e":
+		if !isFileEdit(req) {
+			m.askApprovalEdit()
+		}
+	case "esc":
+		m.answerApproval(approvals.Answer{Decision: approvals.Deny}, "denied: "+commandLine(req))
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// askApprovalFeedback reuses the question prompt to collect a denial note.
+func (m *model) askApprovalFeedback() {
+	req := m.approval.ask.req
+	q := newQuestionPrompt(fmt.Sprintf("Why deny %s? (sent to the model; enter to skip)", commandLine(req)), nil)
+	q.AllowEmpty = true
+	q.onAnswer = func(m *model, answer string) {
+		m.answerApproval(approvals.Answer{Decision: approvals.Deny, Feedback: answer}, "denied: "+commandLine(req))
+	}
+	q.onDismiss = func(m *model) {
+		m.answerApproval(approvals.Answer{Decision: approvals.Deny}, "denied: "+commandLine(req))
+	}
+	m.question = q
+}
+
+// askApprovalEdit reuses the question prompt to let the operator rewrite the
+// command before approving it.
+func (m *model) askApprovalEdit() {
+	req := m.approval.ask.req
+	q := newQuestionPrompt("Edit the command, then press enter to run it (esc to go back)", nil)
+	q.Input.SetValue(commandLine(req))
+	q.Input.CursorEnd()
+	q.onAnswer = func(m *model, answer string) {
+		fields := splitCommandLine(answer)
+		if len(fields) == 0 || fields[0] == "" {
+			m.messages = append(m.messages, "pfui: edited command is empty; choose again")
+			return
+		}
+		edited := req
+		edited.Command, edited.Args = fields[0], fields[1:]
+		m.answerApproval(approvals.Answer{Decision: approvals.Allow, Edited: &edited}, "approved edited command: "+commandLine(edited))
+	}
+	q.onDismiss = func(m *model) {
+		m.messages = append(m.messages, "pfui: edit canceled; choose again")
+	}
+	m.question = q
+}
+
+func renderApprovalPrompt(p *approvalPrompt) string {
+	req := p.ask.req
+	if isFileEdit(req) {
+		return renderEditApproval(p)
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Approve %s?\n", req.Tool))
+	b.WriteString(fmt.Sprintf("  command:    %s\n", req.Command))
+	if len(req.Args) > 0 {
+		b.WriteString(fmt.Sprintf("  args:       %s\n", quoteArgs(req.Args)))
+	}
+	b.WriteString(fmt.Sprintf("  workdir:    %s\n", safeDisplay(req.Workdir, "(project root)")))
+	b.WriteString(fmt.Sprintf("  background: %t\n", req.Background))
+	if req.PTY {
+		b.WriteString("  terminal:   yes (you can type into it; ctrl+z backgrounds it)\n")
+	}
+	b.WriteString(fmt.Sprintf("  reason:     %s\n", safeDisplay(req.Reason, "(none given)")))
+	if risks := p.ask.verdict.Risks; len(risks) > 0 {
+		for _, risk := range risks {
+			b.WriteString(fmt.Sprintf("  RISK:       %s\n", risk))
+		}
+		b.WriteString("[y] run it anyway  [d] deny with feedback  [e] edit  [esc] deny\n")
+		return b.String()
+	}
+	if p.ask.verdict.Reason != "" {
+		b.WriteString(fmt.Sprintf("  policy:     %s\n", p.ask.verdict.Reason))
+	}
+	rule, ok := prefixRule(req)
+	if !ok {
+		b.WriteString(fmt.Sprintf("[y] once (no \"always\": %s runs arbitrary code)  [d] deny with feedback  [e] edit  [esc] deny\n", req.Command))
+		return b.String()
+	}
+	b.WriteString(fmt.Sprintf("[y] once  [a] always for %q this session  [A] always (save)  [d] deny with feedback  [e] edit  [esc] deny\n", rule.String()))
+	return b.String()
+}
+
+// renderEditApproval shows the diff a file tool wants to apply.
+func renderEditApproval(p *approvalPrompt) string {
+	req := p.ask.req
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Approve %s of %s?\n", req.Tool, strings.Join(req.Args, ", ")))
+	for _, line := range renderDiff(req.Preview, maxDiffPreviewLines) {
+		b.WriteString("  " + line + "\n")
+	}
+	if risks := p.ask.verdict.Risks; len(risks) > 0 {
+		for _, risk := range risks {
+			b.WriteString(fmt.Sprintf("  RISK:       %s\n", risk))
+		}
+		b.WriteString("[y] apply it anyway  [d] deny with feedback  [esc] deny\n")
+		return b.String()
+	}
+	if p.ask.verdict.Reason != "" {
+		b.WriteString(fmt.Sprintf("  policy:     %s\n", p.ask.verdict.Reason))
+	}
+	rule, _ := prefixRule(req)
+	b.WriteString(fmt.Sprintf("[y] apply  [a] always for %q this session  [A] always (save)  [d] deny with feedback  [esc] deny\n", rule.String()))
+	return b.String()
+}
+
+// isFileEdit reports whether req comes from a file editing tool rather than
+// exec; those carry a diff and have no command line to edit.
+func isFileEdit(req toolexec.Request) bool {
+	switch req.Tool {
+	case tools.EditFileName, tools.MultiEditName, tools.WriteFileName, tools.ApplyPatchName:
+		return true
+	}
+	return false
+}
+
+// prefixRule allows the command plus its leading flags and first non-flag
+// argument, e.g. `git status*` or `ls -la src*`, so "always" does not
+// approve every invocation. A command whose arguments are all flags is
+// allowed with exactly those flags. Shells, interpreters, and wrappers such
+// as sudo run whatever their arguments say, so no rule is offered for them
+// (ok is false).
+func prefixRule(req toolexec.Request) (rule approvals.Rule, ok bool) {
+	rule = approvals.Rule{Tool: req.Tool, Command: req.Command, Decision: approvals.Allow}
+	if !isFileEdit(req) && approvals.RunsCode(req.Command) {
+		return rule, false
+	}
+	if len(req.Args) == 0 {
+		return rule, true
+	}
+	var prefix []string
+	for _, arg := range req.Args {
+		// A literal * would match anything; ? stands for one character.
+		prefix = append(prefix, strings.NewReplacer("*", "?").Replace(arg))
+		if !strings.HasPrefix(arg, "-") {
+			rule.Args = strings.Join(prefix, " ") + "*"
+			return rule, true
+		}
+	}
+	rule.Args = strings.Join(prefix, " ")
+	return rule, true
+}
+
+func commandLine(req toolexec.Request) string {
+	if len(req.Args) == 0 {
+		return req.Command
+	}
+	return req.Command + " " + quoteArgs(req.Args)
+}
+
+func quoteArgs(args []string) string {
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\$`|&;<>()*?") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		out = append(out, arg)
+	}
+	return strings.Join(out, " ")
+}
+
+// splitCommandLine splits an edited command with POSIX-style quoting.
+func splitCommandLine(line string) []string {
+	var fields []string
+	var cur strings.Builder
+	inField := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inField = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func safeDisplay(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
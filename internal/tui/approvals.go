@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/trust"
+)
+
+// openTrustStore opens ~/.pfui/trusted.toml; nil (trusting nothing) when the
+// home directory cannot be resolved.
+func openTrustStore() *trust.Store {
+	path, err := trust.DefaultPath()
+	if err != nil {
+		return nil
+	}
+	return trust.Open(path)
+}
+
+// newApprovalGate loads user and project approval rules for the session.
+func newApprovalGate(projectPath string, store *trust.Store) (*approvals.Gate, error) {
+	userPath, projectFile := approvals.DefaultPaths(projectPath)
+	engine, err := approvals.LoadTrusted(userPath, projectFile, store)
+	gate := approvals.NewGate(engine)
+	gate.SetProjectRoot(projectPath)
+	return gate, err
+}
+
+func (m *model) handleApprovalsCommand(args []string) {
+	if m.approvals == nil {
+		m.messages = append(m.messages, "pfui: approvals are not available in this session")
+		return
+	}
+	if len(args) == 0 {
+		m.appendHistoryBlock("approvals", m.approvalLines())
+		return
+	}
+	engine := m.approvals.Engine()
+	switch strings.ToLower(args[0]) {
+	case "allow", "deny", "ask":
+		m.addApprovalRule(approvals.Decision(strings.ToLower(args[0])), args[1:])
+	case "remove", "rm":
+		if len(args) < 2 {
+			m.messages = append(m.messages, "pfui: usage: /approvals remove N")
+			return
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: invalid rule number %q", args[1]))
+			return
+		}
+		rule, err := engine.Remove(n - 1)
+		if err != nil {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
+			return
+		}
+		m.messages = append(m.messages, fmt.Sprintf("pfui: removed %s rule: %s", rule.Scope, rule.String()))
+	case "trust":
+		if err := engine.TrustProject(); err != nil {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: approvals trust: %v", err))
+			return
+		}
+		_, projectFile := approvals.DefaultPaths(m.opts.ProjectPath)
+		m.messages = append(m.messages, fmt.Sprintf("pfui: trusted %s; its allow rules apply until the file changes", projectFile))
+	case "reload":
+		if err := engine.Reload(); err != nil {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: approvals reload: %v", err))
+			return
+		}
+		m.messages = append(m.messages, fmt.Sprintf("pfui: reloaded %d approval rules", len(engine.Rules())))
+	case "check":
+		if len(args) < 2 {
+			m.messages = append(m.messages, "pfui: usage: /approvals check <command> [args...]")
+			return
+		}
+		verdict := m.approvals.Evaluate(toolexec.Request{Tool: "exec", Command: args[1], Args: args[2:], Workdir: m.opts.ProjectPath})
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %s %s → %s (%s)", args[1], strings.Join(args[2:], " "), verdict.Decision, verdict.Reason))
+	default:
+		m.messages = append(m.messages, fmt.Sprintf("pfui: unknown /approvals action %q", args[0]))
+	}
+}
+
+// addApprovalRule parses `<command> [args-pattern...] [--save|--project]`.
+func (m *model) addApprovalRule(decision approvals.Decision, args []string) {
+	scope := approvals.ScopeSession
+	var words []string
+	for _, arg := range args {
+		switch arg {
+		case "--save", "--forever":
+			scope = approvals.ScopeUser
+		case "--project":
+			scope = approvals.ScopeProject
+		default:
+			words = append(words, arg)
+		}
+	}
+	if len(words) == 0 {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: usage: /approvals %s <command> [args pattern] [--save|--project]", decision))
+		return
+	}
+	rule := approvals.Rule{Command: words[0], Args: strings.Join(words[1:], " "), Decision: decision}
+	if err := m.approvals.Engine().Add(rule, scope); err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
+		return
+	}
+	m.messages = append(m.messages, fmt.Sprintf("pfui: added %s rule: %s", scope, rule.String()))
+}
+
+func (m model) approvalLines() []string {
+	rules := m.approvals.Engine().Rules()
+	lines := []string{fmt.Sprintf("Mode: %s", strings.ToUpper(string(m.plan)))}
+	if len(rules) == 0 {
+		lines = append(lines, "No rules yet; defaults apply (AUTO allows, otherwise commands that may change files ask).")
+	}
+	trusted := m.approvals.Engine().ProjectTrusted()
+	for i, rule := range rules {
+		line := fmt.Sprintf("%2d. [%s] %s", i+1, rule.Scope, rule.String())
+		if rule.Scope == approvals.ScopeProject && rule.Decision == approvals.Allow && !trusted {
+			line += " (ignored until /approvals trust)"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "Risky commands (rm -r outside the project, force pushes, dd, mkfs, chmod -R, curl | sh, publishes) always ask.")
+	for _, risk := range m.approvals.Engine().RiskRules() {
+		desc := strings.TrimSpace(risk.Command + " " + risk.Args)
+		if risk.Reason != "" {
+			desc += " — " + risk.Reason
+		}
+		lines = append(lines, fmt.Sprintf("    [%s] risk %s", risk.Scope, desc))
+	}
+	userPath, projectPath := approvals.DefaultPaths(m.opts.ProjectPath)
+	lines = append(lines,
+		"",
+		fmt.Sprintf("Files: %s, %s", userPath, projectPath),
+		"Usage: /approvals allow|deny|ask <command> [args pattern] [--save|--project] · /approvals remove N · /approvals check <command> [args] · /approvals trust · /approvals reload",
+	)
+	return lines
+}
@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/fbettag/pfui/internal/tools"
+)
+
+// userAsk carries an ask_user question from the tool goroutine to the TUI;
+// the goroutine blocks on reply until the operator answers or dismisses it.
+type userAsk struct {
+	question tools.Question
+	reply    chan userReply
+}
+
+type userReply struct {
+	answer tools.Answer
+	err    error
+}
+
+type userAskMsg struct {
+	ask userAsk
+}
+
+// userAskFunc returns the tools.Asker that hands questions to the TUI.
+func userAskFunc(asks chan<- userAsk) tools.Asker {
+	return func(ctx context.Context, q tools.Question) (tools.Answer, error) {
+		ask := userAsk{question: q, reply: make(chan userReply, 1)}
+		select {
+		case asks <- ask:
+		case <-ctx.Done():
+			return tools.Answer{}, ctx.Err()
+		}
+		select {
+		case reply := <-ask.reply:
+			return reply.answer, reply.err
+		case <-ctx.Done():
+			return tools.Answer{}, ctx.Err()
+		}
+	}
+}
+
+func listenUserAsks(asks <-chan userAsk) tea.Cmd {
+	if asks == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		ask, ok := <-asks
+		if !ok {
+			return nil
+		}
+		return userAskMsg{ask: ask}
+	}
+}
+
+// openUserQuestion shows a model's question in the question prompt. The
+// agent loop stays paused until the operator answers or presses esc.
+func (m *model) openUserQuestion(ask userAsk) {
+	q := ask.question
+	prompt := newQuestionPrompt(q.Prompt, q.Options)
+	prompt.Multi = q.Multi
+	prompt.OptionsOnly = !q.FreeText
+	// Multi-select answers may be all toggles and no text.
+	prompt.AllowEmpty = q.Multi
+	if q.Multi {
+		prompt.Selected = make([]bool, len(q.Options))
+		prompt.Input.Placeholder = "Press numbers to toggle options"
+		if q.FreeText {
+			prompt.Input.Placeholder += ", or type an answer"
+		}
+	}
+	prompt.onAnswer = func(m *model, text string) {
+		answer, err := resolveQuestionAnswer(prompt, text)
+		if err != nil {
+			m.messages = append(m.messages, "pfui: "+err.Error())
+			m.question = prompt
+			return
+		}
+		ask.reply <- userReply{answer: answer}
+		m.messages = append(m.messages, "[answer] "+answer.String())
+	}
+	prompt.onDismiss = func(m *model) {
+		ask.reply <- userReply{err: tools.ErrDismissed}
+		m.messages = append(m.messages, "pfui: dismissed the model's question")
+		m.compose.Focus()
+		m.refreshComposeStatus()
+	}
+	m.question = prompt
+	m.messages = append(m.messages, "[question] "+sanitizeDiffLine(q.Prompt))
+}
+
+// resolveQuestionAnswer turns the typed text and toggled options into an
+// answer: a number or an option's text picks that option, anything else is
+// free text when the question allows it.
+func resolveQuestionAnswer(q *questionPrompt, text string) (tools.Answer, error) {
+	var answer tools.Answer
+	for i, on := range q.Selected {
+		if on {
+			answer.Selected = append(answer.Selected, q.Options[i])
+		}
+	}
+	text = strings.TrimSpace(text)
+	if text != "" && !q.Multi {
+		if n, err := strconv.Atoi(text); err == nil && n >= 1 && n <= len(q.Options) {
+			answer.Selected = []string{q.Options[n-1]}
+			return answer, nil
+		}
+		for _, opt := range q.Options {
+			if strings.EqualFold(opt, text) {
+				answer.Selected = []string{opt}
+				return answer, nil
+			}
+		}
+	}
+	if text != "" {
+		if q.OptionsOnly {
+			return tools.Answer{}, fmt.Errorf("pick one of the options (1-%d)", len(q.Options))
+		}
+		answer.Text = text
+	}
+	if len(answer.Selected) == 0 && answer.Text == "" {
+		return tools.Answer{}, fmt.Errorf("pick at least one option")
+	}
+	return answer, nil
+}
@@ -0,0 +1,33 @@
+package tui
+
+import (
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/audit"
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+// openAuditRecorder opens the shared audit log for this session and feeds it
+// the gate's approval decisions.
+func openAuditRecorder(sessionID string, gate *approvals.Gate) (*audit.Recorder, error) {
+	path, err := audit.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	log, err := audit.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rec := log.Session(sessionID)
+	gate.SetRecorder(func(r approvals.Record) {
+		rec.Approval(r.Request, string(r.Decision), r.DecidedBy, r.Reason, r.Risks)
+	})
+	return rec, nil
+}
+
+// audited wraps p so its completions land in the audit log.
+func (m *model) audited(p provider.Provider) provider.Provider {
+	if m.audit == nil || p == nil {
+		return p
+	}
+	return m.audit.WrapProvider(p)
+}
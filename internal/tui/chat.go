@@ -15,11 +15,21 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/fbettag/pfui/internal/agents"
+	"github.com/fbettag/pfui/internal/approvals"
+	"github.com/fbettag/pfui/internal/audit"
+	"github.com/fbettag/pfui/internal/checkpoint"
 	"github.com/fbettag/pfui/internal/config"
 	"github.com/fbettag/pfui/internal/history"
+	"github.com/fbettag/pfui/internal/modelcatalog"
+	"github.com/fbettag/pfui/internal/plan"
 	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/routing"
+	"github.com/fbettag/pfui/internal/sandbox"
+	"github.com/fbettag/pfui/internal/skills"
 	"github.com/fbettag/pfui/internal/toolexec"
 	"github.com/fbettag/pfui/internal/tui/compose"
+	"github.com/fbettag/pfui/internal/workspace"
 )
 
 // Options configure the interactive chat run.
@@ -28,6 +38,8 @@ type Options struct {
 	ProjectPath string
 	Providers   provider.Registry
 	LaunchArgs  string
+	// Sandbox overrides the configured exec sandbox level for this session.
+	Sandbox string
 }
 
 type planMode string
@@ -78,6 +90,8 @@ type model struct {
 	defaultModel     string
 	commandPalette   commandPalette
 	executor         *toolexec.Executor
+	execEvents       *toolexec.Subscription
+	workspace        *workspace.Workspace
 	jobs             map[string]toolexec.Job
 	messages         []string
 	compose          compose.Model
@@ -89,7 +103,7 @@ type model struct {
 	recallMode       bool
 	recallPosition   int
 	plan             planMode
-	planSteps        []planStep
+	planSteps        []plan.Step
 	showPlan         bool
 	question         *questionPrompt
 	catalog          modelCatalog
@@ -97,6 +111,43 @@ type model struct {
 	pendingResponse  *streamingResponse
 	responseStream   *responseStreamState
 	pendingCancel    context.CancelFunc
+	routes           *routing.Policy
+	sandbox          sandbox.Policy
+	approvals        *approvals.Gate
+	approvalAsks     chan approvalAsk
+	userAsks         chan userAsk
+	approval         *approvalPrompt
+	audit            *audit.Recorder
+	conversation     []provider.ChatMessage
+	agentTurns       int
+	toolsRunning     bool
+	// jobNotices holds finished-job notes for the model's next turn.
+	jobNotices []string
+	// routeModels merges routeListings, the per-provider route catalog.
+	routeModels        []modelcatalog.Model
+	routeListings      map[string]*routeListing
+	routeModelsLoading bool
+	// pendingToolCalls, pendingSummary, pendingCompact, and
+	// routeReportPending wait for the route catalog; applyRouteCatalog
+	// picks them up.
+	pendingToolCalls   []provider.ToolCall
+	pendingSummary     string
+	pendingCompact     bool
+	routeReportPending bool
+	// compacting is set while /compact waits for its summary.
+	compacting     bool
+	foregroundTail *liveTail
+	jobTail        *liveTail
+	// checkpoints snapshots files before tool calls change them; turn
+	// numbers operator prompts so /undo can revert one at a time.
+	checkpoints *checkpoint.Store
+	turn        int
+	// subagents holds the definitions the task tool can start and their runs.
+	subagents *agents.Manager
+	// skills are the discovered skills; loadedSkills marks those whose
+	// instructions the model pulled into the conversation.
+	skills       []skills.Skill
+	loadedSkills map[string]bool
 }
 
 func newModel(ctx context.Context, cfg config.Config, opts Options) model {
@@ -132,7 +183,72 @@ func newModel(ctx context.Context, cfg config.Config, opts Options) model {
 	}
 	header := historyBlockLines("pfui session", buildSessionHeaderLines(session, opts.ProjectPath, cfg.Plan, available, planModePlan))
 	lines = append(header, lines...)
-	executor := toolexec.NewExecutor()
+	killGrace, _ := cfg.Exec.KillGraceDuration()
+	defaultTimeout, _ := cfg.Exec.DefaultTimeoutDuration()
+	keepFor, _ := cfg.Exec.KeepForDuration()
+	memory, _ := cfg.Exec.Limits.MemoryBytes()
+	cpuTime, _ := cfg.Exec.Limits.CPUTimeDuration()
+	trusted := openTrustStore()
+	gate, err := newApprovalGate(opts.ProjectPath, trusted)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("pfui: approvals: %v", err))
+	}
+	if engine := gate.Engine(); engine.HasProjectAllows() && !engine.ProjectTrusted() {
+		lines = append(lines, "pfui: the project's .pfui/approvals.toml has allow rules; they are ignored until you review the file and run /approvals trust")
+	}
+	env, envIgnored, err := envPolicy(cfg.Exec.Env, opts.ProjectPath, trusted)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("pfui: exec env: %v", err))
+	}
+	if envIgnored {
+		lines = append(lines, "pfui: the project's .pfui/env.toml sets variables; they are ignored until you review the file and run /jobs env trust")
+	}
+	store, err := openJobStore()
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("pfui: job store: %v; background jobs will not be persisted", err))
+	} else if _, err := store.Prune(cfg.Exec.KeepJobs, keepFor); err != nil {
+		lines = append(lines, fmt.Sprintf("pfui: pruning jobs: %v", err))
+	}
+	auditRec, err := openAuditRecorder(session.ID, gate)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("pfui: audit log: %v; agent actions will not be audited", err))
+	}
+	asks := make(chan approvalAsk)
+	gate.SetPrompter(approvalPromptFunc(asks))
+	execOpts := toolexec.Options{
+		KillGrace:      killGrace,
+		DefaultTimeout: defaultTimeout,
+		Approver:       gate,
+		Env:            &env,
+		Store:          store,
+		SessionID:      session.ID,
+		MaxJobs:        cfg.Exec.MaxJobs,
+		KeepJobs:       cfg.Exec.KeepJobs,
+		KeepFor:        keepFor,
+		Limits: toolexec.Limits{
+			CPUs:    cfg.Exec.Limits.CPUs,
+			Memory:  memory,
+			CPUTime: cpuTime,
+		},
+	}
+	if auditRec != nil {
+		execOpts.Auditor = auditRec
+	}
+	executor := toolexec.NewExecutorWithOptions(execOpts)
+	execEvents := executor.Subscribe()
+	jobs := make(map[string]toolexec.Job)
+	if opts.ResumeID != "" {
+		restored, err := executor.Restore(session.ID)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("pfui: restoring jobs: %v", err))
+		}
+		for _, job := range restored {
+			jobs[job.ID] = job
+		}
+		if len(restored) > 0 {
+			lines = append(lines, fmt.Sprintf("Restored %d background jobs from this session (/jobs)", len(restored)))
+		}
+	}
 	spin := spinner.New()
 	spin.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("#C1C6D6"))
 	m := model{
@@ -146,7 +262,9 @@ func newModel(ctx context.Context, cfg config.Config, opts Options) model {
 		defaultModel:     defaultModel,
 		commandPalette:   newCommandPalette(),
 		executor:         executor,
-		jobs:             make(map[string]toolexec.Job),
+		execEvents:       execEvents,
+		workspace:        workspace.New(opts.ProjectPath, cfg.Files.AllowedDirs),
+		jobs:             jobs,
 		messages:         lines,
 		compose:          composer,
 		session:          session,
@@ -156,22 +274,46 @@ func newModel(ctx context.Context, cfg config.Config, opts Options) model {
 		catalog: modelCatalog{
 			loading: make(map[string]bool),
 		},
-		spinner: spin,
-	}
+		spinner:      spin,
+		routes:       routing.NewPolicy(cfg.Routing),
+		approvals:    gate,
+		approvalAsks: asks,
+		userAsks:     make(chan userAsk),
+		loadedSkills: make(map[string]bool),
+		audit:        auditRec,
+	}
+	m.initSandbox()
+	m.initCheckpoints()
+	m.initSubagents()
+	m.initSkills()
 	m.refreshComposeFooter()
 	m.refreshComposeStatus()
 	return m
 }
 
-type planStep struct {
-	Text string
-	Done bool
-}
-
 type questionPrompt struct {
 	Prompt  string
 	Options []string
 	Input   textinput.Model
+	// AllowEmpty accepts a blank answer.
+	AllowEmpty bool
+	// Multi makes the number keys toggle options in Selected instead of
+	// filling in the input; OptionsOnly refuses answers that are not an
+	// option. Both are set for questions from the model.
+	Multi       bool
+	Selected    []bool
+	OptionsOnly bool
+	// onAnswer and onDismiss let other prompts (e.g. approvals) reuse the
+	// question input; when nil the answer is only logged.
+	onAnswer  func(m *model, answer string)
+	onDismiss func(m *model)
+}
+
+func newQuestionPrompt(prompt string, options []string) *questionPrompt {
+	qi := textinput.New()
+	qi.Placeholder = "Type answer or select option"
+	qi.Focus()
+	return &questionPrompt{Prompt: prompt, Options: options, Input: qi}
 }
 
 type modelCatalog struct {
@@ -194,10 +336,13 @@ type blockRef struct {
 }
 
 type streamingResponse struct {
-	title  string
-	style  lipgloss.Style
-	block  blockRef
-	buffer string
+	title     string
+	style     lipgloss.Style
+	block     blockRef
+	buffer    string
+	toolCalls []provider.ToolCall
+	// awaitingRoute holds the turn until the route catalog has loaded.
+	awaitingRoute bool
 }
 
 type responseStreamState struct {
@@ -205,9 +350,10 @@ type responseStreamState struct {
 }
 
 type responseChunkMsg struct {
-	Text string
-	Err  error
-	Done bool
+	Text      string
+	ToolCalls []provider.ToolCall
+	Err       error
+	Done      bool
 }
 
 func initSession(opts Options) (history.Session, string) {
@@ -226,24 +372,24 @@ func initSession(opts Options) (history.Session, string) {
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(textinput.Blink, listenExecEvents(m.executor))
+	return tea.Batch(textinput.Blink, listenExecEvents(m.execEvents), listenApprovalAsks(m.approvalAsks), listenUserAsks(m.userAsks))
 }
 
-func listenExecEvents(exec *toolexec.Executor) tea.Cmd {
-	if exec == nil {
+func listenExecEvents(sub *toolexec.Subscription) tea.Cmd {
+	if sub == nil {
 		return nil
 	}
 	return func() tea.Msg {
-		event, ok := <-exec.Events()
+		event, ok := <-sub.Events()
 		if !ok {
 			return nil
 		}
-		return execEventMsg{job: event.Job}
+		return execEventMsg{event: event}
 	}
 }
 
 type execEventMsg struct {
-	job toolexec.Job
+	event toolexec.Event
 }
 
 type modelFetchMsg struct {
@@ -255,6 +401,11 @@ type modelFetchMsg struct {
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.question == nil && m.approval == nil {
+			if t := m.ptyTarget(); t != nil {
+				return m.updatePTY(msg, t)
+			}
+		}
 		if msg.Type == tea.KeyTab {
 			if m.tryTabComplete(true) {
 				return m, nil
@@ -272,6 +423,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.question != nil {
 			return m.updateQuestion(msg)
 		}
+		if m.approval != nil {
+			return m.updateApproval(msg)
+		}
 		if msg.String() == "esc" && m.catalog.visible {
 			m.catalog.visible = false
 			return m, nil
@@ -298,6 +452,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		case "ctrl+c":
 			return m, tea.Quit
+		case "ctrl+z":
+			m.detachForeground()
+			return m, nil
 		case "esc":
 			if m.commandPalette.visible {
 				m.commandPalette.Reset()
@@ -306,6 +463,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.statusLine = "Canceled foreground command."
 				return m, nil
 			}
+			if m.jobTail != nil {
+				m.jobTail = nil
+				return m, nil
+			}
 			if m.pendingResponse != nil {
 				m.finishResponseStream()
 				m.messages = append(m.messages, "pfui: canceled response stream")
@@ -349,11 +510,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case execEventMsg:
-		if msg.job.ID != "" {
-			m.jobs[msg.job.ID] = msg.job
-			m.recordJobEvent(msg.job)
-		}
-		return m, listenExecEvents(m.executor)
+		m.handleExecEvent(msg.event)
+		return m, tea.Batch(listenExecEvents(m.execEvents), m.followUpJobs())
+	case approvalAskMsg:
+		m.openApprovalPrompt(msg.ask)
+		return m, listenApprovalAsks(m.approvalAsks)
+	case userAskMsg:
+		m.openUserQuestion(msg.ask)
+		return m, listenUserAsks(m.userAsks)
+	case toolResultsMsg:
+		return m, m.handleToolResults(msg)
 	case modelFetchMsg:
 		if msg.err != nil {
 			if m.catalog.visible {
@@ -395,6 +561,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.ensureCatalogSelection()
 		}
 		return m, nil
+	case sessionSummaryMsg:
+		m.applySessionSummary(msg)
+		return m, nil
+	case routeCatalogMsg:
+		return m, m.applyRouteCatalog(msg)
+	case compactMsg:
+		m.applyCompact(msg)
+		return m, nil
 	case responseChunkMsg:
 		if m.pendingResponse == nil {
 			return m, nil
@@ -409,9 +583,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			body := strings.Split(m.pendingResponse.buffer, "\n")
 			m.replaceHistoryBlock(&m.pendingResponse.block, m.pendingResponse.title, body, m.pendingResponse.style)
 		}
+		m.pendingResponse.toolCalls = append(m.pendingResponse.toolCalls, msg.ToolCalls...)
 		if msg.Done {
-			m.finishResponseStream()
-			return m, nil
+			return m, m.completeTurn()
 		}
 		return m, m.nextResponseChunkCmd()
 	default:
@@ -440,12 +614,23 @@ func (m model) View() string {
 		planView = renderPlanDrawer(m.planSteps, m.cfg.Plan)
 		planLines = countLines(planView)
 	}
+	tailView := renderLiveTail(m.foregroundTail, true)
+	if tailView == "" {
+		tailView = renderLiveTail(m.jobTail, false)
+	}
+	tailLines := countLines(tailView)
 	questionView := ""
 	questionLines := 0
 	if m.question != nil {
 		questionView = renderQuestionPrompt(m.question)
 		questionLines = countLines(questionView)
 	}
+	approvalView := ""
+	approvalLines := 0
+	if m.approval != nil && m.question == nil {
+		approvalView = renderApprovalPrompt(m.approval)
+		approvalLines = countLines(approvalView)
+	}
 	composeView := m.compose.View()
 	composeLines := countLines(composeView)
 	jobLine := summarizeJobs(m.jobs)
@@ -461,7 +646,7 @@ func (m model) View() string {
 	if jobLine != "" {
 		dockHeight++
 	}
-	dockHeight += paletteLines + catalogLines + planLines + questionLines + composeLines
+	dockHeight += paletteLines + catalogLines + planLines + tailLines + approvalLines + questionLines + composeLines
 	viewportHeight := m.height - dockHeight
 	if viewportHeight < 3 {
 		viewportHeight = 3
@@ -491,6 +676,12 @@ func (m model) View() string {
 	if planView != "" {
 		builder.WriteString(planView)
 	}
+	if tailView != "" {
+		builder.WriteString(tailView)
+	}
+	if approvalView != "" {
+		builder.WriteString(approvalView)
+	}
 	if composeView != "" {
 		builder.WriteString(composeView)
 	}
@@ -509,12 +700,17 @@ func (m model) View() string {
 func (m model) submitInput() (tea.Model, tea.Cmd) {
 	if m.question != nil {
 		answer := strings.TrimSpace(m.question.Input.Value())
-		if answer == "" {
+		if answer == "" && !m.question.AllowEmpty {
 			m.messages = append(m.messages, "pfui: answer cannot be empty")
 			return m, nil
 		}
-		m.messages = append(m.messages, fmt.Sprintf("[answer] %s", answer))
+		q := m.question
 		m.question = nil
+		if q.onAnswer != nil {
+			q.onAnswer(&m, answer)
+		} else {
+			m.messages = append(m.messages, fmt.Sprintf("[answer] %s", answer))
+		}
 		m.compose.Reset()
 		m.compose.Focus()
 		m.refreshComposeStatus()
@@ -539,10 +735,19 @@ func (m model) submitInput() (tea.Model, tea.Cmd) {
 		m.messages = append(m.messages, providerPromptText(m.available))
 		return m, nil
 	}
+	if m.toolsRunning || m.pendingResponse != nil {
+		// The conversation must not take a user turn between an assistant's
+		// tool calls and their results; keep the draft until the turn is over.
+		m.compose.SetValue(text)
+		m.compose.CursorEnd()
+		m.messages = append(m.messages, "pfui: wait for the current turn to finish before sending another prompt (esc stops a streaming reply)")
+		return m, nil
+	}
 	m.promptHistory = append(m.promptHistory, text)
 	m.recallMode = false
 	m.refreshComposeStatus()
 	m.appendStyledHistoryBlock(fmt.Sprintf("you (%s)", providerLabel(m.activeProvider)), []string{text}, userBlockStyle)
+	firstPrompt := m.session.Title == "New chat"
 	if m.session.ID != "" {
 		if m.session.Title == "New chat" {
 			m.session.Title = truncate(text, 60)
@@ -554,7 +759,14 @@ func (m model) submitInput() (tea.Model, tea.Cmd) {
 			m.statusLine = fmt.Sprintf("Updated %s at %s", m.session.ID, time.Now().Format(time.Kitchen))
 		}
 	}
-	return m, m.beginResponseStream(text)
+	m.appendUserTurn(text)
+	cmd := m.beginResponseStream()
+	if firstPrompt {
+		if summarize := m.summarizeSessionCmd(text); summarize != nil {
+			return m, tea.Batch(cmd, summarize)
+		}
+	}
+	return m, cmd
 }
 
 func (m model) handleReverseSearch() (tea.Model, tea.Cmd) {
@@ -602,8 +814,26 @@ func (m model) handleCommand(text string) (tea.Model, tea.Cmd) {
 		m.setPlanMode(planModeOff)
 	case "ask":
 		m.handleAskCommand(parts[1:])
+	case "route":
+		return m, m.handleRouteCommand(parts[1:])
+	case "sandbox":
+		m.handleSandboxCommand(parts[1:])
+	case "approvals":
+		m.handleApprovalsCommand(parts[1:])
+	case "checkpoints":
+		m.handleCheckpointsCommand()
+	case "undo":
+		m.handleUndoCommand(parts[1:])
+	case "restore":
+		m.handleRestoreCommand(parts[1:])
+	case "compact":
+		return m, m.handleCompactCommand()
+	case "skill", "skills":
+		m.handleSkillCommand(parts[1:])
+	case "subagent", "subagents":
+		m.handleSubagentCommand(parts[1:])
 	case "help":
-		m.messages = append(m.messages, "pfui commands: /model /plan /auto /off /provider /jobs /status /usage /config /resume /ask")
+		m.messages = append(m.messages, "pfui commands: /model /route /sandbox /approvals /plan /auto /off /provider /jobs /undo /checkpoints /restore /compact /skill /subagent /status /usage /config /resume /ask")
 	case "provider":
 		if len(parts) < 2 {
 			m.messages = append(m.messages, providerPromptText(m.available))
@@ -785,10 +1015,14 @@ func providerPromptText(providers []provider.Provider) string {
 
 func defaultModelFor(p provider.Provider) string {
 	switch p.Kind() {
-	case provider.KindOpenAI:
+	case provider.KindOpenAI, provider.KindAzure:
 		return "gpt-5.1-codex"
 	case provider.KindAnthropic:
 		return "claude-4.5-sonnet"
+	case provider.KindBedrock:
+		return "claude-4.5-sonnet"
+	case provider.KindGemini:
+		return "gemini-2.5-pro"
 	default:
 		return ""
 	}
@@ -802,7 +1036,14 @@ func defaultModelDisplay(model string) string {
 }
 
 func (m model) statusDisplay() string {
-	return m.statusLine
+	if m.sandbox.Level == "" {
+		return m.statusLine
+	}
+	label := "Sandbox: " + m.sandbox.Summary()
+	if m.statusLine == "" {
+		return label
+	}
+	return m.statusLine + " | " + label
 }
 
 func (m model) modeBadge() string {
@@ -997,7 +1238,7 @@ func buildSessionHeaderLines(session history.Session, projectPath string, planCf
 		fmt.Sprintf("project: %s", safeString(project)),
 		fmt.Sprintf("plan mode: %s (Tab cycles)", strings.ToUpper(string(mode))),
 		fmt.Sprintf("plan storage: %s", planSummary),
-		"commands: /plan /model /jobs /help",
+		"commands: /plan /model /route /jobs /help",
 	}
 }
 
@@ -1016,24 +1257,48 @@ func planStorageSummary(planCfg config.PlanConfig) string {
 	return fmt.Sprintf("file → %s (%s)", path, policy)
 }
 
-func (m *model) beginResponseStream(prompt string) tea.Cmd {
+func (m *model) beginResponseStream() tea.Cmd {
 	if m.activeProvider == nil {
 		return nil
 	}
 	if m.pendingResponse != nil {
 		m.finishResponseStream()
 	}
-	title := fmt.Sprintf("pfui (%s/%s)", providerLabel(m.activeProvider), defaultModelDisplay(m.defaultModel))
-	ref := m.appendStyledHistoryBlockRef(title, []string{"…"}, assistantBlockStyle)
-	m.pendingResponse = &streamingResponse{title: title, style: assistantBlockStyle, block: ref}
+	if m.routeNeedsCatalog(m.phaseForMode()) {
+		// Hold the turn behind a placeholder so Update never waits on
+		// providers listing their models.
+		ref := m.appendStyledHistoryBlockRef("pfui", []string{"resolving model route…"}, assistantBlockStyle)
+		m.pendingResponse = &streamingResponse{title: "pfui", style: assistantBlockStyle, block: ref, awaitingRoute: true}
+		m.refreshComposeStatus()
+		return tea.Batch(m.loadRouteCatalogCmd(), m.spinner.Tick)
+	}
+	return m.startResponseStream()
+}
+
+// startResponseStream resolves the route and streams the reply, reusing the
+// placeholder block when the turn waited for the route catalog.
+func (m *model) startResponseStream() tea.Cmd {
+	target, modelName, route := m.resolveRoute(m.phaseForMode())
+	title := fmt.Sprintf("pfui (%s/%s)", providerLabel(target), defaultModelDisplay(modelName))
+	if route != nil {
+		title = fmt.Sprintf("%s · %s route", title, route.Phase)
+	}
+	if m.pendingResponse != nil && m.pendingResponse.awaitingRoute {
+		m.pendingResponse.title, m.pendingResponse.awaitingRoute = title, false
+		m.replaceHistoryBlock(&m.pendingResponse.block, title, []string{"…"}, m.pendingResponse.style)
+	} else {
+		ref := m.appendStyledHistoryBlockRef(title, []string{"…"}, assistantBlockStyle)
+		m.pendingResponse = &streamingResponse{title: title, style: assistantBlockStyle, block: ref}
+	}
 
 	req := provider.ChatCompletionRequest{
-		Model:    m.defaultModel,
-		Messages: []provider.ChatMessage{{Role: "user", Content: prompt}},
+		Model:    modelName,
+		Messages: m.conversation,
+		Tools:    m.toolSpecs(),
 	}
 	ctx, cancel := context.WithCancel(m.ctx)
 	m.pendingCancel = cancel
-	stream, err := m.activeProvider.StreamChat(ctx, req)
+	stream, err := m.audited(target).StreamChat(ctx, req)
 	if err != nil {
 		m.finishResponseStream()
 		m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
@@ -1059,7 +1324,7 @@ func (m *model) nextResponseChunkCmd() tea.Cmd {
 		if !ok {
 			return responseChunkMsg{Done: true}
 		}
-		return responseChunkMsg{Text: chunk.Content, Err: chunk.Err, Done: chunk.Done}
+		return responseChunkMsg{Text: chunk.Content, ToolCalls: chunk.ToolCalls, Err: chunk.Err, Done: chunk.Done}
 	}
 }
 
@@ -1077,20 +1342,25 @@ func summarizeJobs(jobs map[string]toolexec.Job) string {
 	if len(jobs) == 0 {
 		return ""
 	}
-	var running, success, failed int
+	var running, queued, success, failed int
 	for _, job := range jobs {
 		switch job.Status {
+		case toolexec.JobQueued:
+			queued++
 		case toolexec.JobSuccess:
 			success++
-		case toolexec.JobFailed:
+		case toolexec.JobFailed, toolexec.JobCanceled, toolexec.JobTimedOut, toolexec.JobLost:
 			failed++
 		default:
 			running++
 		}
 	}
-	if running == 0 && success == 0 && failed == 0 {
+	if running == 0 && queued == 0 && success == 0 && failed == 0 {
 		return ""
 	}
+	if queued > 0 {
+		return fmt.Sprintf("jobs: %d running · %d queued · %d done · %d failed (/jobs)", running, queued, success, failed)
+	}
 	return fmt.Sprintf("jobs: %d running · %d done · %d failed (/jobs)", running, success, failed)
 }
 
@@ -1107,16 +1377,28 @@ func countLines(block string) int {
 
 func (m *model) handleJobsCommand(args []string) {
 	if len(args) >= 2 && strings.EqualFold(args[0], "cancel") {
-		id := args[1]
-		if m.executor != nil && m.executor.CancelJob(id) {
-			m.messages = append(m.messages, fmt.Sprintf("pfui: canceling job %s", id))
+		id, ok := m.resolveJobID(args[1])
+		if ok && m.executor != nil && m.executor.CancelJob(id) {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: canceling job %s", shortJobID(id)))
 		} else {
-			m.messages = append(m.messages, fmt.Sprintf("pfui: job %s not found", id))
+			m.messages = append(m.messages, fmt.Sprintf("pfui: job %s not found", args[1]))
 		}
 		return
 	}
+	if len(args) >= 1 && strings.EqualFold(args[0], "tail") {
+		m.openJobTail(args[1:])
+		return
+	}
+	if len(args) >= 1 && strings.EqualFold(args[0], "attach") {
+		m.attachJob(args[1:])
+		return
+	}
+	if len(args) >= 1 && strings.EqualFold(args[0], "env") {
+		m.handleJobEnv(args[1:])
+		return
+	}
 	if len(m.jobs) == 0 {
-		m.messages = append(m.messages, "pfui: no background jobs running.")
+		m.messages = append(m.messages, "pfui: no background jobs in this session.")
 		return
 	}
 	ids := make([]string, 0, len(m.jobs))
@@ -1126,8 +1408,27 @@ func (m *model) handleJobsCommand(args []string) {
 	sort.Strings(ids)
 	for _, id := range ids {
 		job := m.jobs[id]
-		m.messages = append(m.messages, fmt.Sprintf("%s %s [%s] exit=%d", shortJobID(id), job.Command, strings.ToUpper(string(job.Status)), job.ExitCode))
+		if fresh, ok := m.executor.Job(id); ok {
+			job = fresh
+		} else if job.Status.Finished() {
+			// Pruned by the executor's retention limits.
+			delete(m.jobs, id)
+			continue
+		}
+		line := fmt.Sprintf("%s %s [%s] exit=%d", shortJobID(id), job.Command, strings.ToUpper(string(job.Status)), job.ExitCode)
+		switch job.Status {
+		case toolexec.JobRunning:
+			line = fmt.Sprintf("%s %s [%s] pid=%d", shortJobID(id), job.Command, strings.ToUpper(string(job.Status)), job.PID)
+		case toolexec.JobQueued:
+			line = fmt.Sprintf("%s %s [%s] #%d in queue", shortJobID(id), job.Command, strings.ToUpper(string(job.Status)), job.QueuePosition)
+		}
+		m.messages = append(m.messages, line)
+	}
+	if len(m.jobs) == 0 {
+		m.messages = append(m.messages, "pfui: no background jobs in this session.")
+		return
 	}
+	m.messages = append(m.messages, "pfui: /jobs tail <id> follows output live; /jobs attach <id> types into a terminal job; /jobs env <id> shows its environment (values redacted); /jobs env trust accepts the project's .pfui/env.toml; /jobs cancel <id> stops a job")
 }
 
 func (m *model) setPlanMode(mode planMode) {
@@ -1136,6 +1437,9 @@ func (m *model) setPlanMode(mode planMode) {
 		return
 	}
 	m.plan = mode
+	if m.approvals != nil {
+		m.approvals.SetMode(approvals.Mode(mode))
+	}
 	m.statusLine = fmt.Sprintf("Switched to %s mode", strings.ToUpper(string(mode)))
 	m.messages = append(m.messages, fmt.Sprintf("pfui: switched to %s mode", strings.ToUpper(string(mode))))
 	m.refreshComposeFooter()
@@ -1155,6 +1459,8 @@ func (m *model) cyclePlanMode() {
 func (m *model) recordJobEvent(job toolexec.Job) {
 	prefix := fmt.Sprintf("[job %s]", shortJobID(job.ID))
 	switch job.Status {
+	case toolexec.JobQueued:
+		m.messages = append(m.messages, fmt.Sprintf("%s queued %s%s (position %d)", prefix, job.Command, formatArgs(job.Args), job.QueuePosition))
 	case toolexec.JobRunning:
 		m.messages = append(m.messages, fmt.Sprintf("%s started %s%s", prefix, job.Command, formatArgs(job.Args)))
 	case toolexec.JobSuccess:
@@ -1165,6 +1471,12 @@ func (m *model) recordJobEvent(job toolexec.Job) {
 			msg += ": " + job.Error
 		}
 		m.messages = append(m.messages, msg)
+	case toolexec.JobCanceled:
+		m.messages = append(m.messages, fmt.Sprintf("%s canceled", prefix))
+	case toolexec.JobTimedOut:
+		m.messages = append(m.messages, fmt.Sprintf("%s %s", prefix, job.Error))
+	case toolexec.JobLost:
+		m.messages = append(m.messages, fmt.Sprintf("%s ended: %s", prefix, job.Error))
 	}
 }
 
@@ -1326,20 +1638,7 @@ func (m *model) savePlanToFile(target string) (string, error) {
 			return "", err
 		}
 	}
-	var b strings.Builder
-	b.WriteString("# Plan\n\n")
-	if len(m.planSteps) == 0 {
-		b.WriteString("_No steps yet_\n")
-	} else {
-		for i, step := range m.planSteps {
-			box := "[ ]"
-			if step.Done {
-				box = "[x]"
-			}
-			b.WriteString(fmt.Sprintf("%d. %s %s\n", i+1, box, step.Text))
-		}
-	}
-	if err := os.WriteFile(resolved, []byte(b.String()), 0o644); err != nil {
+	if err := os.WriteFile(resolved, []byte(plan.Markdown(m.planSteps)), 0o644); err != nil {
 		return "", err
 	}
 	return resolved, nil
@@ -1356,15 +1655,37 @@ func (m *model) maybePersistPlan(reason string) {
 	m.statusLine = fmt.Sprintf("Plan auto-saved (%s)", reason)
 }
 
-func renderPlanDrawer(steps []planStep, planCfg config.PlanConfig) string {
+// applyPlanRevision installs a plan the model sent with update_plan and
+// writes what changed to the scrollback.
+func (m *model) applyPlanRevision(rev plan.Revision) {
+	changes := plan.Diff(m.planSteps, rev.Steps)
+	m.planSteps = rev.Steps
+	m.showPlan = true
+	if rev.Explanation != "" {
+		m.messages = append(m.messages, "[plan] "+sanitizeDiffLine(rev.Explanation))
+	}
+	for _, line := range changes {
+		line = sanitizeDiffLine(line)
+		switch line[0] {
+		case '+':
+			line = diffAddStyle.Render(line)
+		case '-':
+			line = diffDeleteStyle.Render(line)
+		default:
+			line = diffHunkStyle.Render(line)
+		}
+		m.messages = append(m.messages, "  "+line)
+	}
+	if len(changes) > 0 {
+		m.maybePersistPlan("plan updated by the model")
+	}
+}
+
+func renderPlanDrawer(steps []plan.Step, planCfg config.PlanConfig) string {
 	var b strings.Builder
 	b.WriteString("Plan:\n")
 	for i, step := range steps {
-		status := "[ ]"
-		if step.Done {
-			status = "[x]"
-		}
-		b.WriteString(fmt.Sprintf("  %d. %s %s\n", i+1, status, step.Text))
+		b.WriteString("  " + plan.Line(i, step) + "\n")
 	}
 	if len(steps) == 0 {
 		b.WriteString("  (no steps yet — try /plan add)\n")
@@ -1402,7 +1723,7 @@ func (m *model) handlePlanCommand(args []string) (tea.Model, tea.Cmd) {
 			m.messages = append(m.messages, "pfui: /plan add requires text")
 			return m, nil
 		}
-		m.planSteps = append(m.planSteps, planStep{Text: text})
+		m.planSteps = append(m.planSteps, plan.Step{Text: text, Status: plan.Pending})
 		m.showPlan = true
 		m.messages = append(m.messages, fmt.Sprintf("pfui: added plan step %q", text))
 		m.maybePersistPlan("step added")
@@ -1416,7 +1737,7 @@ func (m *model) handlePlanCommand(args []string) (tea.Model, tea.Cmd) {
 			m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
 			return m, nil
 		}
-		m.planSteps[idx].Done = true
+		m.planSteps[idx].Status = plan.Done
 		m.messages = append(m.messages, fmt.Sprintf("pfui: marked step %d complete", idx+1))
 		m.maybePersistPlan("step updated")
 	case "clear":
@@ -1485,10 +1806,7 @@ func (m *model) handleAskCommand(args []string) {
 			}
 		}
 	}
-	qi := textinput.New()
-	qi.Placeholder = "Type answer or select option"
-	qi.Focus()
-	m.question = &questionPrompt{Prompt: prompt, Options: options, Input: qi}
+	m.question = newQuestionPrompt(prompt, options)
 	m.messages = append(m.messages, fmt.Sprintf("[question] %s", prompt))
 }
 
@@ -1497,9 +1815,26 @@ func renderQuestionPrompt(q *questionPrompt) string {
 	b.WriteString(fmt.Sprintf("Question: %s\n", q.Prompt))
 	if len(q.Options) > 0 {
 		for i, opt := range q.Options {
+			if q.Multi {
+				box := "[ ]"
+				if q.Selected[i] {
+					box = "[x]"
+				}
+				b.WriteString(fmt.Sprintf("  %s %d) %s\n", box, i+1, opt))
+				continue
+			}
 			b.WriteString(fmt.Sprintf("  %d) %s\n", i+1, opt))
 		}
-		b.WriteString("Type an option number or enter a custom response.\n")
+		switch {
+		case q.Multi && q.OptionsOnly:
+			b.WriteString("Press option numbers to toggle them, then enter.\n")
+		case q.Multi:
+			b.WriteString("Press option numbers to toggle them, add a note if you like, then enter.\n")
+		case q.OptionsOnly:
+			b.WriteString("Type an option number, then enter.\n")
+		default:
+			b.WriteString("Type an option number or enter a custom response.\n")
+		}
 	}
 	b.WriteString(q.Input.View())
 	return b.String()
@@ -1507,7 +1842,12 @@ func renderQuestionPrompt(q *questionPrompt) string {
 
 func (m model) updateQuestion(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if msg.Type == tea.KeyEsc {
+		q := m.question
 		m.question = nil
+		if q.onDismiss != nil {
+			q.onDismiss(&m)
+			return m, nil
+		}
 		m.messages = append(m.messages, "pfui: dismissed question")
 		m.compose.Focus()
 		m.refreshComposeStatus()
@@ -1521,6 +1861,10 @@ func (m model) updateQuestion(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 	if len(m.question.Options) > 0 && len(msg.Runes) == 1 && msg.Runes[0] >= '1' && msg.Runes[0] <= '9' {
 		idx := int(msg.Runes[0] - '1')
+		if idx < len(m.question.Options) && m.question.Multi {
+			m.question.Selected[idx] = !m.question.Selected[idx]
+			return m, nil
+		}
 		if idx < len(m.question.Options) {
 			m.question.Input.SetValue(m.question.Options[idx])
 			return m, nil
@@ -0,0 +1,234 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/checkpoint"
+	"github.com/fbettag/pfui/internal/history"
+	"github.com/fbettag/pfui/internal/provider"
+)
+
+// maxRestoredNames caps how many file names a restore lists in scrollback.
+const maxRestoredNames = 10
+
+// initCheckpoints opens the session's checkpoint store when [checkpoints]
+// is enabled. Turn numbering continues after a resumed session's
+// checkpoints so /undo never mixes old and new turns.
+func (m *model) initCheckpoints() {
+	if !m.cfg.Checkpoints.Enabled || m.session.ID == "" {
+		return
+	}
+	dir, err := checkpoint.DefaultDir(m.session.ID)
+	if err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: checkpoints: %v; /undo is unavailable", err))
+		return
+	}
+	m.checkpoints = checkpoint.Open(dir)
+	m.turn = m.checkpoints.LastTurn()
+	if m.opts.ResumeID != "" && len(m.session.Checkpoints) > 0 {
+		m.messages = append(m.messages, fmt.Sprintf("Session has %d checkpoints (/checkpoints, /undo)", len(m.session.Checkpoints)))
+	}
+}
+
+// recordCheckpoint adds a tool call's checkpoint to the session history so
+// --resume keeps it.
+func (m *model) recordCheckpoint(id int) {
+	if id == 0 || slices.Contains(m.session.Checkpoints, id) {
+		return
+	}
+	m.session.Checkpoints = append(m.session.Checkpoints, id)
+	m.saveSessionCheckpoints()
+}
+
+func (m *model) saveSessionCheckpoints() {
+	if m.session.ID == "" {
+		return
+	}
+	if err := history.Save(m.session); err != nil {
+		m.statusLine = fmt.Sprintf("history save error: %v", err)
+	}
+}
+
+// checkpointsBusy refuses to rewrite files while the agent may be using them.
+func (m *model) checkpointsBusy() bool {
+	if m.checkpoints == nil {
+		m.messages = append(m.messages, "pfui: checkpoints are disabled ([checkpoints] enabled = false)")
+		return true
+	}
+	if m.toolsRunning || m.pendingResponse != nil || m.approval != nil {
+		m.messages = append(m.messages, "pfui: wait for the current turn to finish (or press esc) before restoring files")
+		return true
+	}
+	return false
+}
+
+func (m *model) handleCheckpointsCommand() {
+	if m.checkpoints == nil {
+		m.messages = append(m.messages, "pfui: checkpoints are disabled ([checkpoints] enabled = false)")
+		return
+	}
+	list, err := m.checkpoints.List()
+	if err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: checkpoints: %v", err))
+		return
+	}
+	if len(list) == 0 {
+		m.messages = append(m.messages, "pfui: no checkpoints in this session yet")
+		return
+	}
+	lines := make([]string, 0, len(list)+1)
+	for _, cp := range list {
+		files := fmt.Sprintf("%d files", len(cp.Files))
+		if len(cp.Files) == 1 {
+			files = "1 file"
+		}
+		if cp.Tree() {
+			files = "project tree, " + files
+			if cp.Partial {
+				files += ", partial"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%3d  turn %d  %s  %s (%s)", cp.ID, cp.Turn, cp.CreatedAt.Local().Format("15:04:05"), sanitizeDiffLine(cp.Summary), files))
+	}
+	lines = append(lines, "/undo reverts the latest turn; /restore N puts files back as they were before checkpoint N; both refuse when files changed since (add --force)")
+	m.appendHistoryBlock("checkpoints", lines)
+}
+
+func (m *model) handleUndoCommand(args []string) {
+	force, args := forceFlag(args)
+	if len(args) != 0 {
+		m.messages = append(m.messages, "pfui: usage: /undo [--force]")
+		return
+	}
+	if m.checkpointsBusy() {
+		return
+	}
+	res, err := m.checkpoints.Undo(force)
+	if errors.Is(err, checkpoint.ErrNoCheckpoints) {
+		m.messages = append(m.messages, "pfui: nothing to undo")
+		return
+	}
+	var conflict *checkpoint.ConflictError
+	if errors.As(err, &conflict) {
+		m.messages = append(m.messages, m.conflictLine("undo", "/undo --force", conflict))
+		return
+	}
+	if err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: undo: %v", err))
+		return
+	}
+	m.finishRestore(fmt.Sprintf("undid turn %d", res.Reverted[0].Turn), res)
+}
+
+func (m *model) handleRestoreCommand(args []string) {
+	force, args := forceFlag(args)
+	if len(args) != 1 {
+		m.messages = append(m.messages, "pfui: usage: /restore N [--force] (see /checkpoints)")
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil || id <= 0 {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %q is not a checkpoint number (see /checkpoints)", args[0]))
+		return
+	}
+	if m.checkpointsBusy() {
+		return
+	}
+	res, err := m.checkpoints.Restore(id, force)
+	var conflict *checkpoint.ConflictError
+	if errors.As(err, &conflict) {
+		m.messages = append(m.messages, m.conflictLine("restore", fmt.Sprintf("/restore %d --force", id), conflict))
+		return
+	}
+	if err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: restore: %v", err))
+		return
+	}
+	m.finishRestore(fmt.Sprintf("restored checkpoint %d", id), res)
+}
+
+// forceFlag pulls --force out of a /undo or /restore argument list.
+func forceFlag(args []string) (bool, []string) {
+	force := false
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--force" {
+			force = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return force, rest
+}
+
+// conflictLine explains why a restore was refused and how to confirm it.
+func (m *model) conflictLine(what, confirm string, conflict *checkpoint.ConflictError) string {
+	var reasons []string
+	if changed := m.displayPaths(conflict.Changed); len(changed) > 0 {
+		reasons = append(reasons, "changed since the agent touched them: "+nameList(changed))
+	}
+	if len(conflict.Unsealed) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d checkpoints (background jobs or interrupted calls) did not record what they changed, so the whole snapshot would be put back", len(conflict.Unsealed)))
+	}
+	return fmt.Sprintf("pfui: %s refused, nothing was changed; %s. Run %s to overwrite anyway", what, strings.Join(reasons, "; "), confirm)
+}
+
+// finishRestore reports a restore, drops the reverted checkpoints from the
+// session, and tells the model which files changed under it.
+func (m *model) finishRestore(what string, res checkpoint.Result) {
+	for _, cp := range res.Reverted {
+		m.session.Checkpoints = slices.DeleteFunc(m.session.Checkpoints, func(id int) bool { return id == cp.ID })
+	}
+	m.saveSessionCheckpoints()
+	restored := m.displayPaths(res.Restored)
+	removed := m.displayPaths(res.Removed)
+	line := fmt.Sprintf("pfui: %s (%d checkpoints)", what, len(res.Reverted))
+	switch {
+	case len(restored) == 0 && len(removed) == 0:
+		line += "; files already matched"
+	default:
+		if len(restored) > 0 {
+			line += "; restored " + nameList(restored)
+		}
+		if len(removed) > 0 {
+			line += "; removed " + nameList(removed)
+		}
+	}
+	m.messages = append(m.messages, line)
+	if len(m.conversation) == 0 || (len(restored) == 0 && len(removed) == 0) {
+		return
+	}
+	note := fmt.Sprintf("[pfui] The operator %s, reverting your earlier changes.", what)
+	if len(restored) > 0 {
+		note += " Restored: " + strings.Join(restored, ", ") + "."
+	}
+	if len(removed) > 0 {
+		note += " Removed: " + strings.Join(removed, ", ") + "."
+	}
+	note += " Read files again before editing them."
+	m.conversation = append(m.conversation, provider.ChatMessage{Role: "user", Content: note})
+}
+
+// displayPaths shows paths relative to the project when they are inside it.
+func (m *model) displayPaths(paths []string) []string {
+	out := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if rel, err := filepath.Rel(m.opts.ProjectPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = filepath.ToSlash(rel)
+		}
+		out = append(out, path)
+	}
+	return out
+}
+
+func nameList(names []string) string {
+	if len(names) <= maxRestoredNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:maxRestoredNames], ", "), len(names)-maxRestoredNames)
+}
@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/routing"
+)
+
+const utilityCompactPrompt = "Summarize the conversation below so the assistant can continue the work from the summary alone. Keep the operator's goals and constraints, decisions made, files read or changed (with paths), commands run and their outcomes, open questions, and the next steps. Be concise and factual; no preamble.\n\nConversation:\n"
+
+const (
+	// compactTimeout bounds the summary call; transcripts can be long.
+	compactTimeout = 2 * time.Minute
+	// maxCompactCall and maxCompactResult cap how much of each tool call and
+	// tool result goes into the transcript the utility model reads.
+	maxCompactCall   = 500
+	maxCompactResult = 2000
+)
+
+type compactMsg struct {
+	summary string
+	// upto is how many conversation messages the summary covers.
+	upto  int
+	model string
+	err   error
+}
+
+// handleCompactCommand replaces the conversation so far with a summary
+// written by the utility route (or the current model when no utility route
+// is configured), freeing context for the rest of the session.
+func (m *model) handleCompactCommand() tea.Cmd {
+	switch {
+	case m.activeProvider == nil:
+		m.messages = append(m.messages, "pfui: no provider to compact with")
+		return nil
+	case m.compacting || m.pendingCompact:
+		m.messages = append(m.messages, "pfui: compaction already running")
+		return nil
+	case m.toolsRunning || m.pendingResponse != nil || m.approval != nil:
+		m.messages = append(m.messages, "pfui: wait for the current turn to finish (or press esc) before compacting")
+		return nil
+	case len(m.conversation) < 3:
+		m.messages = append(m.messages, "pfui: nothing to compact yet")
+		return nil
+	}
+	if m.routeNeedsCatalog(routing.PhaseUtility) {
+		m.pendingCompact = true
+		return m.loadRouteCatalogCmd()
+	}
+	return m.compactWithRoute()
+}
+
+func (m *model) compactWithRoute() tea.Cmd {
+	p, modelName, _ := m.resolveRoute(routing.PhaseUtility)
+	if p == nil {
+		m.messages = append(m.messages, "pfui: no provider to compact with")
+		return nil
+	}
+	upto := len(m.conversation)
+	transcript := compactTranscript(m.conversation[:upto])
+	label := fmt.Sprintf("%s/%s", providerLabel(p), defaultModelDisplay(modelName))
+	p = m.audited(p)
+	parent := m.ctx
+	m.compacting = true
+	m.statusLine = fmt.Sprintf("compacting conversation via %s…", label)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(parent, compactTimeout)
+		defer cancel()
+		out, err := provider.Collect(ctx, p, provider.ChatCompletionRequest{
+			Model:    modelName,
+			Messages: []provider.ChatMessage{{Role: "user", Content: utilityCompactPrompt + transcript}},
+		})
+		return compactMsg{summary: strings.TrimSpace(out), upto: upto, model: label, err: err}
+	}
+}
+
+// applyCompact swaps the summarized messages for the summary, keeping the
+// system prompt and anything added while the summary was written.
+func (m *model) applyCompact(msg compactMsg) {
+	m.compacting = false
+	m.statusLine = ""
+	if msg.err == nil && msg.summary == "" {
+		msg.err = fmt.Errorf("%s returned an empty summary", msg.model)
+	}
+	if msg.err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: compact: %v; the conversation was left as it was", msg.err))
+		return
+	}
+	if msg.upto > len(m.conversation) {
+		m.messages = append(m.messages, "pfui: compact: the conversation changed underneath; run /compact again")
+		return
+	}
+	rest := m.conversation[msg.upto:]
+	compacted := []provider.ChatMessage{
+		{Role: "system", Content: m.systemPrompt()},
+		{Role: "user", Content: "[pfui] The earlier conversation was compacted to save context. Summary:\n\n" + msg.summary},
+	}
+	m.conversation = append(compacted, rest...)
+	m.messages = append(m.messages, fmt.Sprintf("pfui: compacted %d messages into a summary via %s", msg.upto, msg.model))
+}
+
+// compactTranscript renders messages as plain text for the summary call,
+// trimming long tool calls and results.
+func compactTranscript(messages []provider.ChatMessage) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			continue
+		case "tool":
+			fmt.Fprintf(&b, "[tool result %s]\n%s\n", msg.Name, clip(msg.Content, maxCompactResult))
+		default:
+			if msg.Content != "" {
+				fmt.Fprintf(&b, "[%s]\n%s\n", msg.Role, msg.Content)
+			}
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&b, "[%s called %s] %s\n", msg.Role, call.Name, clip(call.Arguments, maxCompactCall))
+			}
+		}
+		if len(msg.Images) > 0 {
+			fmt.Fprintf(&b, "(%d images omitted)\n", len(msg.Images))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func clip(s string, limit int) string {
+	if runes := []rune(s); len(runes) > limit {
+		return string(runes[:limit]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// maxDiffPreviewLines caps the diff shown in an approval prompt.
+	maxDiffPreviewLines = 30
+	// maxDiffScrollbackLines caps the diff written to scrollback after an edit.
+	maxDiffScrollbackLines = 80
+)
+
+var (
+	diffAddStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#4ADE80"))
+	diffDeleteStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#F87171"))
+	diffHunkStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#22D3EE"))
+	diffFileStyle   = lipgloss.NewStyle().Bold(true)
+)
+
+// renderDiff colors a unified diff line by line, keeping at most limit lines
+// and noting how many were left out.
+func renderDiff(diff string, limit int) []string {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	hidden := 0
+	if len(lines) > limit {
+		hidden = len(lines) - limit
+		lines = lines[:limit]
+	}
+	out := make([]string, 0, len(lines)+1)
+	for _, line := range lines {
+		line = sanitizeDiffLine(line)
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			out = append(out, diffFileStyle.Render(line))
+		case strings.HasPrefix(line, "@@"):
+			out = append(out, diffHunkStyle.Render(line))
+		case strings.HasPrefix(line, "+"):
+			out = append(out, diffAddStyle.Render(line))
+		case strings.HasPrefix(line, "-"):
+			out = append(out, diffDeleteStyle.Render(line))
+		default:
+			out = append(out, line)
+		}
+	}
+	if hidden > 0 {
+		out = append(out, fmt.Sprintf("… %d more diff lines", hidden))
+	}
+	return out
+}
+
+// sanitizeDiffLine keeps file contents from moving the cursor or recoloring
+// the terminal: tabs become spaces and other control characters are dropped.
+func sanitizeDiffLine(line string) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		}
+		return r
+	}, line)
+}
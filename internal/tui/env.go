@@ -0,0 +1,88 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/config"
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/trust"
+)
+
+// envPolicy combines the [exec.env] config with the project's .pfui/env.toml.
+// The project's deny patterns always apply; its [set] values bypass the
+// denylist, so they are dropped (and ignored reports true) until the operator
+// trusts the file.
+func envPolicy(cfg config.EnvConfig, projectPath string, store *trust.Store) (policy toolexec.EnvPolicy, ignored bool, err error) {
+	policy = toolexec.DefaultEnvPolicy()
+	policy.Inherit = append(policy.Inherit, cfg.Inherit...)
+	policy.Deny = append(policy.Deny, cfg.Deny...)
+	policy.Allow = append(policy.Allow, cfg.Allow...)
+	policy.Set = make(map[string]string, len(cfg.Set))
+	for name, value := range cfg.Set {
+		policy.Set[name] = value
+	}
+	project, err := config.LoadProjectEnv(projectPath)
+	if err != nil {
+		return policy, false, err
+	}
+	policy.Deny = append(policy.Deny, project.Deny...)
+	if len(project.Set) == 0 {
+		return policy, false, nil
+	}
+	if !store.Trusted(filepath.Join(projectPath, config.ProjectEnvFile)) {
+		return policy, true, nil
+	}
+	for name, value := range project.Set {
+		policy.Set[name] = value
+	}
+	return policy, false, nil
+}
+
+// trustProjectEnv accepts the project's .pfui/env.toml as it is now and
+// applies its [set] values to subsequent commands.
+func (m *model) trustProjectEnv() {
+	if m.opts.ProjectPath == "" {
+		m.messages = append(m.messages, "pfui: no project env file")
+		return
+	}
+	path := filepath.Join(m.opts.ProjectPath, config.ProjectEnvFile)
+	store := openTrustStore()
+	if err := store.Trust(path); err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: env trust: %v", err))
+		return
+	}
+	policy, _, err := envPolicy(m.cfg.Exec.Env, m.opts.ProjectPath, store)
+	if err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: env trust: %v", err))
+		return
+	}
+	if m.executor != nil {
+		m.executor.SetEnvPolicy(policy)
+	}
+	m.messages = append(m.messages, fmt.Sprintf("pfui: trusted %s; its [set] values apply until the file changes", path))
+}
+
+// handleJobEnv prints the redacted environment a job ran with.
+func (m *model) handleJobEnv(args []string) {
+	if len(args) == 0 {
+		m.messages = append(m.messages, "pfui: usage: /jobs env <id> | /jobs env trust")
+		return
+	}
+	if strings.EqualFold(args[0], "trust") {
+		m.trustProjectEnv()
+		return
+	}
+	id, ok := m.resolveJobID(args[0])
+	if !ok || m.executor == nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: job %s not found", args[0]))
+		return
+	}
+	job, ok := m.executor.Job(id)
+	if !ok {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: job %s not found", args[0]))
+		return
+	}
+	m.appendHistoryBlock(fmt.Sprintf("job %s env", shortJobID(id)), job.Env)
+}
@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fbettag/pfui/internal/config"
+	"github.com/fbettag/pfui/internal/trust"
+)
+
+func TestEnvPolicyIgnoresUntrustedProjectSet(t *testing.T) {
+	project := t.TempDir()
+	path := filepath.Join(project, config.ProjectEnvFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("deny = [\"DATABASE_URL\"]\n[set]\nLD_PRELOAD = \"./evil.so\"\nPATH = \"./bin\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := trust.Open(filepath.Join(t.TempDir(), trust.FileName))
+	base := []string{"PATH=/usr/bin", "DATABASE_URL=postgres://secret"}
+
+	policy, ignored, err := envPolicy(config.EnvConfig{}, project, store)
+	if err != nil {
+		t.Fatalf("envPolicy: %v", err)
+	}
+	if !ignored {
+		t.Fatal("expected the untrusted [set] table to be reported as ignored")
+	}
+	env := strings.Join(policy.Apply(base), "\n")
+	if strings.Contains(env, "LD_PRELOAD") || !strings.Contains(env, "PATH=/usr/bin") {
+		t.Fatalf("untrusted project file injected variables:\n%s", env)
+	}
+	if strings.Contains(env, "DATABASE_URL") {
+		t.Fatalf("project deny patterns should apply untrusted:\n%s", env)
+	}
+
+	if err := store.Trust(path); err != nil {
+		t.Fatal(err)
+	}
+	policy, ignored, err = envPolicy(config.EnvConfig{}, project, store)
+	if err != nil || ignored {
+		t.Fatalf("trusted file: ignored=%v err=%v", ignored, err)
+	}
+	env = strings.Join(policy.Apply(base), "\n")
+	if !strings.Contains(env, "LD_PRELOAD=./evil.so") || !strings.Contains(env, "PATH=./bin") {
+		t.Fatalf("trusted project file should set variables:\n%s", env)
+	}
+
+	if err := os.WriteFile(path, []byte("[set]\nBASH_ENV = \"./x.sh\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if policy, ignored, _ = envPolicy(config.EnvConfig{}, project, nil); !ignored || len(policy.Set) != 0 {
+		t.Fatalf("a changed file and a nil store should trust nothing, got %+v", policy.Set)
+	}
+}
@@ -0,0 +1,187 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/toolexec"
+	"github.com/fbettag/pfui/internal/tools"
+)
+
+// tailRegionLines bounds the live tail drawn above the compose box.
+const tailRegionLines = 8
+
+// liveTail tracks the lines shown for the foreground command or a job pinned via /jobs tail.
+type liveTail struct {
+	job   toolexec.Job
+	lines []string
+	// attached routes keystrokes to a background PTY job.
+	attached bool
+}
+
+func (t *liveTail) push(line toolexec.OutputLine) {
+	t.lines = append(t.lines, toolexec.FormatLines([]toolexec.OutputLine{line})...)
+	if len(t.lines) > tailRegionLines {
+		t.lines = append([]string(nil), t.lines[len(t.lines)-tailRegionLines:]...)
+	}
+}
+
+// setScreen replaces the tail with a PTY command's visible lines.
+func (t *liveTail) setScreen(job toolexec.Job, screen []string) {
+	t.job = job
+	if len(screen) > ptyRegionLines {
+		screen = screen[len(screen)-ptyRegionLines:]
+	}
+	t.lines = append([]string(nil), screen...)
+}
+
+func (m *model) handleExecEvent(ev toolexec.Event) {
+	job := ev.Job
+	if job.ID == "" {
+		return
+	}
+	if ev.Dropped > 0 {
+		// The TUI fell behind; mark the gap instead of silently skipping it.
+		for _, t := range []*liveTail{m.foregroundTail, m.jobTail} {
+			if t != nil && !t.job.PTY {
+				t.push(toolexec.OutputLine{Stream: toolexec.StreamStderr, Text: fmt.Sprintf("[pfui: %d output lines skipped]", ev.Dropped)})
+			}
+		}
+	}
+	if job.Foreground {
+		m.handleForegroundEvent(ev)
+		return
+	}
+	if ev.Kind == toolexec.EventScreen {
+		if m.jobTail != nil && m.jobTail.job.ID == job.ID {
+			m.jobTail.setScreen(job, ev.Screen)
+		}
+		return
+	}
+	if ev.Kind == toolexec.EventOutput {
+		if m.jobTail != nil && m.jobTail.job.ID == job.ID && !job.PTY {
+			m.jobTail.push(ev.Line)
+		}
+		return
+	}
+	m.jobs[job.ID] = job
+	if m.jobTail != nil && m.jobTail.job.ID == job.ID {
+		m.jobTail.job = job
+	}
+	m.recordJobEvent(job)
+	if job.CallID != "" && job.Status.Finished() {
+		m.jobNotices = append(m.jobNotices, tools.JobNotice(job))
+	}
+}
+
+func (m *model) handleForegroundEvent(ev toolexec.Event) {
+	switch {
+	case ev.Kind == toolexec.EventScreen:
+		if m.foregroundTail == nil || m.foregroundTail.job.ID != ev.Job.ID {
+			m.foregroundTail = &liveTail{job: ev.Job}
+		}
+		m.foregroundTail.setScreen(ev.Job, ev.Screen)
+	case ev.Kind == toolexec.EventOutput && ev.Job.PTY:
+		// Screen events already show PTY output.
+	case ev.Kind == toolexec.EventOutput:
+		if m.foregroundTail == nil || m.foregroundTail.job.ID != ev.Job.ID {
+			m.foregroundTail = &liveTail{job: ev.Job}
+		}
+		m.foregroundTail.push(ev.Line)
+	case ev.Job.Status == toolexec.JobRunning:
+		m.foregroundTail = &liveTail{job: ev.Job}
+	default:
+		if m.foregroundTail != nil && m.foregroundTail.job.ID == ev.Job.ID {
+			m.foregroundTail = nil
+		}
+	}
+}
+
+// openJobTail pins a live tail of a background job to the dock.
+func (m *model) openJobTail(args []string) {
+	if len(args) == 0 {
+		m.messages = append(m.messages, "pfui: /jobs tail <id> [lines] | /jobs tail off")
+		return
+	}
+	if strings.EqualFold(args[0], "off") {
+		m.jobTail = nil
+		return
+	}
+	id, ok := m.resolveJobID(args[0])
+	if !ok || m.executor == nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: job %s not found", args[0]))
+		return
+	}
+	job, _ := m.executor.Job(id)
+	lines, err := m.executor.Tail(id, tailRegionLines)
+	if err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
+		return
+	}
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			m.messages = append(m.messages, "pfui: /jobs tail <id> [lines] expects a positive line count")
+			return
+		}
+		history, _ := m.executor.Tail(id, n)
+		title := fmt.Sprintf("job %s · %s%s (last %d lines)", shortJobID(id), job.Command, formatArgs(job.Args), n)
+		m.appendHistoryBlock(title, toolexec.FormatLines(history))
+	}
+	m.jobTail = &liveTail{job: job, lines: toolexec.FormatLines(lines)}
+}
+
+// resolveJobID accepts a full job ID or the short prefix shown in /jobs.
+func (m *model) resolveJobID(input string) (string, bool) {
+	if _, ok := m.jobs[input]; ok {
+		return input, true
+	}
+	var match string
+	for id := range m.jobs {
+		if strings.HasPrefix(id, input) {
+			if match != "" {
+				return "", false
+			}
+			match = id
+		}
+	}
+	return match, match != ""
+}
+
+func renderLiveTail(t *liveTail, foreground bool) string {
+	if t == nil {
+		return ""
+	}
+	var b strings.Builder
+	label := fmt.Sprintf("job %s", shortJobID(t.job.ID))
+	hint := "/jobs tail off or esc to close"
+	if foreground {
+		label = "running"
+		hint = "esc cancels · ctrl+z background"
+	}
+	if t.job.PTY && t.job.Status == toolexec.JobRunning && (foreground || t.attached) {
+		label = "terminal"
+		if !foreground {
+			label = fmt.Sprintf("terminal %s", shortJobID(t.job.ID))
+		}
+		hint = "keys go to the command · ctrl+z background · ctrl+x stop"
+	}
+	b.WriteString(fmt.Sprintf("▶ %s · %s%s [%s] (%s)\n", label, t.job.Command, formatArgs(t.job.Args), strings.ToUpper(string(t.job.Status)), hint))
+	if len(t.lines) == 0 {
+		b.WriteString("  (no output yet)\n")
+	}
+	for _, line := range t.lines {
+		b.WriteString("  " + truncate(line, 200) + "\n")
+	}
+	return b.String()
+}
+
+// openJobStore opens ~/.pfui/jobs so background jobs survive restarts.
+func openJobStore() (*toolexec.Store, error) {
+	dir, err := toolexec.DefaultJobsDir()
+	if err != nil {
+		return nil, err
+	}
+	return toolexec.NewStore(dir)
+}
@@ -18,6 +18,8 @@ type commandPalette struct {
 
 var defaultCommands = []string{
 	"/model",
+	"/route",
+	"/sandbox",
 	"/plan",
 	"/auto",
 	"/off",
@@ -28,6 +30,9 @@ var defaultCommands = []string{
 	"/usage",
 	"/jobs",
 	"/approvals",
+	"/undo",
+	"/checkpoints",
+	"/restore",
 	"/compact",
 	"/mcp",
 	"/plugin",
@@ -0,0 +1,129 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/fbettag/pfui/internal/toolexec"
+)
+
+// ptyRegionLines bounds the terminal region drawn for PTY commands.
+const ptyRegionLines = 12
+
+// ptyTarget returns the live tail that keystrokes should go to: a running
+// foreground PTY command, or a background one attached via /jobs attach.
+func (m model) ptyTarget() *liveTail {
+	if t := m.foregroundTail; t != nil && t.job.PTY && t.job.Status == toolexec.JobRunning {
+		return t
+	}
+	if t := m.jobTail; t != nil && t.attached && t.job.Status == toolexec.JobRunning {
+		return t
+	}
+	return nil
+}
+
+// updatePTY forwards keys to the terminal. ctrl+z detaches (or releases an
+// attached job) and ctrl+x stops the command; everything else, including
+// esc and ctrl+c, belongs to the program.
+func (m model) updatePTY(msg tea.KeyMsg, t *liveTail) (tea.Model, tea.Cmd) {
+	foreground := t == m.foregroundTail
+	switch msg.String() {
+	case "ctrl+z":
+		if foreground {
+			m.detachForeground()
+		} else {
+			m.jobTail.attached = false
+			m.messages = append(m.messages, fmt.Sprintf("pfui: released job %s; /jobs attach %s to type into it again", shortJobID(t.job.ID), shortJobID(t.job.ID)))
+		}
+		return m, nil
+	case "ctrl+x":
+		if foreground {
+			m.executor.CancelForeground()
+			m.statusLine = "Canceled foreground command."
+		} else if m.executor.CancelJob(t.job.ID) {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: canceling job %s", shortJobID(t.job.ID)))
+		}
+		return m, nil
+	}
+	data := keyBytes(msg)
+	if len(data) == 0 {
+		return m, nil
+	}
+	if err := m.executor.WriteInput(t.job.ID, data); err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
+	}
+	return m, nil
+}
+
+// detachForeground moves the running foreground command to the background.
+func (m *model) detachForeground() bool {
+	if m.executor == nil {
+		return false
+	}
+	id, ok := m.executor.Detach()
+	if !ok {
+		return false
+	}
+	m.foregroundTail = nil
+	m.messages = append(m.messages, fmt.Sprintf("pfui: moved command to background as job %s (/jobs tail %s)", shortJobID(id), shortJobID(id)))
+	return true
+}
+
+// attachJob pins a background PTY job and routes keystrokes to it.
+func (m *model) attachJob(args []string) {
+	if len(args) == 0 {
+		m.messages = append(m.messages, "pfui: usage: /jobs attach <id>")
+		return
+	}
+	id, ok := m.resolveJobID(args[0])
+	if !ok || m.executor == nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: job %s not found", args[0]))
+		return
+	}
+	job, _ := m.executor.Job(id)
+	if !job.PTY || job.Status != toolexec.JobRunning {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: job %s is not a running terminal job", shortJobID(id)))
+		return
+	}
+	lines, _ := m.executor.Tail(id, ptyRegionLines)
+	m.jobTail = &liveTail{job: job, lines: toolexec.FormatLines(lines), attached: true}
+}
+
+// keyBytes encodes a key press the way a terminal would send it.
+func keyBytes(msg tea.KeyMsg) []byte {
+	var out []byte
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		out = []byte(string(msg.Runes))
+	case tea.KeyUp:
+		out = []byte("\x1b[A")
+	case tea.KeyDown:
+		out = []byte("\x1b[B")
+	case tea.KeyRight:
+		out = []byte("\x1b[C")
+	case tea.KeyLeft:
+		out = []byte("\x1b[D")
+	case tea.KeyHome:
+		out = []byte("\x1b[H")
+	case tea.KeyEnd:
+		out = []byte("\x1b[F")
+	case tea.KeyPgUp:
+		out = []byte("\x1b[5~")
+	case tea.KeyPgDown:
+		out = []byte("\x1b[6~")
+	case tea.KeyDelete:
+		out = []byte("\x1b[3~")
+	case tea.KeyShiftTab:
+		out = []byte("\x1b[Z")
+	default:
+		// Control keys (enter, tab, backspace, esc, ctrl+letter) carry their byte value.
+		if msg.Type >= 0 && (msg.Type < 0x20 || msg.Type == 0x7f) {
+			out = []byte{byte(msg.Type)}
+		}
+	}
+	if msg.Alt && len(out) > 0 {
+		out = append([]byte{0x1b}, out...)
+	}
+	return out
+}
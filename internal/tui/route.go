@@ -0,0 +1,384 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/fbettag/pfui/internal/history"
+	"github.com/fbettag/pfui/internal/modelcatalog"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/routing"
+)
+
+const utilitySummaryPrompt = "Summarize the following request for a chat history list. Reply with exactly two lines: a title of at most 60 characters, then a one-sentence summary. No quotes, no markdown.\n\nRequest:\n"
+
+type sessionSummaryMsg struct {
+	sessionID string
+	title     string
+	summary   string
+	err       error
+}
+
+// phaseForMode maps the PLAN/AUTO/OFF badge onto a routing phase. OFF keeps the
+// operator's manual /model selection.
+func (m model) phaseForMode() routing.Phase {
+	switch m.plan {
+	case planModePlan:
+		return routing.PhasePlan
+	case planModeAuto:
+		return routing.PhaseExecution
+	default:
+		return ""
+	}
+}
+
+const (
+	// routeListTimeout bounds each provider's model listing on its own, so
+	// one slow provider cannot starve the others.
+	routeListTimeout = 5 * time.Second
+	// routeRetryMin and routeRetryMax bound the backoff before a provider
+	// whose listing failed is asked again.
+	routeRetryMin = 30 * time.Second
+	routeRetryMax = 10 * time.Minute
+)
+
+// routeListing is what the route catalog knows about one provider.
+type routeListing struct {
+	models   []modelcatalog.Model
+	loaded   bool
+	failures int
+	retryAt  time.Time
+}
+
+// routeCatalogMsg carries the listings of the providers a load asked.
+type routeCatalogMsg struct {
+	results []routeCatalogResult
+}
+
+type routeCatalogResult struct {
+	name   string
+	models []modelcatalog.Model
+	err    error
+}
+
+// loadRouteCatalogCmd lists models off the UI goroutine from every provider
+// that has not answered yet and is not backing off after a failure, each
+// under its own timeout. It returns nil when a load is already in flight or
+// nothing is due; the routeCatalogMsg resumes whatever was waiting for it.
+func (m *model) loadRouteCatalogCmd() tea.Cmd {
+	if m.routeModelsLoading {
+		return nil
+	}
+	now := time.Now()
+	var due []modelcatalog.Source
+	var whitelists []map[string]struct{}
+	for _, src := range provider.AsCatalogSources(m.providers) {
+		if listing, ok := m.routeListings[src.Name()]; ok && (listing.loaded || now.Before(listing.retryAt)) {
+			continue
+		}
+		due = append(due, src)
+		whitelists = append(whitelists, buildWhitelistSet(m.providerWhitelist(m.providerByName(src.Name()))))
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	m.routeModelsLoading = true
+	parent := m.ctx
+	return func() tea.Msg {
+		msg := routeCatalogMsg{results: make([]routeCatalogResult, len(due))}
+		var wg sync.WaitGroup
+		for i, src := range due {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx, cancel := context.WithTimeout(parent, routeListTimeout)
+				defer cancel()
+				result := routeCatalogResult{name: src.Name()}
+				list, err := src.ListModels(ctx)
+				if err != nil {
+					result.err = err
+					msg.results[i] = result
+					return
+				}
+				for _, entry := range list {
+					if len(whitelists[i]) > 0 {
+						if _, ok := whitelists[i][entry.Name]; !ok {
+							continue
+						}
+					}
+					result.models = append(result.models, entry)
+				}
+				msg.results[i] = result
+			}()
+		}
+		wg.Wait()
+		return msg
+	}
+}
+
+// applyRouteCatalog stores the listings that arrived and resumes the stream,
+// tool round, title summary, compaction, or /route listing that was waiting
+// on them. A provider that failed keeps whatever it listed before and is
+// retried with exponential backoff; its error is shown once per failure
+// streak rather than on every turn.
+func (m *model) applyRouteCatalog(msg routeCatalogMsg) tea.Cmd {
+	m.routeModelsLoading = false
+	if m.routeListings == nil {
+		m.routeListings = make(map[string]*routeListing)
+	}
+	now := time.Now()
+	for _, result := range msg.results {
+		listing := m.routeListings[result.name]
+		if listing == nil {
+			listing = &routeListing{}
+			m.routeListings[result.name] = listing
+		}
+		if result.err != nil {
+			listing.failures++
+			backoff := min(routeRetryMin<<min(listing.failures-1, 8), routeRetryMax)
+			listing.retryAt = now.Add(backoff)
+			if listing.failures == 1 {
+				m.messages = append(m.messages, fmt.Sprintf("pfui: route catalog %s: %v (retrying in %s)", result.name, result.err, backoff))
+			} else {
+				m.statusLine = fmt.Sprintf("route catalog %s: %v", result.name, result.err)
+			}
+			continue
+		}
+		listing.models, listing.loaded, listing.failures = result.models, true, 0
+	}
+	m.routeModels = nil
+	for _, src := range provider.AsCatalogSources(m.providers) {
+		if listing := m.routeListings[src.Name()]; listing != nil {
+			m.routeModels = append(m.routeModels, listing.models...)
+		}
+	}
+	var cmds []tea.Cmd
+	if m.pendingResponse != nil && m.pendingResponse.awaitingRoute {
+		cmds = append(cmds, m.startResponseStream())
+	}
+	if calls := m.pendingToolCalls; calls != nil {
+		m.pendingToolCalls = nil
+		cmds = append(cmds, m.runToolCallsCmd(calls))
+	}
+	if prompt := m.pendingSummary; prompt != "" {
+		m.pendingSummary = ""
+		cmds = append(cmds, m.summarizeWithRoute(prompt))
+	}
+	if m.pendingCompact {
+		m.pendingCompact = false
+		cmds = append(cmds, m.compactWithRoute())
+	}
+	if m.routeReportPending {
+		m.routeReportPending = false
+		m.appendHistoryBlock("route", m.routeSummaryLines())
+	}
+	return tea.Batch(cmds...)
+}
+
+// routeCatalogReady reports whether every provider has listed its models or
+// is backing off after a failure, so routes resolve against what is known
+// instead of waiting.
+func (m *model) routeCatalogReady() bool {
+	if m.routeModelsLoading {
+		return false
+	}
+	now := time.Now()
+	for _, src := range provider.AsCatalogSources(m.providers) {
+		listing, ok := m.routeListings[src.Name()]
+		if !ok || (!listing.loaded && !now.Before(listing.retryAt)) {
+			return false
+		}
+	}
+	return true
+}
+
+// routeNeedsCatalog reports whether resolving phase has to wait for the
+// catalog.
+func (m *model) routeNeedsCatalog(phase routing.Phase) bool {
+	if m.routeCatalogReady() || phase == "" || !m.routes.Enabled() {
+		return false
+	}
+	sel, _ := m.routes.Selector(phase)
+	return sel != ""
+}
+
+// resolveRoute returns the provider/model pair for phase. When no route applies
+// it falls back to the active provider and /model selection.
+func (m *model) resolveRoute(phase routing.Phase) (provider.Provider, string, *routing.Route) {
+	if phase == "" || !m.routes.Enabled() {
+		return m.activeProvider, m.defaultModel, nil
+	}
+	if sel, _ := m.routes.Selector(phase); sel == "" {
+		return m.activeProvider, m.defaultModel, nil
+	}
+	route, err := m.routes.Resolve(phase, m.routeModels, providerLabel(m.activeProvider))
+	if err != nil {
+		m.statusLine = fmt.Sprintf("route %s: %v", phase, err)
+		return m.activeProvider, m.defaultModel, nil
+	}
+	p := m.providerByName(route.Provider)
+	if p == nil {
+		m.statusLine = fmt.Sprintf("route %s: provider %s not available", phase, route.Provider)
+		return m.activeProvider, m.defaultModel, nil
+	}
+	return p, route.Model, &route
+}
+
+func (m *model) handleRouteCommand(args []string) tea.Cmd {
+	if len(args) == 0 {
+		m.appendHistoryBlock("route", m.routeSummaryLines())
+		return m.reportRoutesWhenLoaded()
+	}
+	switch strings.ToLower(args[0]) {
+	case "on":
+		m.routes.SetEnabled(true)
+		m.messages = append(m.messages, "pfui: model routing enabled")
+		return nil
+	case "off":
+		m.routes.SetEnabled(false)
+		m.messages = append(m.messages, "pfui: model routing disabled; using the /model selection for every phase")
+		return nil
+	case "reset":
+		var phase routing.Phase
+		if len(args) > 1 {
+			parsed, ok := routing.ParsePhase(args[1])
+			if !ok {
+				m.messages = append(m.messages, fmt.Sprintf("pfui: unknown route phase %s", args[1]))
+				return nil
+			}
+			phase = parsed
+		}
+		m.routes.Reset(phase)
+		m.messages = append(m.messages, "pfui: route overrides cleared")
+		return nil
+	}
+	phase, ok := routing.ParsePhase(args[0])
+	if !ok {
+		m.messages = append(m.messages, "pfui: /route [on|off|reset [phase]|<plan|execution|utility> <model|provider/model|tag:key=value>]")
+		return nil
+	}
+	if len(args) < 2 {
+		m.appendHistoryBlock("route", []string{m.routeLine(phase)})
+		return m.reportRoutesWhenLoaded()
+	}
+	if err := m.routes.Override(phase, strings.Join(args[1:], " ")); err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
+		return nil
+	}
+	m.messages = append(m.messages, fmt.Sprintf("pfui: %s", m.routeLine(phase)))
+	return m.reportRoutesWhenLoaded()
+}
+
+// reportRoutesWhenLoaded starts loading the catalog after /route showed lines
+// that could not be resolved yet, and lists the routes again once it lands.
+func (m *model) reportRoutesWhenLoaded() tea.Cmd {
+	if m.routeCatalogReady() || !m.routes.Enabled() {
+		return nil
+	}
+	m.routeReportPending = true
+	return m.loadRouteCatalogCmd()
+}
+
+func (m *model) routeSummaryLines() []string {
+	state := "on"
+	if !m.routes.Enabled() {
+		state = "off"
+	}
+	current := "none (OFF mode uses /model selection)"
+	if phase := m.phaseForMode(); phase != "" {
+		current = string(phase)
+	}
+	lines := []string{fmt.Sprintf("routing: %s · current phase: %s", state, current)}
+	for _, phase := range routing.Phases {
+		lines = append(lines, m.routeLine(phase))
+	}
+	lines = append(lines, "override with /route <phase> <selector>; /route reset clears overrides")
+	return lines
+}
+
+func (m *model) routeLine(phase routing.Phase) string {
+	sel, overridden := m.routes.Selector(phase)
+	if sel == "" {
+		return fmt.Sprintf("%s → not configured (uses /model selection)", phase)
+	}
+	source := "config"
+	if overridden {
+		source = "override"
+	}
+	route, err := m.routes.Resolve(phase, m.routeModels, providerLabel(m.activeProvider))
+	if err != nil && !m.routeCatalogReady() {
+		return fmt.Sprintf("%s → %s (%s; model catalog loading)", phase, sel, source)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s → unresolved: %v (%s)", phase, err, source)
+	}
+	return fmt.Sprintf("%s → %s/%s (%s, %s)", phase, route.Provider, route.Model, sel, source)
+}
+
+// summarizeSessionCmd asks the utility route for a history title and summary.
+// It returns nil when no utility route is configured so titles keep using the
+// truncated first prompt.
+func (m *model) summarizeSessionCmd(prompt string) tea.Cmd {
+	if m.session.ID == "" || !m.routes.Enabled() {
+		return nil
+	}
+	if sel, _ := m.routes.Selector(routing.PhaseUtility); sel == "" {
+		return nil
+	}
+	if m.routeNeedsCatalog(routing.PhaseUtility) {
+		m.pendingSummary = prompt
+		return m.loadRouteCatalogCmd()
+	}
+	return m.summarizeWithRoute(prompt)
+}
+
+func (m *model) summarizeWithRoute(prompt string) tea.Cmd {
+	p, modelName, route := m.resolveRoute(routing.PhaseUtility)
+	if route == nil || p == nil {
+		return nil
+	}
+	p = m.audited(p)
+	sessionID := m.session.ID
+	parent := m.ctx
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+		defer cancel()
+		out, err := provider.Collect(ctx, p, provider.ChatCompletionRequest{
+			Model:    modelName,
+			Messages: []provider.ChatMessage{{Role: "user", Content: utilitySummaryPrompt + prompt}},
+		})
+		if err != nil {
+			return sessionSummaryMsg{sessionID: sessionID, err: err}
+		}
+		title, summary, _ := strings.Cut(strings.TrimSpace(out), "\n")
+		return sessionSummaryMsg{
+			sessionID: sessionID,
+			title:     truncate(strings.TrimSpace(title), 60),
+			summary:   truncate(strings.TrimSpace(summary), 120),
+		}
+	}
+}
+
+func (m *model) applySessionSummary(msg sessionSummaryMsg) {
+	if msg.sessionID != m.session.ID {
+		return
+	}
+	if msg.err != nil {
+		m.statusLine = fmt.Sprintf("utility route: %v", msg.err)
+		return
+	}
+	if msg.title != "" {
+		m.session.Title = msg.title
+	}
+	if msg.summary != "" {
+		m.session.Summary = msg.summary
+	}
+	if err := history.Save(m.session); err != nil {
+		m.statusLine = fmt.Sprintf("history save error: %v", err)
+	}
+}
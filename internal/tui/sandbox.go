@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/sandbox"
+)
+
+// initSandbox resolves the session's sandbox from config and --sandbox. When the
+// host cannot enforce the requested level it falls back to full and says so.
+func (m *model) initSandbox() {
+	policy, err := sandbox.NewPolicy(m.cfg.Sandbox, m.opts.ProjectPath)
+	if err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: sandbox config: %v", err))
+	}
+	if strings.TrimSpace(m.opts.Sandbox) != "" {
+		level, err := sandbox.ParseLevel(m.opts.Sandbox)
+		if err != nil {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: --sandbox: %v", err))
+		} else {
+			policy.Level = level
+		}
+	}
+	if err := m.applySandbox(policy); err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %v; exec commands run unconfined", err))
+		policy.Level = sandbox.LevelFull
+		policy.IsolateNetwork = false
+		_ = m.applySandbox(policy)
+	}
+}
+
+// applySandbox installs policy on the executor for subsequent commands.
+func (m *model) applySandbox(policy sandbox.Policy) error {
+	if !policy.Confined() {
+		m.sandbox = policy
+		if m.executor != nil {
+			m.executor.SetWrapper(nil)
+		}
+		return nil
+	}
+	sb, err := sandbox.New(policy)
+	if err != nil {
+		return err
+	}
+	m.sandbox = policy
+	if m.executor != nil {
+		m.executor.SetWrapper(sb)
+	}
+	return nil
+}
+
+func (m *model) handleSandboxCommand(args []string) {
+	if len(args) == 0 {
+		m.appendHistoryBlock("sandbox", m.sandboxSummaryLines())
+		return
+	}
+	policy := m.sandbox
+	if strings.EqualFold(args[0], "network") {
+		if len(args) < 2 {
+			m.messages = append(m.messages, "pfui: usage: /sandbox network on|off")
+			return
+		}
+		switch strings.ToLower(args[1]) {
+		case "on", "allow":
+			policy.IsolateNetwork = false
+		case "off", "block", "isolate":
+			policy.IsolateNetwork = true
+		default:
+			m.messages = append(m.messages, "pfui: usage: /sandbox network on|off")
+			return
+		}
+	} else {
+		level, err := sandbox.ParseLevel(args[0])
+		if err != nil {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
+			return
+		}
+		policy.Level = level
+	}
+	if err := m.applySandbox(policy); err != nil {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: %v", err))
+		return
+	}
+	m.messages = append(m.messages, fmt.Sprintf("pfui: sandbox set to %s for this session", m.sandbox.Summary()))
+}
+
+func (m model) sandboxSummaryLines() []string {
+	lines := []string{fmt.Sprintf("Level: %s", m.sandbox.Level)}
+	if m.sandbox.IsolateNetwork {
+		lines = append(lines, "Network: blocked (Unix sockets only)")
+	} else {
+		lines = append(lines, "Network: allowed")
+	}
+	switch m.sandbox.Level {
+	case sandbox.LevelFull:
+		lines = append(lines, "Filesystem: unconfined")
+	default:
+		lines = append(lines, "Filesystem: read-only outside the writable paths")
+		lines = append(lines, "Writable:")
+		for _, path := range m.sandbox.WritableRoots() {
+			lines = append(lines, "  "+path)
+		}
+	}
+	if err := sandbox.Available(); err != nil {
+		lines = append(lines, fmt.Sprintf("Unavailable here: %v", err))
+	}
+	lines = append(lines, "", "Usage: /sandbox read-only|workspace-write|full · /sandbox network on|off")
+	return lines
+}
@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/history"
+	"github.com/fbettag/pfui/internal/skills"
+)
+
+// initSkills discovers ~/.pfui/skills and .pfui/skills.
+func (m *model) initSkills() {
+	list, errs := skills.Load(m.opts.ProjectPath)
+	m.skills = list
+	for _, err := range errs {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: skill: %v", err))
+	}
+}
+
+func (m *model) skillEnabled(name string) bool {
+	return !slices.Contains(m.session.DisabledSkills, name)
+}
+
+// enabledSkills lists the skills the model may see and load this session.
+func (m *model) enabledSkills() []skills.Skill {
+	var out []skills.Skill
+	for _, skill := range m.skills {
+		if m.skillEnabled(skill.Name) {
+			out = append(out, skill)
+		}
+	}
+	return out
+}
+
+// skillEntries are the system prompt's skill lines.
+func (m *model) skillEntries() []string {
+	var entries []string
+	for _, skill := range m.enabledSkills() {
+		entries = append(entries, skill.Entry())
+	}
+	return entries
+}
+
+func (m *model) handleSkillCommand(args []string) {
+	if len(args) == 0 {
+		m.appendHistoryBlock("skills", m.skillLines())
+		return
+	}
+	switch action := strings.ToLower(args[0]); action {
+	case "reload":
+		m.initSkills()
+		m.refreshSystemPrompt()
+		m.messages = append(m.messages, fmt.Sprintf("pfui: loaded %d skills", len(m.skills)))
+		return
+	case "on", "off", "toggle":
+		if len(args) != 2 {
+			break
+		}
+		skill, ok := skills.Find(m.skills, strings.ToLower(args[1]))
+		if !ok {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: no skill named %s (/skill lists them)", args[1]))
+			return
+		}
+		enable := action == "on" || (action == "toggle" && !m.skillEnabled(skill.Name))
+		m.setSkillEnabled(skill.Name, enable)
+		return
+	}
+	m.messages = append(m.messages, "pfui: /skill [on|off|toggle NAME | reload]")
+}
+
+// setSkillEnabled switches a skill for this session and rewrites the
+// system prompt so the model's list matches.
+func (m *model) setSkillEnabled(name string, enable bool) {
+	disabled := slices.DeleteFunc(m.session.DisabledSkills, func(n string) bool { return n == name })
+	if !enable {
+		disabled = append(disabled, name)
+	}
+	m.session.DisabledSkills = disabled
+	if m.session.ID != "" {
+		if err := history.Save(m.session); err != nil {
+			m.statusLine = fmt.Sprintf("history save error: %v", err)
+		}
+	}
+	m.refreshSystemPrompt()
+	state := "enabled"
+	if !enable {
+		state = "disabled"
+		if m.loadedSkills[name] {
+			state += "; the instructions already loaded stay in this conversation"
+		}
+	}
+	m.messages = append(m.messages, fmt.Sprintf("pfui: skill %s %s", name, state))
+}
+
+func (m *model) skillLines() []string {
+	if len(m.skills) == 0 {
+		userDir, _ := skills.UserDir()
+		return []string{fmt.Sprintf("No skills found. Add a directory with a %s to %s or %s.", skills.FileName, safeDisplay(userDir, "~/.pfui/skills"), skills.ProjectDir(m.opts.ProjectPath))}
+	}
+	lines := make([]string, 0, len(m.skills)*2+2)
+	promptTokens, enabled := 0, 0
+	for _, skill := range m.skills {
+		state := "off"
+		if m.skillEnabled(skill.Name) {
+			state = "on"
+			enabled++
+			promptTokens += skill.PromptTokens()
+		}
+		if m.loadedSkills[skill.Name] {
+			state += ", loaded"
+		}
+		lines = append(lines, fmt.Sprintf("%s [%s] (%s) %s", skill.Name, skill.Scope, state, skill.Description))
+		lines = append(lines, fmt.Sprintf("    ~%d tokens listed · ~%d tokens when loaded · %d resources · %s", skill.PromptTokens(), skill.BodyTokens(), len(skill.Resources), skill.Dir))
+	}
+	lines = append(lines, fmt.Sprintf("%d of %d skills enabled, ~%d tokens in the system prompt", enabled, len(m.skills), promptTokens))
+	lines = append(lines, "/skill on|off|toggle NAME switches a skill for this session · /skill reload rescans the directories")
+	return lines
+}
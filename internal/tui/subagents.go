@@ -0,0 +1,184 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fbettag/pfui/internal/agents"
+	"github.com/fbettag/pfui/internal/provider"
+	"github.com/fbettag/pfui/internal/tools"
+)
+
+// maxRunTaskChars keeps /subagent's run list to one line per run.
+const maxRunTaskChars = 60
+
+// initSubagents loads ~/.pfui/agents and .pfui/agents.
+func (m *model) initSubagents() {
+	m.subagents = agents.NewManager(m.opts.ProjectPath)
+	for _, err := range m.subagents.Errors() {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: subagent: %v", err))
+	}
+}
+
+// toolSpecs lists the tools offered to the model: the built-in ones, plus
+// load_skill when skills are enabled and task when subagents are defined.
+func (m *model) toolSpecs() []provider.ToolSpec {
+	specs := tools.Specs()
+	if enabled := m.enabledSkills(); len(enabled) > 0 {
+		specs = append(specs, tools.LoadSkillSpec(enabled))
+	}
+	if defs := m.subagents.Definitions(); len(defs) > 0 {
+		specs = append(specs, tools.TaskSpec(agents.Info(defs)))
+	}
+	return specs
+}
+
+// subagentNames describes the subagents for the system prompt.
+func (m *model) subagentNames() []string {
+	var names []string
+	for _, def := range m.subagents.Definitions() {
+		names = append(names, fmt.Sprintf("%s (%s)", def.Name, def.Description))
+	}
+	return names
+}
+
+// subagentDelegate returns the Delegator for this round of tool calls. It
+// resolves model selectors against a snapshot of the provider state, since
+// subagents run on the tool goroutine.
+func (m *model) subagentDelegate() tools.Delegator {
+	defs := m.subagents.Definitions()
+	if len(defs) == 0 {
+		return nil
+	}
+	target := agents.Target{
+		Provider:  m.activeProvider,
+		Model:     m.defaultModel,
+		Providers: append([]provider.Provider(nil), m.available...),
+		Catalog:   m.routeModels,
+	}
+	rec := m.audit
+	return m.subagents.Delegator(func(selector string) (provider.Provider, string, error) {
+		p, model, err := agents.Resolve(selector, target)
+		if err != nil {
+			return nil, "", err
+		}
+		if rec != nil {
+			p = rec.WrapProvider(p)
+		}
+		return p, model, nil
+	})
+}
+
+// tasksNeedCatalog reports whether calls start a subagent while a tag:
+// model selector still needs the route catalog to resolve.
+func (m *model) tasksNeedCatalog(calls []provider.ToolCall) bool {
+	if m.routeCatalogReady() || !slices.ContainsFunc(calls, func(call provider.ToolCall) bool { return call.Name == tools.TaskName }) {
+		return false
+	}
+	return slices.ContainsFunc(m.subagents.Definitions(), func(def agents.Definition) bool {
+		return strings.HasPrefix(def.Model, "tag:")
+	})
+}
+
+// recordTask keeps the checkpoints a subagent's edits took so /undo covers
+// them like the caller's own.
+func (m *model) recordTask(report *tools.TaskReport) {
+	if report == nil {
+		return
+	}
+	for _, id := range report.Checkpoints {
+		m.recordCheckpoint(id)
+	}
+}
+
+func (m *model) handleSubagentCommand(args []string) {
+	if len(args) == 0 {
+		m.appendHistoryBlock("subagents", m.subagentLines())
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "reload":
+		m.subagents.Reload(m.opts.ProjectPath)
+		for _, err := range m.subagents.Errors() {
+			m.messages = append(m.messages, fmt.Sprintf("pfui: subagent: %v", err))
+		}
+		m.messages = append(m.messages, fmt.Sprintf("pfui: loaded %d subagents", len(m.subagents.Definitions())))
+		return
+	case "run":
+		if len(args) == 2 {
+			if id, err := strconv.Atoi(args[1]); err == nil {
+				m.showSubagentRun(id)
+				return
+			}
+		}
+	}
+	m.messages = append(m.messages, "pfui: /subagent [reload | run N]")
+}
+
+func (m *model) subagentLines() []string {
+	defs := m.subagents.Definitions()
+	var lines []string
+	if len(defs) == 0 {
+		userDir, _ := agents.UserDir()
+		lines = append(lines, fmt.Sprintf("No subagents defined. Add Markdown files to %s or %s.", safeDisplay(userDir, "~/.pfui/agents"), agents.ProjectDir(m.opts.ProjectPath)))
+	}
+	for _, def := range defs {
+		model := def.Model
+		if model == "" {
+			model = "inherit"
+		}
+		toolList := "all tools"
+		if len(def.Tools) > 0 {
+			toolList = strings.Join(def.Tools, ", ")
+		}
+		lines = append(lines, fmt.Sprintf("%s [%s] %s", def.Name, def.Scope, def.Description))
+		lines = append(lines, fmt.Sprintf("    model %s · %s · %d rounds · %s", model, toolList, def.MaxTurns, def.Path))
+	}
+	for _, err := range m.subagents.Errors() {
+		lines = append(lines, "error: "+err.Error())
+	}
+	runs := m.subagents.Runs()
+	if len(runs) > 0 {
+		lines = append(lines, "", "Runs:")
+	}
+	for _, run := range runs {
+		lines = append(lines, fmt.Sprintf("%3d  %s  %-7s  %s  %d rounds, %d tool calls  %s", run.ID, run.StartedAt.Local().Format("15:04:05"), run.Status, run.Agent, run.Rounds, run.ToolCalls, truncate(oneLine(run.Task), maxRunTaskChars)))
+	}
+	lines = append(lines, "/subagent run N shows a run's task and report · /subagent reload rereads the definitions")
+	return lines
+}
+
+func (m *model) showSubagentRun(id int) {
+	run, ok := m.subagents.Run(id)
+	if !ok {
+		m.messages = append(m.messages, fmt.Sprintf("pfui: no subagent run %d", id))
+		return
+	}
+	lines := []string{
+		fmt.Sprintf("%s on %s, %s: %d rounds, %d tool calls", run.Agent, defaultModelDisplay(run.Model), run.Status, run.Rounds, run.ToolCalls),
+		"Task:",
+	}
+	lines = append(lines, indentLines(run.Task)...)
+	switch {
+	case run.Error != "":
+		lines = append(lines, "Error: "+run.Error)
+	case run.Report != "":
+		lines = append(lines, "Report:")
+		lines = append(lines, indentLines(run.Report)...)
+	}
+	m.appendHistoryBlock(fmt.Sprintf("subagent run %d", run.ID), lines)
+}
+
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func indentLines(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		out = append(out, "  "+sanitizeDiffLine(line))
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+// Package workspace confines the file tools to the project root (plus any
+// directories the operator allowed) and remembers which files the model has
+// read, so an edit can refuse to overwrite a file that changed since.
+package workspace
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotRead means the model tried to change a file it never read.
+	ErrNotRead = errors.New("file has not been read in this session")
+	// ErrStale means the file changed after the model last read it.
+	ErrStale = errors.New("file changed since it was last read")
+)
+
+// OutsideError reports a path that escapes the allowed directories.
+type OutsideError struct {
+	Path string
+}
+
+func (e *OutsideError) Error() string {
+	return fmt.Sprintf("%s is outside the project and allowed directories", e.Path)
+}
+
+// Stamp identifies the contents of a file when it was read.
+type Stamp struct {
+	ModTime time.Time
+	Size    int64
+	SHA256  [sha256.Size]byte
+}
+
+// Workspace resolves tool paths and tracks reads. It is safe for concurrent use.
+type Workspace struct {
+	root    string
+	allowed []string
+
+	mu    sync.Mutex
+	reads map[string]Stamp
+}
+
+// New returns a workspace rooted at root. allowed lists extra directories
+// the tools may touch; "~" expands to the home directory.
+func New(root string, allowed []string) *Workspace {
+	w := &Workspace{root: canonical(root), reads: make(map[string]Stamp)}
+	for _, dir := range allowed {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			w.allowed = append(w.allowed, canonical(expandHome(dir)))
+		}
+	}
+	return w
+}
+
+// Root returns the project root.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Resolve turns a tool path (absolute, or relative to the root) into a clean
+// absolute path with symlinks resolved, failing with *OutsideError when it
+// lands outside the root and allowed directories. The file need not exist.
+func (w *Workspace) Resolve(path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", errors.New("path is required")
+	}
+	path = expandHome(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.root, path)
+	}
+	resolved := canonical(path)
+	for _, dir := range append([]string{w.root}, w.allowed...) {
+		if within(dir, resolved) {
+			return resolved, nil
+		}
+	}
+	return "", &OutsideError{Path: path}
+}
+
+// Rel returns path relative to the root when it lies inside it, for display.
+func (w *Workspace) Rel(path string) string {
+	if !within(w.root, path) {
+		return path
+	}
+	rel, _ := filepath.Rel(w.root, path)
+	return rel
+}
+
+// RecordRead stamps the current contents of path as seen by the model.
+func (w *Workspace) RecordRead(path string) error {
+	stamp, err := stampFile(path)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.reads[path] = stamp
+	w.mu.Unlock()
+	return nil
+}
+
+// CheckFresh returns ErrNotRead if path was never read, or ErrStale if its
+// contents changed since the last read. Files whose modification time moved
+// but whose contents did not are still fresh.
+func (w *Workspace) CheckFresh(path string) error {
+	w.mu.Lock()
+	seen, ok := w.reads[path]
+	w.mu.Unlock()
+	if !ok {
+		return ErrNotRead
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStale, err)
+	}
+	if info.Size() == seen.Size && info.ModTime().Equal(seen.ModTime) {
+		return nil
+	}
+	now, err := stampFile(path)
+	if err != nil {
+		return err
+	}
+	if now.SHA256 != seen.SHA256 {
+		return ErrStale
+	}
+	return nil
+}
+
+// Forget drops the read record for path, e.g. after the file was deleted.
+func (w *Workspace) Forget(path string) {
+	w.mu.Lock()
+	delete(w.reads, path)
+	w.mu.Unlock()
+}
+
+func stampFile(path string) (Stamp, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Stamp{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Stamp{}, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return Stamp{}, fmt.Errorf("hashing %s: %w", path, err)
+	}
+	stamp := Stamp{ModTime: info.ModTime(), Size: info.Size()}
+	copy(stamp.SHA256[:], h.Sum(nil))
+	return stamp, nil
+}
+
+// canonical cleans path and resolves symlinks in its longest existing
+// prefix, so a link inside the project cannot point the tools elsewhere.
+func canonical(path string) string {
+	path = filepath.Clean(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	rest := ""
+	for dir := path; ; {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
@@ -0,0 +1,62 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveConfinesPaths(t *testing.T) {
+	root := t.TempDir()
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	w := New(root, []string{allowed})
+
+	if got, err := w.Resolve("sub/new.go"); err != nil || got != filepath.Join(w.Root(), "sub", "new.go") {
+		t.Fatalf("Resolve(relative) = %q, %v", got, err)
+	}
+	if _, err := w.Resolve(filepath.Join(allowed, "notes.md")); err != nil {
+		t.Fatalf("expected allowed dir to resolve: %v", err)
+	}
+	for _, path := range []string{"../x", filepath.Join(outside, "secret"), "escape/secret"} {
+		var outsideErr *OutsideError
+		if _, err := w.Resolve(path); !errors.As(err, &outsideErr) {
+			t.Fatalf("Resolve(%q) = %v, want OutsideError", path, err)
+		}
+	}
+}
+
+func TestCheckFreshDetectsChanges(t *testing.T) {
+	w := New(t.TempDir(), nil)
+	path := filepath.Join(w.Root(), "a.txt")
+	if err := os.WriteFile(path, []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.CheckFresh(path); !errors.Is(err, ErrNotRead) {
+		t.Fatalf("expected ErrNotRead, got %v", err)
+	}
+	if err := w.RecordRead(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.CheckFresh(path); err != nil {
+		t.Fatalf("expected fresh, got %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.CheckFresh(path); err != nil {
+		t.Fatalf("touching without changes should stay fresh, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte("two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.CheckFresh(path); !errors.Is(err, ErrStale) {
+		t.Fatalf("expected ErrStale, got %v", err)
+	}
+}